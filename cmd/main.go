@@ -1,65 +1,491 @@
 package main
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/39sanskar/serverless-go/config"
+	"github.com/39sanskar/serverless-go/pkg/audit"
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/cache"
+	"github.com/39sanskar/serverless-go/pkg/changes"
+	"github.com/39sanskar/serverless-go/pkg/encryption"
 	"github.com/39sanskar/serverless-go/pkg/handlers"
+	"github.com/39sanskar/serverless-go/pkg/logging"
+	"github.com/39sanskar/serverless-go/pkg/metrics"
 	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/tracing"
+	"github.com/39sanskar/serverless-go/pkg/validators"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-xray-sdk-go/xray"
 )
 
 // Declare dynaClient globally for direct use, or pass it via a handler struct if preferred for strict DI.
 // For AWS Lambda, initializing it once outside the handler function is a common and efficient pattern.
-var dynamoClient *dynamodb.DynamoDB
+var dynamoClient dynamodbiface.DynamoDBAPI
+var fieldEncryptor *encryption.FieldEncryptor // nil unless FIELD_ENCRYPTION_KEY_ARN is set
 var userHandler handlers.UserHandler
+var healthHandler handlers.HealthHandler
+var sqsHandler handlers.SQSHandler
+var cleanupHandler handlers.CleanupHandler
+var streamHandler *handlers.StreamHandler // nil unless EVENT_BUS_NAME is set
+var auditLog repository.AuditLog          // nil unless AUDIT_TABLE_NAME is set
+var cors handlers.CORS
+var authenticator *handlers.Authenticator           // nil when AUTH_ENABLED is off
+var rateLimiter *handlers.RateLimiter               // nil unless RATE_LIMIT_TABLE_NAME is set
+var tenantResolver *handlers.TenantResolver         // nil unless TENANTS is set
+var tenantHandlers map[string]*handlers.UserHandler // user handlers by tenant, when TENANTS is set
+var router *handlers.VersionedRouter
+var responseEnvelope bool
+var requireHTTPS bool
+var fieldNaming handlers.FieldNaming
+var logger = logging.New(os.Stdout)
 
 func init() {
+	slog.SetDefault(logger)
+
 	// Initialize configurations from environment variables
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
-
-	// Initialize AWS session
-	awsSession, err := session.NewSession(&aws.Config{
-		Region: aws.String(cfg.AWSRegion),
-	})
-	if err != nil {
-		log.Fatalf("Failed to create AWS session: %v", err) // Use log.Fatalf instead of panic
+	if cfg.HasSecretReferences() {
+		// Fetch ssm: and secretsmanager: values once per container rather than per request
+		awsSession, err := session.NewSession(cfg.AWSConfig())
+		if err != nil {
+			fatal("Failed to create AWS session", err)
+		}
+		resolver := config.NewSecretResolver(ssm.New(awsSession), secretsmanager.New(awsSession))
+		if err := cfg.ResolveSecrets(context.Background(), resolver); err != nil {
+			fatal("Failed to resolve configuration secrets", err)
+		}
 	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	repoOpts := repository.DynamoDBOptions{
+		SoftDelete:    cfg.SoftDelete,
+		LastNameIndex: cfg.LastNameIndex,
+		MaxAttempts:   cfg.MaxAttempts,
+
+		RetryBaseDelay: time.Duration(cfg.RetryBaseDelayMs) * time.Millisecond,
+		RetryMaxDelay:  time.Duration(cfg.RetryMaxDelayMs) * time.Millisecond,
+		BillingMode:    cfg.BillingMode,
 
-	// Initialize DynamoDB client
-	dynamoClient = dynamodb.New(awsSession)
+		NormalizedEmailIndex: cfg.NormalizedEmailIndex,
+		AllowDestructiveOps:  cfg.AllowDestructiveOps,
+		SkipExistenceCheck:   cfg.SkipExistenceCheck,
+
+		KeySchema:      repository.KeySchema(cfg.KeySchema),
+		EmailIndex:     cfg.EmailIndex,
+		EmailTableName: cfg.EmailTableName,
+	}
 
 	// Initialize the user repository and handler
-	userRepo := repository.NewDynamoDBUserRepository(dynamoClient, cfg.TableName)
-	userHandler = handlers.NewUserHandler(userRepo)
+	var idempotencyStore repository.IdempotencyStore
+	var limiter repository.RateLimiter
+	rateLimitOpts := repository.RateLimitOptions{
+		RequestsPerSecond: cfg.RateLimitRPS,
+		Burst:             cfg.RateLimitBurst,
+	}
+	if cfg.UseInMemory {
+		// Local development: no AWS session or credentials required
+		idempotencyStore = repository.NewInMemoryIdempotencyStore()
+		if cfg.RateLimitTableName != "" {
+			limiter = repository.NewInMemoryRateLimiter(rateLimitOpts)
+		}
+		if cfg.AuditTableName != "" {
+			auditLog = repository.NewInMemoryAuditLog()
+		}
+	} else {
+		// Initialize AWS session. It is shared by every client, so it keeps the regional endpoints.
+		awsSession, err := session.NewSession(cfg.AWSConfig())
+		if err != nil {
+			fatal("Failed to create AWS session", err)
+		}
+
+		// Initialize DynamoDB client. Its calls are retried by the repositories (DYNAMODB_MAX_ATTEMPTS),
+		// so the SDK's own retries are turned off rather than multiplying with them.
+		dynamoConfig := aws.NewConfig().WithMaxRetries(0)
+		if cfg.Endpoint != "" {
+			// Point DynamoDB alone at DynamoDB Local or LocalStack instead of AWS
+			dynamoConfig = dynamoConfig.WithEndpoint(cfg.Endpoint)
+		}
+		client := dynamodb.New(awsSession, dynamoConfig)
+		if cfg.TracingEnabled {
+			// Record every DynamoDB call as an X-Ray subsegment
+			xray.AWS(client.Client)
+		}
+		dynamoClient = client
+		if cfg.LogConsumedCapacity {
+			// Debugging aid: log the capacity units of every DynamoDB call
+			dynamoClient = repository.NewCapacityLoggingClient(client)
+		}
+
+		if cfg.FieldEncryptionKey != "" {
+			// From the endpoint-free session: KMS is never served by DynamoDB Local or LocalStack's DynamoDB
+			kmsClient := kms.New(awsSession)
+			if cfg.TracingEnabled {
+				xray.AWS(kmsClient.Client)
+			}
+			fieldEncryptor = encryption.NewFieldEncryptor(kmsClient, cfg.FieldEncryptionKey)
+		}
+		if cfg.EventBusName != "" {
+			eventBridgeClient := eventbridge.New(awsSession)
+			if cfg.TracingEnabled {
+				xray.AWS(eventBridgeClient.Client)
+			}
+			publisher := changes.NewEventBridgePublisher(eventBridgeClient, cfg.EventBusName)
+			h := handlers.NewStreamHandler(publisher)
+			streamHandler = &h
+		}
+		if cfg.IdempotencyTableName != "" {
+			idempotencyStore = repository.NewDynamoDBIdempotencyStore(dynamoClient, cfg.IdempotencyTableName, repoOpts)
+		}
+		if cfg.RateLimitTableName != "" {
+			limiter = repository.NewDynamoDBRateLimiter(dynamoClient, cfg.RateLimitTableName, rateLimitOpts, repoOpts)
+		}
+		if cfg.AuditTableName != "" {
+			auditLog = repository.NewDynamoDBAuditLog(dynamoClient, cfg.AuditTableName, repoOpts)
+		}
+	}
+	userRepo := newUserRepository(cfg, repoOpts, cfg.TableName)
+	handlerOpts := handlers.UserHandlerOptions{
+		DefaultPageSize:    cfg.DefaultPageSize,
+		MaxPageSize:        cfg.MaxPageSize,
+		MaxBodyBytes:       cfg.MaxBodyBytes,
+		LenientQueryParams: cfg.LenientQueryParams,
+		NameSanitization:   validators.NameSanitization(cfg.NameSanitization),
+		Validation: validators.ValidationOptions{
+			EmailValidation:        validators.EmailValidation(cfg.EmailValidation),
+			DisposableEmailDomains: cfg.DisposableDomains,
+		},
+
+		EmailSuggestions:    cfg.EmailSuggestions,
+		SuggestionScanLimit: cfg.SuggestionScanLimit,
+
+		CacheMaxAge:               time.Duration(cfg.CacheMaxAgeSeconds) * time.Second,
+		CacheStaleWhileRevalidate: time.Duration(cfg.CacheStaleWhileRevalidateSeconds) * time.Second,
+
+		IdempotencyStore: idempotencyStore,
+		IdempotencyTTL:   time.Duration(cfg.IdempotencyTTLSeconds) * time.Second,
+	}
+	userHandler = handlers.NewUserHandler(userRepo, handlerOpts)
+	if len(cfg.Tenants) > 0 {
+		// Each tenant gets its own table, named by prefixing the base table name with the tenant ID
+		tenantHandlers = make(map[string]*handlers.UserHandler, len(cfg.Tenants))
+		for _, tenant := range cfg.Tenants {
+			tenantOpts := repoOpts
+			if tenantOpts.EmailTableName != "" {
+				tenantOpts.EmailTableName = tenant + "-" + cfg.EmailTableName
+			}
+			h := handlers.NewUserHandler(newUserRepository(cfg, tenantOpts, tenant+"-"+cfg.TableName), handlerOpts)
+			tenantHandlers[tenant] = &h
+		}
+		t := handlers.NewTenantResolver(cfg.Tenants, cfg.TenantHeader)
+		tenantResolver = &t
+	}
+	healthHandler = handlers.NewHealthHandler(userRepo)
+	sqsHandler = handlers.NewSQSHandler(userRepo, validators.NameSanitization(cfg.NameSanitization), handlerOpts.Validation)
+	cleanupHandler = handlers.NewCleanupHandler(userRepo, time.Duration(cfg.SoftDeleteRetention)*24*time.Hour)
+	cors = handlers.NewCORS(cfg.AllowedOrigins, cfg.AllowedMethods, cfg.AllowedHeaders)
+
+	if cfg.AuthEnabled {
+		verifier, err := auth.NewVerifier(cfg.JWTSecret, cfg.JWTPublicKey)
+		if err != nil {
+			fatal("Failed to configure JWT verification", err)
+		}
+		a := handlers.NewAuthenticator(verifier)
+		authenticator = &a
+	}
+	if limiter != nil {
+		r := handlers.NewRateLimiter(limiter)
+		rateLimiter = &r
+	}
+	router = handlers.NewVersionedRouter("v1")
+	router.Version("v1", newRouter())
+	responseEnvelope = cfg.ResponseEnvelope
+	requireHTTPS = cfg.RequireHTTPS
+	fieldNaming = handlers.FieldNaming(cfg.FieldNaming)
+	responseReserve = time.Duration(cfg.DeadlineMarginMs) * time.Millisecond
+}
+
+// userRepository is a user repository that also supports health checks.
+type userRepository interface {
+	repository.UserRepository
+	handlers.HealthChecker
+}
+
+// newUserRepository creates the repository for the given table, wrapped with the encryption,
+// caching, tracing and metrics decorators that are enabled. In-memory mode ignores tableName.
+func newUserRepository(cfg *config.Config, opts repository.DynamoDBOptions, tableName string) userRepository {
+	var userRepo userRepository
+	if cfg.UseInMemory {
+		userRepo = repository.NewInMemoryUserRepository(opts)
+	} else {
+		dynamoRepo := repository.NewDynamoDBUserRepository(dynamoClient, tableName, opts)
+		if !cfg.SkipSchemaCheck {
+			// Fail the cold start rather than every request when the table has the wrong key
+			if err := dynamoRepo.ValidateSchema(context.Background()); err != nil {
+				fatal("DynamoDB table schema check failed", err)
+			}
+		}
+		if opts.BillingMode == "" {
+			// Retries are tuned to the table's capacity mode; the default strategy is kept if it cannot be read
+			billingMode, err := dynamoRepo.DetectBillingMode(context.Background())
+			if err != nil {
+				slog.Warn("Could not detect the table's billing mode", slog.String("operation", "init"), slog.Any("error", err))
+			} else {
+				slog.Info("Detected the table's billing mode", slog.String("operation", "init"), slog.String("table", tableName), slog.String("billingMode", billingMode))
+			}
+		}
+		userRepo = dynamoRepo
+	}
+	if auditLog != nil {
+		// Record users as they are stored, so encrypted names stay encrypted in the audit log
+		userRepo = audit.NewAuditedUserRepository(userRepo, auditLog, tableName)
+	}
+	if fieldEncryptor != nil {
+		// Encrypt before tracing and metrics, so their timings include the KMS calls
+		userRepo = encryption.NewEncryptedUserRepository(userRepo, fieldEncryptor)
+	}
+	if cfg.UserCacheTTLSeconds > 0 {
+		// Cache decrypted users, under tracing and metrics so they time what the handlers see
+		userRepo = cache.NewCachedUserRepository(userRepo, time.Duration(cfg.UserCacheTTLSeconds)*time.Second, cfg.UserCacheSize)
+	}
+	if cfg.TracingEnabled {
+		// Group the AWS calls of each repository operation under a subsegment named after it
+		userRepo = tracing.NewTracedUserRepository(userRepo)
+	}
+	if cfg.MetricsEnabled {
+		// Publish per-operation latency and error counts as EMF lines on stdout
+		userRepo = metrics.NewInstrumentedUserRepository(userRepo, metrics.NewEmitter(os.Stdout, cfg.MetricsNamespace))
+	}
+	return userRepo
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(dispatch)
 }
 
-func handler(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	// Add logging for incoming requests
-	log.Printf("Received request: %s %s", req.HTTPMethod, req.Path)
-
-	switch req.HTTPMethod {
-	case "GET":
-		return userHandler.GetUser(req)
-	case "POST":
-		return userHandler.CreateUser(req)
-	case "PUT":
-		return userHandler.UpdateUser(req)
-	case "DELETE":
-		return userHandler.DeleteUser(req)
-	default:
-		return handlers.UnhandledMethod()
+// eventEnvelope holds just enough of an incoming event to tell the event sources apart.
+type eventEnvelope struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+}
+
+// dispatch routes the raw invocation payload to the handler for its event source,
+// so one function can serve API Gateway requests, SQS queues and scheduled events alike.
+func dispatch(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	var envelope eventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, err
 	}
+
+	if len(envelope.Records) > 0 && envelope.Records[0].EventSource == "aws:sqs" {
+		var event events.SQSEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return handleSQS(ctx, event)
+	}
+
+	if len(envelope.Records) > 0 && envelope.Records[0].EventSource == "aws:dynamodb" {
+		var event events.DynamoDBEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return handleStream(ctx, event)
+	}
+
+	if envelope.Source == "aws.events" && envelope.DetailType == "Scheduled Event" {
+		var event events.CloudWatchEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return nil, handleCleanup(ctx, event)
+	}
+
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
 }
 
+func handleCleanup(ctx context.Context, event events.CloudWatchEvent) error {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		slog.SetDefault(logging.WithRequestID(logger, lc.AwsRequestID))
+	}
+	slog.Info("Received scheduled cleanup", slog.String("operation", "handleCleanup"), slog.String("rule", strings.Join(event.Resources, ",")))
+
+	ctx, cancel := handlers.WithDeadlineMargin(ctx, responseReserve)
+	defer cancel()
+
+	return cleanupHandler.Handle(ctx, event)
+}
+
+func handleStream(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		slog.SetDefault(logging.WithRequestID(logger, lc.AwsRequestID))
+	}
+	slog.Info("Received DynamoDB stream batch", slog.String("operation", "handleStream"), slog.Int("records", len(event.Records)))
+	if streamHandler == nil {
+		return events.DynamoDBEventResponse{}, errors.New("received DynamoDB stream records but EVENT_BUS_NAME is not set")
+	}
+
+	ctx, cancel := handlers.WithDeadlineMargin(ctx, responseReserve)
+	defer cancel()
+
+	return streamHandler.Handle(ctx, event)
+}
+
+func handleSQS(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		slog.SetDefault(logging.WithRequestID(logger, lc.AwsRequestID))
+	}
+	slog.Info("Received SQS batch", slog.String("operation", "handleSQS"), slog.Int("records", len(event.Records)))
+
+	ctx, cancel := handlers.WithDeadlineMargin(ctx, responseReserve)
+	defer cancel()
+
+	return sqsHandler.Handle(ctx, event)
+}
+
+func handler(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Tag every log line of this invocation with the API Gateway request ID and the client's correlation ID
+	correlationID := handlers.CorrelationID(req)
+	slog.SetDefault(logging.WithCorrelationID(logging.WithRequestID(logger, req.RequestContext.RequestID), correlationID))
+	slog.Info("Received request", slog.String("operation", "handler"), slog.String("method", req.HTTPMethod), slog.String("path", req.Path))
+
+	ctx, cancel := handlers.WithDeadlineMargin(ctx, responseReserve)
+	defer cancel()
+
+	// Accept snake_case request keys regardless of the response style
+	handlers.NormalizeRequestKeys(&req)
+	resp, err := handlers.WithinBudget(handlers.Recover(route))(ctx, req)
+	if responseEnvelope {
+		handlers.WrapEnvelope(req, resp)
+	}
+	handlers.ApplyFieldNaming(resp, handlers.ResponseFieldNaming(req, fieldNaming))
+	handlers.Compress(req, resp)
+	handlers.SetCorrelationID(resp, correlationID)
+	cors.Apply(req, resp)
+	return resp, err
+}
+
+func route(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Reject insecure requests before any credentials or data are processed
+	if requireHTTPS {
+		if denied := handlers.RequireHTTPS(req); denied != nil {
+			return denied, nil
+		}
+	}
+
+	// The health check is routed before the user endpoints so probes never touch user data
+	if req.HTTPMethod == "GET" && strings.HasSuffix(req.Path, "/health") {
+		return healthHandler.Check(ctx, req)
+	}
+
+	// Preflight requests carry no credentials, so only the user endpoints require a token
+	if authenticator != nil && req.HTTPMethod != "OPTIONS" {
+		var denied *events.APIGatewayProxyResponse
+		if ctx, denied = authenticator.Authenticate(ctx, req); denied != nil {
+			return denied, nil
+		}
+	}
+
+	// The tenant claim comes from the token, so tenants are resolved after authentication
+	if tenantResolver != nil && req.HTTPMethod != "OPTIONS" {
+		var denied *events.APIGatewayProxyResponse
+		if ctx, denied = tenantResolver.Resolve(ctx, req); denied != nil {
+			return denied, nil
+		}
+	}
+
+	// Limits are keyed on the token subject, so they apply after authentication
+	if rateLimiter != nil && req.HTTPMethod != "OPTIONS" {
+		if throttled := rateLimiter.Check(ctx, req); throttled != nil {
+			return throttled, nil
+		}
+	}
+
+	if unsupported := handlers.RequireJSON(req); unsupported != nil {
+		return unsupported, nil
+	}
+
+	return router.Route(ctx, req)
+}
+
+// newRouter registers the user endpoints of API version v1. Every user path also answers OPTIONS preflights.
+func newRouter() *handlers.Router {
+	r := handlers.NewRouter()
+	r.Handle("GET", "/users", users((*handlers.UserHandler).GetUser))
+	r.Handle("GET", "/users/{email}", users((*handlers.UserHandler).GetUser))
+	r.Handle("GET", "/users/export", users((*handlers.UserHandler).ExportUsers))
+	r.Handle("GET", "/users/duplicates", users((*handlers.UserHandler).DuplicateUsers))
+	r.Handle("HEAD", "/users", users((*handlers.UserHandler).HeadUser))
+	r.Handle("HEAD", "/users/{email}", users((*handlers.UserHandler).HeadUser))
+	r.Handle("POST", "/users", users((*handlers.UserHandler).CreateUser))
+	r.Handle("POST", "/users/batch", users((*handlers.UserHandler).CreateUsers))
+	r.Handle("POST", "/users/lookup", users((*handlers.UserHandler).GetUsersByEmails))
+	r.Handle("POST", "/users/merges", users((*handlers.UserHandler).MergeUsers))
+	r.Handle("POST", "/users/migrations", users((*handlers.UserHandler).MigrateUsers))
+	r.Handle("POST", "/users/transactions", users((*handlers.UserHandler).TransactUsers))
+	r.Handle("PUT", "/users", users((*handlers.UserHandler).UpdateUser))
+	r.Handle("PUT", "/users/{email}", users((*handlers.UserHandler).UpdateUser))
+	r.Handle("PUT", "/users/{email}/email", users((*handlers.UserHandler).ChangeEmail))
+	r.Handle("PATCH", "/users", users((*handlers.UserHandler).BulkUpdateUsers))
+	r.Handle("DELETE", "/users", users((*handlers.UserHandler).DeleteUser))
+	r.Handle("DELETE", "/users/{email}", users((*handlers.UserHandler).DeleteUser))
+	r.Handle("DELETE", "/users/batch", users((*handlers.UserHandler).DeleteUsers))
+	r.Handle("DELETE", "/users/all", users((*handlers.UserHandler).PurgeUsers))
+
+	preflight := func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return cors.Preflight(req)
+	}
+	for _, pattern := range []string{"/users", "/users/{email}", "/users/batch", "/users/transactions", "/users/all", "/users/export", "/users/duplicates", "/users/merges", "/users/lookup", "/users/migrations", "/users/{email}/email"} {
+		r.Handle("OPTIONS", pattern, preflight)
+	}
+	return r
+}
+
+// users adapts a UserHandler method to a route, calling it on the handler of the request's tenant.
+func users(method func(*handlers.UserHandler, context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)) handlers.HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		h := &userHandler
+		if tenant := handlers.TenantFromContext(ctx); tenant != "" {
+			h = tenantHandlers[tenant]
+		}
+		return method(h, ctx, req)
+	}
+}
+
+// responseReserve is the slice of the Lambda deadline kept back for writing the response
+// (DEADLINE_MARGIN_MS), so a slow DynamoDB call is cancelled before the runtime kills the invocation.
+var responseReserve = 200 * time.Millisecond
+
+// fatal logs a startup failure and exits, since the Lambda cannot serve requests without its dependencies.
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String("operation", "init"), slog.Any("error", err))
+	os.Exit(1)
+}
@@ -0,0 +1,76 @@
+// Command migrate-keys copies the users of a table keyed on email to a table keyed on id, with its
+// email index and email table, for switching DYNAMODB_KEY_SCHEMA to id. It is meant to be run several
+// times: while the service still writes to the source table, and a final time with -prune once
+// writes are stopped. See the README for the whole procedure.
+//
+// It exits with status 1 when the copy failed or left conflicting emails behind.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func main() {
+	sourceTable := flag.String("source-table", "", "table keyed on email to copy the users from")
+	targetTable := flag.String("target-table", "", "table keyed on id to copy the users to")
+	emailTable := flag.String("email-table", "", "email table of the target table")
+	emailIndex := flag.String("email-index", "email-index", "email index of the target table")
+	prune := flag.Bool("prune", false, "delete target users and reservations the source no longer has")
+	region := flag.String("region", os.Getenv("AWS_REGION"), "AWS region of the tables")
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint, e.g. of DynamoDB Local")
+	flag.Parse()
+	if *sourceTable == "" || *targetTable == "" || *emailTable == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	awsConfig := aws.NewConfig().WithRegion(*region)
+	if *endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(*endpoint)
+	}
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		fatal("Failed to create AWS session", err)
+	}
+	// The repositories retry DynamoDB calls themselves
+	client := dynamodb.New(awsSession, aws.NewConfig().WithMaxRetries(0))
+
+	ctx := context.Background()
+	source := repository.NewDynamoDBUserRepository(client, *sourceTable, repository.DynamoDBOptions{})
+	target := repository.NewDynamoDBUserRepository(client, *targetTable, repository.DynamoDBOptions{
+		KeySchema:      repository.KeySchemaID,
+		EmailIndex:     *emailIndex,
+		EmailTableName: *emailTable,
+	})
+	if err := source.ValidateSchema(ctx); err != nil {
+		fatal("Source table schema check failed", err)
+	}
+	if err := target.ValidateSchema(ctx); err != nil {
+		fatal("Target table schema check failed", err)
+	}
+
+	result, err := repository.CopyUsers(ctx, source, target, *prune)
+	json.NewEncoder(os.Stdout).Encode(result)
+	if err != nil {
+		fatal("Copying the users failed", err)
+	}
+	if len(result.Conflicts) > 0 {
+		slog.Error("Emails are held by more than one user", slog.String("operation", "CopyUsers"), slog.Any("emails", result.Conflicts))
+		os.Exit(1)
+	}
+}
+
+// fatal logs a failure and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String("operation", "CopyUsers"), slog.Any("error", err))
+	os.Exit(1)
+}
@@ -1,30 +1,370 @@
 package config
 
 import (
-	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
 )
 
 // Config holds all application configurations
 type Config struct {
-	AWSRegion string
-	TableName string
+	AWSRegion            string
+	TableName            string
+	SoftDelete           bool
+	SoftDeleteRetention  int
+	LastNameIndex        string
+	NormalizedEmailIndex string
+	KeySchema            string
+	EmailIndex           string
+	EmailTableName       string
+	MaxAttempts          int
+	RetryBaseDelayMs     int
+	RetryMaxDelayMs      int
+	BillingMode          string
+	SDKMaxRetries        int
+	SDKHTTPTimeoutMs     int
+	UseInMemory          bool
+	Endpoint             string
+	SkipSchemaCheck      bool
+	AllowDestructiveOps  bool
+	SkipExistenceCheck   bool
+	LogConsumedCapacity  bool
+	FieldEncryptionKey   string
+	UserCacheTTLSeconds  int
+	UserCacheSize        int
+
+	DefaultPageSize    int
+	MaxPageSize        int
+	MaxBodyBytes       int
+	ResponseEnvelope   bool
+	LenientQueryParams bool
+	NameSanitization   string
+	EmailValidation    string
+	DisposableDomains  []string
+	FieldNaming        string
+	RequireHTTPS       bool
+	DeadlineMarginMs   int
+
+	EmailSuggestions    bool
+	SuggestionScanLimit int
+
+	CacheMaxAgeSeconds               int
+	CacheStaleWhileRevalidateSeconds int
+
+	MetricsEnabled   bool
+	MetricsNamespace string
+	TracingEnabled   bool
+
+	IdempotencyTableName  string
+	IdempotencyTTLSeconds int
+
+	EventBusName string
+
+	AuditTableName string
+
+	RateLimitTableName string
+	RateLimitRPS       int
+	RateLimitBurst     int
+
+	AuthEnabled  bool
+	JWTSecret    string
+	JWTPublicKey string
+
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	Tenants      []string
+	TenantHeader string
 }
 
-// LoadConfig loads configuration from environment variables
+// LoadConfig loads configuration from environment variables.
+// It only reports values that cannot be parsed; call Validate to check the result.
 func LoadConfig() (*Config, error) {
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		return nil, errors.New("AWS_REGION environment variable not set")
+	useInMemory, err := getEnvBool("USE_IN_MEMORY", false)
+	if err != nil {
+		return nil, err
+	}
+
+	softDelete, err := getEnvBool("SOFT_DELETE", false)
+	if err != nil {
+		return nil, err
+	}
+	softDeleteRetention, err := getEnvInt("SOFT_DELETE_RETENTION_DAYS", 30)
+	if err != nil {
+		return nil, err
+	}
+
+	skipSchemaCheck, err := getEnvBool("SKIP_SCHEMA_CHECK", false)
+	if err != nil {
+		return nil, err
+	}
+	allowDestructiveOps, err := getEnvBool("ALLOW_DESTRUCTIVE_OPS", false)
+	if err != nil {
+		return nil, err
+	}
+	skipExistenceCheck, err := getEnvBool("SKIP_EXISTENCE_CHECK", false)
+	if err != nil {
+		return nil, err
+	}
+	logConsumedCapacity, err := getEnvBool("LOG_CONSUMED_CAPACITY", false)
+	if err != nil {
+		return nil, err
+	}
+	userCacheTTL, err := getEnvInt("USER_CACHE_TTL_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+	userCacheSize, err := getEnvInt("USER_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zero retry settings fall back to the defaults of the table's billing mode
+	maxAttempts, err := getEnvInt("DYNAMODB_MAX_ATTEMPTS", 0)
+	if err != nil {
+		return nil, err
+	}
+	retryBaseDelayMs, err := getEnvInt("DYNAMODB_RETRY_BASE_DELAY_MS", 0)
+	if err != nil {
+		return nil, err
+	}
+	retryMaxDelayMs, err := getEnvInt("DYNAMODB_RETRY_MAX_DELAY_MS", 0)
+	if err != nil {
+		return nil, err
 	}
 
-	tableName := os.Getenv("DYNAMODB_TABLE_NAME")
-	if tableName == "" {
-		return nil, errors.New("DYNAMODB_TABLE_NAME environment variable not set")
+	// The SDK's own retries and HTTP timeout, beneath the retries above
+	sdkMaxRetries, err := getEnvInt("SDK_MAX_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+	sdkHTTPTimeoutMs, err := getEnvInt("SDK_HTTP_TIMEOUT_MS", 3000)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultPageSize, err := getEnvInt("DEFAULT_PAGE_SIZE", 10)
+	if err != nil {
+		return nil, err
+	}
+	maxPageSize, err := getEnvInt("MAX_PAGE_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBodyBytes, err := getEnvInt("MAX_BODY_BYTES", 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	responseEnvelope, err := getEnvBool("RESPONSE_ENVELOPE", false)
+	if err != nil {
+		return nil, err
+	}
+	lenientQueryParams, err := getEnvBool("LENIENT_QUERY_PARAMS", false)
+	if err != nil {
+		return nil, err
+	}
+	requireHTTPS, err := getEnvBool("REQUIRE_HTTPS", false)
+	if err != nil {
+		return nil, err
+	}
+	deadlineMarginMs, err := getEnvInt("DEADLINE_MARGIN_MS", 200)
+	if err != nil {
+		return nil, err
+	}
+	keySchema := os.Getenv("DYNAMODB_KEY_SCHEMA")
+	if keySchema == "" {
+		keySchema = "email"
+	}
+	emailIndex := os.Getenv("DYNAMODB_EMAIL_INDEX")
+	if emailIndex == "" {
+		emailIndex = "email-index"
+	}
+	nameSanitization := os.Getenv("NAME_SANITIZATION")
+	if nameSanitization == "" {
+		nameSanitization = "reject"
+	}
+	emailValidation := os.Getenv("EMAIL_VALIDATION")
+	if emailValidation == "" {
+		emailValidation = "practical"
+	}
+	cacheMaxAge, err := getEnvInt("CACHE_MAX_AGE_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+	cacheStaleWhileRevalidate, err := getEnvInt("CACHE_STALE_WHILE_REVALIDATE_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+	emailSuggestions, err := getEnvBool("EMAIL_SUGGESTIONS", false)
+	if err != nil {
+		return nil, err
+	}
+	suggestionScanLimit, err := getEnvInt("EMAIL_SUGGESTION_SCAN_LIMIT", 1000)
+	if err != nil {
+		return nil, err
+	}
+	fieldNaming := os.Getenv("JSON_FIELD_NAMING")
+	if fieldNaming == "" {
+		fieldNaming = "camelCase"
+	}
+
+	metricsEnabled, err := getEnvBool("METRICS_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+
+	tracingEnabled, err := getEnvBool("TRACING_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyTTL, err := getEnvInt("IDEMPOTENCY_TTL_SECONDS", 86400)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitRPS, err := getEnvInt("RATE_LIMIT_RPS", 10)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitBurst, err := getEnvInt("RATE_LIMIT_BURST", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	authEnabled, err := getEnvBool("AUTH_ENABLED", false)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Config{
-		AWSRegion: region,
-		TableName: tableName,
+		AWSRegion:            os.Getenv("AWS_REGION"),
+		TableName:            os.Getenv("DYNAMODB_TABLE_NAME"),
+		SoftDelete:           softDelete,
+		SoftDeleteRetention:  softDeleteRetention,
+		LastNameIndex:        os.Getenv("DYNAMODB_LAST_NAME_INDEX"),
+		NormalizedEmailIndex: os.Getenv("DYNAMODB_NORMALIZED_EMAIL_INDEX"),
+		KeySchema:            keySchema,
+		EmailIndex:           emailIndex,
+		EmailTableName:       os.Getenv("DYNAMODB_EMAIL_TABLE_NAME"),
+		MaxAttempts:          maxAttempts,
+		RetryBaseDelayMs:     retryBaseDelayMs,
+		RetryMaxDelayMs:      retryMaxDelayMs,
+		BillingMode:          os.Getenv("DYNAMODB_BILLING_MODE"),
+		SDKMaxRetries:        sdkMaxRetries,
+		SDKHTTPTimeoutMs:     sdkHTTPTimeoutMs,
+		UseInMemory:          useInMemory,
+		Endpoint:             os.Getenv("DYNAMODB_ENDPOINT"),
+		SkipSchemaCheck:      skipSchemaCheck,
+		AllowDestructiveOps:  allowDestructiveOps,
+		SkipExistenceCheck:   skipExistenceCheck,
+		LogConsumedCapacity:  logConsumedCapacity,
+		FieldEncryptionKey:   os.Getenv("FIELD_ENCRYPTION_KEY_ARN"),
+		UserCacheTTLSeconds:  userCacheTTL,
+		UserCacheSize:        userCacheSize,
+
+		DefaultPageSize:    defaultPageSize,
+		MaxPageSize:        maxPageSize,
+		MaxBodyBytes:       maxBodyBytes,
+		ResponseEnvelope:   responseEnvelope,
+		LenientQueryParams: lenientQueryParams,
+		NameSanitization:   nameSanitization,
+		EmailValidation:    emailValidation,
+		DisposableDomains:  getEnvList("DISPOSABLE_EMAIL_DOMAINS", nil),
+		FieldNaming:        fieldNaming,
+		RequireHTTPS:       requireHTTPS,
+		DeadlineMarginMs:   deadlineMarginMs,
+
+		EmailSuggestions:    emailSuggestions,
+		SuggestionScanLimit: suggestionScanLimit,
+
+		CacheMaxAgeSeconds:               cacheMaxAge,
+		CacheStaleWhileRevalidateSeconds: cacheStaleWhileRevalidate,
+
+		MetricsEnabled:   metricsEnabled,
+		MetricsNamespace: os.Getenv("METRICS_NAMESPACE"),
+		TracingEnabled:   tracingEnabled,
+
+		IdempotencyTableName:  os.Getenv("IDEMPOTENCY_TABLE_NAME"),
+		IdempotencyTTLSeconds: idempotencyTTL,
+
+		EventBusName: os.Getenv("EVENT_BUS_NAME"),
+
+		AuditTableName: os.Getenv("AUDIT_TABLE_NAME"),
+
+		RateLimitTableName: os.Getenv("RATE_LIMIT_TABLE_NAME"),
+		RateLimitRPS:       rateLimitRPS,
+		RateLimitBurst:     rateLimitBurst,
+
+		AuthEnabled:  authEnabled,
+		JWTSecret:    os.Getenv("JWT_SECRET"),
+		JWTPublicKey: os.Getenv("JWT_PUBLIC_KEY"),
+
+		AllowedOrigins: getEnvList("ALLOWED_ORIGINS", nil),
+		AllowedMethods: getEnvList("ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		AllowedHeaders: getEnvList("ALLOWED_HEADERS", []string{"Content-Type", "Authorization"}),
+
+		Tenants:      getEnvList("TENANTS", nil),
+		TenantHeader: os.Getenv("TENANT_HEADER"),
 	}, nil
-}
\ No newline at end of file
+}
+
+// getEnvBool reads an optional boolean environment variable, returning fallback when unset.
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s environment variable must be a boolean: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// getEnvInt reads an optional integer environment variable, returning fallback when unset.
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s environment variable must be an integer: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// getEnvList reads an optional comma-separated environment variable, returning fallback when unset.
+// Surrounding whitespace and empty entries are dropped.
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AWSConfig returns the SDK configuration of every AWS client. A Lambda invocation is short, so
+// the SDK retries a failed call only SDK_MAX_RETRIES times and gives up on a call after
+// SDK_HTTP_TIMEOUT_MS, rather than letting a hung connection use up the invocation's time budget.
+func (c *Config) AWSConfig() *aws.Config {
+	return &aws.Config{
+		Region:     aws.String(c.AWSRegion),
+		MaxRetries: aws.Int(c.SDKMaxRetries),
+		HTTPClient: &http.Client{Timeout: time.Duration(c.SDKHTTPTimeoutMs) * time.Millisecond},
+	}
+}
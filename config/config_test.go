@@ -0,0 +1,205 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestLoadConfigEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "unset"},
+		{name: "DynamoDB Local", endpoint: "http://localhost:8000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DYNAMODB_ENDPOINT", tt.endpoint)
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Endpoint != tt.endpoint {
+				t.Errorf("Endpoint = %q, want %q", cfg.Endpoint, tt.endpoint)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTracingEnabled(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "false", want: false},
+		{value: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TRACING_ENABLED", tt.value)
+			cfg, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.TracingEnabled != tt.want {
+				t.Errorf("TracingEnabled = %v, want %v", cfg.TracingEnabled, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTenants(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "unset"},
+		{name: "one", value: "acme", want: []string{"acme"}},
+		{name: "several with spaces", value: " acme, globex ,,initech", want: []string{"acme", "globex", "initech"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TENANTS", tt.value)
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cfg.Tenants, tt.want) {
+				t.Errorf("Tenants = %q, want %q", cfg.Tenants, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDisposableDomains(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "unset"},
+		{name: "several with spaces", value: "mailinator.com, guerrillamail.com", want: []string{"mailinator.com", "guerrillamail.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DISPOSABLE_EMAIL_DOMAINS", tt.value)
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cfg.DisposableDomains, tt.want) {
+				t.Errorf("DisposableDomains = %q, want %q", cfg.DisposableDomains, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResponseEnvelope(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("RESPONSE_ENVELOPE", tt.value)
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ResponseEnvelope != tt.want {
+				t.Errorf("ResponseEnvelope = %v, want %v", cfg.ResponseEnvelope, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigLogConsumedCapacityIsOffByDefault(t *testing.T) {
+	t.Setenv("LOG_CONSUMED_CAPACITY", "")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogConsumedCapacity {
+		t.Error("LogConsumedCapacity = true, want false")
+	}
+}
+
+func TestLoadConfigCacheControl(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxAge    string
+		stale     string
+		wantAge   int
+		wantStale int
+		wantErr   bool
+	}{
+		{name: "unset"},
+		{name: "configured", maxAge: "30", stale: "120", wantAge: 30, wantStale: 120},
+		{name: "not a number", maxAge: "thirty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_MAX_AGE_SECONDS", tt.maxAge)
+			t.Setenv("CACHE_STALE_WHILE_REVALIDATE_SECONDS", tt.stale)
+			cfg, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err == nil && (cfg.CacheMaxAgeSeconds != tt.wantAge || cfg.CacheStaleWhileRevalidateSeconds != tt.wantStale) {
+				t.Errorf("cache settings = %d, %d, want %d, %d", cfg.CacheMaxAgeSeconds, cfg.CacheStaleWhileRevalidateSeconds, tt.wantAge, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSDK(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxRetries  string
+		timeoutMs   string
+		wantRetries int
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "unset", wantRetries: 3, wantTimeout: 3 * time.Second},
+		{name: "configured", maxRetries: "1", timeoutMs: "500", wantRetries: 1, wantTimeout: 500 * time.Millisecond},
+		{name: "no retries", maxRetries: "0", wantRetries: 0, wantTimeout: 3 * time.Second},
+		{name: "retries not a number", maxRetries: "few", wantErr: true},
+		{name: "timeout not a number", timeoutMs: "1s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", "eu-west-1")
+			t.Setenv("SDK_MAX_RETRIES", tt.maxRetries)
+			t.Setenv("SDK_HTTP_TIMEOUT_MS", tt.timeoutMs)
+			cfg, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			awsConfig := cfg.AWSConfig()
+			if got := aws.StringValue(awsConfig.Region); got != "eu-west-1" {
+				t.Errorf("region = %s, want eu-west-1", got)
+			}
+			if got := aws.IntValue(awsConfig.MaxRetries); got != tt.wantRetries {
+				t.Errorf("max retries = %d, want %d", got, tt.wantRetries)
+			}
+			if awsConfig.HTTPClient == nil || awsConfig.HTTPClient.Timeout != tt.wantTimeout {
+				t.Errorf("HTTP client = %v, want a timeout of %v", awsConfig.HTTPClient, tt.wantTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// Prefixes marking a configuration value as a reference to be fetched from AWS, e.g.
+// JWT_SECRET=ssm:/users-api/jwt-secret or JWT_SECRET=secretsmanager:users-api/jwt.
+const (
+	ssmPrefix            = "ssm:"
+	secretsManagerPrefix = "secretsmanager:"
+)
+
+// SecretResolver fetches referenced values from SSM Parameter Store and Secrets Manager.
+// Fetched values are cached for the lifetime of the resolver, which is the Lambda container's
+// when it is created during init.
+type SecretResolver struct {
+	ssm     ssmiface.SSMAPI
+	secrets secretsmanageriface.SecretsManagerAPI
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewSecretResolver creates a SecretResolver using the given clients.
+func NewSecretResolver(ssmClient ssmiface.SSMAPI, secretsClient secretsmanageriface.SecretsManagerAPI) *SecretResolver {
+	return &SecretResolver{
+		ssm:     ssmClient,
+		secrets: secretsClient,
+		cache:   make(map[string]string),
+	}
+}
+
+// IsSecretReference reports whether value names an SSM parameter or Secrets Manager secret.
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, ssmPrefix) || strings.HasPrefix(value, secretsManagerPrefix)
+}
+
+// Resolve returns the value referenced by value, or value itself when it is not a reference.
+// SSM parameters are decrypted, so SecureString parameters work as well.
+func (r *SecretResolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsSecretReference(value) {
+		return value, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if resolved, ok := r.cache[value]; ok {
+		return resolved, nil
+	}
+
+	var resolved string
+	if name, ok := strings.CutPrefix(value, ssmPrefix); ok {
+		output, err := r.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("fetching SSM parameter %s: %w", name, err)
+		}
+		if output.Parameter != nil {
+			resolved = aws.StringValue(output.Parameter.Value)
+		}
+	} else {
+		id := strings.TrimPrefix(value, secretsManagerPrefix)
+		output, err := r.secrets.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(id),
+		})
+		if err != nil {
+			return "", fmt.Errorf("fetching secret %s: %w", id, err)
+		}
+		resolved = aws.StringValue(output.SecretString)
+		if output.SecretString == nil {
+			resolved = string(output.SecretBinary)
+		}
+	}
+	r.cache[value] = resolved
+	return resolved, nil
+}
+
+// secretFields lists the settings that may hold a secret reference, keyed by environment variable.
+// AWS_REGION is excluded since it is needed to reach the services.
+func (c *Config) secretFields() map[string]*string {
+	return map[string]*string{
+		"DYNAMODB_TABLE_NAME":       &c.TableName,
+		"DYNAMODB_EMAIL_TABLE_NAME": &c.EmailTableName,
+		"DYNAMODB_ENDPOINT":         &c.Endpoint,
+		"IDEMPOTENCY_TABLE_NAME":    &c.IdempotencyTableName,
+		"RATE_LIMIT_TABLE_NAME":     &c.RateLimitTableName,
+		"AUDIT_TABLE_NAME":          &c.AuditTableName,
+		"EVENT_BUS_NAME":            &c.EventBusName,
+		"JWT_SECRET":                &c.JWTSecret,
+		"JWT_PUBLIC_KEY":            &c.JWTPublicKey,
+	}
+}
+
+// HasSecretReferences reports whether any setting must be resolved with ResolveSecrets.
+func (c *Config) HasSecretReferences() bool {
+	for _, field := range c.secretFields() {
+		if IsSecretReference(*field) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecrets replaces every secret reference in c with the value it names.
+// Plain values are left unchanged.
+func (c *Config) ResolveSecrets(ctx context.Context, resolver *SecretResolver) error {
+	for key, field := range c.secretFields() {
+		resolved, err := resolver.Resolve(ctx, *field)
+		if err != nil {
+			return fmt.Errorf("%s environment variable: %w", key, err)
+		}
+		*field = resolved
+	}
+	return nil
+}
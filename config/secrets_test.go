@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+var errAccessDenied = errors.New("access denied")
+
+// mockSSM serves parameters from a map, counting the calls. Missing parameters fail.
+type mockSSM struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+	calls      int
+}
+
+func (m *mockSSM) GetParameterWithContext(_ aws.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+	m.calls++
+	if !aws.BoolValue(input.WithDecryption) {
+		return nil, errors.New("parameter read without decryption")
+	}
+	value, ok := m.parameters[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, errAccessDenied
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(value)}}, nil
+}
+
+// mockSecretsManager serves secrets from maps of string and binary secrets, counting the calls.
+// Missing secrets fail.
+type mockSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	stringSecrets map[string]string
+	binarySecrets map[string][]byte
+	calls         int
+}
+
+func (m *mockSecretsManager) GetSecretValueWithContext(_ aws.Context, input *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	m.calls++
+	id := aws.StringValue(input.SecretId)
+	if value, ok := m.stringSecrets[id]; ok {
+		return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+	}
+	if value, ok := m.binarySecrets[id]; ok {
+		return &secretsmanager.GetSecretValueOutput{SecretBinary: value}, nil
+	}
+	return nil, errAccessDenied
+}
+
+func TestSecretResolverResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "plain value", value: "users", want: "users"},
+		{name: "empty value", value: "", want: ""},
+		{name: "prefix not at the start", value: "my-ssm:value", want: "my-ssm:value"},
+		{name: "SSM parameter", value: "ssm:/users-api/jwt-secret", want: "from-ssm"},
+		{name: "Secrets Manager string", value: "secretsmanager:users-api/jwt", want: "from-secrets-manager"},
+		{name: "Secrets Manager binary", value: "secretsmanager:users-api/key", want: "binary"},
+		{name: "missing SSM parameter", value: "ssm:/users-api/missing", wantErr: errAccessDenied},
+		{name: "missing secret", value: "secretsmanager:missing", wantErr: errAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewSecretResolver(
+				&mockSSM{parameters: map[string]string{"/users-api/jwt-secret": "from-ssm"}},
+				&mockSecretsManager{
+					stringSecrets: map[string]string{"users-api/jwt": "from-secrets-manager"},
+					binarySecrets: map[string][]byte{"users-api/key": []byte("binary")},
+				},
+			)
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretResolverCaches(t *testing.T) {
+	ssmClient := &mockSSM{parameters: map[string]string{"/jwt": "from-ssm"}}
+	secretsClient := &mockSecretsManager{stringSecrets: map[string]string{"jwt": "from-secrets-manager"}}
+	resolver := NewSecretResolver(ssmClient, secretsClient)
+
+	for range 3 {
+		for _, value := range []string{"ssm:/jwt", "secretsmanager:jwt"} {
+			if _, err := resolver.Resolve(context.Background(), value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if ssmClient.calls != 1 || secretsClient.calls != 1 {
+		t.Errorf("%d SSM and %d Secrets Manager calls, want 1 each", ssmClient.calls, secretsClient.calls)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	cfg := &Config{
+		TableName:    "users",
+		AWSRegion:    "ssm:/region",
+		JWTSecret:    "ssm:/users-api/jwt-secret",
+		JWTPublicKey: "secretsmanager:users-api/public-key",
+	}
+	if !cfg.HasSecretReferences() {
+		t.Fatal("HasSecretReferences = false, want true")
+	}
+	resolver := NewSecretResolver(
+		&mockSSM{parameters: map[string]string{"/users-api/jwt-secret": "s3cret"}},
+		&mockSecretsManager{stringSecrets: map[string]string{"users-api/public-key": "-----BEGIN PUBLIC KEY-----"}},
+	)
+
+	if err := cfg.ResolveSecrets(context.Background(), resolver); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWTSecret != "s3cret" || cfg.JWTPublicKey != "-----BEGIN PUBLIC KEY-----" {
+		t.Errorf("secrets = %q, %q, want the resolved values", cfg.JWTSecret, cfg.JWTPublicKey)
+	}
+	if cfg.TableName != "users" {
+		t.Errorf("TableName = %q, want the plain value unchanged", cfg.TableName)
+	}
+	// The region is needed to reach SSM, so it is never resolved
+	if cfg.AWSRegion != "ssm:/region" {
+		t.Errorf("AWSRegion = %q, want it left alone", cfg.AWSRegion)
+	}
+	if cfg.HasSecretReferences() {
+		t.Error("HasSecretReferences = true after resolving")
+	}
+}
+
+func TestResolveSecretsNamesTheSetting(t *testing.T) {
+	cfg := &Config{JWTSecret: "ssm:/missing"}
+	resolver := NewSecretResolver(&mockSSM{}, &mockSecretsManager{})
+
+	err := cfg.ResolveSecrets(context.Background(), resolver)
+	if !errors.Is(err, errAccessDenied) {
+		t.Fatalf("err = %v, want %v", err, errAccessDenied)
+	}
+	if want := "JWT_SECRET environment variable"; !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v, want it to name %s", err, want)
+	}
+}
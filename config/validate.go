@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxAttempts bounds DYNAMODB_MAX_ATTEMPTS: with the backoff capped at the maximum delay, more
+// attempts would only hold a failing request until the Lambda times out.
+const maxAttempts = 10
+
+// Validate checks the configuration as a whole: required settings, numeric ranges, mutually
+// exclusive flags and well-formed values. Every problem is reported, joined into one error, so
+// a misconfigured deployment can be fixed in a single pass.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	// The AWS settings are only required when talking to DynamoDB
+	check(c.UseInMemory || c.AWSRegion != "", "AWS_REGION environment variable not set")
+	check(c.UseInMemory || c.TableName != "", "DYNAMODB_TABLE_NAME environment variable not set")
+	check(!c.UseInMemory || c.Endpoint == "", "DYNAMODB_ENDPOINT and USE_IN_MEMORY are mutually exclusive")
+	check(!c.UseInMemory || c.FieldEncryptionKey == "", "FIELD_ENCRYPTION_KEY_ARN and USE_IN_MEMORY are mutually exclusive")
+	if c.Endpoint != "" {
+		endpoint, err := url.Parse(c.Endpoint)
+		check(err == nil && endpoint.Host != "" && (endpoint.Scheme == "http" || endpoint.Scheme == "https"),
+			"DYNAMODB_ENDPOINT environment variable must be an http or https URL, got %q", c.Endpoint)
+	}
+	check(c.KeySchema == "email" || c.KeySchema == "id", "DYNAMODB_KEY_SCHEMA environment variable must be email or id")
+	if c.KeySchema == "id" && !c.UseInMemory {
+		check(c.EmailTableName != "", "DYNAMODB_EMAIL_TABLE_NAME environment variable must be set when DYNAMODB_KEY_SCHEMA is id")
+		// Tables keyed on id are created by the key migration, which normalizes every email
+		check(c.NormalizedEmailIndex == "", "DYNAMODB_NORMALIZED_EMAIL_INDEX cannot be used when DYNAMODB_KEY_SCHEMA is id")
+	}
+	check(c.BillingMode == "" || c.BillingMode == "PROVISIONED" || c.BillingMode == "PAY_PER_REQUEST",
+		"DYNAMODB_BILLING_MODE environment variable must be PROVISIONED or PAY_PER_REQUEST")
+
+	// Zero retry settings mean "use the billing mode's defaults", so only negatives are invalid
+	check(c.MaxAttempts >= 0 && c.MaxAttempts <= maxAttempts,
+		"DYNAMODB_MAX_ATTEMPTS environment variable must be between 0 and %d", maxAttempts)
+	check(c.RetryBaseDelayMs >= 0, "DYNAMODB_RETRY_BASE_DELAY_MS environment variable must not be negative")
+	check(c.RetryMaxDelayMs >= 0, "DYNAMODB_RETRY_MAX_DELAY_MS environment variable must not be negative")
+	check(c.RetryBaseDelayMs == 0 || c.RetryMaxDelayMs == 0 || c.RetryBaseDelayMs <= c.RetryMaxDelayMs,
+		"DYNAMODB_RETRY_BASE_DELAY_MS must not exceed DYNAMODB_RETRY_MAX_DELAY_MS")
+
+	check(c.SDKMaxRetries >= 0, "SDK_MAX_RETRIES environment variable must not be negative")
+	check(c.SDKHTTPTimeoutMs > 0, "SDK_HTTP_TIMEOUT_MS environment variable must be positive")
+
+	check(c.UserCacheTTLSeconds >= 0, "USER_CACHE_TTL_SECONDS environment variable must not be negative")
+	check(c.UserCacheSize > 0, "USER_CACHE_SIZE environment variable must be positive")
+
+	check(c.DefaultPageSize > 0, "DEFAULT_PAGE_SIZE environment variable must be positive")
+	check(c.MaxPageSize > 0, "MAX_PAGE_SIZE environment variable must be positive")
+	check(c.DefaultPageSize <= c.MaxPageSize, "DEFAULT_PAGE_SIZE must not exceed MAX_PAGE_SIZE")
+	check(c.CacheMaxAgeSeconds >= 0, "CACHE_MAX_AGE_SECONDS environment variable must not be negative")
+	check(c.CacheStaleWhileRevalidateSeconds >= 0, "CACHE_STALE_WHILE_REVALIDATE_SECONDS environment variable must not be negative")
+	check(c.DeadlineMarginMs >= 0, "DEADLINE_MARGIN_MS environment variable must not be negative")
+	check(c.SuggestionScanLimit > 0, "EMAIL_SUGGESTION_SCAN_LIMIT environment variable must be positive")
+	check(c.MaxBodyBytes > 0, "MAX_BODY_BYTES environment variable must be positive")
+	check(c.NameSanitization == "reject" || c.NameSanitization == "strip",
+		"NAME_SANITIZATION environment variable must be reject or strip")
+	check(c.EmailValidation == "practical" || c.EmailValidation == "rfc",
+		"EMAIL_VALIDATION environment variable must be practical or rfc")
+	for _, domain := range c.DisposableDomains {
+		check(!strings.ContainsAny(domain, "@/ ") && strings.Contains(domain, "."),
+			"DISPOSABLE_EMAIL_DOMAINS entry %q must be a domain such as mailinator.com", domain)
+	}
+	check(c.FieldNaming == "camelCase" || c.FieldNaming == "snake_case",
+		"JSON_FIELD_NAMING environment variable must be camelCase or snake_case")
+
+	check(c.SoftDeleteRetention > 0, "SOFT_DELETE_RETENTION_DAYS environment variable must be positive")
+	check(c.IdempotencyTTLSeconds > 0, "IDEMPOTENCY_TTL_SECONDS environment variable must be positive")
+	check(c.RateLimitRPS > 0 && c.RateLimitBurst > 0, "RATE_LIMIT_RPS and RATE_LIMIT_BURST environment variables must be positive")
+
+	check(!c.AuthEnabled || c.JWTSecret != "" || c.JWTPublicKey != "",
+		"JWT_SECRET or JWT_PUBLIC_KEY environment variable must be set when AUTH_ENABLED is true")
+
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "" && parsed.Path == "",
+			"ALLOWED_ORIGINS entry %q must be * or an origin such as https://example.com", origin)
+	}
+	check(len(c.Tenants) == len(slices.Compact(slices.Sorted(slices.Values(c.Tenants)))),
+		"TENANTS environment variable must not list a tenant twice")
+
+	return errors.Join(errs...)
+}
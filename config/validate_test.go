@@ -0,0 +1,153 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMaxAttempts(t *testing.T) {
+	tests := []struct {
+		maxAttempts int
+		wantErr     bool
+	}{
+		{0, false},
+		{3, false},
+		{maxAttempts, false},
+		{maxAttempts + 1, true},
+		{100, true},
+		{-1, true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.MaxAttempts = tt.maxAttempts
+		err = cfg.Validate()
+		if gotErr := err != nil && strings.Contains(err.Error(), "DYNAMODB_MAX_ATTEMPTS"); gotErr != tt.wantErr {
+			t.Errorf("MaxAttempts %d: err = %v, want an error: %v", tt.maxAttempts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateKeySchema(t *testing.T) {
+	tests := []struct {
+		name                 string
+		keySchema            string
+		emailTable           string
+		normalizedEmailIndex string
+		wantErr              string
+	}{
+		{name: "email", keySchema: "email"},
+		{name: "id with an email table", keySchema: "id", emailTable: "emails"},
+		{name: "unknown", keySchema: "uuid", wantErr: "DYNAMODB_KEY_SCHEMA"},
+		{name: "id without an email table", keySchema: "id", wantErr: "DYNAMODB_EMAIL_TABLE_NAME"},
+		{name: "id with the normalized email index", keySchema: "id", emailTable: "emails", normalizedEmailIndex: "normalized", wantErr: "DYNAMODB_NORMALIZED_EMAIL_INDEX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.UseInMemory = false
+			cfg.AWSRegion = "us-east-1"
+			cfg.TableName = "users"
+			cfg.KeySchema = tt.keySchema
+			cfg.EmailTableName = tt.emailTable
+			cfg.NormalizedEmailIndex = tt.normalizedEmailIndex
+			err = cfg.Validate()
+			if tt.wantErr == "" && err != nil && strings.Contains(err.Error(), "DYNAMODB_") {
+				t.Errorf("err = %v, want none about DynamoDB", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want one about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// validConfig returns the configuration LoadConfig builds from an empty environment, set up for
+// DynamoDB, failing the test unless it is valid.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.UseInMemory = false
+	cfg.AWSRegion = "us-east-1"
+	cfg.TableName = "users"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "DynamoDB Local", modify: func(cfg *Config) { cfg.Endpoint = "http://localhost:8000" }},
+		{name: "in memory without AWS settings", modify: func(cfg *Config) { cfg.UseInMemory, cfg.AWSRegion, cfg.TableName = true, "", "" }},
+		{name: "no region", modify: func(cfg *Config) { cfg.AWSRegion = "" }, wantErr: "AWS_REGION"},
+		{name: "no table", modify: func(cfg *Config) { cfg.TableName = "" }, wantErr: "DYNAMODB_TABLE_NAME"},
+		{name: "endpoint with in-memory storage", modify: func(cfg *Config) { cfg.UseInMemory, cfg.Endpoint = true, "http://localhost:8000" }, wantErr: "mutually exclusive"},
+		{name: "endpoint without a scheme", modify: func(cfg *Config) { cfg.Endpoint = "localhost:8000" }, wantErr: "DYNAMODB_ENDPOINT"},
+		{name: "endpoint with another scheme", modify: func(cfg *Config) { cfg.Endpoint = "ftp://localhost" }, wantErr: "DYNAMODB_ENDPOINT"},
+		{name: "zero max page size", modify: func(cfg *Config) { cfg.MaxPageSize = 0 }, wantErr: "MAX_PAGE_SIZE"},
+		{name: "default page size above the maximum", modify: func(cfg *Config) { cfg.DefaultPageSize, cfg.MaxPageSize = 50, 20 }, wantErr: "DEFAULT_PAGE_SIZE must not exceed MAX_PAGE_SIZE"},
+		{name: "base delay above the maximum", modify: func(cfg *Config) { cfg.RetryBaseDelayMs, cfg.RetryMaxDelayMs = 500, 100 }, wantErr: "DYNAMODB_RETRY_BASE_DELAY_MS"},
+		{name: "unknown name sanitization", modify: func(cfg *Config) { cfg.NameSanitization = "escape" }, wantErr: "NAME_SANITIZATION"},
+		{name: "auth without a key", modify: func(cfg *Config) { cfg.AuthEnabled, cfg.JWTSecret, cfg.JWTPublicKey = true, "", "" }, wantErr: "JWT_SECRET"},
+		{name: "origin with a path", modify: func(cfg *Config) { cfg.AllowedOrigins = []string{"https://example.com/app"} }, wantErr: "ALLOWED_ORIGINS"},
+		{name: "RFC email validation", modify: func(cfg *Config) { cfg.EmailValidation = "rfc" }},
+		{name: "unknown email validation", modify: func(cfg *Config) { cfg.EmailValidation = "strict" }, wantErr: "EMAIL_VALIDATION"},
+		{name: "user cache", modify: func(cfg *Config) { cfg.UserCacheTTLSeconds, cfg.UserCacheSize = 30, 500 }},
+		{name: "no SDK retries", modify: func(cfg *Config) { cfg.SDKMaxRetries = 0 }},
+		{name: "negative SDK retries", modify: func(cfg *Config) { cfg.SDKMaxRetries = -1 }, wantErr: "SDK_MAX_RETRIES"},
+		{name: "zero SDK HTTP timeout", modify: func(cfg *Config) { cfg.SDKHTTPTimeoutMs = 0 }, wantErr: "SDK_HTTP_TIMEOUT_MS"},
+		{name: "negative user cache TTL", modify: func(cfg *Config) { cfg.UserCacheTTLSeconds = -1 }, wantErr: "USER_CACHE_TTL_SECONDS"},
+		{name: "zero user cache size", modify: func(cfg *Config) { cfg.UserCacheSize = 0 }, wantErr: "USER_CACHE_SIZE"},
+		{name: "disposable domains", modify: func(cfg *Config) { cfg.DisposableDomains = []string{"mailinator.com", "guerrillamail.com"} }},
+		{name: "disposable domain with an @", modify: func(cfg *Config) { cfg.DisposableDomains = []string{"@mailinator.com"} }, wantErr: "DISPOSABLE_EMAIL_DOMAINS"},
+		{name: "disposable domain without a dot", modify: func(cfg *Config) { cfg.DisposableDomains = []string{"mailinator"} }, wantErr: "DISPOSABLE_EMAIL_DOMAINS"},
+		{name: "tenant listed twice", modify: func(cfg *Config) { cfg.Tenants = []string{"acme", "globex", "acme"} }, wantErr: "TENANTS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.TableName = ""
+	cfg.MaxPageSize = 0
+	cfg.RateLimitRPS = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("err = nil, want one")
+	}
+	for _, want := range []string{"DYNAMODB_TABLE_NAME", "MAX_PAGE_SIZE", "RATE_LIMIT_RPS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// timeFormat is RFC3339 with a fixed number of fractional digits, so audit IDs sort chronologically.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+type messageIDKey struct{}
+
+// WithMessageID returns a copy of ctx whose changes are recorded as requested by the SQS message with
+// messageID, since queued messages carry no claims naming the caller.
+func WithMessageID(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, messageID)
+}
+
+// AuditedUserRepository decorates a UserRepository, appending a record of every change to a user to
+// an AuditLog: the caller from the request's verified claims, the operation, and the user before and
+// after it. Batch, transactional and table-wide operations append one record per user they change.
+//
+// The record is appended once the change succeeded, so a failed change leaves none. The user before
+// an update is read just ahead of it, consistently, which costs a read per write; a concurrent write
+// in between would show in the record as part of the change. The users after a transaction are read
+// again once it succeeded. Merges and the table-wide operations report the users they change
+// themselves, through a repository.ChangeRecorder, so they cost no extra reads. A record that cannot
+// be appended does not fail the change, which is already stored, but is logged in full as an error
+// instead.
+type AuditedUserRepository struct {
+	repository.UserRepository
+	log   repository.AuditLog
+	table string
+	now   func() time.Time
+}
+
+// NewAuditedUserRepository wraps repo, which stores users in table, so that its changes are
+// recorded in log.
+func NewAuditedUserRepository(repo repository.UserRepository, log repository.AuditLog, table string) *AuditedUserRepository {
+	return &AuditedUserRepository{
+		UserRepository: repo,
+		log:            log,
+		table:          table,
+		now:            time.Now,
+	}
+}
+
+// CreateUser records the user created by UserRepository.CreateUser.
+func (r *AuditedUserRepository) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	created, err := r.UserRepository.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, "CreateUser", nil, created)
+	return created, nil
+}
+
+// UpdateUser records the user before and after UserRepository.UpdateUser.
+func (r *AuditedUserRepository) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	before := r.current(ctx, user.Email)
+	updated, err := r.UserRepository.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, "UpdateUser", before, updated)
+	return updated, nil
+}
+
+// UpsertUser records the user before and after UserRepository.UpsertUser.
+func (r *AuditedUserRepository) UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error) {
+	before := r.current(ctx, user.Email)
+	upserted, created, err := r.UserRepository.UpsertUser(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+	r.record(ctx, "UpsertUser", before, upserted)
+	return upserted, created, nil
+}
+
+// DeleteUser records the user deleted by UserRepository.DeleteUser.
+func (r *AuditedUserRepository) DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	deleted, err := r.UserRepository.DeleteUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, "DeleteUser", deleted, nil)
+	return deleted, nil
+}
+
+// RestoreUser records the user before and after UserRepository.RestoreUser.
+func (r *AuditedUserRepository) RestoreUser(ctx context.Context, email string) (*models.User, error) {
+	before := r.current(ctx, email)
+	restored, err := r.UserRepository.RestoreUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, "RestoreUser", before, restored)
+	return restored, nil
+}
+
+// ChangeEmail records the user before and after UserRepository.ChangeEmail, under the new email.
+func (r *AuditedUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	before := r.current(ctx, oldEmail)
+	moved, err := r.UserRepository.ChangeEmail(ctx, oldEmail, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, "ChangeEmail", before, moved)
+	return moved, nil
+}
+
+// CreateUsers records each user created by UserRepository.CreateUsers.
+func (r *AuditedUserRepository) CreateUsers(ctx context.Context, users []models.User) (*repository.BatchCreateResult, error) {
+	result, err := r.UserRepository.CreateUsers(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Created {
+		r.record(ctx, "CreateUsers", nil, &result.Created[i])
+	}
+	return result, nil
+}
+
+// DeleteUsers records each user deleted by UserRepository.DeleteUsers.
+func (r *AuditedUserRepository) DeleteUsers(ctx context.Context, emails []string) (*repository.BatchDeleteResult, error) {
+	before := make(map[string]*models.User, len(emails))
+	for _, email := range emails {
+		before[validators.NormalizeEmail(email)] = r.current(ctx, email)
+	}
+	result, err := r.UserRepository.DeleteUsers(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range result.Deleted {
+		r.record(ctx, "DeleteUsers", orEmail(before[email], email), nil)
+	}
+	return result, nil
+}
+
+// TransactWriteUsers records each user changed by UserRepository.TransactWriteUsers, reading the
+// created and updated users again once the transaction succeeded.
+func (r *AuditedUserRepository) TransactWriteUsers(ctx context.Context, ops []repository.UserOperation) error {
+	before := make([]*models.User, len(ops))
+	for i, op := range ops {
+		if op.Type != repository.OperationCreate {
+			before[i] = r.current(ctx, op.User.Email)
+		}
+	}
+	if err := r.UserRepository.TransactWriteUsers(ctx, ops); err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if op.Type == repository.OperationDelete {
+			r.record(ctx, "TransactWriteUsers", orEmail(before[i], op.User.Email), nil)
+			continue
+		}
+		r.record(ctx, "TransactWriteUsers", before[i], orEmail(r.current(ctx, op.User.Email), op.User.Email))
+	}
+	return nil
+}
+
+// MergeUsers records the primary before and after UserRepository.MergeUsers, and the deleted duplicate.
+func (r *AuditedUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error) {
+	return r.UserRepository.MergeUsers(r.recording(ctx, "MergeUsers"), primaryEmail, duplicateEmail)
+}
+
+// UpdateUsersWhere records each user before and after UserRepository.UpdateUsersWhere.
+func (r *AuditedUserRepository) UpdateUsersWhere(ctx context.Context, filter repository.UserFilter, patch repository.UserPatch, dryRun bool) (repository.BulkUpdateResult, error) {
+	return r.UserRepository.UpdateUsersWhere(r.recording(ctx, "UpdateUsersWhere"), filter, patch, dryRun)
+}
+
+// DeleteAllUsers records each user deleted by UserRepository.DeleteAllUsers.
+func (r *AuditedUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	return r.UserRepository.DeleteAllUsers(r.recording(ctx, "DeleteAllUsers"))
+}
+
+// PurgeDeletedUsers records each user purged by UserRepository.PurgeDeletedUsers.
+func (r *AuditedUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (repository.PurgeResult, error) {
+	return r.UserRepository.PurgeDeletedUsers(r.recording(ctx, "PurgeDeletedUsers"), deletedBefore)
+}
+
+// MigrateUsers records each user before and after UserRepository.MigrateUsers.
+func (r *AuditedUserRepository) MigrateUsers(ctx context.Context) (int, error) {
+	return r.UserRepository.MigrateUsers(r.recording(ctx, "MigrateUsers"))
+}
+
+// Ping forwards the health check when the wrapped repository supports one.
+func (r *AuditedUserRepository) Ping(ctx context.Context) error {
+	pinger, ok := r.UserRepository.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return pinger.Ping(ctx)
+}
+
+// current returns the stored user of email, deleted or not, or nil if there is none or it cannot
+// be read. In the latter case the change is most likely to fail as well.
+func (r *AuditedUserRepository) current(ctx context.Context, email string) *models.User {
+	user, err := r.UserRepository.FetchUser(ctx, email, repository.FetchOptions{ConsistentRead: true, IncludeDeleted: true})
+	if err != nil {
+		slog.Warn("Could not read the user before a change", slog.String("operation", "Audit"), slog.Any("error", err))
+		return nil
+	}
+	return user
+}
+
+// recording returns ctx with a ChangeRecorder recording each change under operation.
+func (r *AuditedUserRepository) recording(ctx context.Context, operation string) context.Context {
+	return repository.WithChangeRecorder(ctx, func(before, after *models.User) {
+		r.record(ctx, operation, before, after)
+	})
+}
+
+// orEmail returns user, or a user with only email if user is nil because it could not be read, so
+// the record is still filed under the email.
+func orEmail(user *models.User, email string) *models.User {
+	if user == nil {
+		return &models.User{Email: validators.NormalizeEmail(email)}
+	}
+	return user
+}
+
+// record appends the record of operation, which changed before into after, to the audit log.
+func (r *AuditedUserRepository) record(ctx context.Context, operation string, before, after *models.User) {
+	now := r.now().UTC()
+	record := repository.AuditRecord{
+		AuditID:   now.Format(timeFormat) + "#" + randomSuffix(),
+		Operation: operation,
+		Table:     r.table,
+		Timestamp: now.Format(timeFormat),
+		Before:    snapshot(before),
+		After:     snapshot(after),
+	}
+	switch {
+	case after != nil:
+		record.Email = after.Email
+	case before != nil:
+		record.Email = before.Email
+	}
+	if claims := auth.ClaimsFromContext(ctx); claims != nil {
+		record.Actor = claims.Subject
+		record.ActorRole = claims.Role
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		record.RequestID = lc.AwsRequestID
+	}
+	record.MessageID, _ = ctx.Value(messageIDKey{}).(string)
+	if after != nil && after.PasswordHash != "" && (before == nil || before.PasswordHash != after.PasswordHash) {
+		record.PasswordChanged = true
+	}
+
+	if err := r.log.AppendRecord(ctx, record); err != nil {
+		slog.Error("Could not append audit record", slog.String("operation", operation), slog.Any("record", record), slog.Any("error", err))
+	}
+}
+
+// snapshot returns a copy of user as it is recorded: without its password hash and the attributes
+// derived from other fields. A nil user stays nil.
+func snapshot(user *models.User) *models.User {
+	if user == nil {
+		return nil
+	}
+	recorded := *user
+	recorded.Metadata = maps.Clone(user.Metadata)
+	recorded.Password = ""
+	recorded.PasswordHash = ""
+	recorded.NormalizedEmail = ""
+	recorded.SearchTokens = nil
+	return &recorded
+}
+
+// randomSuffix returns 8 random bytes in hex, telling apart records written in the same nanosecond.
+func randomSuffix() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
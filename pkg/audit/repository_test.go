@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestRepository returns an audited in-memory repository holding users, and its audit log.
+func newTestRepository(t *testing.T, users ...models.User) (*AuditedUserRepository, *repository.InMemoryAuditLog) {
+	t.Helper()
+	base := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{SoftDelete: true, AllowDestructiveOps: true})
+	for _, user := range users {
+		if _, err := base.CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := repository.NewInMemoryAuditLog()
+	return NewAuditedUserRepository(base, log, "users"), log
+}
+
+func TestUpdateUserIsAudited(t *testing.T) {
+	repo, log := newTestRepository(t, models.User{Email: "a@example.com", FirstName: "John", LastName: "Doe", Password: "secret123"})
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: "admin", RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+
+	if _, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", FirstName: "Jonathan", LastName: "Doe", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := log.Records()
+	if len(records) != 1 {
+		t.Fatalf("%d records, want 1", len(records))
+	}
+	record := records[0]
+	if record.Operation != "UpdateUser" || record.Email != "a@example.com" || record.Table != "users" {
+		t.Errorf("record = %+v", record)
+	}
+	if record.Actor != "admin@example.com" || record.ActorRole != "admin" {
+		t.Errorf("actor = %q (%q), want admin@example.com (admin)", record.Actor, record.ActorRole)
+	}
+	if record.Before == nil || record.Before.FirstName != "John" || record.Before.Version != 1 {
+		t.Errorf("before = %+v", record.Before)
+	}
+	if record.After == nil || record.After.FirstName != "Jonathan" || record.After.Version != 2 {
+		t.Errorf("after = %+v", record.After)
+	}
+	if record.Before.PasswordHash != "" || record.After.PasswordHash != "" {
+		t.Error("password hash recorded")
+	}
+}
+
+// change is the gist of an audit record: whether it has a user before and after the change.
+type change struct {
+	operation string
+	email     string
+	before    bool
+	after     bool
+}
+
+func TestBatchOperationsAreAuditedPerUser(t *testing.T) {
+	stored := []models.User{
+		{Email: "a@example.com", FirstName: "A", Role: models.RoleViewer},
+		{Email: "b@example.com", FirstName: "B", Role: models.RoleViewer},
+	}
+	tests := []struct {
+		name  string
+		setup func(ctx context.Context, repo *AuditedUserRepository) error
+		run   func(ctx context.Context, repo *AuditedUserRepository) error
+		want  []change
+	}{
+		{
+			name: "CreateUsers",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.CreateUsers(ctx, []models.User{{Email: "a@example.com"}, {Email: "c@example.com"}, {Email: "d@example.com"}})
+				return err
+			},
+			want: []change{{"CreateUsers", "c@example.com", false, true}, {"CreateUsers", "d@example.com", false, true}},
+		},
+		{
+			name: "DeleteUsers",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.DeleteUsers(ctx, []string{"A@Example.com", "nobody@example.com"})
+				return err
+			},
+			want: []change{{"DeleteUsers", "a@example.com", true, false}},
+		},
+		{
+			name: "TransactWriteUsers",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				return repo.TransactWriteUsers(ctx, []repository.UserOperation{
+					{Type: repository.OperationCreate, User: models.User{Email: "c@example.com"}},
+					{Type: repository.OperationUpdate, User: models.User{Email: "a@example.com", FirstName: "Anna"}},
+					{Type: repository.OperationDelete, User: models.User{Email: "b@example.com"}},
+				})
+			},
+			want: []change{
+				{"TransactWriteUsers", "c@example.com", false, true},
+				{"TransactWriteUsers", "a@example.com", true, true},
+				{"TransactWriteUsers", "b@example.com", true, false},
+			},
+		},
+		{
+			name: "MergeUsers",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.MergeUsers(ctx, "a@example.com", "b@example.com")
+				return err
+			},
+			want: []change{{"MergeUsers", "a@example.com", true, true}, {"MergeUsers", "b@example.com", true, false}},
+		},
+		{
+			name: "UpdateUsersWhere",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.UpdateUsersWhere(ctx, repository.UserFilter{Role: models.RoleViewer}, repository.UserPatch{Locale: "de-DE"}, false)
+				return err
+			},
+			want: []change{{"UpdateUsersWhere", "a@example.com", true, true}, {"UpdateUsersWhere", "b@example.com", true, true}},
+		},
+		{
+			name: "dry run",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.UpdateUsersWhere(ctx, repository.UserFilter{Role: models.RoleViewer}, repository.UserPatch{Locale: "de-DE"}, true)
+				return err
+			},
+		},
+		{
+			name: "DeleteAllUsers",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.DeleteAllUsers(ctx)
+				return err
+			},
+			want: []change{{"DeleteAllUsers", "a@example.com", true, false}, {"DeleteAllUsers", "b@example.com", true, false}},
+		},
+		{
+			name: "PurgeDeletedUsers",
+			setup: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.UserRepository.DeleteUser(ctx, "a@example.com")
+				return err
+			},
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+				return err
+			},
+			want: []change{{"PurgeDeletedUsers", "a@example.com", true, false}},
+		},
+		{
+			name: "MigrateUsers without users to migrate",
+			run: func(ctx context.Context, repo *AuditedUserRepository) error {
+				_, err := repo.MigrateUsers(ctx)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, log := newTestRepository(t, stored...)
+			ctx := WithMessageID(context.Background(), "message-1")
+			if tt.setup != nil {
+				if err := tt.setup(ctx, repo); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.run(ctx, repo); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []change
+			for _, record := range log.Records() {
+				got = append(got, change{record.Operation, record.Email, record.Before != nil, record.After != nil})
+				if record.MessageID != "message-1" {
+					t.Errorf("message id = %q, want message-1", record.MessageID)
+				}
+			}
+			// The in-memory repository visits users in map order
+			byEmail := func(a, b change) int { return strings.Compare(a.email, b.email) }
+			if tt.name != "TransactWriteUsers" {
+				slices.SortFunc(got, byEmail)
+				slices.SortFunc(tt.want, byEmail)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("records = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrorMissingToken = "missing bearer token"
+	ErrorInvalidToken = "invalid token"
+	ErrorNoVerifyKey  = "a JWT secret or public key is required"
+)
+
+// Claims are the verified JWT claims made available to handlers.
+// The subject is the caller's email.
+type Claims struct {
+	Role   string `json:"role,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier validates bearer JWTs signed with an HMAC secret or an RSA/ECDSA/Ed25519 key pair.
+type Verifier struct {
+	secret    []byte
+	publicKey crypto.PublicKey
+	parser    *jwt.Parser
+}
+
+// NewVerifier creates a Verifier from an HMAC secret, a PEM-encoded public key, or both.
+// Tokens must carry an expiry; tokens signed with an algorithm matching neither key are rejected.
+func NewVerifier(secret, publicKeyPEM string) (*Verifier, error) {
+	verifier := &Verifier{}
+	var methods []string
+	if secret != "" {
+		verifier.secret = []byte(secret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if publicKeyPEM != "" {
+		key, err := parsePublicKey([]byte(publicKeyPEM))
+		if err != nil {
+			return nil, err
+		}
+		verifier.publicKey = key
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	if len(methods) == 0 {
+		return nil, errors.New(ErrorNoVerifyKey)
+	}
+	verifier.parser = jwt.NewParser(jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	return verifier, nil
+}
+
+// parsePublicKey accepts an RSA, ECDSA or Ed25519 public key in PEM format.
+func parsePublicKey(pem []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	key, err := jwt.ParseEdPublicKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JWT public key: %w", err)
+	}
+	return key, nil
+}
+
+// Verify checks the signature and expiry of a raw token and returns its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return v.secret, nil
+		}
+		return v.publicKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrorInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// VerifyHeader extracts and verifies the token of an "Authorization: Bearer <token>" header.
+func (v *Verifier) VerifyHeader(header string) (*Claims, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, errors.New(ErrorMissingToken)
+	}
+	return v.Verify(strings.TrimSpace(token))
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying the verified claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by WithClaims, or nil when the request was not authenticated.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
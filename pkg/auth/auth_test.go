@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// signed returns a token for claims signed with method and key.
+func signed(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// claimsFor returns claims for subject expiring at expiresAt.
+func claimsFor(subject string, expiresAt time.Time) *Claims {
+	return &Claims{Role: "editor", RegisteredClaims: jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(expiresAt)}}
+}
+
+func TestVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	verifier, err := NewVerifier(testSecret, publicKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := signed(t, jwt.SigningMethodHS256, []byte(testSecret), claimsFor("a@example.com", time.Now().Add(time.Hour)))
+	parts := strings.Split(valid, ".") // header, payload and signature
+	// A payload for another subject, to pass off with the original signature
+	forged := strings.Split(signed(t, jwt.SigningMethodHS256, []byte("other-secret"), claimsFor("admin@example.com", time.Now().Add(time.Hour))), ".")
+	tests := []struct {
+		name        string
+		token       string
+		wantSubject string
+	}{
+		{name: "valid HMAC token", token: valid, wantSubject: "a@example.com"},
+		{name: "valid Ed25519 token", token: signed(t, jwt.SigningMethodEdDSA, privateKey, claimsFor("b@example.com", time.Now().Add(time.Hour))), wantSubject: "b@example.com"},
+		{name: "expired token", token: signed(t, jwt.SigningMethodHS256, []byte(testSecret), claimsFor("a@example.com", time.Now().Add(-time.Minute)))},
+		{name: "token without expiry", token: signed(t, jwt.SigningMethodHS256, []byte(testSecret), &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "a@example.com"}})},
+		{name: "tampered payload", token: parts[0] + "." + forged[1] + "." + parts[2]},
+		{name: "tampered signature", token: parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))},
+		{name: "other secret", token: signed(t, jwt.SigningMethodHS256, []byte("other-secret"), claimsFor("a@example.com", time.Now().Add(time.Hour)))},
+		{name: "unsigned token", token: signed(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claimsFor("a@example.com", time.Now().Add(time.Hour)))},
+		{name: "not a token", token: "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.wantSubject == "" {
+				if err == nil || !strings.HasPrefix(err.Error(), ErrorInvalidToken) {
+					t.Errorf("err = %v, want %s", err, ErrorInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", claims.Subject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestVerifyHeader(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signed(t, jwt.SigningMethodHS256, []byte(testSecret), claimsFor("a@example.com", time.Now().Add(time.Hour)))
+	tests := []struct {
+		name    string
+		header  string
+		wantErr string
+	}{
+		{name: "bearer token", header: "Bearer " + token},
+		{name: "lower-case scheme", header: "bearer " + token},
+		{name: "missing header", wantErr: ErrorMissingToken},
+		{name: "basic credentials", header: "Basic dXNlcjpwYXNz", wantErr: ErrorMissingToken},
+		{name: "scheme without token", header: "Bearer ", wantErr: ErrorMissingToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.VerifyHeader(tt.header)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("err = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresAKey(t *testing.T) {
+	if _, err := NewVerifier("", ""); err == nil || err.Error() != ErrorNoVerifyKey {
+		t.Errorf("err = %v, want %s", err, ErrorNoVerifyKey)
+	}
+	if _, err := NewVerifier("", "not a key"); err == nil {
+		t.Error("invalid public key accepted")
+	}
+}
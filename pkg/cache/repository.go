@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+)
+
+// CachedUserRepository decorates a UserRepository with a read-through cache of FetchUser results.
+// Lambda reuses containers across invocations, so users read repeatedly are served from memory
+// until their entry expires or is evicted as the least recently used one.
+//
+// The cache is per container: a write invalidates the entries of the users it changes in this
+// container only, and other containers may serve the previous version of a user until their entry
+// expires. The TTL therefore bounds how stale a read may be, and should be kept short. Reads that
+// ask for consistency, deleted users or selected fields always go to the wrapped repository, and
+// misses are not cached, so a created user is found at once.
+type CachedUserRepository struct {
+	repository.UserRepository
+	ttl     time.Duration
+	maxSize int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // by normalized email
+	lru     *list.List               // of *entry, most recently used first
+}
+
+// entry is a cached user with its expiry.
+type entry struct {
+	email   string
+	user    models.User
+	expires time.Time
+}
+
+// NewCachedUserRepository wraps repo so that FetchUser results are cached for ttl, keeping at most
+// maxSize users.
+func NewCachedUserRepository(repo repository.UserRepository, ttl time.Duration, maxSize int) *CachedUserRepository {
+	return &CachedUserRepository{
+		UserRepository: repo,
+		ttl:            ttl,
+		maxSize:        maxSize,
+		now:            time.Now,
+		entries:        make(map[string]*list.Element),
+		lru:            list.New(),
+	}
+}
+
+// FetchUser returns the cached user when there is a fresh entry, and otherwise reads the user
+// through UserRepository.FetchUser and caches it.
+func (r *CachedUserRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (*models.User, error) {
+	if opts.ConsistentRead || opts.IncludeDeleted || len(opts.Fields) > 0 {
+		return r.UserRepository.FetchUser(ctx, email, opts)
+	}
+	key := validators.NormalizeEmail(email)
+	if user, ok := r.get(key); ok {
+		return user, nil
+	}
+	user, err := r.UserRepository.FetchUser(ctx, email, opts)
+	if err != nil || user == nil {
+		return user, err
+	}
+	r.put(key, user)
+	return user, nil
+}
+
+// CreateUser invalidates the created user after UserRepository.CreateUser.
+func (r *CachedUserRepository) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	defer r.invalidate(user.Email)
+	return r.UserRepository.CreateUser(ctx, user)
+}
+
+// CreateUsers invalidates the created users after UserRepository.CreateUsers.
+func (r *CachedUserRepository) CreateUsers(ctx context.Context, users []models.User) (*repository.BatchCreateResult, error) {
+	defer func() {
+		for _, user := range users {
+			r.invalidate(user.Email)
+		}
+	}()
+	return r.UserRepository.CreateUsers(ctx, users)
+}
+
+// UpdateUser invalidates the updated user after UserRepository.UpdateUser.
+func (r *CachedUserRepository) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	defer r.invalidate(user.Email)
+	return r.UserRepository.UpdateUser(ctx, user)
+}
+
+// UpsertUser invalidates the written user after UserRepository.UpsertUser.
+func (r *CachedUserRepository) UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error) {
+	defer r.invalidate(user.Email)
+	return r.UserRepository.UpsertUser(ctx, user)
+}
+
+// DeleteUser invalidates the deleted user after UserRepository.DeleteUser.
+func (r *CachedUserRepository) DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	defer r.invalidate(email)
+	return r.UserRepository.DeleteUser(ctx, email)
+}
+
+// DeleteUsers invalidates the deleted users after UserRepository.DeleteUsers.
+func (r *CachedUserRepository) DeleteUsers(ctx context.Context, emails []string) (*repository.BatchDeleteResult, error) {
+	defer func() {
+		for _, email := range emails {
+			r.invalidate(email)
+		}
+	}()
+	return r.UserRepository.DeleteUsers(ctx, emails)
+}
+
+// TransactWriteUsers invalidates the users of every operation after UserRepository.TransactWriteUsers.
+func (r *CachedUserRepository) TransactWriteUsers(ctx context.Context, ops []repository.UserOperation) error {
+	defer func() {
+		for _, op := range ops {
+			r.invalidate(op.User.Email)
+		}
+	}()
+	return r.UserRepository.TransactWriteUsers(ctx, ops)
+}
+
+// RestoreUser invalidates the restored user after UserRepository.RestoreUser.
+func (r *CachedUserRepository) RestoreUser(ctx context.Context, email string) (*models.User, error) {
+	defer r.invalidate(email)
+	return r.UserRepository.RestoreUser(ctx, email)
+}
+
+// ChangeEmail invalidates both emails after UserRepository.ChangeEmail.
+func (r *CachedUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	defer r.invalidate(oldEmail)
+	defer r.invalidate(newEmail)
+	return r.UserRepository.ChangeEmail(ctx, oldEmail, newEmail)
+}
+
+// MergeUsers invalidates both users after UserRepository.MergeUsers.
+func (r *CachedUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error) {
+	defer r.invalidate(primaryEmail)
+	defer r.invalidate(duplicateEmail)
+	return r.UserRepository.MergeUsers(ctx, primaryEmail, duplicateEmail)
+}
+
+// DeleteAllUsers empties the cache after UserRepository.DeleteAllUsers.
+func (r *CachedUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	defer r.clear()
+	return r.UserRepository.DeleteAllUsers(ctx)
+}
+
+// MigrateUsers empties the cache after UserRepository.MigrateUsers.
+func (r *CachedUserRepository) MigrateUsers(ctx context.Context) (int, error) {
+	defer r.clear()
+	return r.UserRepository.MigrateUsers(ctx)
+}
+
+// UpdateUsersWhere empties the cache after UserRepository.UpdateUsersWhere, since any user may have matched.
+func (r *CachedUserRepository) UpdateUsersWhere(ctx context.Context, filter repository.UserFilter, patch repository.UserPatch, dryRun bool) (repository.BulkUpdateResult, error) {
+	if !dryRun {
+		defer r.clear()
+	}
+	return r.UserRepository.UpdateUsersWhere(ctx, filter, patch, dryRun)
+}
+
+// Ping forwards the health check when the wrapped repository supports one.
+func (r *CachedUserRepository) Ping(ctx context.Context) error {
+	pinger, ok := r.UserRepository.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return pinger.Ping(ctx)
+}
+
+// get returns a copy of the cached user of email, if neither its entry nor the user (when it is a
+// temporary one) has expired, and marks it as the most recently used.
+func (r *CachedUserRepository) get(email string) (*models.User, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	element, ok := r.entries[email]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*entry)
+	now := r.now()
+	if !now.Before(cached.expires) || (cached.user.ExpiresAt > 0 && cached.user.ExpiresAt <= now.Unix()) {
+		r.remove(element)
+		return nil, false
+	}
+	r.lru.MoveToFront(element)
+	return copyUser(cached.user), true
+}
+
+// put caches a copy of user under email, evicting the least recently used entry when the cache is full.
+func (r *CachedUserRepository) put(email string, user *models.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cached := &entry{email: email, user: *copyUser(*user), expires: r.now().Add(r.ttl)}
+	if element, ok := r.entries[email]; ok {
+		element.Value = cached
+		r.lru.MoveToFront(element)
+		return
+	}
+	r.entries[email] = r.lru.PushFront(cached)
+	for r.lru.Len() > r.maxSize {
+		r.remove(r.lru.Back())
+	}
+}
+
+// invalidate drops the cached user of email.
+func (r *CachedUserRepository) invalidate(email string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if element, ok := r.entries[validators.NormalizeEmail(email)]; ok {
+		r.remove(element)
+	}
+}
+
+// clear drops every cached user.
+func (r *CachedUserRepository) clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.entries)
+	r.lru.Init()
+}
+
+// remove drops a cache entry. The caller must hold mu.
+func (r *CachedUserRepository) remove(element *list.Element) {
+	r.lru.Remove(element)
+	delete(r.entries, element.Value.(*entry).email)
+}
+
+// copyUser returns a copy of user that shares no map with it, so callers cannot modify a cached user.
+func copyUser(user models.User) *models.User {
+	user.Metadata = maps.Clone(user.Metadata)
+	return &user
+}
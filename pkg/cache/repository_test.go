@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+)
+
+// countingRepository counts the FetchUser calls that reach the wrapped repository.
+type countingRepository struct {
+	repository.UserRepository
+	fetches int
+}
+
+func (r *countingRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (*models.User, error) {
+	r.fetches++
+	return r.UserRepository.FetchUser(ctx, email, opts)
+}
+
+// newTestRepository returns a cached in-memory repository holding users, the counter of the reads
+// that miss the cache, and a function advancing the cache's clock.
+func newTestRepository(t *testing.T, ttl time.Duration, maxSize int, users ...models.User) (*CachedUserRepository, *countingRepository, func(time.Duration)) {
+	t.Helper()
+	base := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{AllowDestructiveOps: true})
+	for _, user := range users {
+		if _, err := base.CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counter := &countingRepository{UserRepository: base}
+	repo := NewCachedUserRepository(counter, ttl, maxSize)
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+	return repo, counter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestFetchUserCaches(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		opts        repository.FetchOptions
+		wantFetches int // Reads reaching the repository for the first and second FetchUser
+	}{
+		{name: "hit", email: "a@example.com", wantFetches: 1},
+		{name: "hit in another case", email: "A@Example.com", wantFetches: 1},
+		{name: "miss is not cached", email: "nobody@example.com", wantFetches: 2},
+		{name: "consistent read", email: "a@example.com", opts: repository.FetchOptions{ConsistentRead: true}, wantFetches: 2},
+		{name: "deleted users included", email: "a@example.com", opts: repository.FetchOptions{IncludeDeleted: true}, wantFetches: 2},
+		{name: "selected fields", email: "a@example.com", opts: repository.FetchOptions{Fields: []string{"email"}}, wantFetches: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, counter, _ := newTestRepository(t, time.Minute, 10, models.User{Email: "a@example.com", FirstName: "Ann"})
+
+			for range 2 {
+				if _, err := repo.FetchUser(context.Background(), tt.email, tt.opts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if counter.fetches != tt.wantFetches {
+				t.Errorf("%d fetches, want %d", counter.fetches, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		write         func(ctx context.Context, repo *CachedUserRepository) error
+		wantFirstName string // Of a@example.com after the write; deleted when empty
+	}{
+		{
+			name: "update",
+			write: func(ctx context.Context, repo *CachedUserRepository) error {
+				_, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", FirstName: "Anna", Version: 1})
+				return err
+			},
+			wantFirstName: "Anna",
+		},
+		{
+			name: "upsert",
+			write: func(ctx context.Context, repo *CachedUserRepository) error {
+				_, _, err := repo.UpsertUser(ctx, models.User{Email: "A@Example.com", FirstName: "Anna"})
+				return err
+			},
+			wantFirstName: "Anna",
+		},
+		{
+			name: "delete",
+			write: func(ctx context.Context, repo *CachedUserRepository) error {
+				_, err := repo.DeleteUser(ctx, "a@example.com")
+				return err
+			},
+		},
+		{
+			name: "email change",
+			write: func(ctx context.Context, repo *CachedUserRepository) error {
+				_, err := repo.ChangeEmail(ctx, "a@example.com", "b@example.com")
+				return err
+			},
+		},
+		{
+			name: "bulk update",
+			write: func(ctx context.Context, repo *CachedUserRepository) error {
+				_, err := repo.UpdateUsersWhere(ctx, repository.UserFilter{Role: models.RoleViewer}, repository.UserPatch{Locale: "de-DE"}, false)
+				return err
+			},
+			wantFirstName: "Ann",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, counter, _ := newTestRepository(t, time.Minute, 10, models.User{Email: "a@example.com", FirstName: "Ann"})
+			ctx := context.Background()
+			if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.write(ctx, repo); err != nil {
+				t.Fatal(err)
+			}
+			user, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counter.fetches != 2 {
+				t.Errorf("%d fetches, want the cached user invalidated", counter.fetches)
+			}
+			if tt.wantFirstName == "" {
+				if user != nil {
+					t.Errorf("user = %+v, want none", user)
+				}
+				return
+			}
+			if user == nil || user.FirstName != tt.wantFirstName {
+				t.Errorf("user = %+v, want first name %s", user, tt.wantFirstName)
+			}
+		})
+	}
+}
+
+func TestBulkUpdateDryRunKeepsCache(t *testing.T) {
+	repo, counter, _ := newTestRepository(t, time.Minute, 10, models.User{Email: "a@example.com", FirstName: "Ann"})
+	ctx := context.Background()
+	if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.UpdateUsersWhere(ctx, repository.UserFilter{Role: models.RoleViewer}, repository.UserPatch{Locale: "de-DE"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetches != 1 {
+		t.Errorf("%d fetches, want 1", counter.fetches)
+	}
+}
+
+func TestFetchUserExpires(t *testing.T) {
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		ttlSeconds  int64 // Of a temporary user; none when zero
+		wantFetches int
+	}{
+		{name: "fresh", elapsed: 59 * time.Second, wantFetches: 1},
+		{name: "at the TTL", elapsed: time.Minute, wantFetches: 2},
+		{name: "past the TTL", elapsed: time.Hour, wantFetches: 2},
+		{name: "temporary user expired", elapsed: 30 * time.Second, ttlSeconds: 10, wantFetches: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, counter, advance := newTestRepository(t, time.Minute, 10, models.User{Email: "a@example.com", FirstName: "Ann", TTLSeconds: tt.ttlSeconds})
+			ctx := context.Background()
+			if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			advance(tt.elapsed)
+			if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if counter.fetches != tt.wantFetches {
+				t.Errorf("%d fetches, want %d", counter.fetches, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	repo, counter, _ := newTestRepository(t, time.Minute, 2,
+		models.User{Email: "a@example.com"}, models.User{Email: "b@example.com"}, models.User{Email: "c@example.com"})
+	ctx := context.Background()
+
+	// a is read again before c is cached, so b is the one evicted
+	for _, email := range []string{"a@example.com", "b@example.com", "a@example.com", "c@example.com"} {
+		if _, err := repo.FetchUser(ctx, email, repository.FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counter.fetches != 3 {
+		t.Fatalf("%d fetches, want 3", counter.fetches)
+	}
+	for _, tt := range []struct {
+		email  string
+		cached bool
+	}{{"a@example.com", true}, {"b@example.com", false}, {"c@example.com", true}} {
+		if _, cached := repo.get(tt.email); cached != tt.cached {
+			t.Errorf("%s cached = %v, want %v", tt.email, cached, tt.cached)
+		}
+	}
+}
+
+func TestCachedUsersAreCopies(t *testing.T) {
+	repo, _, _ := newTestRepository(t, time.Minute, 10, models.User{Email: "a@example.com", FirstName: "Ann", Metadata: map[string]string{"plan": "free"}})
+	ctx := context.Background()
+
+	user, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.FirstName = "Changed"
+	user.Metadata["plan"] = "pro"
+
+	cached, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.FirstName != "Ann" || cached.Metadata["plan"] != "free" {
+		t.Errorf("cached user = %+v, want it unchanged", cached)
+	}
+}
@@ -0,0 +1,98 @@
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+var (
+	ErrorCouldNotPublishEvent = "could not publish change event"
+)
+
+// EventSource is the EventBridge source of every published change event.
+const EventSource = "serverless-go.users"
+
+// ChangeType classifies a change to a user record.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// ChangeEvent is the normalized description of a user change forwarded to downstream systems.
+// Old is absent for creations and New is absent for hard deletions.
+type ChangeEvent struct {
+	Type       ChangeType   `json:"type"`
+	Email      string       `json:"email"`
+	Old        *models.User `json:"old,omitempty"`
+	New        *models.User `json:"new,omitempty"`
+	OccurredAt string       `json:"occurredAt"` // RFC3339, when the change was written to the table
+}
+
+// Publisher forwards change events to downstream consumers.
+type Publisher interface {
+	Publish(ctx context.Context, event ChangeEvent) error
+}
+
+// EventBridgePublisher publishes change events to an EventBridge bus.
+// The detail type is "User" followed by the capitalized change type, e.g. "UserCreated".
+type EventBridgePublisher struct {
+	client  eventbridgeiface.EventBridgeAPI
+	busName string
+}
+
+// NewEventBridgePublisher creates a new EventBridgePublisher instance.
+func NewEventBridgePublisher(client eventbridgeiface.EventBridgeAPI, busName string) *EventBridgePublisher {
+	return &EventBridgePublisher{
+		client:  client,
+		busName: busName,
+	}
+}
+
+// Publish sends a single change event. PutEvents reports per-entry failures in the response
+// rather than as an error, so those are surfaced as errors too.
+func (p *EventBridgePublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrorCouldNotPublishEvent, err)
+	}
+
+	result, err := p.client.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{{
+			EventBusName: aws.String(p.busName),
+			Source:       aws.String(EventSource),
+			DetailType:   aws.String(detailType(event.Type)),
+			Detail:       aws.String(string(detail)),
+		}},
+	})
+	if err != nil {
+		slog.Error("EventBridge PutEvents failed", slog.String("operation", "Publish"), slog.Any("error", err))
+		return fmt.Errorf("%s: %w", ErrorCouldNotPublishEvent, err)
+	}
+	if aws.Int64Value(result.FailedEntryCount) > 0 {
+		entry := result.Entries[0]
+		return fmt.Errorf("%s: %s: %s", ErrorCouldNotPublishEvent, aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+	}
+	return nil
+}
+
+// detailType maps a change type to its EventBridge detail type.
+func detailType(changeType ChangeType) string {
+	switch changeType {
+	case ChangeCreated:
+		return "UserCreated"
+	case ChangeDeleted:
+		return "UserDeleted"
+	default:
+		return "UserUpdated"
+	}
+}
@@ -0,0 +1,168 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// ciphertextPrefix marks an encrypted attribute value. Values without it are plaintext written
+// before encryption was enabled, and are read as they are.
+const ciphertextPrefix = "enc:v1:"
+
+// encryptionContext is bound to every data key, so KMS refuses to decrypt keys made for another purpose.
+var encryptionContext = map[string]*string{"purpose": aws.String("serverless-go/user-fields")}
+
+// ErrMalformedCiphertext is returned for an encrypted value that cannot be parsed or authenticated.
+var ErrMalformedCiphertext = errors.New("malformed encrypted value")
+
+// FieldEncryptor encrypts attribute values with envelope encryption: values are sealed locally with
+// AES-256-GCM under a data key generated by KMS, and the KMS-encrypted data key is stored with each
+// value. One data key is generated per encryptor (that is, per Lambda container), and decrypted data
+// keys are cached, so KMS is called once per container and key rather than once per value.
+type FieldEncryptor struct {
+	client kmsiface.KMSAPI
+	keyID  string
+
+	mu      sync.Mutex
+	current *dataKey               // data key used for encryption, generated on first use
+	keys    map[string]cipher.AEAD // decrypted data keys by their encrypted form
+}
+
+// dataKey is a plaintext data key ready for use, with its KMS-encrypted form as stored in values.
+type dataKey struct {
+	encrypted string // base64
+	aead      cipher.AEAD
+}
+
+// NewFieldEncryptor creates a FieldEncryptor whose data keys are protected by the KMS key keyID
+// (a key ID, ARN or alias).
+func NewFieldEncryptor(client kmsiface.KMSAPI, keyID string) *FieldEncryptor {
+	return &FieldEncryptor{
+		client: client,
+		keyID:  keyID,
+		keys:   make(map[string]cipher.AEAD),
+	}
+}
+
+// Encrypt seals plaintext as the value of the attribute field, which is authenticated with it so a
+// value cannot be moved to another attribute. The empty string is left as it is, so absent
+// optional attributes stay absent.
+func (e *FieldEncryptor) Encrypt(ctx context.Context, field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	key, err := e.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return ciphertextPrefix + key.encrypted + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt for the same field. Plaintext values are returned
+// unchanged, so records written before encryption was enabled remain readable.
+func (e *FieldEncryptor) Decrypt(ctx context.Context, field, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, ciphertextPrefix)
+	if !ok {
+		return value, nil
+	}
+	encryptedKey, encodedSealed, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrMalformedCiphertext
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encodedSealed)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMalformedCiphertext, err)
+	}
+	aead, err := e.keyFor(ctx, encryptedKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedCiphertext
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMalformedCiphertext, err)
+	}
+	return string(plaintext), nil
+}
+
+// currentKey returns the data key used for encryption, generating it with KMS on first use.
+func (e *FieldEncryptor) currentKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current != nil {
+		return e.current, nil
+	}
+
+	output, err := e.client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(e.keyID),
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generating data key: %w", err)
+	}
+	aead, err := newAEAD(output.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := base64.StdEncoding.EncodeToString(output.CiphertextBlob)
+	e.current = &dataKey{encrypted: encrypted, aead: aead}
+	e.keys[encrypted] = aead
+	return e.current, nil
+}
+
+// keyFor returns the data key whose KMS-encrypted form is encrypted, decrypting it with KMS on first use.
+func (e *FieldEncryptor) keyFor(ctx context.Context, encrypted string) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if aead, ok := e.keys[encrypted]; ok {
+		return aead, nil
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedCiphertext, err)
+	}
+	output, err := e.client.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(e.keyID),
+		CiphertextBlob:    blob,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decrypting data key: %w", err)
+	}
+	aead, err := newAEAD(output.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e.keys[encrypted] = aead
+	return aead, nil
+}
+
+// newAEAD creates an AES-GCM cipher from a 256-bit data key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
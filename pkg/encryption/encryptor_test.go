@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+const testKeyARN = "arn:aws:kms:us-east-1:123456789012:key/test"
+
+var errKMSUnavailable = errors.New("KMS unavailable")
+
+// mockKMS generates random data keys and decrypts the ones it generated, counting the calls.
+// Its data keys are only decrypted under the key ID and encryption context they were made with.
+type mockKMS struct {
+	kmsiface.KMSAPI
+	keys      map[string][]byte // plaintext data keys by their "encrypted" form
+	err       error
+	generated int
+	decrypted int
+}
+
+func newMockKMS() *mockKMS {
+	return &mockKMS{keys: make(map[string][]byte)}
+}
+
+func (m *mockKMS) GenerateDataKeyWithContext(_ aws.Context, input *kms.GenerateDataKeyInput, _ ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	m.generated++
+	if m.err != nil {
+		return nil, m.err
+	}
+	if aws.StringValue(input.KeyId) != testKeyARN || aws.StringValue(input.KeySpec) != kms.DataKeySpecAes256 {
+		return nil, errors.New("unexpected key request")
+	}
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+	blob := []byte(fmt.Sprintf("data-key-%d", len(m.keys)))
+	m.keys[string(blob)] = plaintext
+	return &kms.GenerateDataKeyOutput{Plaintext: plaintext, CiphertextBlob: blob}, nil
+}
+
+func (m *mockKMS) DecryptWithContext(_ aws.Context, input *kms.DecryptInput, _ ...request.Option) (*kms.DecryptOutput, error) {
+	m.decrypted++
+	if m.err != nil {
+		return nil, m.err
+	}
+	plaintext, ok := m.keys[string(input.CiphertextBlob)]
+	if !ok || aws.StringValue(input.EncryptionContext["purpose"]) != "serverless-go/user-fields" {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext}, nil
+}
+
+func TestFieldEncryptorRoundTrip(t *testing.T) {
+	client := newMockKMS()
+	encryptor := NewFieldEncryptor(client, testKeyARN)
+	ctx := context.Background()
+
+	for _, plaintext := range []string{"Ada", "Zoë-Renée", "山田", strings.Repeat("x", 1000)} {
+		sealed, err := encryptor.Encrypt(ctx, "firstName", plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sealed, ciphertextPrefix) || strings.Contains(sealed, plaintext) {
+			t.Errorf("Encrypt(%q) = %q, want ciphertext", plaintext, sealed)
+		}
+		opened, err := encryptor.Decrypt(ctx, "firstName", sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opened != plaintext {
+			t.Errorf("Decrypt = %q, want %q", opened, plaintext)
+		}
+	}
+	if client.generated != 1 || client.decrypted != 0 {
+		t.Errorf("%d data keys generated and %d decrypted, want one generated and none decrypted", client.generated, client.decrypted)
+	}
+}
+
+func TestFieldEncryptorUsesFreshNonces(t *testing.T) {
+	encryptor := NewFieldEncryptor(newMockKMS(), testKeyARN)
+	first, err := encryptor.Encrypt(context.Background(), "lastName", "Lee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encryptor.Encrypt(context.Background(), "lastName", "Lee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("the same name was encrypted twice as %q", first)
+	}
+}
+
+func TestFieldEncryptorDecryptsAcrossContainers(t *testing.T) {
+	client := newMockKMS()
+	sealed, err := NewFieldEncryptor(client, testKeyARN).Encrypt(context.Background(), "firstName", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another container knows the data key only in its encrypted form, and decrypts it once
+	other := NewFieldEncryptor(client, testKeyARN)
+	for range 3 {
+		opened, err := other.Decrypt(context.Background(), "firstName", sealed)
+		if err != nil || opened != "Ada" {
+			t.Fatalf("Decrypt = %q, %v, want Ada", opened, err)
+		}
+	}
+	if client.decrypted != 1 {
+		t.Errorf("%d data keys decrypted, want 1", client.decrypted)
+	}
+}
+
+func TestFieldEncryptorDecrypt(t *testing.T) {
+	client := newMockKMS()
+	encryptor := NewFieldEncryptor(client, testKeyARN)
+	sealed, err := encryptor.Encrypt(context.Background(), "firstName", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey, _, _ := strings.Cut(strings.TrimPrefix(sealed, ciphertextPrefix), ":")
+	tests := []struct {
+		name    string
+		field   string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "plaintext written before encryption", field: "firstName", value: "Ada", want: "Ada"},
+		{name: "empty", field: "firstName", value: "", want: ""},
+		{name: "ciphertext", field: "firstName", value: sealed, want: "Ada"},
+		{name: "moved to another attribute", field: "lastName", value: sealed, wantErr: ErrMalformedCiphertext},
+		{name: "tampered", field: "firstName", value: sealed[:len(sealed)-4] + "AAA=", wantErr: ErrMalformedCiphertext},
+		{name: "no data key", field: "firstName", value: ciphertextPrefix + "abc", wantErr: ErrMalformedCiphertext},
+		{name: "not base64", field: "firstName", value: ciphertextPrefix + encryptedKey + ":???", wantErr: ErrMalformedCiphertext},
+		{name: "too short", field: "firstName", value: ciphertextPrefix + encryptedKey + ":AAAA", wantErr: ErrMalformedCiphertext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encryptor.Decrypt(context.Background(), tt.field, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldEncryptorKMSFailure(t *testing.T) {
+	client := newMockKMS()
+	sealed, err := NewFieldEncryptor(client, testKeyARN).Encrypt(context.Background(), "firstName", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.err = errKMSUnavailable
+	encryptor := NewFieldEncryptor(client, testKeyARN)
+
+	if _, err := encryptor.Encrypt(context.Background(), "firstName", "Ada"); !errors.Is(err, errKMSUnavailable) {
+		t.Errorf("Encrypt err = %v, want %v", err, errKMSUnavailable)
+	}
+	if _, err := encryptor.Decrypt(context.Background(), "firstName", sealed); !errors.Is(err, errKMSUnavailable) {
+		t.Errorf("Decrypt err = %v, want %v", err, errKMSUnavailable)
+	}
+	// Empty values need no data key
+	if got, err := encryptor.Encrypt(context.Background(), "firstName", ""); err != nil || got != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v, want it left empty", got, err)
+	}
+}
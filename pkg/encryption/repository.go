@@ -0,0 +1,232 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+)
+
+// EncryptedUserRepository decorates a UserRepository, encrypting the users' names before they are
+// written and decrypting them when they are read, so only ciphertext is stored in the table.
+// The email is not encrypted: it is the table's key and must stay queryable.
+type EncryptedUserRepository struct {
+	repository.UserRepository
+	encryptor *FieldEncryptor
+}
+
+// NewEncryptedUserRepository wraps repo so that user names are encrypted with encryptor.
+func NewEncryptedUserRepository(repo repository.UserRepository, encryptor *FieldEncryptor) *EncryptedUserRepository {
+	return &EncryptedUserRepository{
+		UserRepository: repo,
+		encryptor:      encryptor,
+	}
+}
+
+// FetchUser decrypts the user returned by UserRepository.FetchUser.
+func (r *EncryptedUserRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (*models.User, error) {
+	user, err := r.UserRepository.FetchUser(ctx, email, opts)
+	if err != nil {
+		return nil, err
+	}
+	return user, r.decryptUser(ctx, user)
+}
+
+// FetchUsers decrypts the users returned by UserRepository.FetchUsers.
+func (r *EncryptedUserRepository) FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts repository.FetchOptions) ([]models.User, string, error) {
+	users, next, err := r.UserRepository.FetchUsers(ctx, limit, lastEvaluatedKey, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return users, next, r.decryptUsers(ctx, users)
+}
+
+// FetchUsersByLastName is not available on encrypted tables: the lastName index holds ciphertext,
+// which is different for every write of the same name.
+func (r *EncryptedUserRepository) FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) ([]models.User, string, error) {
+	return nil, "", fmt.Errorf("%w: last names are encrypted and cannot be queried", repository.ErrIndexNotConfigured)
+}
+
+// FetchUsersByEmailPrefix decrypts the users returned by UserRepository.FetchUsersByEmailPrefix.
+func (r *EncryptedUserRepository) FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) ([]models.User, string, error) {
+	users, next, err := r.UserRepository.FetchUsersByEmailPrefix(ctx, prefix, limit, lastEvaluatedKey, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return users, next, r.decryptUsers(ctx, users)
+}
+
+// SearchUsers is not available on encrypted tables either: the search tokens are the words of the
+// ciphertext, not of the names.
+func (r *EncryptedUserRepository) SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) ([]models.User, string, error) {
+	return nil, "", fmt.Errorf("%w: names are encrypted and cannot be searched", repository.ErrIndexNotConfigured)
+}
+
+// FetchUsersByEmails decrypts the users returned by UserRepository.FetchUsersByEmails.
+func (r *EncryptedUserRepository) FetchUsersByEmails(ctx context.Context, emails []string, opts repository.FetchOptions) (*repository.BatchFetchResult, error) {
+	result, err := r.UserRepository.FetchUsersByEmails(ctx, emails, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result, r.decryptUsers(ctx, result.Users)
+}
+
+// ScanAll decrypts each user visited by UserRepository.ScanAll before passing it to fn.
+func (r *EncryptedUserRepository) ScanAll(ctx context.Context, lastEvaluatedKey string, opts repository.FetchOptions, fn func(user models.User) error) error {
+	return r.UserRepository.ScanAll(ctx, lastEvaluatedKey, opts, func(user models.User) error {
+		if err := r.decryptUser(ctx, &user); err != nil {
+			return err
+		}
+		return fn(user)
+	})
+}
+
+// CreateUser encrypts the user's names before UserRepository.CreateUser.
+func (r *EncryptedUserRepository) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	if err := r.encryptUser(ctx, &user); err != nil {
+		return nil, err
+	}
+	created, err := r.UserRepository.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return created, r.decryptUser(ctx, created)
+}
+
+// CreateUsers encrypts the users' names before UserRepository.CreateUsers.
+func (r *EncryptedUserRepository) CreateUsers(ctx context.Context, users []models.User) (*repository.BatchCreateResult, error) {
+	encrypted := make([]models.User, len(users))
+	copy(encrypted, users)
+	for i := range encrypted {
+		if err := r.encryptUser(ctx, &encrypted[i]); err != nil {
+			return nil, err
+		}
+	}
+	result, err := r.UserRepository.CreateUsers(ctx, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return result, r.decryptUsers(ctx, result.Created)
+}
+
+// UpdateUser encrypts the user's names before UserRepository.UpdateUser.
+func (r *EncryptedUserRepository) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	if err := r.encryptUser(ctx, &user); err != nil {
+		return nil, err
+	}
+	updated, err := r.UserRepository.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return updated, r.decryptUser(ctx, updated)
+}
+
+// UpsertUser encrypts the user's names before UserRepository.UpsertUser.
+func (r *EncryptedUserRepository) UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error) {
+	if err := r.encryptUser(ctx, &user); err != nil {
+		return nil, false, err
+	}
+	upserted, created, err := r.UserRepository.UpsertUser(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+	return upserted, created, r.decryptUser(ctx, upserted)
+}
+
+// DeleteUser decrypts the user returned by UserRepository.DeleteUser.
+func (r *EncryptedUserRepository) DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	deleted, err := r.UserRepository.DeleteUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return deleted, r.decryptUser(ctx, deleted)
+}
+
+// TransactWriteUsers encrypts the names of the created and updated users before
+// UserRepository.TransactWriteUsers.
+func (r *EncryptedUserRepository) TransactWriteUsers(ctx context.Context, ops []repository.UserOperation) error {
+	encrypted := make([]repository.UserOperation, len(ops))
+	copy(encrypted, ops)
+	for i := range encrypted {
+		if err := r.encryptUser(ctx, &encrypted[i].User); err != nil {
+			return err
+		}
+	}
+	return r.UserRepository.TransactWriteUsers(ctx, encrypted)
+}
+
+// RestoreUser decrypts the user returned by UserRepository.RestoreUser.
+func (r *EncryptedUserRepository) RestoreUser(ctx context.Context, email string) (*models.User, error) {
+	restored, err := r.UserRepository.RestoreUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return restored, r.decryptUser(ctx, restored)
+}
+
+// ChangeEmail decrypts the user returned by UserRepository.ChangeEmail. The names' ciphertext is
+// bound to the attribute, not the email, so it moves along unchanged.
+func (r *EncryptedUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	moved, err := r.UserRepository.ChangeEmail(ctx, oldEmail, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	return moved, r.decryptUser(ctx, moved)
+}
+
+// MergeUsers decrypts the user returned by UserRepository.MergeUsers. Names are merged as
+// ciphertext, which is bound to the attribute and so stays readable in the primary.
+func (r *EncryptedUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error) {
+	merged, err := r.UserRepository.MergeUsers(ctx, primaryEmail, duplicateEmail)
+	if err != nil {
+		return nil, err
+	}
+	return merged, r.decryptUser(ctx, merged)
+}
+
+// Ping forwards the health check when the wrapped repository supports one.
+func (r *EncryptedUserRepository) Ping(ctx context.Context) error {
+	pinger, ok := r.UserRepository.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return pinger.Ping(ctx)
+}
+
+// encryptUser replaces the user's names with their ciphertext.
+func (r *EncryptedUserRepository) encryptUser(ctx context.Context, user *models.User) (err error) {
+	if user.FirstName, err = r.encryptor.Encrypt(ctx, "firstName", user.FirstName); err != nil {
+		return fmt.Errorf("encrypting firstName: %w", err)
+	}
+	if user.LastName, err = r.encryptor.Encrypt(ctx, "lastName", user.LastName); err != nil {
+		return fmt.Errorf("encrypting lastName: %w", err)
+	}
+	return nil
+}
+
+// decryptUser replaces the ciphertext of the user's names with their plaintext. A nil user is left as it is.
+func (r *EncryptedUserRepository) decryptUser(ctx context.Context, user *models.User) (err error) {
+	if user == nil {
+		return nil
+	}
+	if user.FirstName, err = r.encryptor.Decrypt(ctx, "firstName", user.FirstName); err != nil {
+		return fmt.Errorf("decrypting firstName of %s: %w", user.Email, err)
+	}
+	if user.LastName, err = r.encryptor.Decrypt(ctx, "lastName", user.LastName); err != nil {
+		return fmt.Errorf("decrypting lastName of %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+// decryptUsers decrypts each of users in place.
+func (r *EncryptedUserRepository) decryptUsers(ctx context.Context, users []models.User) error {
+	for i := range users {
+		if err := r.decryptUser(ctx, &users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
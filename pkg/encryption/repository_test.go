@@ -0,0 +1,109 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+)
+
+// newTestRepository returns an EncryptedUserRepository over an in-memory repository, which is
+// returned as well to inspect what is stored.
+func newTestRepository() (*EncryptedUserRepository, *repository.InMemoryUserRepository) {
+	stored := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{})
+	return NewEncryptedUserRepository(stored, NewFieldEncryptor(newMockKMS(), testKeyARN)), stored
+}
+
+func TestEncryptedUserRepositoryStoresCiphertext(t *testing.T) {
+	repo, stored := newTestRepository()
+	ctx := context.Background()
+
+	created, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.FirstName != "Ada" || created.LastName != "Lovelace" {
+		t.Errorf("created = %+v, want the plaintext names", created)
+	}
+
+	raw, err := stored.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, value := range map[string]string{"firstName": raw.FirstName, "lastName": raw.LastName} {
+		if !strings.HasPrefix(value, ciphertextPrefix) || strings.Contains(value, "Ada") || strings.Contains(value, "Lovelace") {
+			t.Errorf("stored %s = %q, want ciphertext", field, value)
+		}
+	}
+	if raw.Email != "a@example.com" {
+		t.Errorf("stored email = %q, want it in plaintext", raw.Email)
+	}
+
+	fetched, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.FirstName != "Ada" || fetched.LastName != "Lovelace" {
+		t.Errorf("fetched = %+v, want the plaintext names", fetched)
+	}
+}
+
+func TestEncryptedUserRepositoryRoundTrips(t *testing.T) {
+	repo, _ := newTestRepository()
+	ctx := context.Background()
+	created, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee", Version: created.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.FirstName != "Ann" {
+		t.Errorf("updated first name = %q, want Ann", updated.FirstName)
+	}
+	users, _, err := repo.FetchUsers(ctx, 10, "", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].FirstName != "Ann" || users[0].LastName != "Lee" {
+		t.Errorf("users = %+v, want Ann Lee", users)
+	}
+	deleted, err := repo.DeleteUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.FirstName != "Ann" {
+		t.Errorf("deleted first name = %q, want Ann", deleted.FirstName)
+	}
+}
+
+func TestEncryptedUserRepositoryReadsPlaintextRecords(t *testing.T) {
+	repo, stored := newTestRepository()
+	// Written before encryption was enabled
+	if _, err := stored.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.FirstName != "Ada" || fetched.LastName != "Lee" {
+		t.Errorf("fetched = %+v, want the names as stored", fetched)
+	}
+}
+
+func TestEncryptedUserRepositoryCannotQueryNames(t *testing.T) {
+	repo, _ := newTestRepository()
+	if _, _, err := repo.FetchUsersByLastName(context.Background(), "Lee", 10, "", repository.FetchOptions{}); !errors.Is(err, repository.ErrIndexNotConfigured) {
+		t.Errorf("FetchUsersByLastName err = %v, want %v", err, repository.ErrIndexNotConfigured)
+	}
+	if _, _, err := repo.SearchUsers(context.Background(), "Lee", 10, "", repository.FetchOptions{}); !errors.Is(err, repository.ErrIndexNotConfigured) {
+		t.Errorf("SearchUsers err = %v, want %v", err, repository.ErrIndexNotConfigured)
+	}
+}
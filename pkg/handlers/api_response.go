@@ -2,29 +2,91 @@ package handlers
 
 import (
 	"encoding/json"
-	"log" // Added for logging errors during JSON marshaling
-  "net/http"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
 	"github.com/aws/aws-lambda-go/events"
 )
 
 // ErrorBody represents a standardized error response structure.
+// Code is one of the stable ErrorCode values, for clients to match on instead of the message.
 type ErrorBody struct {
-	ErrorMsg *string `json:"error,omitempty"`
+	ErrorMsg *string   `json:"error,omitempty"`
+	Code     ErrorCode `json:"code,omitempty"`
+}
+
+// ValidationErrorBody is the response structure for requests that failed validation.
+// Its Code is always CodeValidationFailed.
+type ValidationErrorBody struct {
+	Code   ErrorCode                   `json:"code"`
+	Errors validators.ValidationErrors `json:"errors"`
+}
+
+// UserWithWarnings is the response structure for a written user whose request drew validation
+// warnings. The user's fields are inlined, so clients that ignore warnings read it as a plain user.
+type UserWithWarnings struct {
+	*models.User
+	Warnings validators.ValidationWarnings `json:"warnings"`
+}
+
+// withWarnings returns the response body for a written user: the user itself, or a UserWithWarnings
+// when there are warnings.
+func withWarnings(user *models.User, warnings validators.ValidationWarnings) interface{} {
+	if len(warnings) == 0 {
+		return user
+	}
+	return UserWithWarnings{User: user, Warnings: warnings}
+}
+
+// BatchFailure is an item of a batch request that could not be processed, with the reason.
+type BatchFailure struct {
+	Email string    `json:"email"`
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code"`
+}
+
+// BatchResult is the response structure of every batch endpoint: the items that were processed,
+// in the endpoint's representation, and the ones that were not.
+type BatchResult struct {
+	Succeeded interface{}    `json:"succeeded"`
+	Failed    []BatchFailure `json:"failed"`
+	// Warnings are the validation warnings of the items, for batches that validate users.
+	Warnings validators.ValidationWarnings `json:"warnings,omitempty"`
+}
+
+// batchResponse answers a batch request with a BatchResult: with status when every item was
+// processed, and with 207 Multi-Status when some (or all) of them failed.
+func batchResponse(status int, result BatchResult) (*events.APIGatewayProxyResponse, error) {
+	if result.Failed == nil {
+		result.Failed = []BatchFailure{}
+	}
+	if len(result.Failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	return apiResponse(status, result)
 }
 
 // apiResponse creates a standardized APIGatewayProxyResponse.
-func apiResponse(status int, body interface{}) (*events.APIGatewayProxyResponse, error) {
+// Content-Type defaults to application/json; any headers given are merged in on top and may override it.
+func apiResponse(status int, body interface{}, headers ...map[string]string) (*events.APIGatewayProxyResponse, error) {
 	resp := events.APIGatewayProxyResponse{
 		Headers: map[string]string{"Content-Type": "application/json"},
 	}
+	for _, h := range headers {
+		maps.Copy(resp.Headers, h)
+	}
 	resp.StatusCode = status
 
 	// Marshal the body to JSON. Handle potential errors during marshaling.
 	stringBody, err := json.Marshal(body)
 	if err != nil {
-		log.Printf("Error marshaling response body: %v", err)
+		slog.Error("Could not marshal response body", slog.String("operation", "apiResponse"), slog.Any("error", err))
 		// Fallback to a generic error message if the original body couldn't be marshaled
-		errorJson, _ := json.Marshal(ErrorBody{ErrorMsg: StringPtr("Failed to marshal response body")})
+		errorJson, _ := json.Marshal(ErrorBody{ErrorMsg: StringPtr("Failed to marshal response body"), Code: CodeInternalError})
 		resp.Body = string(errorJson)
 		resp.StatusCode = 500 // Internal Server Error
 		return &resp, nil
@@ -34,12 +96,13 @@ func apiResponse(status int, body interface{}) (*events.APIGatewayProxyResponse,
 	return &resp, nil
 }
 
-// UnhandledMethod returns a 405 Method Not Allowed response.
-func UnhandledMethod() (*events.APIGatewayProxyResponse, error) {
-	return apiResponse(http.StatusMethodNotAllowed, ErrorBody{ErrorMsg: StringPtr("Method Not Allowed")})
+// UnhandledMethod returns a 405 Method Not Allowed response listing the allowed methods in its Allow header.
+func UnhandledMethod(allowed ...string) (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusMethodNotAllowed, ErrorBody{ErrorMsg: StringPtr("Method Not Allowed"), Code: CodeMethodNotAllowed},
+		map[string]string{"Allow": strings.Join(allowed, ", ")})
 }
 
 // Helper to get a pointer to a string.
 func StringPtr(s string) *string {
 	return &s
-}
\ No newline at end of file
+}
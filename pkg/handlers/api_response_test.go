@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/validators"
+)
+
+func TestAPIResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"Content-Type": "application/json"},
+		},
+		{
+			name:    "extra headers",
+			headers: []map[string]string{{"ETag": `"v1"`, "Cache-Control": "no-store"}},
+			want:    map[string]string{"Content-Type": "application/json", "ETag": `"v1"`, "Cache-Control": "no-store"},
+		},
+		{
+			name:    "Content-Type overridden",
+			headers: []map[string]string{{"Content-Type": "text/csv"}},
+			want:    map[string]string{"Content-Type": "text/csv"},
+		},
+		{
+			name:    "later maps win",
+			headers: []map[string]string{{"Location": "/users/a@example.com"}, {"Location": "/users/b@example.com"}},
+			want:    map[string]string{"Content-Type": "application/json", "Location": "/users/b@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := apiResponse(http.StatusOK, map[string]string{"ok": "yes"}, tt.headers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK || resp.Body != `{"ok":"yes"}` {
+				t.Errorf("response = %d %s", resp.StatusCode, resp.Body)
+			}
+			if !maps.Equal(resp.Headers, tt.want) {
+				t.Errorf("headers = %v, want %v", resp.Headers, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIResponseDoesNotShareHeaders(t *testing.T) {
+	supplied := map[string]string{"ETag": `"v1"`}
+	resp, err := apiResponse(http.StatusOK, nil, supplied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Headers["Vary"] = "Origin"
+	if _, ok := supplied["Vary"]; ok {
+		t.Error("response headers alias the supplied map")
+	}
+}
+
+func TestAPIResponseUnmarshalableBody(t *testing.T) {
+	resp, err := apiResponse(http.StatusOK, func() {}, map[string]string{"ETag": `"v1"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+	if resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", resp.Headers["Content-Type"])
+	}
+}
+
+func TestBatchResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     BatchResult
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "every item processed",
+			result:     BatchResult{Succeeded: []string{"a@example.com"}},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"succeeded":["a@example.com"],"failed":[]}`,
+		},
+		{
+			name: "mixed results",
+			result: BatchResult{
+				Succeeded: []string{"a@example.com"},
+				Failed:    []BatchFailure{{Email: "b@example.com", Error: "User not found", Code: CodeUserNotFound}},
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody:   `{"succeeded":["a@example.com"],"failed":[{"email":"b@example.com","error":"User not found","code":"USER_NOT_FOUND"}]}`,
+		},
+		{
+			name: "every item failed",
+			result: BatchResult{
+				Succeeded: []string{},
+				Failed:    []BatchFailure{{Email: "b@example.com", Error: "User not found", Code: CodeUserNotFound}},
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody:   `{"succeeded":[],"failed":[{"email":"b@example.com","error":"User not found","code":"USER_NOT_FOUND"}]}`,
+		},
+		{
+			name: "warnings",
+			result: BatchResult{
+				Succeeded: []string{"a@mailinator.com"},
+				Warnings:  validators.ValidationWarnings{{Field: "[0].email", Message: "disposable"}},
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"succeeded":["a@mailinator.com"],"failed":[],"warnings":[{"field":"[0].email","message":"disposable"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := batchResponse(http.StatusCreated, tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.Body != tt.wantBody {
+				t.Errorf("body = %s, want %s", resp.Body, tt.wantBody)
+			}
+		})
+	}
+}
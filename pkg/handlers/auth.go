@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Authenticator guards the user routes with a bearer JWT.
+type Authenticator struct {
+	verifier *auth.Verifier
+}
+
+// NewAuthenticator creates an Authenticator that checks tokens with verifier.
+func NewAuthenticator(verifier *auth.Verifier) Authenticator {
+	return Authenticator{
+		verifier: verifier,
+	}
+}
+
+// Authenticate verifies the Authorization header. On success it returns ctx carrying the claims
+// for the handlers; otherwise it returns a 401 response to send instead of dispatching.
+func (a Authenticator) Authenticate(ctx context.Context, req events.APIGatewayProxyRequest) (context.Context, *events.APIGatewayProxyResponse) {
+	claims, err := a.verifier.VerifyHeader(requestHeader(req, "Authorization"))
+	if err != nil {
+		slog.Info("Rejected unauthenticated request", slog.String("operation", "Authenticate"), slog.Any("error", err))
+		resp, _ := apiResponse(http.StatusUnauthorized, ErrorBody{
+			ErrorMsg: StringPtr("Missing or invalid bearer token"),
+			Code:     CodeUnauthorized,
+		}, map[string]string{"WWW-Authenticate": `Bearer realm="users"`})
+		return ctx, resp
+	}
+	return auth.WithClaims(ctx, claims), nil
+}
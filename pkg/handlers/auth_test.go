@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthenticate(t *testing.T) {
+	const secret = "test-secret"
+	verifier, err := auth.NewVerifier(secret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(expiresAt time.Time) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+			Role:             "admin",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "a@example.com", ExpiresAt: jwt.NewNumericDate(expiresAt)},
+		}).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	valid := token(time.Now().Add(time.Hour))
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantOK  bool
+	}{
+		{name: "valid token", headers: map[string]string{"Authorization": "Bearer " + valid}, wantOK: true},
+		{name: "lower-case header", headers: map[string]string{"authorization": "Bearer " + valid}, wantOK: true},
+		{name: "missing token"},
+		{name: "expired token", headers: map[string]string{"Authorization": "Bearer " + token(time.Now().Add(-time.Minute))}},
+		{name: "tampered token", headers: map[string]string{"Authorization": "Bearer " + valid[:len(valid)-4] + "AAAA"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, resp := NewAuthenticator(verifier).Authenticate(context.Background(), events.APIGatewayProxyRequest{Headers: tt.headers})
+			claims := auth.ClaimsFromContext(ctx)
+			if tt.wantOK {
+				if resp != nil {
+					t.Fatalf("status = %d, want the request dispatched", resp.StatusCode)
+				}
+				if claims == nil || claims.Subject != "a@example.com" || claims.Role != "admin" {
+					t.Errorf("claims = %+v", claims)
+				}
+				return
+			}
+			if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("response = %+v, want %d", resp, http.StatusUnauthorized)
+			}
+			if got := decodeResponse[ErrorBody](t, resp).Code; got != CodeUnauthorized {
+				t.Errorf("code = %s, want %s", got, CodeUnauthorized)
+			}
+			if resp.Headers["WWW-Authenticate"] == "" {
+				t.Error("no WWW-Authenticate header")
+			}
+			if claims != nil {
+				t.Errorf("claims = %+v, want none", claims)
+			}
+		})
+	}
+}
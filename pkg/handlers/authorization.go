@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// canModify reports whether the caller may update or delete the user with the given email.
+// Callers may only modify their own record (the token subject) unless their role claim is admin.
+// Without claims in ctx authentication is disabled, and every request is allowed.
+func canModify(ctx context.Context, email string) bool {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil || models.Role(claims.Role) == models.RoleAdmin {
+		return true
+	}
+	return validators.NormalizeEmail(claims.Subject) == validators.NormalizeEmail(email)
+}
+
+// canAssignRole reports whether the caller may write role onto a record. Non-admins may only
+// keep their own role, so an update can never be used to escalate privileges.
+func canAssignRole(ctx context.Context, role models.Role) bool {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil || models.Role(claims.Role) == models.RoleAdmin {
+		return true
+	}
+	return role == "" || role == models.Role(claims.Role)
+}
+
+// isAdmin reports whether the caller holds the admin role, or authentication is disabled.
+func isAdmin(ctx context.Context) bool {
+	claims := auth.ClaimsFromContext(ctx)
+	return claims == nil || models.Role(claims.Role) == models.RoleAdmin
+}
+
+// forbidden rejects an authenticated caller that is not allowed to perform the request.
+func forbidden(message string) (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusForbidden, ErrorBody{
+		ErrorMsg: StringPtr(message),
+		Code:     CodeForbidden,
+	})
+}
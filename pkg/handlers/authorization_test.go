@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOwnershipChecks(t *testing.T) {
+	const target = "a@example.com"
+	tests := []struct {
+		name       string
+		claims     *auth.Claims
+		body       string
+		wantUpdate int
+		wantDelete int
+	}{
+		{
+			name:       "owner",
+			claims:     &auth.Claims{Role: "viewer", RegisteredClaims: jwt.RegisteredClaims{Subject: "A@Example.com"}},
+			wantUpdate: http.StatusOK,
+			wantDelete: http.StatusNoContent,
+		},
+		{
+			name:       "non-owner",
+			claims:     &auth.Claims{Role: "editor", RegisteredClaims: jwt.RegisteredClaims{Subject: "b@example.com"}},
+			wantUpdate: http.StatusForbidden,
+			wantDelete: http.StatusForbidden,
+		},
+		{
+			name:       "admin",
+			claims:     &auth.Claims{Role: "admin", RegisteredClaims: jwt.RegisteredClaims{Subject: "b@example.com"}},
+			wantUpdate: http.StatusOK,
+			wantDelete: http.StatusNoContent,
+		},
+		{name: "authentication disabled", wantUpdate: http.StatusOK, wantDelete: http.StatusNoContent},
+		{
+			name:       "owner raising their role",
+			claims:     &auth.Claims{Role: "viewer", RegisteredClaims: jwt.RegisteredClaims{Subject: target}},
+			body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","role":"admin"}`,
+			wantUpdate: http.StatusForbidden,
+			wantDelete: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: target, FirstName: "Ada", LastName: "Lee"})
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = auth.WithClaims(ctx, tt.claims)
+			}
+			body := tt.body
+			if body == "" {
+				body = `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`
+			}
+			path := map[string]string{"email": target}
+
+			resp, err := h.UpdateUser(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodPut, PathParameters: path, Body: body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantUpdate {
+				t.Errorf("update status = %d, want %d (body %s)", resp.StatusCode, tt.wantUpdate, resp.Body)
+			}
+			if resp.StatusCode == http.StatusForbidden {
+				if got := decodeResponse[ErrorBody](t, resp).Code; got != CodeForbidden {
+					t.Errorf("code = %s, want %s", got, CodeForbidden)
+				}
+			}
+
+			resp, err = h.DeleteUser(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodDelete, PathParameters: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantDelete {
+				t.Errorf("delete status = %d, want %d (body %s)", resp.StatusCode, tt.wantDelete, resp.Body)
+			}
+		})
+	}
+}
+
+func TestPurgeUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		allowed    bool
+		wantStatus int
+		wantLeft   int
+	}{
+		{name: "admin", role: "admin", allowed: true, wantStatus: http.StatusOK},
+		{name: "editor", role: "editor", allowed: true, wantStatus: http.StatusForbidden, wantLeft: 2},
+		{name: "destructive operations disabled", role: "admin", wantStatus: http.StatusForbidden, wantLeft: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{AllowDestructiveOps: tt.allowed})
+			for _, email := range []string{"a@example.com", "b@example.com"} {
+				if _, err := repo.CreateUser(context.Background(), models.User{Email: email}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h := NewUserHandler(repo, UserHandlerOptions{})
+			ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: tt.role, RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+
+			resp, err := h.PurgeUsers(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodDelete})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if body := decodeResponse[PurgeResult](t, resp); body.Deleted != 2 {
+					t.Errorf("deleted = %d, want 2", body.Deleted)
+				}
+			}
+			users, _, err := repo.FetchUsers(context.Background(), 10, "", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.wantLeft {
+				t.Errorf("%d users left, want %d", len(users), tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestMigrateUsers(t *testing.T) {
+	tests := []struct {
+		role       string
+		wantStatus int
+	}{
+		{role: "admin", wantStatus: http.StatusOK},
+		{role: "editor", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann"})
+			ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: tt.role, RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+
+			resp, err := h.MigrateUsers(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			// Users created by this version have nothing to backfill
+			if tt.wantStatus == http.StatusOK {
+				if body := decodeResponse[MigrationResult](t, resp); body.Migrated != 0 {
+					t.Errorf("migrated = %d, want 0", body.Migrated)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// BulkUpdateRequest is the request body of BulkUpdateUsers.
+type BulkUpdateRequest struct {
+	Filter repository.UserFilter `json:"filter"`
+	Update repository.UserPatch  `json:"update"`
+}
+
+// BulkUpdateResult is the response body of BulkUpdateUsers.
+type BulkUpdateResult struct {
+	repository.BulkUpdateResult
+	DryRun bool `json:"dryRun"`
+}
+
+// BulkUpdateUsers handles PATCH /users, applying a partial update (role, locale or timezone) to
+// every live user matching a filter on the same fields, e.g. demoting all editors to viewers.
+// With ?dryRun=true the matching users are only counted. It is only available to admins.
+func (h *UserHandler) BulkUpdateUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, bulkUpdateParams...); invalid != nil {
+		return invalid, nil
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may update users in bulk")
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	var body BulkUpdateRequest
+	if err := decodeJSON(req.Body, &body); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	var errs validators.ValidationErrors
+	if body.Filter.IsEmpty() {
+		errs = append(errs, validators.FieldError{Field: "filter", Message: "at least one of role, locale or timezone is required"})
+	}
+	if body.Update.IsEmpty() {
+		errs = append(errs, validators.FieldError{Field: "update", Message: "at least one of role, locale or timezone is required"})
+	}
+	errs = append(errs, validateBulkFields("filter.", body.Filter.Role, body.Filter.Locale, body.Filter.Timezone)...)
+	errs = append(errs, validateBulkFields("update.", body.Update.Role, body.Update.Locale, body.Update.Timezone)...)
+	if len(errs) > 0 {
+		return validationFailed(errs)
+	}
+
+	dryRun := req.QueryStringParameters["dryRun"] == "true"
+	result, err := h.userRepo.UpdateUsersWhere(ctx, body.Filter, body.Update, dryRun)
+	if err != nil {
+		return repositoryFailure("BulkUpdateUsers", err)
+	}
+	return apiResponse(http.StatusOK, BulkUpdateResult{BulkUpdateResult: result, DryRun: dryRun})
+}
+
+// validateBulkFields checks the fields of a bulk update filter or patch like ValidateUser does,
+// prefixing the field names with prefix.
+func validateBulkFields(prefix string, role models.Role, locale, timezone string) validators.ValidationErrors {
+	var errs validators.ValidationErrors
+	if role != "" && !validators.IsRoleValid(role) {
+		errs = append(errs, validators.FieldError{Field: prefix + "role", Message: fmt.Sprintf("invalid role %q", role)})
+	}
+	if locale != "" && !validators.IsLocaleValid(locale) {
+		errs = append(errs, validators.FieldError{Field: prefix + "locale", Message: "invalid locale; expected a BCP 47 language tag such as en-US"})
+	}
+	if timezone != "" && !validators.IsTimezoneValid(timezone) {
+		errs = append(errs, validators.FieldError{Field: prefix + "timezone", Message: "invalid timezone; expected an IANA time zone such as America/New_York"})
+	}
+	return errs
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestBulkUpdateUsers(t *testing.T) {
+	unchanged := map[string]models.Role{
+		"editor1@example.com": models.RoleEditor,
+		"editor2@example.com": models.RoleEditor,
+		"admin@example.com":   models.RoleAdmin,
+	}
+	tests := []struct {
+		name       string
+		role       string
+		body       string
+		query      map[string]string
+		wantStatus int
+		wantCode   ErrorCode
+		want       BulkUpdateResult
+		wantRoles  map[string]models.Role
+	}{
+		{
+			name:       "admin",
+			role:       "admin",
+			body:       `{"filter":{"role":"editor"},"update":{"role":"viewer"}}`,
+			wantStatus: http.StatusOK,
+			want:       BulkUpdateResult{BulkUpdateResult: repository.BulkUpdateResult{Matched: 2, Updated: 2, Complete: true}},
+			wantRoles: map[string]models.Role{
+				"editor1@example.com": models.RoleViewer,
+				"editor2@example.com": models.RoleViewer,
+				"admin@example.com":   models.RoleAdmin,
+			},
+		},
+		{
+			name:       "dry run",
+			role:       "admin",
+			body:       `{"filter":{"role":"editor"},"update":{"role":"viewer"}}`,
+			query:      map[string]string{"dryRun": "true"},
+			wantStatus: http.StatusOK,
+			want:       BulkUpdateResult{BulkUpdateResult: repository.BulkUpdateResult{Matched: 2, Complete: true}, DryRun: true},
+			wantRoles:  unchanged,
+		},
+		{
+			name:       "editor",
+			role:       "editor",
+			body:       `{"filter":{"role":"editor"},"update":{"role":"viewer"}}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   CodeForbidden,
+			wantRoles:  unchanged,
+		},
+		{
+			name:       "no filter",
+			role:       "admin",
+			body:       `{"update":{"role":"viewer"}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantRoles:  unchanged,
+		},
+		{
+			name:       "invalid role",
+			role:       "admin",
+			body:       `{"filter":{"role":"editor"},"update":{"role":"owner"}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantRoles:  unchanged,
+		},
+		{
+			name:       "unknown query parameter",
+			role:       "admin",
+			body:       `{"filter":{"role":"editor"},"update":{"role":"viewer"}}`,
+			query:      map[string]string{"dryrun": "true"},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidQueryParameter,
+			wantRoles:  unchanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t,
+				models.User{Email: "editor1@example.com", FirstName: "Ann", Role: models.RoleEditor},
+				models.User{Email: "editor2@example.com", FirstName: "Bo", Role: models.RoleEditor},
+				models.User{Email: "admin@example.com", FirstName: "Cy", Role: models.RoleAdmin},
+			)
+			ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: tt.role, RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+
+			resp, err := h.BulkUpdateUsers(ctx, events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodPatch,
+				Body:                  tt.body,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if body := decodeResponse[BulkUpdateResult](t, resp); body != tt.want {
+					t.Errorf("result = %+v, want %+v", body, tt.want)
+				}
+			} else if body := decodeResponse[ErrorBody](t, resp); body.Code != tt.wantCode {
+				t.Errorf("code = %s, want %s", body.Code, tt.wantCode)
+			}
+			for email, want := range tt.wantRoles {
+				user, err := repo.FetchUser(context.Background(), email, repository.FetchOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user.Role != want {
+					t.Errorf("%s role = %s, want %s", email, user.Role, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// cacheControl returns the Cache-Control header value for a successful GetUser response.
+// Without a configured max-age, and for consistent reads, clients must revalidate every time.
+// Authenticated responses are marked private, so shared caches such as CDNs never serve one
+// caller's view to another.
+func (h *UserHandler) cacheControl(ctx context.Context, req events.APIGatewayProxyRequest) string {
+	if h.opts.CacheMaxAge <= 0 || req.QueryStringParameters["consistent"] == "true" {
+		return "no-cache"
+	}
+	visibility := "public"
+	if auth.ClaimsFromContext(ctx) != nil {
+		visibility = "private"
+	}
+	value := fmt.Sprintf("%s, max-age=%d", visibility, int(h.opts.CacheMaxAge.Seconds()))
+	if h.opts.CacheStaleWhileRevalidate > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", int(h.opts.CacheStaleWhileRevalidate.Seconds()))
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGetUserCacheControl(t *testing.T) {
+	admin := auth.WithClaims(context.Background(), &auth.Claims{Role: "admin", RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+	tests := []struct {
+		name       string
+		maxAge     time.Duration
+		stale      time.Duration
+		ctx        context.Context
+		consistent bool
+		want       string
+	}{
+		{name: "not configured", want: "no-cache"},
+		{name: "stale-while-revalidate without max-age", stale: time.Minute, want: "no-cache"},
+		{name: "max-age", maxAge: 30 * time.Second, want: "public, max-age=30"},
+		{name: "max-age and stale-while-revalidate", maxAge: 30 * time.Second, stale: 2 * time.Minute, want: "public, max-age=30, stale-while-revalidate=120"},
+		{name: "authenticated", maxAge: 30 * time.Second, ctx: admin, want: "private, max-age=30"},
+		{name: "consistent read", maxAge: 30 * time.Second, stale: time.Minute, consistent: true, want: "no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+			h.opts.CacheMaxAge, h.opts.CacheStaleWhileRevalidate = tt.maxAge, tt.stale
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			var query map[string]string
+			if tt.consistent {
+				query = map[string]string{"consistent": "true"}
+			}
+
+			for _, req := range []events.APIGatewayProxyRequest{
+				{HTTPMethod: http.MethodGet, PathParameters: map[string]string{"email": "a@example.com"}, QueryStringParameters: query},
+				{HTTPMethod: http.MethodGet, QueryStringParameters: query},
+			} {
+				resp, err := h.GetUser(ctx, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusOK, resp.Body)
+				}
+				if got := resp.Headers["Cache-Control"]; got != tt.want {
+					t.Errorf("GET %v: Cache-Control = %q, want %q", req.PathParameters, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNotModifiedKeepsCacheControl(t *testing.T) {
+	h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+	h.opts.CacheMaxAge = time.Minute
+	req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, PathParameters: map[string]string{"email": "a@example.com"}}
+	read, err := h.GetUser(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Headers = map[string]string{"If-None-Match": read.Headers["ETag"]}
+	resp, err := h.GetUser(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotModified || resp.Headers["Cache-Control"] != "public, max-age=60" {
+		t.Errorf("status = %d, Cache-Control = %q, want %d with public, max-age=60", resp.StatusCode, resp.Headers["Cache-Control"], http.StatusNotModified)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ChangeEmailRequest is the request body of ChangeEmail.
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+// ChangeEmail handles PUT /users/{email}/email, moving the user to the email in the body while
+// keeping its ID and every other field. The user is then only found under the new email, which
+// the Location header points to. A taken email yields 409 Conflict.
+func (h *UserHandler) ChangeEmail(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	oldEmail := validators.NormalizeEmail(requestEmail(req))
+	var body ChangeEmailRequest
+	if err := decodeJSON(req.Body, &body); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	newEmail := validators.NormalizeEmail(body.Email)
+	if !validators.IsEmailValidFor(newEmail, h.opts.Validation.EmailValidation) {
+		return validationFailed(validators.ValidationErrors{{Field: "email", Message: "invalid email format"}})
+	}
+	if !canModify(ctx, oldEmail) {
+		return forbidden("You may only change the email of your own user")
+	}
+
+	moved, err := h.userRepo.ChangeEmail(ctx, oldEmail, newEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserDoesNotExist):
+			return apiResponse(http.StatusNotFound, ErrorBody{
+				ErrorMsg: StringPtr("User not found"),
+				Code:     CodeUserNotFound,
+			})
+		case errors.Is(err, repository.ErrUserAlreadyExists):
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr("A user with this email already exists"),
+				Code:     CodeUserAlreadyExists,
+			})
+		case errors.Is(err, repository.ErrVersionConflict):
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr("The user changed while its email was being changed; please retry"),
+				Code:     CodeConflict,
+			})
+		}
+		return repositoryFailure("ChangeEmail", err)
+	}
+	return apiResponse(http.StatusOK, moved, map[string]string{"Location": userLocation(moved.Email)})
+}
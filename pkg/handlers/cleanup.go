@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CleanupHandler purges soft-deleted users on an EventBridge schedule.
+type CleanupHandler struct {
+	userRepo  repository.UserRepository
+	retention time.Duration
+}
+
+// NewCleanupHandler creates a CleanupHandler that purges users soft-deleted more than retention ago.
+func NewCleanupHandler(userRepo repository.UserRepository, retention time.Duration) CleanupHandler {
+	return CleanupHandler{
+		userRepo:  userRepo,
+		retention: retention,
+	}
+}
+
+// Handle hard-deletes the users whose deletedAt is older than the retention period, measured from
+// the time the event was scheduled. A run that is cut short by its deadline leaves the rest to
+// the next scheduled run.
+func (h CleanupHandler) Handle(ctx context.Context, event events.CloudWatchEvent) error {
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	deletedBefore := now.Add(-h.retention)
+
+	result, err := h.userRepo.PurgeDeletedUsers(ctx, deletedBefore)
+	if err != nil {
+		slog.Error("Failed to purge soft-deleted users",
+			slog.String("operation", "CleanupHandler.Handle"),
+			slog.Int("purged", result.Purged),
+			slog.Any("error", err))
+		return err
+	}
+	slog.Info("Purged soft-deleted users",
+		slog.String("operation", "CleanupHandler.Handle"),
+		slog.Int("purged", result.Purged),
+		slog.Bool("complete", result.Complete),
+		slog.Time("deletedBefore", deletedBefore))
+	return nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// purgeRecorder records the cutoff PurgeDeletedUsers is called with, answering with result and err.
+type purgeRecorder struct {
+	repository.UserRepository
+	deletedBefore time.Time
+	result        repository.PurgeResult
+	err           error
+}
+
+func (r *purgeRecorder) PurgeDeletedUsers(_ context.Context, deletedBefore time.Time) (repository.PurgeResult, error) {
+	r.deletedBefore = deletedBefore
+	return r.result, r.err
+}
+
+func TestCleanupHandlerRetention(t *testing.T) {
+	scheduled := time.Date(2024, 6, 30, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		event   events.CloudWatchEvent
+		err     error
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "measured from the scheduled time",
+			event: events.CloudWatchEvent{Source: "aws.events", DetailType: "Scheduled Event", Time: scheduled},
+			want:  scheduled.Add(-30 * 24 * time.Hour),
+		},
+		{
+			name:    "purge failure",
+			event:   events.CloudWatchEvent{Source: "aws.events", DetailType: "Scheduled Event", Time: scheduled},
+			err:     repository.ErrCouldNotDeleteItem,
+			want:    scheduled.Add(-30 * 24 * time.Hour),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &purgeRecorder{err: tt.err}
+			h := NewCleanupHandler(repo, 30*24*time.Hour)
+
+			err := h.Handle(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if !repo.deletedBefore.Equal(tt.want) {
+				t.Errorf("deletedBefore = %v, want %v", repo.deletedBefore, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanupHandlerWithoutEventTime(t *testing.T) {
+	repo := &purgeRecorder{}
+	h := NewCleanupHandler(repo, time.Hour)
+
+	before := time.Now()
+	if err := h.Handle(context.Background(), events.CloudWatchEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if cutoff := repo.deletedBefore.Add(time.Hour); cutoff.Before(before) || cutoff.After(time.Now()) {
+		t.Errorf("deletedBefore = %v, want an hour before the invocation", repo.deletedBefore)
+	}
+}
+
+func TestCleanupHandlerPurgesExpiredUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventTime  time.Time
+		wantPurged bool
+	}{
+		{name: "within the retention period", eventTime: time.Now()},
+		{name: "past the retention period", eventTime: time.Now().Add(48 * time.Hour), wantPurged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{SoftDelete: true})
+			for _, email := range []string{"deleted@example.com", "live@example.com"} {
+				if _, err := repo.CreateUser(context.Background(), models.User{Email: email, FirstName: "Ann"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := repo.DeleteUser(context.Background(), "deleted@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			h := NewCleanupHandler(repo, 24*time.Hour)
+
+			if err := h.Handle(context.Background(), events.CloudWatchEvent{Source: "aws.events", DetailType: "Scheduled Event", Time: tt.eventTime}); err != nil {
+				t.Fatal(err)
+			}
+			// Whatever the scheduled run left is still there to purge
+			left, err := repo.PurgeDeletedUsers(context.Background(), time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged := left.Purged == 0; purged != tt.wantPurged {
+				t.Errorf("soft-deleted user purged: %v, want %v", purged, tt.wantPurged)
+			}
+			if live, err := repo.FetchUser(context.Background(), "live@example.com", repository.FetchOptions{}); err != nil || live == nil {
+				t.Errorf("FetchUser(live@example.com) = %v, %v, want the live user kept", live, err)
+			}
+		})
+	}
+}
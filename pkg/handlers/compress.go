@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// minCompressBytes is the smallest body worth compressing. Below it the gzip header and
+// base64 overhead outweigh the savings.
+const minCompressBytes = 1024
+
+// Compress gzips the response body when the client accepts gzip and the body is large enough.
+// API Gateway only passes binary bodies through base64, so the compressed body is encoded and
+// IsBase64Encoded is set. Clients without gzip support keep receiving plain JSON.
+func Compress(req events.APIGatewayProxyRequest, resp *events.APIGatewayProxyResponse) {
+	if resp == nil || resp.IsBase64Encoded {
+		return
+	}
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	// Caches must key on Accept-Encoding whether or not this response is compressed
+	addVary(resp.Headers, "Accept-Encoding")
+	if len(resp.Body) < minCompressBytes || !acceptsGzip(requestHeader(req, "Accept-Encoding")) {
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(resp.Body)); err != nil {
+		slog.Error("Could not compress response body", slog.String("operation", "Compress"), slog.Any("error", err))
+		return
+	}
+	if err := writer.Close(); err != nil {
+		slog.Error("Could not compress response body", slog.String("operation", "Compress"), slog.Any("error", err))
+		return
+	}
+
+	resp.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	resp.IsBase64Encoded = true
+	resp.Headers["Content-Encoding"] = "gzip"
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, honoring "q=0" as a refusal.
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, "gzip") && name != "*" {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if q, err := strconv.ParseFloat(value, 64); key == "q" && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// addVary appends value to the Vary header without dropping values set earlier.
+func addVary(headers map[string]string, value string) {
+	existing := headers["Vary"]
+	if existing == "" {
+		headers["Vary"] = value
+		return
+	}
+	for _, current := range strings.Split(existing, ",") {
+		if strings.EqualFold(strings.TrimSpace(current), value) {
+			return
+		}
+	}
+	headers["Vary"] = existing + ", " + value
+}
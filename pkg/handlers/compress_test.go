@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCompress(t *testing.T) {
+	large := `{"users":[` + strings.Repeat(`{"email":"a@example.com","firstName":"Ada"},`, 50) + `{}]}`
+	small := `{"email":"a@example.com"}`
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		wantGzip       bool
+	}{
+		{name: "gzip client", acceptEncoding: "gzip, deflate, br", body: large, wantGzip: true},
+		{name: "wildcard", acceptEncoding: "*", body: large, wantGzip: true},
+		{name: "gzip with a weight", acceptEncoding: "br;q=1.0, gzip;q=0.5", body: large, wantGzip: true},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0", body: large},
+		{name: "no Accept-Encoding", body: large},
+		{name: "other encodings", acceptEncoding: "deflate, br", body: large},
+		{name: "small body", acceptEncoding: "gzip", body: small},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{}
+			if tt.acceptEncoding != "" {
+				req.Headers = map[string]string{"Accept-Encoding": tt.acceptEncoding}
+			}
+			resp := &events.APIGatewayProxyResponse{StatusCode: 200, Body: tt.body, Headers: map[string]string{"Content-Type": "application/json"}}
+
+			Compress(req, resp)
+
+			if resp.Headers["Vary"] != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", resp.Headers["Vary"])
+			}
+			if !tt.wantGzip {
+				if resp.Body != tt.body || resp.IsBase64Encoded || resp.Headers["Content-Encoding"] != "" {
+					t.Errorf("response = %+v, want the plain body", resp)
+				}
+				return
+			}
+			if !resp.IsBase64Encoded || resp.Headers["Content-Encoding"] != "gzip" {
+				t.Fatalf("IsBase64Encoded = %v, Content-Encoding = %q", resp.IsBase64Encoded, resp.Headers["Content-Encoding"])
+			}
+			compressed, err := base64.StdEncoding.DecodeString(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(compressed) >= len(tt.body) {
+				t.Errorf("compressed to %d bytes from %d", len(compressed), len(tt.body))
+			}
+			reader, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decompressed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decompressed) != tt.body {
+				t.Errorf("round trip = %q, want %q", decompressed, tt.body)
+			}
+		})
+	}
+}
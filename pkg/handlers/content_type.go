@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RequireJSON rejects POST, PUT and PATCH requests whose Content-Type is not application/json
+// with 415 Unsupported Media Type, before any handler tries to unmarshal the body.
+// Parameters such as charset are allowed, and requests without a body (such as POST /users/migrations)
+// need no Content-Type. It returns nil when the request may proceed.
+func RequireJSON(req events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	switch req.HTTPMethod {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil
+	}
+	if req.Body == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(requestHeader(req, "Content-Type"))
+	if err == nil && mediaType == "application/json" {
+		return nil
+	}
+	resp, _ := apiResponse(http.StatusUnsupportedMediaType, ErrorBody{
+		ErrorMsg: StringPtr("Content-Type must be application/json"),
+		Code:     CodeUnsupportedMediaType,
+	})
+	return resp
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRequireJSON(t *testing.T) {
+	const body = `{"email":"a@example.com"}`
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantReject  bool
+	}{
+		{name: "JSON", method: http.MethodPost, contentType: "application/json", body: body},
+		{name: "JSON with a charset", method: http.MethodPut, contentType: "application/json; charset=utf-8", body: body},
+		{name: "upper-case media type", method: http.MethodPatch, contentType: "Application/JSON", body: body},
+		{name: "plain text", method: http.MethodPost, contentType: "text/plain", body: body, wantReject: true},
+		{name: "form", method: http.MethodPut, contentType: "application/x-www-form-urlencoded", body: "email=a%40example.com", wantReject: true},
+		{name: "missing Content-Type", method: http.MethodPost, body: body, wantReject: true},
+		{name: "POST without a body", method: http.MethodPost},
+		{name: "GET", method: http.MethodGet, contentType: "text/plain", body: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Body: tt.body}
+			if tt.contentType != "" {
+				req.Headers = map[string]string{"content-type": tt.contentType}
+			}
+			resp := RequireJSON(req)
+			if !tt.wantReject {
+				if resp != nil {
+					t.Errorf("status = %d, want the request accepted", resp.StatusCode)
+				}
+				return
+			}
+			if resp == nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+				t.Fatalf("response = %+v, want %d", resp, http.StatusUnsupportedMediaType)
+			}
+			if got := decodeResponse[ErrorBody](t, resp).Code; got != CodeUnsupportedMediaType {
+				t.Errorf("code = %s, want %s", got, CodeUnsupportedMediaType)
+			}
+		})
+	}
+}
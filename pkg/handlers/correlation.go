@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CorrelationIDHeader is the header carrying the ID that ties a request to its logs and response.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength bounds a client-supplied correlation ID, which ends up in every log line.
+const maxCorrelationIDLength = 128
+
+// CorrelationID returns the correlation ID of req: the X-Correlation-ID header, else the
+// X-Request-ID header, else a new random UUID. IDs that are too long or contain anything but
+// printable ASCII are replaced too, so clients cannot forge log lines or response headers.
+func CorrelationID(req events.APIGatewayProxyRequest) string {
+	for _, name := range []string{CorrelationIDHeader, "X-Request-ID"} {
+		if id := requestHeader(req, name); validCorrelationID(id) {
+			return id
+		}
+	}
+	return newUUID()
+}
+
+// SetCorrelationID echoes the correlation ID on resp, so clients can quote it when reporting a problem.
+func SetCorrelationID(resp *events.APIGatewayProxyResponse, id string) {
+	if resp == nil {
+		return
+	}
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	resp.Headers[CorrelationIDHeader] = id
+}
+
+// validCorrelationID reports whether id is a non-empty, bounded string of printable ASCII.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
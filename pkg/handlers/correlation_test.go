@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// want is the expected ID; empty means a generated UUID.
+		want string
+	}{
+		{name: "provided", headers: map[string]string{"X-Correlation-ID": "abc-123"}, want: "abc-123"},
+		{name: "provided in lower case", headers: map[string]string{"x-correlation-id": "abc-123"}, want: "abc-123"},
+		{name: "request ID fallback", headers: map[string]string{"X-Request-ID": "req-456"}, want: "req-456"},
+		{name: "correlation ID preferred", headers: map[string]string{"X-Correlation-ID": "abc-123", "X-Request-ID": "req-456"}, want: "abc-123"},
+		{name: "invalid correlation ID falls back", headers: map[string]string{"X-Correlation-ID": "abc 123", "X-Request-ID": "req-456"}, want: "req-456"},
+		{name: "longest allowed", headers: map[string]string{"X-Correlation-ID": strings.Repeat("a", maxCorrelationIDLength)}, want: strings.Repeat("a", maxCorrelationIDLength)},
+		{name: "absent"},
+		{name: "empty", headers: map[string]string{"X-Correlation-ID": ""}},
+		{name: "too long", headers: map[string]string{"X-Correlation-ID": strings.Repeat("a", maxCorrelationIDLength+1)}},
+		{name: "line break", headers: map[string]string{"X-Correlation-ID": "abc\nlevel=ERROR"}},
+		{name: "non-ASCII", headers: map[string]string{"X-Request-ID": "abcé"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CorrelationID(events.APIGatewayProxyRequest{Headers: tt.headers})
+			if tt.want != "" {
+				if got != tt.want {
+					t.Errorf("correlation ID = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			if !uuidPattern.MatchString(got) {
+				t.Errorf("correlation ID = %q, want a generated UUID", got)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDGeneratesUniqueIDs(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		id := CorrelationID(events.APIGatewayProxyRequest{})
+		if seen[id] {
+			t.Fatalf("correlation ID %q generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSetCorrelationID(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *events.APIGatewayProxyResponse
+	}{
+		{name: "no headers", resp: &events.APIGatewayProxyResponse{}},
+		{name: "other headers", resp: &events.APIGatewayProxyResponse{Headers: map[string]string{"Content-Type": "application/json"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetCorrelationID(tt.resp, "abc-123")
+			if got := tt.resp.Headers[CorrelationIDHeader]; got != "abc-123" {
+				t.Errorf("%s = %q, want %q", CorrelationIDHeader, got, "abc-123")
+			}
+		})
+	}
+
+	SetCorrelationID(nil, "abc-123") // must not panic
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CORS holds the cross-origin settings applied to API responses.
+type CORS struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+}
+
+// NewCORS creates a CORS policy for the given allowlist of origins.
+// An origin of "*" allows any origin, but the caller's Origin is still echoed back rather than "*".
+func NewCORS(allowedOrigins, allowedMethods, allowedHeaders []string) CORS {
+	return CORS{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: allowedMethods,
+		AllowedHeaders: allowedHeaders,
+	}
+}
+
+// Apply adds CORS headers to resp when the request's Origin is in the allowlist.
+// Disallowed or missing origins leave the response untouched, so browsers block it.
+func (c CORS) Apply(req events.APIGatewayProxyRequest, resp *events.APIGatewayProxyResponse) {
+	if resp == nil {
+		return
+	}
+	origin := requestHeader(req, "Origin")
+	if !c.isAllowed(origin) {
+		return
+	}
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	resp.Headers["Access-Control-Allow-Origin"] = origin
+	resp.Headers["Access-Control-Allow-Methods"] = strings.Join(c.AllowedMethods, ", ")
+	resp.Headers["Access-Control-Allow-Headers"] = strings.Join(c.AllowedHeaders, ", ")
+	// Let browser clients read the correlation ID, which is not a CORS-safelisted header
+	resp.Headers["Access-Control-Expose-Headers"] = CorrelationIDHeader
+	addVary(resp.Headers, "Origin")
+}
+
+// Preflight answers an OPTIONS preflight request with 204 and the CORS headers for the caller's origin.
+func (c CORS) Preflight(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	resp := &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+		Headers:    map[string]string{},
+	}
+	c.Apply(req, resp)
+	return resp, nil
+}
+
+// isAllowed reports whether origin is present in the allowlist.
+func (c CORS) isAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHeader looks up a request header case-insensitively, since API Gateway preserves the client's casing.
+func requestHeader(req events.APIGatewayProxyRequest, name string) string {
+	if value, ok := req.Headers[name]; ok {
+		return value
+	}
+	for key, value := range req.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
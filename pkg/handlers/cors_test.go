@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCORS(t *testing.T) {
+	cors := NewCORS([]string{"https://app.example.com"}, []string{"GET", "POST"}, []string{"Content-Type", "Authorization"})
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantOrigin string
+	}{
+		{name: "allowed origin", headers: map[string]string{"Origin": "https://app.example.com"}, wantOrigin: "https://app.example.com"},
+		{name: "allowed origin in lower-case header", headers: map[string]string{"origin": "https://app.example.com"}, wantOrigin: "https://app.example.com"},
+		{name: "disallowed origin", headers: map[string]string{"Origin": "https://evil.example.com"}},
+		{name: "no origin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodOptions, Headers: tt.headers}
+			resp, err := cors.Preflight(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+			}
+			if got := resp.Headers["Access-Control-Allow-Origin"]; got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			wantMethods, wantHeaders := "", ""
+			if tt.wantOrigin != "" {
+				wantMethods, wantHeaders = "GET, POST", "Content-Type, Authorization"
+			}
+			if got := resp.Headers["Access-Control-Allow-Methods"]; got != wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, wantMethods)
+			}
+			if got := resp.Headers["Access-Control-Allow-Headers"]; got != wantHeaders {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, wantHeaders)
+			}
+		})
+	}
+}
+
+func TestCORSWildcardEchoesOrigin(t *testing.T) {
+	cors := NewCORS([]string{"*"}, []string{"GET"}, nil)
+	resp := &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Vary": "Accept-Encoding"}}
+	cors.Apply(events.APIGatewayProxyRequest{Headers: map[string]string{"Origin": "https://any.example.com"}}, resp)
+
+	if got := resp.Headers["Access-Control-Allow-Origin"]; got != "https://any.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request's origin", got)
+	}
+	if got := resp.Headers["Vary"]; got != "Accept-Encoding, Origin" {
+		t.Errorf("Vary = %q, want %q", got, "Accept-Encoding, Origin")
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WithDeadlineMargin derives a context that expires margin before the deadline of ctx, the Lambda
+// deadline, so slow calls are cancelled while there is still time to answer. Without a deadline
+// (e.g. when invoked locally) the context is only made cancellable.
+func WithDeadlineMargin(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// WithinBudget wraps next, answering 503 instead when the time budget of ctx runs out: either next
+// ignores the cancelled context and is still running, or it failed because its calls were cancelled.
+// A handler left running is abandoned; its response is discarded.
+func WithinBudget(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		type result struct {
+			resp *events.APIGatewayProxyResponse
+			err  error
+		}
+		done := make(chan result, 1)
+		start := time.Now()
+		go func() {
+			resp, err := next(ctx, req)
+			done <- result{resp, err}
+		}()
+
+		var r result
+		select {
+		case r = <-done:
+			if ctx.Err() == nil || r.resp == nil || r.resp.StatusCode < http.StatusInternalServerError {
+				return r.resp, r.err
+			}
+		case <-ctx.Done():
+		}
+		slog.Error("Request exceeded the invocation's time budget",
+			slog.String("operation", "handler"),
+			slog.Duration("elapsed", time.Since(start)),
+			slog.Any("error", ctx.Err()))
+		return DeadlineExceeded()
+	}
+}
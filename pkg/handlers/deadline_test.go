@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// slowRepository takes delay to fetch a user, giving up when the context is done unless it
+// ignores the context, like a call stuck on a hung connection.
+type slowRepository struct {
+	repository.UserRepository
+	delay         time.Duration
+	ignoreContext bool
+}
+
+func (r slowRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (*models.User, error) {
+	if r.ignoreContext {
+		time.Sleep(r.delay)
+		return r.UserRepository.FetchUser(ctx, email, opts)
+	}
+	select {
+	case <-time.After(r.delay):
+		return r.UserRepository.FetchUser(ctx, email, opts)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestWithinBudget(t *testing.T) {
+	const margin = 100 * time.Millisecond
+	tests := []struct {
+		name          string
+		timeout       time.Duration // The Lambda deadline; none when zero
+		delay         time.Duration
+		ignoreContext bool
+		wantStatus    int
+	}{
+		{name: "fast repository", timeout: time.Second, wantStatus: http.StatusOK},
+		{name: "no deadline", delay: 50 * time.Millisecond, wantStatus: http.StatusOK},
+		{name: "slow repository", timeout: 200 * time.Millisecond, delay: time.Second, wantStatus: http.StatusServiceUnavailable},
+		{name: "repository ignoring the context", timeout: 200 * time.Millisecond, delay: time.Second, ignoreContext: true, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann"})
+			h := NewUserHandler(slowRepository{UserRepository: repo, delay: tt.delay, ignoreContext: tt.ignoreContext}, UserHandlerOptions{})
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			lambdaDeadline, _ := ctx.Deadline()
+			ctx, cancel := WithDeadlineMargin(ctx, margin)
+			defer cancel()
+
+			resp, err := WithinBudget(h.GetUser)(ctx, events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"email": "a@example.com"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusServiceUnavailable {
+				return
+			}
+			if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeDeadlineExceeded {
+				t.Errorf("code = %s, want %s", body.Code, CodeDeadlineExceeded)
+			}
+			if left := time.Until(lambdaDeadline); left < margin/2 {
+				t.Errorf("answered %v before the Lambda deadline, want about %v", left, margin)
+			}
+		})
+	}
+}
+
+func TestWithDeadlineMargin(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	parent, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ctx, cancel := WithDeadlineMargin(parent, time.Second)
+	defer cancel()
+	if got, ok := ctx.Deadline(); !ok || !got.Equal(deadline.Add(-time.Second)) {
+		t.Errorf("deadline = %v, %v, want %v", got, ok, deadline.Add(-time.Second))
+	}
+
+	ctx, cancel = WithDeadlineMargin(context.Background(), time.Second)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("deadline set on a context without one")
+	}
+}
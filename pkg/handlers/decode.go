@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// errTrailingData reports a body with more after its JSON value, such as two concatenated objects.
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+// decodeJSON decodes a request body into v. Unlike json.Unmarshal it rejects fields v does not
+// have, so a typo such as "fistName" fails instead of being silently dropped, and data after the
+// JSON value.
+func decodeJSON(body string, v interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errTrailingData
+	}
+	return nil
+}
+
+// invalidBody answers 400 for a body that could not be decoded, saying where and why it broke so
+// the client can fix its payload.
+func invalidBody(body string, err error) (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusBadRequest, ErrorBody{
+		ErrorMsg: StringPtr("Invalid request body: " + describeDecodeError(body, err)),
+		Code:     CodeInvalidRequestBody,
+	})
+}
+
+// describeDecodeError explains a decoding error of body, with the line and column of syntax and
+// type errors (both 1-based) and the byte offset they were found at.
+func describeDecodeError(body string, err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case strings.TrimSpace(body) == "":
+		return "body is empty"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("%s %s", strings.TrimPrefix(syntaxErr.Error(), "json: "), position(body, syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("%s must be %s, not %s %s", fieldPath(typeErr.Field), jsonType(typeErr.Type), typeErr.Value, position(body, typeErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected end of JSON input " + position(body, int64(len(body)))
+	}
+	// Unknown fields and trailing data carry no offset
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+// fieldPath formats the dotted path of a type error like the field names of validation errors,
+// e.g. "0.user.firstName" as "[0].user.firstName". An empty path is the body itself.
+func fieldPath(path string) string {
+	if path == "" {
+		return "body"
+	}
+	var b strings.Builder
+	for i, segment := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(segment); err == nil {
+			b.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+// position formats where in body a decoding error was found. Like encoding/json, offset counts the
+// bytes read, so the line and column are those of the last byte read, the one in error.
+func position(body string, offset int64) string {
+	offset = min(max(offset, 0), int64(len(body)))
+	before := body[:max(offset-1, 0)]
+	line := strings.Count(before, "\n") + 1
+	column := len(before) - strings.LastIndex(before, "\n")
+	return fmt.Sprintf("at line %d, column %d (offset %d)", line, column, offset)
+}
+
+// jsonType names the JSON type expected for a Go type, e.g. "a string" or "an array".
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Interface:
+		return "a JSON value"
+	}
+	return "a number"
+}
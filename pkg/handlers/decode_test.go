@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDescribeDecodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "empty", body: " ", want: "body is empty"},
+		{
+			name: "syntax error",
+			body: `{"email":"a@example.com",}`,
+			want: "invalid character '}' looking for beginning of object key string at line 1, column 26 (offset 26)",
+		},
+		{
+			name: "syntax error on a later line",
+			body: "{\n  \"email\": \"a@example.com\"\n  \"firstName\": \"Ann\"\n}",
+			want: "invalid character '\"' after object key:value pair at line 3, column 3 (offset 32)",
+		},
+		{
+			name: "wrong type",
+			body: "{\n  \"email\": \"a@example.com\",\n  \"firstName\": 7\n}",
+			want: "firstName must be a string, not number at line 3, column 16 (offset 46)",
+		},
+		{name: "unknown field", body: `{"email":"a@example.com","fistName":"Ann"}`, want: `unknown field "fistName"`},
+		{name: "trailing data", body: `{"email":"a@example.com"}{}`, want: "unexpected data after the JSON value"},
+		{name: "truncated", body: `{"email":"a@exa`, want: "unexpected end of JSON input at line 1, column 15 (offset 15)"},
+		{name: "not an object", body: `["a@example.com"]`, want: "body must be an object, not array at line 1, column 1 (offset 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user models.User
+			err := decodeJSON(tt.body, &user)
+			if err == nil {
+				t.Fatal("err = nil, want a decoding error")
+			}
+			if got := describeDecodeError(tt.body, err); got != tt.want {
+				t.Errorf("error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeDecodeErrorInArrays(t *testing.T) {
+	body := `[{"email":"a@example.com"},{"email":"b@example.com","firstName":["Ann"]}]`
+	var users []models.User
+	err := decodeJSON(body, &users)
+	if err == nil {
+		t.Fatal("err = nil, want a decoding error")
+	}
+	if got, want := describeDecodeError(body, err), "[1].firstName must be a string, not array at line 1, column 65 (offset 65)"; got != want {
+		t.Errorf("error = %q, want %q", got, want)
+	}
+}
+
+func TestInvalidBodyResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(*UserHandler, context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		req     events.APIGatewayProxyRequest
+		want    string
+	}{
+		{
+			name:    "syntax error",
+			handler: (*UserHandler).CreateUser,
+			req:     events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: `{"email":"a@example.com",}`},
+			want:    "Invalid request body: invalid character '}' looking for beginning of object key string at line 1, column 26 (offset 26)",
+		},
+		{
+			name:    "unknown field",
+			handler: (*UserHandler).ChangeEmail,
+			req: events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodPut,
+				PathParameters: map[string]string{"email": "a@example.com"},
+				Body:           `{"emial":"b@example.com"}`,
+			},
+			want: `Invalid request body: unknown field "emial"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+
+			resp, err := tt.handler(h, context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusBadRequest, resp.Body)
+			}
+			body := decodeResponse[ErrorBody](t, resp)
+			if body.Code != CodeInvalidRequestBody {
+				t.Errorf("code = %s, want %s", body.Code, CodeInvalidRequestBody)
+			}
+			if body.ErrorMsg == nil || *body.ErrorMsg != tt.want {
+				t.Errorf("error = %v, want %q", body.ErrorMsg, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// duplicateFields are the fields reported for each user of a duplicate cluster: enough to tell the
+// records apart and pick the one to keep.
+var duplicateFields = []string{"id", "email", "firstName", "lastName", "createdAt", "updatedAt", "deleted"}
+
+// DuplicateCluster is a group of users stored under emails that normalize to the same one.
+type DuplicateCluster struct {
+	NormalizedEmail string        `json:"normalizedEmail"`
+	Users           []models.User `json:"users"`
+}
+
+// DuplicateReport is the response of DuplicateUsers.
+type DuplicateReport struct {
+	Scanned  int                `json:"scanned"`
+	Clusters []DuplicateCluster `json:"clusters"`
+}
+
+// DuplicateUsers handles GET /users/duplicates, reporting the users whose emails collide once
+// normalized, such as User@Example.com and user@example.com, which were stored as separate records
+// before emails were normalized on write. It scans the whole table, soft-deleted users included, and
+// changes nothing: the clusters are meant for a manual merge. Only admins may run it.
+func (h *UserHandler) DuplicateUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may look for duplicate users")
+	}
+
+	report := DuplicateReport{Clusters: []DuplicateCluster{}}
+	byEmail := map[string][]models.User{}
+	opts := repository.FetchOptions{IncludeDeleted: true, Fields: duplicateFields}
+	err := h.userRepo.ScanAll(ctx, "", opts, func(user models.User) error {
+		report.Scanned++
+		email := validators.NormalizeEmail(user.Email)
+		byEmail[email] = append(byEmail[email], user)
+		return nil
+	})
+	if err != nil {
+		return repositoryFailure("DuplicateUsers", err)
+	}
+
+	for email, users := range byEmail {
+		if len(users) < 2 {
+			continue
+		}
+		slices.SortFunc(users, func(a, b models.User) int { return strings.Compare(a.Email, b.Email) })
+		report.Clusters = append(report.Clusters, DuplicateCluster{NormalizedEmail: email, Users: users})
+	}
+	slices.SortFunc(report.Clusters, func(a, b DuplicateCluster) int {
+		return strings.Compare(a.NormalizedEmail, b.NormalizedEmail)
+	})
+	return apiResponse(http.StatusOK, report)
+}
+
+// MergeUsersRequest is the body of MergeUsers: the stored emails of the user to keep and of the
+// duplicate merged into it, as listed by DuplicateUsers.
+type MergeUsersRequest struct {
+	Primary   string `json:"primary"`
+	Duplicate string `json:"duplicate"`
+}
+
+// MergeUsers handles POST /users/merges, merging a duplicate user into the primary one and deleting
+// the duplicate (see repository.UserRepository.MergeUsers for which values are kept). The emails are
+// used exactly as given, since duplicates differ from the primary only in case. Only admins may merge.
+func (h *UserHandler) MergeUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may merge users")
+	}
+
+	var body MergeUsersRequest
+	if err := decodeJSON(req.Body, &body); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	var invalid validators.ValidationErrors
+	if body.Primary == "" {
+		invalid = append(invalid, validators.FieldError{Field: "primary", Message: "primary is required"})
+	}
+	if body.Duplicate == "" {
+		invalid = append(invalid, validators.FieldError{Field: "duplicate", Message: "duplicate is required"})
+	}
+	if len(invalid) > 0 {
+		return validationFailed(invalid)
+	}
+
+	merged, err := h.userRepo.MergeUsers(ctx, body.Primary, body.Duplicate)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUserDoesNotExist):
+			return apiResponse(http.StatusNotFound, ErrorBody{
+				ErrorMsg: StringPtr(err.Error()),
+				Code:     CodeUserNotFound,
+			})
+		case errors.Is(err, repository.ErrVersionConflict):
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr("A user changed while they were being merged; please retry"),
+				Code:     CodeConflict,
+			})
+		}
+		return repositoryFailure("MergeUsers", err)
+	}
+	return apiResponse(http.StatusOK, merged, map[string]string{"Location": userLocation(merged.Email)})
+}
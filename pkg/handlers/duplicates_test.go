@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// scannedUsers answers ScanAll with users as stored, which may include emails that differ only in
+// case, recording the options it is called with. Any other call panics, so a report that writes fails.
+type scannedUsers struct {
+	repository.UserRepository
+	users []models.User
+	err   error
+	opts  repository.FetchOptions
+}
+
+func (r *scannedUsers) ScanAll(_ context.Context, _ string, opts repository.FetchOptions, fn func(models.User) error) error {
+	r.opts = opts
+	if r.err != nil {
+		return r.err
+	}
+	for _, user := range r.users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDuplicateUsers(t *testing.T) {
+	seeded := []models.User{
+		{Email: "ann@example.com"},
+		{Email: "Bo@Example.com"},
+		{Email: "ANN@example.com", Deleted: true},
+		{Email: "cy@example.com"},
+		{Email: "bo@example.com"},
+		{Email: "Ann@Example.COM"},
+	}
+	tests := []struct {
+		name         string
+		role         string
+		users        []models.User
+		scanErr      error
+		wantStatus   int
+		wantScanned  int
+		wantClusters map[string][]string
+	}{
+		{
+			name:        "seeded duplicates",
+			role:        "admin",
+			users:       seeded,
+			wantStatus:  http.StatusOK,
+			wantScanned: 6,
+			wantClusters: map[string][]string{
+				"ann@example.com": {"ANN@example.com", "Ann@Example.COM", "ann@example.com"},
+				"bo@example.com":  {"Bo@Example.com", "bo@example.com"},
+			},
+		},
+		{
+			name:         "no duplicates",
+			role:         "admin",
+			users:        []models.User{{Email: "ann@example.com"}, {Email: "bo@example.com"}},
+			wantStatus:   http.StatusOK,
+			wantScanned:  2,
+			wantClusters: map[string][]string{},
+		},
+		{name: "editor", role: "editor", users: seeded, wantStatus: http.StatusForbidden},
+		{name: "scan failure", role: "admin", scanErr: errors.New("throttled"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &scannedUsers{users: tt.users, err: tt.scanErr}
+			h := NewUserHandler(repo, UserHandlerOptions{})
+			ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: tt.role, RegisteredClaims: jwt.RegisteredClaims{Subject: "admin@example.com"}})
+
+			resp, err := h.DuplicateUsers(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !repo.opts.IncludeDeleted {
+				t.Error("the scan skipped deleted users")
+			}
+			report := decodeResponse[DuplicateReport](t, resp)
+			if report.Scanned != tt.wantScanned {
+				t.Errorf("scanned = %d, want %d", report.Scanned, tt.wantScanned)
+			}
+			if len(report.Clusters) != len(tt.wantClusters) {
+				t.Fatalf("%d clusters, want %d: %+v", len(report.Clusters), len(tt.wantClusters), report.Clusters)
+			}
+			for i, cluster := range report.Clusters {
+				if i > 0 && report.Clusters[i-1].NormalizedEmail >= cluster.NormalizedEmail {
+					t.Errorf("clusters are not sorted by email: %s before %s", report.Clusters[i-1].NormalizedEmail, cluster.NormalizedEmail)
+				}
+				var emails []string
+				for _, user := range cluster.Users {
+					emails = append(emails, user.Email)
+				}
+				if want := tt.wantClusters[cluster.NormalizedEmail]; !slices.Equal(emails, want) {
+					t.Errorf("cluster %s = %v, want %v", cluster.NormalizedEmail, emails, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Envelope is the shape of successful responses when the response envelope is enabled.
+type Envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta EnvelopeMeta    `json:"meta"`
+}
+
+// EnvelopeMeta carries the details clients need to correlate a response with the server logs.
+type EnvelopeMeta struct {
+	RequestID string `json:"requestId"`
+	Timestamp string `json:"timestamp"` // RFC3339, UTC
+}
+
+// WrapEnvelope wraps the body of a successful JSON response in an Envelope, with the API Gateway
+// request ID of req. Error responses, empty bodies (204, 304) and non-JSON bodies such as the NDJSON
+// export are left as they are.
+// It must run before Compress, which replaces the body with its gzipped encoding.
+func WrapEnvelope(req events.APIGatewayProxyRequest, resp *events.APIGatewayProxyResponse) {
+	if resp == nil || resp.IsBase64Encoded || resp.Body == "" {
+		return
+	}
+	if resp.Headers["Content-Type"] != "application/json" {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || !json.Valid([]byte(resp.Body)) {
+		return
+	}
+
+	body, err := json.Marshal(Envelope{
+		Data: json.RawMessage(resp.Body),
+		Meta: EnvelopeMeta{
+			RequestID: req.RequestContext.RequestID,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		slog.Error("Could not wrap response body", slog.String("operation", "WrapEnvelope"), slog.Any("error", err))
+		return
+	}
+	resp.Body = string(body)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWrapEnvelope(t *testing.T) {
+	h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+	req := events.APIGatewayProxyRequest{
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"email": "a@example.com"},
+	}
+	req.RequestContext.RequestID = "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"
+	resp, err := h.GetUser(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := resp.Body
+
+	WrapEnvelope(req, resp)
+
+	envelope := decodeResponse[Envelope](t, resp)
+	if string(envelope.Data) != raw {
+		t.Errorf("data = %s, want %s", envelope.Data, raw)
+	}
+	if envelope.Meta.RequestID != req.RequestContext.RequestID {
+		t.Errorf("requestId = %q, want %q", envelope.Meta.RequestID, req.RequestContext.RequestID)
+	}
+	if ts, err := time.Parse(time.RFC3339, envelope.Meta.Timestamp); err != nil || time.Since(ts) > time.Minute {
+		t.Errorf("timestamp = %q, want the current time", envelope.Meta.Timestamp)
+	}
+}
+
+func TestWrapEnvelopeLeavesOtherResponsesAlone(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *events.APIGatewayProxyResponse
+	}{
+		{name: "error", resp: &events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"error":"User not found"}`}},
+		{name: "no content", resp: &events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: map[string]string{"Content-Type": "application/json"}}},
+		{name: "NDJSON", resp: &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "application/x-ndjson"}, Body: "{}\n{}\n"}},
+		{name: "compressed", resp: &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "application/json"}, Body: "H4sIAAAAAAAA", IsBase64Encoded: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.resp.Body
+			WrapEnvelope(events.APIGatewayProxyRequest{}, tt.resp)
+			if tt.resp.Body != want {
+				t.Errorf("body = %q, want %q", tt.resp.Body, want)
+			}
+		})
+	}
+	WrapEnvelope(events.APIGatewayProxyRequest{}, nil) // Must not panic
+}
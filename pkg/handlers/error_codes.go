@@ -0,0 +1,61 @@
+package handlers
+
+// ErrorCode is the machine-readable "code" of an error response, so clients can react to an error
+// without matching its message. Codes are stable: existing values are never renamed or reused,
+// while messages may be reworded at any time.
+type ErrorCode string
+
+const (
+	// CodeInvalidRequest is a request missing a required value, such as the user's email.
+	CodeInvalidRequest ErrorCode = "INVALID_REQUEST"
+	// CodeInvalidRequestBody is a body that is not valid JSON of the expected shape, or is empty.
+	CodeInvalidRequestBody ErrorCode = "INVALID_REQUEST_BODY"
+	// CodeInvalidQueryParameter is an unknown query parameter, or a known one with an invalid value.
+	CodeInvalidQueryParameter ErrorCode = "INVALID_QUERY_PARAMETER"
+	// CodeInvalidPaginationToken is a lastEvaluatedKey that was not issued by the API.
+	CodeInvalidPaginationToken ErrorCode = "INVALID_PAGINATION_TOKEN"
+	// CodeValidationFailed is a user that failed validation; the response lists the failing fields.
+	CodeValidationFailed ErrorCode = "VALIDATION_FAILED"
+	// CodeUserNotFound is a request for a user that does not exist.
+	CodeUserNotFound ErrorCode = "USER_NOT_FOUND"
+	// CodeUserAlreadyExists is a create for an email that is already taken.
+	CodeUserAlreadyExists ErrorCode = "USER_ALREADY_EXISTS"
+	// CodeConflict is a write that lost to a concurrent change of the same user.
+	CodeConflict ErrorCode = "CONFLICT"
+	// CodePreconditionFailed is an If-Match header that no longer matches the user.
+	CodePreconditionFailed ErrorCode = "PRECONDITION_FAILED"
+	// CodeTransactionCanceled is a transaction rolled back because one of its operations failed.
+	CodeTransactionCanceled ErrorCode = "TRANSACTION_CANCELED"
+	// CodeIdempotencyKeyReused is an Idempotency-Key sent again with a different body.
+	CodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	// CodeIdempotencyKeyInUse is an Idempotency-Key whose first request is still being processed.
+	CodeIdempotencyKeyInUse ErrorCode = "IDEMPOTENCY_KEY_IN_USE"
+	// CodeInvalidCredentials is a login with an unknown email or a wrong password.
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+	// CodeUnauthorized is a request without a valid bearer token.
+	CodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	// CodeForbidden is an authenticated request the caller is not allowed to make.
+	CodeForbidden ErrorCode = "FORBIDDEN"
+	// CodeHTTPSRequired is a request that arrived over plain HTTP when REQUIRE_HTTPS is set.
+	CodeHTTPSRequired ErrorCode = "HTTPS_REQUIRED"
+	// CodeOperationDisabled is an operation switched off by configuration, such as deleting all users.
+	CodeOperationDisabled ErrorCode = "OPERATION_DISABLED"
+	// CodeIndexNotConfigured is a query needing a secondary index the deployment does not have.
+	CodeIndexNotConfigured ErrorCode = "INDEX_NOT_CONFIGURED"
+	// CodeNotFound is a path no route matches.
+	CodeNotFound ErrorCode = "NOT_FOUND"
+	// CodeMethodNotAllowed is a method the matched route does not support.
+	CodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+	// CodeUnsupportedVersion is a request for an API version the service does not serve.
+	CodeUnsupportedVersion ErrorCode = "UNSUPPORTED_API_VERSION"
+	// CodeUnsupportedMediaType is a body sent without an application/json Content-Type.
+	CodeUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	// CodePayloadTooLarge is a body larger than MAX_BODY_BYTES.
+	CodePayloadTooLarge ErrorCode = "PAYLOAD_TOO_LARGE"
+	// CodeRateLimited is a request over the caller's rate limit; see the Retry-After header.
+	CodeRateLimited ErrorCode = "RATE_LIMITED"
+	// CodeDeadlineExceeded is a request that could not be completed within the invocation's time budget.
+	CodeDeadlineExceeded ErrorCode = "DEADLINE_EXCEEDED"
+	// CodeInternalError is a failure on the service's side; the details are only logged.
+	CodeInternalError ErrorCode = "INTERNAL_ERROR"
+)
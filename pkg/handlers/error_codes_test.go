@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestErrorCodes(t *testing.T) {
+	viewer := auth.WithClaims(context.Background(), &auth.Claims{Role: "viewer", RegisteredClaims: jwt.RegisteredClaims{Subject: "b@example.com"}})
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		handler    func(*UserHandler, context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		req        events.APIGatewayProxyRequest
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{
+			name:       "missing user",
+			handler:    (*UserHandler).GetUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, PathParameters: map[string]string{"email": "missing@example.com"}},
+			wantStatus: http.StatusNotFound, wantCode: CodeUserNotFound,
+		},
+		{
+			name:       "existing user",
+			handler:    (*UserHandler).CreateUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`},
+			wantStatus: http.StatusConflict, wantCode: CodeUserAlreadyExists,
+		},
+		{
+			name:       "invalid user",
+			handler:    (*UserHandler).CreateUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: `{"email":"not-an-email","firstName":"Ann","lastName":"Lee"}`},
+			wantStatus: http.StatusUnprocessableEntity, wantCode: CodeValidationFailed,
+		},
+		{
+			name:       "malformed body",
+			handler:    (*UserHandler).CreateUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: `{"email":`},
+			wantStatus: http.StatusBadRequest, wantCode: CodeInvalidRequestBody,
+		},
+		{
+			name:       "oversized body",
+			handler:    (*UserHandler).CreateUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: strings.Repeat(" ", defaultMaxBodyBytes+1)},
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: CodePayloadTooLarge,
+		},
+		{
+			name:       "stale version",
+			handler:    (*UserHandler).UpdateUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodPut, Body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","version":7}`},
+			wantStatus: http.StatusConflict, wantCode: CodeConflict,
+		},
+		{
+			name:       "invalid pagination token",
+			handler:    (*UserHandler).GetUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, QueryStringParameters: map[string]string{"lastEvaluatedKey": "%%%"}},
+			wantStatus: http.StatusBadRequest, wantCode: CodeInvalidPaginationToken,
+		},
+		{
+			name:       "unknown query parameter",
+			handler:    (*UserHandler).GetUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, QueryStringParameters: map[string]string{"sort": "email"}},
+			wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQueryParameter,
+		},
+		{
+			name:       "another user's record",
+			ctx:        viewer,
+			handler:    (*UserHandler).DeleteUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodDelete, PathParameters: map[string]string{"email": "a@example.com"}},
+			wantStatus: http.StatusForbidden, wantCode: CodeForbidden,
+		},
+		{
+			name:       "deleting a missing user",
+			handler:    (*UserHandler).DeleteUser,
+			req:        events.APIGatewayProxyRequest{HTTPMethod: http.MethodDelete, PathParameters: map[string]string{"email": "missing@example.com"}},
+			wantStatus: http.StatusNotFound, wantCode: CodeUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			resp, err := tt.handler(h, ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			// ValidationErrorBody and ErrorBody share the code field
+			if got := decodeResponse[ErrorBody](t, resp).Code; got != tt.wantCode {
+				t.Errorf("code = %s, want %s", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestUnhandledMethod(t *testing.T) {
+	resp, err := UnhandledMethod(http.MethodGet, http.MethodPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+	if got := decodeResponse[ErrorBody](t, resp).Code; got != CodeMethodNotAllowed {
+		t.Errorf("code = %s, want %s", got, CodeMethodNotAllowed)
+	}
+	if got := resp.Headers["Allow"]; got != "GET, POST" {
+		t.Errorf("Allow = %q, want %q", got, "GET, POST")
+	}
+}
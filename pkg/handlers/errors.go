@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// clientErrors are the repository errors caused by the request rather than by the service,
+// with the status and code reported for each.
+var clientErrors = []struct {
+	err    error
+	status int
+	code   ErrorCode
+}{
+	{repository.ErrUserAlreadyExists, http.StatusConflict, CodeUserAlreadyExists},
+	{repository.ErrUserDoesNotExist, http.StatusNotFound, CodeUserNotFound},
+	{repository.ErrInvalidUserData, http.StatusUnprocessableEntity, CodeValidationFailed},
+	{repository.ErrInvalidLastEvaluatedKey, http.StatusBadRequest, CodeInvalidPaginationToken},
+	{repository.ErrVersionConflict, http.StatusConflict, CodeConflict},
+	{repository.ErrIndexNotConfigured, http.StatusBadRequest, CodeIndexNotConfigured},
+	{repository.ErrTransactionCanceled, http.StatusConflict, CodeTransactionCanceled},
+	{repository.ErrInvalidOperation, http.StatusBadRequest, CodeInvalidRequestBody},
+	{repository.ErrInvalidCredentials, http.StatusUnauthorized, CodeInvalidCredentials},
+	{repository.ErrDestructiveOpsDisabled, http.StatusForbidden, CodeOperationDisabled},
+}
+
+// clientError reports whether err was caused by the request, such as a missing user or a bad
+// pagination token, and the status and code to answer it with. Everything else, like DynamoDB
+// outages or marshaling failures, is a server error.
+func clientError(err error) (int, ErrorCode, bool) {
+	var validationErrs validators.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return http.StatusUnprocessableEntity, CodeValidationFailed, true
+	}
+	for _, clientError := range clientErrors {
+		if errors.Is(err, clientError.err) {
+			return clientError.status, clientError.code, true
+		}
+	}
+	return 0, "", false
+}
+
+// repositoryFailure answers a failed repository call. Client errors get their 4xx status with the
+// error message; server errors get 500 with a generic message, so an outage is not blamed on the
+// request and its details are only logged.
+func repositoryFailure(operation string, err error) (*events.APIGatewayProxyResponse, error) {
+	if status, code, ok := clientError(err); ok {
+		return apiResponse(status, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     code,
+		})
+	}
+	slog.Error("Repository call failed", slog.String("operation", operation), slog.Any("error", err))
+	return InternalServerError()
+}
+
+// InternalServerError answers with a generic 500, for failures whose details must stay in the logs.
+func InternalServerError() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusInternalServerError, ErrorBody{
+		ErrorMsg: StringPtr("Internal server error"),
+		Code:     CodeInternalError,
+	})
+}
+
+// DeadlineExceeded answers with 503 when the request ran out of its time budget, so the client gets
+// a response it may retry instead of the gateway's timeout error when Lambda kills the invocation.
+func DeadlineExceeded() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusServiceUnavailable, ErrorBody{
+		ErrorMsg: StringPtr("The request could not be completed in time; please retry"),
+		Code:     CodeDeadlineExceeded,
+	})
+}
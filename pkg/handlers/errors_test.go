@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func TestRepositoryFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{"user not found", repository.ErrUserDoesNotExist, http.StatusNotFound, CodeUserNotFound},
+		{"user already exists", repository.ErrUserAlreadyExists, http.StatusConflict, CodeUserAlreadyExists},
+		{"version conflict", fmt.Errorf("%w: version 3", repository.ErrVersionConflict), http.StatusConflict, CodeConflict},
+		{"transaction canceled", repository.ErrTransactionCanceled, http.StatusConflict, CodeTransactionCanceled},
+		{"bad pagination token", repository.ErrInvalidLastEvaluatedKey, http.StatusBadRequest, CodeInvalidPaginationToken},
+		{"wrapped twice", fmt.Errorf("deleting: %w", fmt.Errorf("%w: a@example.com", repository.ErrUserDoesNotExist)), http.StatusNotFound, CodeUserNotFound},
+		{"DynamoDB outage", fmt.Errorf("%w: %w", repository.ErrCouldNotDynamoPutItem, errors.New("InternalServerError")), http.StatusInternalServerError, CodeInternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := repositoryFailure("Test", tt.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := decodeResponse[ErrorBody](t, resp).Code; got != tt.wantCode {
+				t.Errorf("code = %s, want %s", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+// unavailableDynamoDB is a DynamoDB client whose every user-table call fails with a server error.
+type unavailableDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+var errDynamoDBInternal = awserr.New(dynamodb.ErrCodeInternalServerError, "Internal server error", nil)
+
+func (unavailableDynamoDB) GetItemWithContext(aws.Context, *dynamodb.GetItemInput, ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return nil, errDynamoDBInternal
+}
+
+func (unavailableDynamoDB) PutItemWithContext(aws.Context, *dynamodb.PutItemInput, ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return nil, errDynamoDBInternal
+}
+
+func (unavailableDynamoDB) ScanWithContext(aws.Context, *dynamodb.ScanInput, ...request.Option) (*dynamodb.ScanOutput, error) {
+	return nil, errDynamoDBInternal
+}
+
+func TestDynamoDBOutageIsAServerError(t *testing.T) {
+	repo := repository.NewDynamoDBUserRepository(unavailableDynamoDB{}, "users", repository.DynamoDBOptions{MaxAttempts: 1})
+	h := NewUserHandler(repo, UserHandlerOptions{})
+	tests := []struct {
+		name string
+		call func() (*events.APIGatewayProxyResponse, error)
+	}{
+		{name: "get", call: func() (*events.APIGatewayProxyResponse, error) {
+			return h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: map[string]string{"email": "a@example.com"},
+			})
+		}},
+		{name: "list", call: func() (*events.APIGatewayProxyResponse, error) {
+			return h.GetUser(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+		}},
+		{name: "create", call: func() (*events.APIGatewayProxyResponse, error) {
+			return h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`,
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusInternalServerError, resp.Body)
+			}
+			body := decodeResponse[ErrorBody](t, resp)
+			if body.Code != CodeInternalError || body.ErrorMsg == nil || *body.ErrorMsg != "Internal server error" {
+				t.Errorf("body = %s, want the generic internal error", resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// userETag computes a strong ETag from the marshaled user and its version.
+func userETag(user *models.User) (string, error) {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write(body)
+	hash.Write([]byte(strconv.Itoa(user.Version)))
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// etagMatches reports whether an If-None-Match / If-Match header value matches etag.
+// The header may list several tags separated by commas, or be "*" to match anything.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed rejects a write whose If-Match header no longer matches the stored user.
+func preconditionFailed() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusPreconditionFailed, ErrorBody{
+		ErrorMsg: StringPtr("The user has changed since it was read; fetch it again and retry"),
+		Code:     CodePreconditionFailed,
+	})
+}
+
+// notModified returns a 304 response with no body, as required for a matching conditional GET.
+// It repeats the Cache-Control of the 200 response, so caches refresh the stored copy's lifetime.
+func notModified(etag, cacheControl string) (*events.APIGatewayProxyResponse, error) {
+	return &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNotModified,
+		Headers:    map[string]string{"ETag": etag, "Cache-Control": cacheControl},
+	}, nil
+}
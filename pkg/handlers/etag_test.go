@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestGetUserConditional(t *testing.T) {
+	h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+	get := func(headers map[string]string) *events.APIGatewayProxyResponse {
+		t.Helper()
+		resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodGet,
+			PathParameters: map[string]string{"email": "a@example.com"},
+			Headers:        headers,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	etag := get(nil).Headers["ETag"]
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "no If-None-Match", wantStatus: http.StatusOK},
+		{name: "matching ETag", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "matching ETag in a list", ifNoneMatch: `"stale", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "weak matching ETag", ifNoneMatch: "W/" + etag, wantStatus: http.StatusNotModified},
+		{name: "wildcard", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "stale ETag", ifNoneMatch: `"stale"`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers map[string]string
+			if tt.ifNoneMatch != "" {
+				headers = map[string]string{"If-None-Match": tt.ifNoneMatch}
+			}
+			resp := get(headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.Headers["ETag"] != etag {
+				t.Errorf("ETag = %q, want %q", resp.Headers["ETag"], etag)
+			}
+			if gotBody := resp.Body != ""; gotBody != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("body = %q", resp.Body)
+			}
+		})
+	}
+}
+
+func TestUserETagChangesWithUser(t *testing.T) {
+	user := models.User{Email: "a@example.com", FirstName: "Ada", Version: 1}
+	etag, err := userETag(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, bumped := user, user
+	renamed.FirstName = "Ann"
+	bumped.Version = 2
+	for _, changed := range []models.User{renamed, bumped} {
+		if got, err := userETag(&changed); err != nil || got == etag {
+			t.Errorf("ETag of %+v = %q, %v, want one other than %q", changed, got, err, etag)
+		}
+	}
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		ifMatch    func(etag string) string
+		wantStatus int
+	}{
+		{name: "no If-Match", email: "a@example.com", wantStatus: http.StatusOK},
+		{name: "matching ETag", email: "a@example.com", ifMatch: func(etag string) string { return etag }, wantStatus: http.StatusOK},
+		{name: "matching ETag in a list", email: "a@example.com", ifMatch: func(etag string) string { return `"stale", ` + etag }, wantStatus: http.StatusOK},
+		{name: "wildcard", email: "a@example.com", ifMatch: func(string) string { return "*" }, wantStatus: http.StatusOK},
+		{name: "stale ETag", email: "a@example.com", ifMatch: func(string) string { return `"stale"` }, wantStatus: http.StatusPreconditionFailed},
+		{name: "missing user", email: "b@example.com", ifMatch: func(etag string) string { return etag }, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+			read, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"email": "a@example.com"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var headers map[string]string
+			if tt.ifMatch != nil {
+				headers = map[string]string{"If-Match": tt.ifMatch(read.Headers["ETag"])}
+			}
+
+			resp, err := h.UpdateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodPut,
+				PathParameters: map[string]string{"email": tt.email},
+				Headers:        headers,
+				Body:           `{"email":"` + tt.email + `","firstName":"Ann","lastName":"Lee"}`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if resp.Headers["ETag"] == "" || resp.Headers["ETag"] == read.Headers["ETag"] {
+					t.Errorf("ETag = %q, want the updated user's", resp.Headers["ETag"])
+				}
+				return
+			}
+			if tt.wantStatus == http.StatusPreconditionFailed {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodePreconditionFailed {
+					t.Errorf("code = %s, want %s", body.Code, CodePreconditionFailed)
+				}
+			}
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.FirstName != "Ada" {
+				t.Errorf("user was updated to %q", stored.FirstName)
+			}
+		})
+	}
+}
+
+func TestUpsertUserIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		ifMatch    func(etag string) string
+		wantStatus int
+		wantStored string // the first name stored afterwards; none when the user must not exist
+	}{
+		{name: "no If-Match, missing user", email: "b@example.com", wantStatus: http.StatusCreated, wantStored: "Ann"},
+		{name: "matching ETag", email: "a@example.com", ifMatch: func(etag string) string { return etag }, wantStatus: http.StatusOK, wantStored: "Ann"},
+		{name: "wildcard", email: "a@example.com", ifMatch: func(string) string { return "*" }, wantStatus: http.StatusOK, wantStored: "Ann"},
+		{name: "stale ETag", email: "a@example.com", ifMatch: func(string) string { return `"stale"` }, wantStatus: http.StatusPreconditionFailed, wantStored: "Ada"},
+		{name: "missing user", email: "b@example.com", ifMatch: func(etag string) string { return etag }, wantStatus: http.StatusPreconditionFailed},
+		{name: "wildcard, missing user", email: "b@example.com", ifMatch: func(string) string { return "*" }, wantStatus: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			etag, err := userETag(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var headers map[string]string
+			if tt.ifMatch != nil {
+				headers = map[string]string{"If-Match": tt.ifMatch(etag)}
+			}
+
+			resp, err := h.UpdateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodPut,
+				PathParameters:        map[string]string{"email": tt.email},
+				QueryStringParameters: map[string]string{"upsert": "true"},
+				Headers:               headers,
+				Body:                  `{"email":"` + tt.email + `","firstName":"Ann","lastName":"Lee"}`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus == http.StatusPreconditionFailed {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodePreconditionFailed {
+					t.Errorf("code = %s, want %s", body.Code, CodePreconditionFailed)
+				}
+			}
+			user, err := repo.FetchUser(context.Background(), tt.email, repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.wantStored == "" && user != nil:
+				t.Errorf("user %s was created", tt.email)
+			case tt.wantStored != "" && (user == nil || user.FirstName != tt.wantStored):
+				t.Errorf("stored user = %+v, want first name %s", user, tt.wantStored)
+			}
+		})
+	}
+}
+
+// racingRepository changes the user after every read, as a concurrent writer would between the
+// If-Match check and the update.
+type racingRepository struct {
+	repository.UserRepository
+}
+
+func (r racingRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (*models.User, error) {
+	user, err := r.UserRepository.FetchUser(ctx, email, opts)
+	if err != nil || user == nil {
+		return user, err
+	}
+	concurrent := *user
+	concurrent.FirstName = "Bea"
+	if _, err := r.UserRepository.UpdateUser(ctx, concurrent); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func TestUpdateUserIfMatchRace(t *testing.T) {
+	seeded, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+	stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := userETag(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewUserHandler(racingRepository{repo}, seeded.opts)
+
+	resp, err := h.UpdateUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:     http.MethodPut,
+		PathParameters: map[string]string{"email": "a@example.com"},
+		Headers:        map[string]string{"If-Match": etag},
+		Body:           `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusPreconditionFailed, resp.Body)
+	}
+}
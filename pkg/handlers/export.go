@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxExportBytes caps the body of one export response. Lambda rejects synchronous responses
+// over 6 MB, so the export stops well below that and hands back a continuation token.
+const maxExportBytes = 4 << 20
+
+// exportContinuationHeader carries the token to resume an export that hit maxExportBytes.
+const exportContinuationHeader = "X-Last-Evaluated-Key"
+
+// errExportFull stops the scan of ExportUsers once the body has reached maxExportBytes.
+var errExportFull = errors.New("export body is full")
+
+// ExportUsers handles GET /users/export, streaming every user as newline-delimited JSON for backups.
+// Users are appended until maxExportBytes is reached; the export then stops and returns the position
+// after the last user in the X-Last-Evaluated-Key header, to be passed back as the lastEvaluatedKey
+// query parameter. Only admins may export users.
+func (h *UserHandler) ExportUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, exportUsersParams...); invalid != nil {
+		return invalid, nil
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may export users")
+	}
+
+	opts := repository.FetchOptions{
+		IncludeDeleted: req.QueryStringParameters["includeDeleted"] == "true",
+	}
+
+	var body bytes.Buffer
+	var last models.User
+	encoder := json.NewEncoder(&body) // Encode terminates every value with a newline
+	err := h.userRepo.ScanAll(ctx, req.QueryStringParameters["lastEvaluatedKey"], opts, func(user models.User) error {
+		if err := encoder.Encode(user); err != nil {
+			return err
+		}
+		last = user
+		if body.Len() >= maxExportBytes {
+			return errExportFull
+		}
+		return nil
+	})
+
+	headers := map[string]string{"Content-Type": "application/x-ndjson"}
+	switch {
+	case errors.Is(err, errExportFull):
+		token, err := repository.ResumeToken(last)
+		if err != nil {
+			return InternalServerError()
+		}
+		headers[exportContinuationHeader] = token
+	case err != nil:
+		return repositoryFailure("ExportUsers", err)
+	}
+	return &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       body.String(),
+	}, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// exportedEmails parses an NDJSON export body, failing the test on any line that is not a user.
+func exportedEmails(t *testing.T, body string) []string {
+	t.Helper()
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		t.Errorf("body does not end with a newline")
+	}
+	var emails []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(nil, maxExportBytes)
+	for scanner.Scan() {
+		var user models.User
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		emails = append(emails, user.Email)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return emails
+}
+
+func TestExportUsers(t *testing.T) {
+	h, _ := newTestHandler(t,
+		models.User{Email: "b@example.com", FirstName: "Bo", LastName: "Lee", Password: "secret123"},
+		models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"},
+	)
+
+	resp, err := h.ExportUsers(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+	if got := resp.Headers["Content-Type"]; got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+	}
+	if token, ok := resp.Headers[exportContinuationHeader]; ok {
+		t.Errorf("continuation token %q on a complete export", token)
+	}
+	if got, want := exportedEmails(t, resp.Body), []string{"a@example.com", "b@example.com"}; !slices.Equal(got, want) {
+		t.Errorf("emails = %v, want %v", got, want)
+	}
+	if strings.Contains(strings.ToLower(resp.Body), "password") {
+		t.Error("export contains password data")
+	}
+}
+
+func TestExportUsersContinues(t *testing.T) {
+	// Users of about 5 kB, so that the export does not fit into one response
+	metadata := map[string]string{}
+	for i := range 20 {
+		metadata[fmt.Sprintf("key%02d", i)] = strings.Repeat("x", 256)
+	}
+	var users []models.User
+	var want []string
+	for i := range 1000 {
+		email := fmt.Sprintf("user%04d@example.com", i)
+		users = append(users, models.User{Email: email, FirstName: "Ann", LastName: "Lee", Metadata: metadata})
+		want = append(want, email)
+	}
+	h, _ := newTestHandler(t, users...)
+
+	var got []string
+	query := map[string]string{}
+	for responses := 1; ; responses++ {
+		resp, err := h.ExportUsers(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, QueryStringParameters: query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if len(resp.Body) > maxExportBytes+10<<10 {
+			t.Errorf("response %d has %d bytes, want at most about %d", responses, len(resp.Body), maxExportBytes)
+		}
+		got = append(got, exportedEmails(t, resp.Body)...)
+		token := resp.Headers[exportContinuationHeader]
+		if token == "" {
+			if responses < 2 {
+				t.Errorf("export completed in %d response, want it split", responses)
+			}
+			break
+		}
+		query["lastEvaluatedKey"] = token
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("exported %d users, want %d without gaps or duplicates", len(got), len(want))
+	}
+}
+
+func TestExportUsersRequiresAdmin(t *testing.T) {
+	h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{Role: "editor"})
+
+	resp, err := h.ExportUsers(ctx, events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
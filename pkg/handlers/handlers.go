@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv" // For pagination
+	"strings"
+	"time"
 
 	"github.com/39sanskar/serverless-go/pkg/models" // Use models package for User struct
 	"github.com/39sanskar/serverless-go/pkg/repository"
@@ -11,151 +18,958 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
+const (
+	// defaultPageSize is used when UserHandlerOptions.DefaultPageSize is not set.
+	defaultPageSize = 10
+	// defaultMaxPageSize is used when UserHandlerOptions.MaxPageSize is not set.
+	defaultMaxPageSize = 100
+	// defaultMaxBodyBytes is used when UserHandlerOptions.MaxBodyBytes is not set.
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// UserHandlerOptions configures optional behavior of UserHandler.
+type UserHandlerOptions struct {
+	// DefaultPageSize is the listing limit used when the client sends none.
+	DefaultPageSize int
+	// MaxPageSize caps the listing limit; larger requested limits are clamped to it.
+	MaxPageSize int
+	// MaxBodyBytes is the largest request body accepted before unmarshaling.
+	MaxBodyBytes int
+	// IdempotencyStore records CreateUser responses per Idempotency-Key header. Nil disables the header.
+	IdempotencyStore repository.IdempotencyStore
+	// IdempotencyTTL is how long a recorded response is replayed for.
+	IdempotencyTTL time.Duration
+	// LenientQueryParams ignores unknown query parameters instead of rejecting them with 400.
+	LenientQueryParams bool
+	// NameSanitization strips HTML markup from names instead of rejecting it. Empty means reject.
+	NameSanitization validators.NameSanitization
+	// Validation configures the checks that warn about a user instead of rejecting it.
+	Validation validators.ValidationOptions
+	// CacheMaxAge is the max-age of the Cache-Control header on user reads. Zero sends no-cache.
+	CacheMaxAge time.Duration
+	// EmailSuggestions adds the emails of users close to the requested one to the 404 of GetUser,
+	// for admins only.
+	EmailSuggestions bool
+	// SuggestionScanLimit is how many users are compared when looking for suggestions.
+	SuggestionScanLimit int
+	// CacheStaleWhileRevalidate lets caches serve a read this long past CacheMaxAge while refetching it.
+	CacheStaleWhileRevalidate time.Duration
+}
+
 // UserHandler provides methods for handling user-related API requests.
 type UserHandler struct {
 	userRepo repository.UserRepository
+	opts     UserHandlerOptions
 }
 
 // NewUserHandler creates a new UserHandler instance.
-func NewUserHandler(userRepo repository.UserRepository) UserHandler {
+func NewUserHandler(userRepo repository.UserRepository, opts UserHandlerOptions) UserHandler {
+	if opts.DefaultPageSize <= 0 {
+		opts.DefaultPageSize = defaultPageSize
+	}
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = defaultMaxPageSize
+	}
+	opts.DefaultPageSize = min(opts.DefaultPageSize, opts.MaxPageSize)
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if opts.SuggestionScanLimit <= 0 {
+		opts.SuggestionScanLimit = defaultSuggestionScanLimit
+	}
+	if opts.IdempotencyTTL <= 0 {
+		opts.IdempotencyTTL = defaultIdempotencyTTL
+	}
 	return UserHandler{
 		userRepo: userRepo,
+		opts:     opts,
 	}
 }
 
 // GetUser handles GET requests for users.
-// It can fetch a single user by email or all users with pagination.
-func (h *UserHandler) GetUser(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	email := req.QueryStringParameters["email"]
+// It can fetch a single user by email, users by last name, or all users with pagination.
+func (h *UserHandler) GetUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, getUserParams...); invalid != nil {
+		return invalid, nil
+	}
+	email := requestEmail(req)
+	fields, err := parseFields(req.QueryStringParameters["fields"])
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     CodeInvalidQueryParameter,
+		})
+	}
+	descending, err := parseOrder(req.QueryStringParameters["order"])
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     CodeInvalidQueryParameter,
+		})
+	}
+	createdAfter, err := parseTimestamp("createdAfter", req.QueryStringParameters["createdAfter"])
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     CodeInvalidQueryParameter,
+		})
+	}
+	createdBefore, err := parseTimestamp("createdBefore", req.QueryStringParameters["createdBefore"])
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     CodeInvalidQueryParameter,
+		})
+	}
+	opts := repository.FetchOptions{
+		IncludeDeleted: req.QueryStringParameters["includeDeleted"] == "true",
+		ConsistentRead: req.QueryStringParameters["consistent"] == "true",
+		Fields:         fields,
+		Descending:     descending,
+		CreatedAfter:   createdAfter,
+		CreatedBefore:  createdBefore,
+	}
 
 	if email != "" {
 		// Fetch single user
-		user, err := h.userRepo.FetchUser(email)
+		user, err := h.userRepo.FetchUser(ctx, email, opts)
 		if err != nil {
-			return apiResponse(http.StatusBadRequest, ErrorBody{
-				ErrorMsg: StringPtr(err.Error()),
-			})
+			return repositoryFailure("GetUser", err)
 		}
 		if user == nil {
-			return apiResponse(http.StatusNotFound, ErrorBody{
-				ErrorMsg: StringPtr("User not found"),
+			return h.userNotFound(ctx, email)
+		}
+
+		// Honor conditional GETs so polling clients don't re-download unchanged users
+		etag, err := userETag(user)
+		if err != nil {
+			return apiResponse(http.StatusInternalServerError, ErrorBody{
+				ErrorMsg: StringPtr("Failed to compute ETag"),
+				Code:     CodeInternalError,
 			})
 		}
-		return apiResponse(http.StatusOK, user)
+		cacheControl := h.cacheControl(ctx, req)
+		if ifNoneMatch := requestHeader(req, "If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+			return notModified(etag, cacheControl)
+		}
+		selected, err := selectFields(*user, fields)
+		if err != nil {
+			return apiResponse(http.StatusInternalServerError, ErrorBody{
+				ErrorMsg: StringPtr("Failed to select fields"),
+				Code:     CodeInternalError,
+			})
+		}
+		return apiResponse(http.StatusOK, selected, map[string]string{"ETag": etag, "Cache-Control": cacheControl})
 	}
 
 	// Fetch all users with optional pagination
-	limitStr := req.QueryStringParameters["limit"]
+	limit, err := h.pageLimit(req, "limit")
+	if err != nil {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr(err.Error()),
+			Code:     CodeInvalidQueryParameter,
+		})
+	}
 	lastEvaluatedKey := req.QueryStringParameters["lastEvaluatedKey"] // For pagination token
 
-	limit := 10 // Default limit
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	lastName := req.QueryStringParameters["lastName"]
+	emailPrefix := req.QueryStringParameters["emailPrefix"]
+	search, searching := req.QueryStringParameters["search"]
+	if (lastName != "" && emailPrefix != "") || (searching && (lastName != "" || emailPrefix != "")) {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr("lastName, emailPrefix and search cannot be combined"),
+			Code:     CodeInvalidQueryParameter,
+		})
 	}
-
-	users, newLastEvaluatedKey, err := h.userRepo.FetchUsers(limit, lastEvaluatedKey)
-	if err != nil {
+	if searching && len(repository.SearchTokens(search)) == 0 {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
+			ErrorMsg: StringPtr("search must contain at least one letter or digit"),
+			Code:     CodeInvalidQueryParameter,
 		})
 	}
+	fetchPage := func(limit int, lastEvaluatedKey string) ([]models.User, string, error) {
+		switch {
+		case lastName != "":
+			// Use the last-name index instead of a full table scan
+			return h.userRepo.FetchUsersByLastName(ctx, lastName, limit, lastEvaluatedKey, opts)
+		case emailPrefix != "":
+			return h.userRepo.FetchUsersByEmailPrefix(ctx, emailPrefix, limit, lastEvaluatedKey, opts)
+		case searching:
+			return h.userRepo.SearchUsers(ctx, search, limit, lastEvaluatedKey, opts)
+		default:
+			return h.userRepo.FetchUsers(ctx, limit, lastEvaluatedKey, opts)
+		}
+	}
+
+	var users []models.User
+	var newLastEvaluatedKey string
+	responseBody := map[string]interface{}{}
+	if pageParam := req.QueryStringParameters["page"]; pageParam != "" {
+		// Page numbers are a convenience for clients that cannot carry the token
+		page, err := parsePage(pageParam)
+		if err != nil {
+			return apiResponse(http.StatusBadRequest, ErrorBody{
+				ErrorMsg: StringPtr(err.Error()),
+				Code:     CodeInvalidQueryParameter,
+			})
+		}
+		if lastEvaluatedKey != "" {
+			return apiResponse(http.StatusBadRequest, ErrorBody{
+				ErrorMsg: StringPtr("page and lastEvaluatedKey cannot be combined"),
+				Code:     CodeInvalidQueryParameter,
+			})
+		}
+		pageSize, err := h.pageLimit(req, "pageSize")
+		if err != nil {
+			return apiResponse(http.StatusBadRequest, ErrorBody{
+				ErrorMsg: StringPtr(err.Error()),
+				Code:     CodeInvalidQueryParameter,
+			})
+		}
+		users, newLastEvaluatedKey, err = walkToPage(page, pageSize, fetchPage)
+		if err != nil {
+			return repositoryFailure("GetUser", err)
+		}
+		responseBody["page"] = page
+		responseBody["pageSize"] = pageSize
+		responseBody["hasMore"] = newLastEvaluatedKey != ""
+	} else {
+		users, newLastEvaluatedKey, err = fetchPage(limit, lastEvaluatedKey)
+		if err != nil {
+			return repositoryFailure("GetUser", err)
+		}
+	}
 
-	responseBody := map[string]interface{}{
-		"users": users,
+	if len(fields) == 0 {
+		responseBody["users"] = users
+	} else {
+		selected := make([]interface{}, len(users))
+		for i, user := range users {
+			if selected[i], err = selectFields(user, fields); err != nil {
+				return apiResponse(http.StatusInternalServerError, ErrorBody{
+					ErrorMsg: StringPtr("Failed to select fields"),
+					Code:     CodeInternalError,
+				})
+			}
+		}
+		responseBody["users"] = selected
 	}
 	if newLastEvaluatedKey != "" {
 		responseBody["lastEvaluatedKey"] = newLastEvaluatedKey
 	}
 
-	return apiResponse(http.StatusOK, responseBody)
+	// Counting scans the whole table, so it is opt-in and only offered for the unfiltered listing
+	if req.QueryStringParameters["count"] == "true" && lastName == "" && emailPrefix == "" && !searching {
+		total, err := h.userRepo.CountUsers(ctx, opts)
+		if err != nil {
+			return repositoryFailure("GetUser", err)
+		}
+		responseBody["total"] = total
+	}
+
+	return apiResponse(http.StatusOK, responseBody, map[string]string{"Cache-Control": h.cacheControl(ctx, req)})
 }
 
-// CreateUser handles POST requests to create a new user.
-func (h *UserHandler) CreateUser(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	var user models.User
-	if err := json.Unmarshal([]byte(req.Body), &user); err != nil {
+// GetUsersByEmails handles POST /users/lookup, whose body is a JSON array of emails, returning
+// every user found in one call. Emails without a user are reported in the notFound list.
+func (h *UserHandler) GetUsersByEmails(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, lookupUsersParams...); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	var emails []string
+	if err := decodeJSON(req.Body, &emails); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	if len(emails) == 0 {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr("Invalid request body"),
+			ErrorMsg: StringPtr("At least one email is required"),
+			Code:     CodeInvalidRequestBody,
 		})
 	}
 
+	opts := repository.FetchOptions{
+		IncludeDeleted: req.QueryStringParameters["includeDeleted"] == "true",
+	}
+	result, err := h.userRepo.FetchUsersByEmails(ctx, emails, opts)
+	if err != nil {
+		return repositoryFailure("GetUsersByEmails", err)
+	}
+	return apiResponse(http.StatusOK, result)
+}
+
+// fieldErrors extracts the per-field failures from a validation error. Any other error is
+// reported as a single failure without a field.
+func fieldErrors(err error) validators.ValidationErrors {
+	var errs validators.ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return validators.ValidationErrors{{Message: err.Error()}}
+}
+
+// validationFailed rejects a request with 422 Unprocessable Entity, listing every invalid field.
+func validationFailed(errs validators.ValidationErrors) (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusUnprocessableEntity, ValidationErrorBody{
+		Code:   CodeValidationFailed,
+		Errors: errs,
+	})
+}
+
+// parseTimestamp parses an optional RFC3339 query parameter. An empty value yields the zero time.
+func parseTimestamp(name, param string) (time.Time, error) {
+	if param == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: must be an RFC3339 timestamp", name, param)
+	}
+	return t, nil
+}
+
+// maxPageNumber bounds the page parameter, since reaching page N reads all N-1 pages before it.
+const maxPageNumber = 100
+
+// parsePage parses the 1-based page parameter.
+func parsePage(param string) (int, error) {
+	page, err := strconv.Atoi(param)
+	if err != nil || page < 1 {
+		return 0, errors.New("page must be a positive integer")
+	}
+	if page > maxPageNumber {
+		return 0, fmt.Errorf("page must be at most %d; use lastEvaluatedKey to read further", maxPageNumber)
+	}
+	return page, nil
+}
+
+// walkToPage reads pages of pageSize users until it reaches the given 1-based page, and returns that page
+// with its continuation token. Every skipped page costs a full read, which is why tokens are preferred.
+// A page past the end is returned empty.
+func walkToPage(page, pageSize int, fetchPage func(limit int, lastEvaluatedKey string) ([]models.User, string, error)) ([]models.User, string, error) {
+	var lastEvaluatedKey string
+	for skipped := 1; skipped < page; skipped++ {
+		_, next, err := fetchPage(pageSize, lastEvaluatedKey)
+		if err != nil {
+			return nil, "", err
+		}
+		if next == "" {
+			return []models.User{}, "", nil
+		}
+		lastEvaluatedKey = next
+	}
+	return fetchPage(pageSize, lastEvaluatedKey)
+}
+
+// parseOrder parses the order query parameter, reporting whether results should be sorted descending.
+// Only "asc" and "desc" are accepted; an empty value means ascending.
+func parseOrder(param string) (bool, error) {
+	switch strings.ToLower(param) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid order %q: must be asc or desc", param)
+	}
+}
+
+// parseFields parses the comma-separated "fields" query parameter into models.UserFields names.
+// Unknown names are rejected so typos are not silently answered with empty records.
+func parseFields(param string) ([]string, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || slices.Contains(fields, field) {
+			continue
+		}
+		if !slices.Contains(models.UserFields, field) {
+			return nil, fmt.Errorf("unknown field %q in fields; must be among %s", field, strings.Join(models.UserFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// selectFields renders user with only the fields requested through the "fields" parameter, or
+// whole when none were. A projected user still has the zero value of its other fields, which
+// would otherwise be rendered for the fields without omitempty, such as lastName.
+func selectFields(user models.User, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return user, nil
+	}
+	body, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var rendered map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rendered); err != nil {
+		return nil, err
+	}
+	for name := range rendered {
+		if !slices.Contains(fields, name) {
+			delete(rendered, name)
+		}
+	}
+	return rendered, nil
+}
+
+// requestEmail returns the email addressed by the request. The RESTful /users/{email} path
+// parameter takes precedence; the ?email= query parameter is kept for backward compatibility.
+func requestEmail(req events.APIGatewayProxyRequest) string {
+	if email := req.PathParameters["email"]; email != "" {
+		// Clients may percent-encode the "@" in the path segment
+		if decoded, err := url.PathUnescape(email); err == nil {
+			return decoded
+		}
+		return email
+	}
+	return req.QueryStringParameters["email"]
+}
+
+// pageLimit parses the listing limit in the query parameter param. A missing parameter gives the
+// default page size, and values above the maximum are clamped to it. Anything but a positive integer,
+// including an empty value, is an error rather than a silent default, so client bugs surface.
+func (h *UserHandler) pageLimit(req events.APIGatewayProxyRequest, param string) (int, error) {
+	value, ok := req.QueryStringParameters[param]
+	if !ok {
+		return h.opts.DefaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", param)
+	}
+	return min(limit, h.opts.MaxPageSize), nil
+}
+
+// payloadTooLarge rejects a request whose body exceeds MaxBodyBytes. API Gateway already caps
+// payloads, so this is defense-in-depth against wasting memory and CPU on oversized bodies.
+func (h *UserHandler) payloadTooLarge() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusRequestEntityTooLarge, ErrorBody{
+		ErrorMsg: StringPtr(fmt.Sprintf("Request body exceeds the maximum of %d bytes", h.opts.MaxBodyBytes)),
+		Code:     CodePayloadTooLarge,
+	})
+}
+
+// CreateUser handles POST requests to create a new user.
+// Retries carrying the same Idempotency-Key header replay the original response.
+func (h *UserHandler) CreateUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+	return h.idempotent(ctx, req, func() (*events.APIGatewayProxyResponse, error) {
+		return h.createUser(ctx, req)
+	})
+}
+
+// createUser validates and stores the user in the request body.
+func (h *UserHandler) createUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	user, invalid := decodeUser([]byte(req.Body))
+	if invalid != nil {
+		return invalid, nil
+	}
+
+	user.Email = validators.NormalizeEmail(user.Email)
+	validators.SanitizeNames(&user, h.opts.NameSanitization)
+
 	// Validate user data
-	if err := validators.ValidateUser(user); err != nil {
+	warnings, err := validators.ValidateUser(user, h.opts.Validation)
+	if err != nil {
+		return validationFailed(fieldErrors(err))
+	}
+	if !canAssignRole(ctx, user.Role) {
+		return forbidden("Only admins may change a user's role")
+	}
+
+	createdUser, err := h.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserAlreadyExists) {
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr("A user with this email already exists"),
+				Code:     CodeUserAlreadyExists,
+			})
+		}
+		return repositoryFailure("createUser", err)
+	}
+	return apiResponse(http.StatusCreated, withWarnings(createdUser, warnings), map[string]string{"Location": userLocation(createdUser.Email)})
+}
+
+// decodeUser checks a single-user request body against the user JSON Schema and decodes it.
+// A body that is not JSON yields 400 and a schema violation 422; the response is nil on success.
+func decodeUser(body []byte) (models.User, *events.APIGatewayProxyResponse) {
+	var user models.User
+	if err := validators.ValidateUserJSON(body); err != nil {
+		var errs validators.ValidationErrors
+		if errors.As(err, &errs) {
+			resp, _ := validationFailed(errs)
+			return user, resp
+		}
+		resp, _ := invalidBody(string(body), err)
+		return user, resp
+	}
+	if err := decodeJSON(string(body), &user); err != nil {
+		resp, _ := invalidBody(string(body), err)
+		return user, resp
+	}
+	return user, nil
+}
+
+// userLocation returns the path of the user resource with the given email.
+func userLocation(email string) string {
+	return "/users/" + url.PathEscape(email)
+}
+
+// CreateUsers handles bulk POST requests whose body is a JSON array of users.
+// Every user is validated before anything is written; a single invalid user rejects the whole batch.
+// Users that already exist or could not be written are reported as failed in the BatchResult.
+func (h *UserHandler) CreateUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	var bodies []json.RawMessage
+	if err := decodeJSON(req.Body, &bodies); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	if len(bodies) == 0 {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
+			ErrorMsg: StringPtr("At least one user is required"),
+			Code:     CodeInvalidRequestBody,
 		})
 	}
 
-	createdUser, err := h.userRepo.CreateUser(user)
+	var invalid validators.ValidationErrors
+	for i, body := range bodies {
+		if err := validators.ValidateUserJSON(body); err != nil {
+			invalid = append(invalid, fieldErrors(err).Prefixed(fmt.Sprintf("[%d].", i))...)
+		}
+	}
+	if len(invalid) > 0 {
+		return validationFailed(invalid)
+	}
+	var users []models.User
+	if err := decodeJSON(req.Body, &users); err != nil {
+		return invalidBody(req.Body, err)
+	}
+
+	seen := make(map[string]bool, len(users))
+	var warnings validators.ValidationWarnings
+	for i := range users {
+		users[i].Email = validators.NormalizeEmail(users[i].Email)
+		validators.SanitizeNames(&users[i], h.opts.NameSanitization)
+		user := users[i]
+		prefix := fmt.Sprintf("[%d].", i)
+		userWarnings, err := validators.ValidateUser(user, h.opts.Validation)
+		if err != nil {
+			invalid = append(invalid, fieldErrors(err).Prefixed(prefix)...)
+		}
+		warnings = append(warnings, userWarnings.Prefixed(prefix)...)
+		if user.Email != "" && seen[user.Email] {
+			invalid = append(invalid, validators.FieldError{
+				Field:   prefix + "email",
+				Message: "duplicate email " + user.Email,
+			})
+		}
+		seen[user.Email] = true
+	}
+	if len(invalid) > 0 {
+		return validationFailed(invalid)
+	}
+	for _, user := range users {
+		if !canAssignRole(ctx, user.Role) {
+			return forbidden("Only admins may change a user's role")
+		}
+	}
+
+	created, err := h.userRepo.CreateUsers(ctx, users)
 	if err != nil {
-		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
+		return repositoryFailure("CreateUsers", err)
+	}
+
+	result := BatchResult{Succeeded: created.Created, Warnings: warnings}
+	for _, email := range created.Existing {
+		result.Failed = append(result.Failed, BatchFailure{
+			Email: email,
+			Error: "A user with this email already exists",
+			Code:  CodeUserAlreadyExists,
+		})
+	}
+	for _, email := range created.Failed {
+		result.Failed = append(result.Failed, BatchFailure{
+			Email: email,
+			Error: "The user could not be written; please retry",
+			Code:  CodeInternalError,
 		})
 	}
-	return apiResponse(http.StatusCreated, createdUser)
+	return batchResponse(http.StatusCreated, result)
 }
 
 // UpdateUser handles PUT requests to update an existing user.
-func (h *UserHandler) UpdateUser(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	var user models.User
-	if err := json.Unmarshal([]byte(req.Body), &user); err != nil {
-		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr("Invalid request body"),
-		})
+// With ?upsert=true a missing user is created instead of yielding 404.
+func (h *UserHandler) UpdateUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, updateUserParams...); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	user, invalid := decodeUser([]byte(req.Body))
+	if invalid != nil {
+		return invalid, nil
 	}
 
-	// Email is required for update
+	// Email is required for update, and must be the one addressed by /users/{email} (or ?email=) if any
+	user.Email = validators.NormalizeEmail(user.Email)
+	if target := validators.NormalizeEmail(requestEmail(req)); target != "" {
+		if user.Email != target {
+			return apiResponse(http.StatusBadRequest, ErrorBody{
+				ErrorMsg: StringPtr("The email in the body does not match the addressed user; use PUT /users/{email}/email to change it"),
+				Code:     CodeInvalidRequest,
+			})
+		}
+	}
 	if user.Email == "" {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
 			ErrorMsg: StringPtr("Email is required for user update"),
+			Code:     CodeInvalidRequest,
 		})
 	}
 
+	if !canModify(ctx, user.Email) {
+		return forbidden("You may only update your own user")
+	}
+	if !canAssignRole(ctx, user.Role) {
+		return forbidden("Only admins may change a user's role")
+	}
+
 	// Validate user data (excluding email format if not changing, but general content validation)
 	// For simplicity, re-validating the whole user struct.
-	if err := validators.ValidateUser(user); err != nil {
-		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
-		})
+	validators.SanitizeNames(&user, h.opts.NameSanitization)
+	warnings, err := validators.ValidateUser(user, h.opts.Validation)
+	if err != nil {
+		return validationFailed(fieldErrors(err))
+	}
+
+	// An If-Match makes an upsert a conditional update: the precondition needs a current user, so a
+	// missing one fails it with 412 instead of being created
+	upsert := req.QueryStringParameters["upsert"] == "true"
+	ifMatch := requestHeader(req, "If-Match")
+	if ifMatch != "" {
+		if failed := h.checkIfMatch(ctx, ifMatch, &user, upsert); failed != nil {
+			return failed, nil
+		}
+	} else if upsert {
+		return h.upsertUser(ctx, user, warnings)
 	}
 
-	updatedUser, err := h.userRepo.UpdateUser(user)
+	updatedUser, err := h.userRepo.UpdateUser(ctx, user)
 	if err != nil {
 		// Specific error checks for 404 vs 400
-		if err.Error() == repository.ErrorUserDoesNotExist {
+		if errors.Is(err, repository.ErrUserDoesNotExist) {
+			if upsert {
+				return preconditionFailed()
+			}
 			return apiResponse(http.StatusNotFound, ErrorBody{
 				ErrorMsg: StringPtr("User not found for update"),
+				Code:     CodeUserNotFound,
 			})
 		}
-		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if ifMatch != "" {
+				// The user changed between the precondition check and the write
+				return preconditionFailed()
+			}
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr(err.Error()),
+				Code:     CodeConflict,
+			})
+		}
+		return repositoryFailure("UpdateUser", err)
+	}
+
+	etag, err := userETag(updatedUser)
+	if err != nil {
+		return apiResponse(http.StatusOK, withWarnings(updatedUser, warnings))
+	}
+	return apiResponse(http.StatusOK, withWarnings(updatedUser, warnings), map[string]string{"ETag": etag})
+}
+
+// checkIfMatch evaluates an If-Match header against the stored user. When it matches, user.Version is
+// set to the stored version, so the conditional update also fails if the user changes before the write.
+// It returns 412 Precondition Failed when the stored user no longer has one of the listed ETags, and
+// 404 when there is no user, or 412 with upsert, where a missing user would otherwise be created.
+// "*" only requires the user to exist, which the update checks itself.
+func (h *UserHandler) checkIfMatch(ctx context.Context, ifMatch string, user *models.User, upsert bool) *events.APIGatewayProxyResponse {
+	if strings.TrimSpace(ifMatch) == "*" {
+		return nil
+	}
+	current, err := h.userRepo.FetchUser(ctx, user.Email, repository.FetchOptions{ConsistentRead: true})
+	if err != nil {
+		resp, _ := repositoryFailure("UpdateUser", err)
+		return resp
+	}
+	if current == nil && upsert {
+		resp, _ := preconditionFailed()
+		return resp
+	}
+	if current == nil {
+		resp, _ := apiResponse(http.StatusNotFound, ErrorBody{
+			ErrorMsg: StringPtr("User not found for update"),
+			Code:     CodeUserNotFound,
 		})
+		return resp
+	}
+	etag, err := userETag(current)
+	if err != nil {
+		resp, _ := apiResponse(http.StatusInternalServerError, ErrorBody{
+			ErrorMsg: StringPtr("Failed to compute ETag"),
+			Code:     CodeInternalError,
+		})
+		return resp
+	}
+	if !etagMatches(ifMatch, etag) {
+		resp, _ := preconditionFailed()
+		return resp
 	}
-	return apiResponse(http.StatusOK, updatedUser)
+	user.Version = current.Version
+	return nil
 }
 
+// upsertUser writes the user regardless of whether it exists, answering 201 when it was created
+// and 200 when an existing user was replaced. The validation warnings are returned with the user.
+func (h *UserHandler) upsertUser(ctx context.Context, user models.User, warnings validators.ValidationWarnings) (*events.APIGatewayProxyResponse, error) {
+	upserted, created, err := h.userRepo.UpsertUser(ctx, user)
+	if err != nil {
+		return repositoryFailure("upsertUser", err)
+	}
+	if created {
+		return apiResponse(http.StatusCreated, withWarnings(upserted, warnings), map[string]string{"Location": userLocation(upserted.Email)})
+	}
+	return apiResponse(http.StatusOK, withWarnings(upserted, warnings))
+}
+
+// returnRepresentation is the DeleteUser "return" query value asking for the deleted user
+// to be returned with a 200 instead of an empty 204.
+const returnRepresentation = "representation"
+
 // DeleteUser handles DELETE requests to delete a user by email.
-func (h *UserHandler) DeleteUser(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	email := req.QueryStringParameters["email"]
+func (h *UserHandler) DeleteUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, deleteUserParams...); invalid != nil {
+		return invalid, nil
+	}
+	email := requestEmail(req)
 	if email == "" {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr("Email query parameter is required for deletion"),
+			ErrorMsg: StringPtr("Email path or query parameter is required for deletion"),
+			Code:     CodeInvalidRequest,
+		})
+	}
+	if !canModify(ctx, email) {
+		return forbidden("You may only delete your own user")
+	}
+	returnPref := req.QueryStringParameters["return"]
+	if returnPref != "" && returnPref != returnRepresentation {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr("return must be " + returnRepresentation),
+			Code:     CodeInvalidQueryParameter,
 		})
 	}
 
-	err := h.userRepo.DeleteUser(email)
+	deleted, err := h.userRepo.DeleteUser(ctx, email)
 	if err != nil {
 		// Specific error checks for 404 vs 400
-		if err.Error() == repository.ErrorUserDoesNotExist {
+		if errors.Is(err, repository.ErrUserDoesNotExist) {
 			return apiResponse(http.StatusNotFound, ErrorBody{
 				ErrorMsg: StringPtr("User not found for deletion"),
+				Code:     CodeUserNotFound,
 			})
 		}
+		return repositoryFailure("DeleteUser", err)
+	}
+	if returnPref == returnRepresentation {
+		return apiResponse(http.StatusOK, deleted)
+	}
+	return apiResponse(http.StatusNoContent, nil) // 204 No Content for successful deletion
+}
+
+// DeleteUsers handles bulk DELETE requests whose body is a JSON array of emails.
+// Missing users do not fail the batch; they are reported as failed in the BatchResult, like the
+// users that could not be deleted.
+func (h *UserHandler) DeleteUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	var emails []string
+	if err := decodeJSON(req.Body, &emails); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	if len(emails) == 0 {
 		return apiResponse(http.StatusBadRequest, ErrorBody{
-			ErrorMsg: StringPtr(err.Error()),
+			ErrorMsg: StringPtr("At least one email is required"),
+			Code:     CodeInvalidRequestBody,
 		})
 	}
-	return apiResponse(http.StatusNoContent, nil) // 204 No Content for successful deletion
-}
\ No newline at end of file
+	for _, email := range emails {
+		if !canModify(ctx, email) {
+			return forbidden("You may only delete your own user")
+		}
+	}
+
+	deleted, err := h.userRepo.DeleteUsers(ctx, emails)
+	if err != nil {
+		return repositoryFailure("DeleteUsers", err)
+	}
+	result := BatchResult{Succeeded: deleted.Deleted}
+	for _, email := range deleted.NotFound {
+		result.Failed = append(result.Failed, BatchFailure{
+			Email: email,
+			Error: "User not found for deletion",
+			Code:  CodeUserNotFound,
+		})
+	}
+	for _, email := range deleted.Failed {
+		result.Failed = append(result.Failed, BatchFailure{
+			Email: email,
+			Error: "The user could not be deleted; please retry",
+			Code:  CodeInternalError,
+		})
+	}
+	return batchResponse(http.StatusOK, result)
+}
+
+// PurgeResult is the response body of PurgeUsers.
+type PurgeResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// PurgeUsers handles DELETE /users/all, removing every user from the table. It is only
+// available to admins and only when destructive operations are enabled in the configuration.
+func (h *UserHandler) PurgeUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may delete all users")
+	}
+
+	deleted, err := h.userRepo.DeleteAllUsers(ctx)
+	if err != nil {
+		if errors.Is(err, repository.ErrDestructiveOpsDisabled) {
+			return forbidden("Destructive operations are disabled")
+		}
+		return repositoryFailure("PurgeUsers", err)
+	}
+	return apiResponse(http.StatusOK, PurgeResult{Deleted: deleted})
+}
+
+// MigrationResult is the response body of MigrateUsers.
+type MigrationResult struct {
+	Migrated int `json:"migrated"`
+}
+
+// MigrateUsers handles POST /users/migrations, backfilling the attributes that users written by
+// older versions lack. It is idempotent and only available to admins.
+func (h *UserHandler) MigrateUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if !isAdmin(ctx) {
+		return forbidden("Only admins may migrate users")
+	}
+
+	migrated, err := h.userRepo.MigrateUsers(ctx)
+	if err != nil {
+		return repositoryFailure("MigrateUsers", err)
+	}
+	return apiResponse(http.StatusOK, MigrationResult{Migrated: migrated})
+}
+
+// TransactUsers handles POST requests that apply a list of create/update/delete operations atomically.
+// Either all operations succeed or none is applied; a cancelled transaction yields 409 with the reasons.
+func (h *UserHandler) TransactUsers(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req); invalid != nil {
+		return invalid, nil
+	}
+	if len(req.Body) > h.opts.MaxBodyBytes {
+		return h.payloadTooLarge()
+	}
+
+	var ops []repository.UserOperation
+	if err := decodeJSON(req.Body, &ops); err != nil {
+		return invalidBody(req.Body, err)
+	}
+	if len(ops) == 0 {
+		return apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr("At least one operation is required"),
+			Code:     CodeInvalidRequestBody,
+		})
+	}
+
+	var invalid validators.ValidationErrors
+	var warnings validators.ValidationWarnings
+	for i := range ops {
+		ops[i].User.Email = validators.NormalizeEmail(ops[i].User.Email)
+		prefix := fmt.Sprintf("[%d].", i)
+		switch ops[i].Type {
+		case repository.OperationCreate, repository.OperationUpdate:
+			validators.SanitizeNames(&ops[i].User, h.opts.NameSanitization)
+			userWarnings, err := validators.ValidateUser(ops[i].User, h.opts.Validation)
+			if err != nil {
+				invalid = append(invalid, fieldErrors(err).Prefixed(prefix+"user.")...)
+			}
+			warnings = append(warnings, userWarnings.Prefixed(prefix+"user.")...)
+		case repository.OperationDelete:
+			if ops[i].User.Email == "" {
+				invalid = append(invalid, validators.FieldError{Field: prefix + "user.email", Message: "email is required"})
+			}
+		default:
+			invalid = append(invalid, validators.FieldError{Field: prefix + "type", Message: "type must be one of create, update, delete"})
+		}
+	}
+	if len(invalid) > 0 {
+		return validationFailed(invalid)
+	}
+
+	for _, op := range ops {
+		if op.Type != repository.OperationCreate && !canModify(ctx, op.User.Email) {
+			return forbidden("You may only update or delete your own user")
+		}
+		if !canAssignRole(ctx, op.User.Role) {
+			return forbidden("Only admins may change a user's role")
+		}
+	}
+
+	if err := h.userRepo.TransactWriteUsers(ctx, ops); err != nil {
+		if errors.Is(err, repository.ErrTransactionCanceled) {
+			return apiResponse(http.StatusConflict, ErrorBody{
+				ErrorMsg: StringPtr(err.Error()),
+				Code:     CodeTransactionCanceled,
+			})
+		}
+		return repositoryFailure("TransactUsers", err)
+	}
+	body := map[string]interface{}{
+		"processed": len(ops),
+	}
+	if len(warnings) > 0 {
+		body["warnings"] = warnings
+	}
+	return apiResponse(http.StatusOK, body)
+}
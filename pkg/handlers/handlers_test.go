@@ -0,0 +1,836 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// newTestHandler returns a UserHandler with default options over an in-memory repository
+// holding users.
+func newTestHandler(t *testing.T, users ...models.User) (*UserHandler, *repository.InMemoryUserRepository) {
+	t.Helper()
+	repo := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{})
+	for _, user := range users {
+		if _, err := repo.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("seeding %s: %v", user.Email, err)
+		}
+	}
+	h := NewUserHandler(repo, UserHandlerOptions{})
+	return &h, repo
+}
+
+// decodeResponse unmarshals the JSON body of resp into a T.
+func decodeResponse[T any](t *testing.T, resp *events.APIGatewayProxyResponse) T {
+	t.Helper()
+	var body T
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatalf("decoding body %q: %v", resp.Body, err)
+	}
+	return body
+}
+
+func TestUpdateUserAddressedByPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{
+			name:       "path and body agree",
+			path:       "a@example.com",
+			body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "body email in another case",
+			path:       "a@example.com",
+			body:       `{"email":"A@Example.com","firstName":"Ann","lastName":"Lee"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "percent-encoded path",
+			path:       "a%40example.com",
+			body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "body addressing another user",
+			path:       "a@example.com",
+			body:       `{"email":"b@example.com","firstName":"Ann","lastName":"Lee"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidRequest,
+		},
+		{
+			name:       "missing user",
+			path:       "c@example.com",
+			body:       `{"email":"c@example.com","firstName":"Ann","lastName":"Lee"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t,
+				models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"},
+				models.User{Email: "b@example.com", FirstName: "Bea", LastName: "Lee"},
+			)
+			resp, err := h.UpdateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodPut,
+				PathParameters: map[string]string{"email": tt.path},
+				Body:           tt.body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantCode != "" {
+				if got := decodeResponse[ErrorBody](t, resp).Code; got != tt.wantCode {
+					t.Errorf("code = %s, want %s", got, tt.wantCode)
+				}
+			}
+
+			other, err := repo.FetchUser(context.Background(), "b@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other.FirstName != "Bea" {
+				t.Errorf("b@example.com was changed to %q", other.FirstName)
+			}
+		})
+	}
+}
+
+func TestCreateUsersReportsExistingUsers(t *testing.T) {
+	h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+
+	resp, err := h.CreateUsers(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Body: `[{"email":"a@example.com","firstName":"Ann","lastName":"Lee"},
+			{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusMultiStatus, resp.Body)
+	}
+	result := decodeResponse[struct {
+		Succeeded []models.User
+		Failed    []BatchFailure
+	}](t, resp)
+	if len(result.Succeeded) != 1 || result.Succeeded[0].Email != "b@example.com" {
+		t.Errorf("succeeded = %v, want b@example.com", result.Succeeded)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Email != "a@example.com" || result.Failed[0].Code != CodeUserAlreadyExists {
+		t.Errorf("failed = %v, want a@example.com with %s", result.Failed, CodeUserAlreadyExists)
+	}
+
+	stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FirstName != "Ada" {
+		t.Errorf("a@example.com was overwritten with %q", stored.FirstName)
+	}
+}
+
+func TestTransactUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   ErrorCode
+		wantFirst  string // first name of a@example.com afterwards
+	}{
+		{
+			name: "all operations succeed",
+			body: `[{"type":"update","user":{"email":"a@example.com","firstName":"Ann","lastName":"Lee","version":1}},
+				{"type":"create","user":{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}}]`,
+			wantStatus: http.StatusOK,
+			wantFirst:  "Ann",
+		},
+		{
+			name: "cancelled by a missing user",
+			body: `[{"type":"update","user":{"email":"a@example.com","firstName":"Ann","lastName":"Lee","version":1}},
+				{"type":"delete","user":{"email":"nobody@example.com"}}]`,
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeTransactionCanceled,
+			wantFirst:  "Ada",
+		},
+		{
+			name:       "unknown type",
+			body:       `[{"type":"upsert","user":{"email":"a@example.com"}}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidationFailed,
+			wantFirst:  "Ada",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+
+			resp, err := h.TransactUsers(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantCode != "" {
+				if got := decodeResponse[ErrorBody](t, resp).Code; got != tt.wantCode {
+					t.Errorf("code = %s, want %s", got, tt.wantCode)
+				}
+			}
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.FirstName != tt.wantFirst {
+				t.Errorf("first name = %q, want %q", stored.FirstName, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestOversizedBodiesAreRejected(t *testing.T) {
+	const maxBodyBytes = 64
+	body := `{"email":"a@example.com","firstName":"Ann","lastName":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	tests := []struct {
+		name   string
+		handle func(h *UserHandler, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+	}{
+		{"CreateUser", func(h *UserHandler, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return h.CreateUser(context.Background(), req)
+		}},
+		{"UpdateUser", func(h *UserHandler, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return h.UpdateUser(context.Background(), req)
+		}},
+		{"CreateUsers", func(h *UserHandler, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return h.CreateUsers(context.Background(), req)
+		}},
+		{"TransactUsers", func(h *UserHandler, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return h.TransactUsers(context.Background(), req)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+			h.opts.MaxBodyBytes = maxBodyBytes
+
+			resp, err := tt.handle(h, events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodPost,
+				PathParameters: map[string]string{"email": "a@example.com"},
+				Body:           body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusRequestEntityTooLarge, resp.Body)
+			}
+			if got := decodeResponse[ErrorBody](t, resp).Code; got != CodePayloadTooLarge {
+				t.Errorf("code = %s, want %s", got, CodePayloadTooLarge)
+			}
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.FirstName != "Ada" {
+				t.Errorf("a@example.com was changed to %q", stored.FirstName)
+			}
+		})
+	}
+}
+
+func TestBodyAtTheLimitIsAccepted(t *testing.T) {
+	body := `{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}`
+	h, _ := newTestHandler(t)
+	h.opts.MaxBodyBytes = len(body)
+
+	resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusCreated, resp.Body)
+	}
+}
+
+func TestUserAddressedByPathOrQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      map[string]string
+		query     map[string]string
+		wantEmail string // the user addressed, or "" for a 400
+	}{
+		{name: "path", path: map[string]string{"email": "a@example.com"}, wantEmail: "a@example.com"},
+		{name: "percent-encoded path", path: map[string]string{"email": "a%40example.com"}, wantEmail: "a@example.com"},
+		{name: "query", query: map[string]string{"email": "a@example.com"}, wantEmail: "a@example.com"},
+		{name: "path takes precedence", path: map[string]string{"email": "b@example.com"}, query: map[string]string{"email": "a@example.com"}, wantEmail: "b@example.com"},
+		{name: "neither"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t,
+				models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"},
+				models.User{Email: "b@example.com", FirstName: "Bea", LastName: "Lee"},
+			)
+			req := events.APIGatewayProxyRequest{PathParameters: tt.path, QueryStringParameters: tt.query}
+
+			req.HTTPMethod = http.MethodGet
+			resp, err := h.GetUser(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantEmail != "" {
+				if got := decodeResponse[models.User](t, resp).Email; resp.StatusCode != http.StatusOK || got != tt.wantEmail {
+					t.Errorf("GET: status %d for %q, want %d for %q", resp.StatusCode, got, http.StatusOK, tt.wantEmail)
+				}
+			}
+
+			req.HTTPMethod = http.MethodDelete
+			resp, err = h.DeleteUser(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantEmail == "" {
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("DELETE: status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusNoContent {
+				t.Fatalf("DELETE: status = %d, want %d (body %s)", resp.StatusCode, http.StatusNoContent, resp.Body)
+			}
+			if deleted, err := repo.FetchUser(context.Background(), tt.wantEmail, repository.FetchOptions{}); err != nil || deleted != nil {
+				t.Errorf("DELETE: %s = %+v, %v, want it deleted", tt.wantEmail, deleted, err)
+			}
+		})
+	}
+}
+
+func TestCreateUserReportsEveryInvalidField(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Body:       `{"email":"a@example.com","firstName":"","lastName":"Lee","phone":"12345","role":"owner"}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusUnprocessableEntity, resp.Body)
+	}
+	body := decodeResponse[ValidationErrorBody](t, resp)
+	if body.Code != CodeValidationFailed {
+		t.Errorf("code = %s, want %s", body.Code, CodeValidationFailed)
+	}
+	var fields []string
+	for _, fieldErr := range body.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	slices.Sort(fields) // The schema check reports fields in no particular order
+	if want := []string{"firstName", "phone", "role"}; !slices.Equal(fields, want) {
+		t.Errorf("fields = %v, want %v (body %s)", fields, want, resp.Body)
+	}
+}
+
+func TestPasswordIsNeverReturned(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","password":"secret123"}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusCreated, resp.Body)
+	}
+	if strings.Contains(resp.Body, "secret123") || strings.Contains(strings.ToLower(resp.Body), "password") {
+		t.Errorf("body = %s, want no password", resp.Body)
+	}
+	if err := repo.VerifyPassword(context.Background(), "a@example.com", "secret123"); err != nil {
+		t.Errorf("VerifyPassword: err = %v, want none", err)
+	}
+}
+
+func TestCreateUserEnforcesTheSchema(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Body:       `{"email":"a@example.com","firstName":"Ann","isAdmin":true}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusUnprocessableEntity, resp.Body)
+	}
+	body := decodeResponse[ValidationErrorBody](t, resp)
+	if body.Code != CodeValidationFailed || len(body.Errors) == 0 {
+		t.Fatalf("body = %s, want the schema errors", resp.Body)
+	}
+	var messages []string
+	for _, fieldErr := range body.Errors {
+		messages = append(messages, fieldErr.Message)
+	}
+	joined := strings.Join(messages, "; ")
+	if !strings.Contains(joined, "lastName") || !strings.Contains(joined, "isAdmin") {
+		t.Errorf("errors = %q, want the missing lastName and the unknown isAdmin", joined)
+	}
+	if exists, _ := repo.UserExists(context.Background(), "a@example.com"); exists {
+		t.Error("user created despite the schema violation")
+	}
+}
+
+func TestUpdateUserUpsert(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		query        map[string]string
+		wantStatus   int
+		wantLocation bool
+	}{
+		{name: "upsert creates a missing user", email: "b@example.com", query: map[string]string{"upsert": "true"}, wantStatus: http.StatusCreated, wantLocation: true},
+		{name: "upsert updates an existing user", email: "a@example.com", query: map[string]string{"upsert": "true"}, wantStatus: http.StatusOK},
+		{name: "strict update of a missing user", email: "b@example.com", wantStatus: http.StatusNotFound},
+		{name: "strict update of an existing user", email: "a@example.com", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+
+			resp, err := h.UpdateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodPut,
+				PathParameters:        map[string]string{"email": tt.email},
+				QueryStringParameters: tt.query,
+				Body:                  `{"email":"` + tt.email + `","firstName":"Ann","lastName":"Lee"}`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if hasLocation := resp.Headers["Location"] != ""; hasLocation != tt.wantLocation {
+				t.Errorf("Location = %q, want one: %v", resp.Headers["Location"], tt.wantLocation)
+			}
+			stored, err := repo.FetchUser(context.Background(), tt.email, repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				if stored != nil {
+					t.Errorf("strict update created %+v", stored)
+				}
+				return
+			}
+			if stored == nil || stored.FirstName != "Ann" {
+				t.Errorf("stored = %+v, want the upserted user", stored)
+			}
+		})
+	}
+}
+
+func TestGetUserFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		wantKeys   []string
+	}{
+		{name: "one user", query: map[string]string{"email": "a@example.com", "fields": "email, firstName"}, wantStatus: http.StatusOK, wantKeys: []string{"email", "firstName"}},
+		{name: "listing", query: map[string]string{"fields": "email"}, wantStatus: http.StatusOK, wantKeys: []string{"email"}},
+		{name: "unknown field", query: map[string]string{"email": "a@example.com", "fields": "email,passwordHash"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee", Phone: "+14155552671"})
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, QueryStringParameters: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got := decodeResponse[ErrorBody](t, resp).Code; got != CodeInvalidQueryParameter {
+					t.Errorf("code = %s, want %s", got, CodeInvalidQueryParameter)
+				}
+				return
+			}
+			user := decodeResponse[map[string]any](t, resp)
+			if users, ok := user["users"].([]any); ok && len(users) == 1 {
+				user, _ = users[0].(map[string]any)
+			}
+			keys := slices.Sorted(maps.Keys(user))
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v (body %s)", keys, tt.wantKeys, resp.Body)
+			}
+		})
+	}
+}
+
+func TestCreateUserLocation(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "a@example.com", want: "/users/a@example.com"},
+		{email: "Ann.Lee+news@Example.com", want: "/users/ann.lee+news@example.com"},
+		{email: "50%off@example.com", want: "/users/50%25off@example.com"},
+		{email: "a#b?c@example.com", want: "/users/a%23b%3Fc@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+
+			resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"email":"` + tt.email + `","firstName":"Ann","lastName":"Lee"}`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusCreated, resp.Body)
+			}
+			if got := resp.Headers["Location"]; got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUsersByEmailPrefix(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		want       []string
+	}{
+		{name: "prefix", query: map[string]string{"emailPrefix": "support@"}, wantStatus: http.StatusOK, want: []string{"support@example.com"}},
+		{name: "combined with lastName", query: map[string]string{"emailPrefix": "support@", "lastName": "Lee"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t,
+				models.User{Email: "support@example.com", FirstName: "Sam", LastName: "Lee"},
+				models.User{Email: "sales@example.com", FirstName: "Sal", LastName: "Lee"},
+			)
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got []string
+			for _, user := range decodeResponse[struct{ Users []models.User }](t, resp).Users {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("emails = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUsersByEmails(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantUsers    []string
+		wantNotFound []string
+	}{
+		{
+			name:         "existing and missing",
+			body:         `["b@example.com","nobody@example.com","A@Example.com"]`,
+			wantStatus:   http.StatusOK,
+			wantUsers:    []string{"b@example.com", "a@example.com"},
+			wantNotFound: []string{"nobody@example.com"},
+		},
+		{name: "empty list", body: `[]`, wantStatus: http.StatusBadRequest},
+		{name: "not a list", body: `{"emails":["a@example.com"]}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t,
+				models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"},
+				models.User{Email: "b@example.com", FirstName: "Bo", LastName: "Lee"},
+			)
+
+			resp, err := h.GetUsersByEmails(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			result := decodeResponse[repository.BatchFetchResult](t, resp)
+			var users []string
+			for _, user := range result.Users {
+				users = append(users, user.Email)
+			}
+			if !slices.Equal(users, tt.wantUsers) || !slices.Equal(result.NotFound, tt.wantNotFound) {
+				t.Errorf("users = %v, not found = %v, want %v and %v", users, result.NotFound, tt.wantUsers, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestDeleteUserReturnRepresentation(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		returnPref string
+		wantStatus int
+	}{
+		{name: "no preference", email: "a@example.com", wantStatus: http.StatusNoContent},
+		{name: "representation", email: "a@example.com", returnPref: "representation", wantStatus: http.StatusOK},
+		{name: "unknown preference", email: "a@example.com", returnPref: "minimal", wantStatus: http.StatusBadRequest},
+		{name: "missing user", email: "b@example.com", returnPref: "representation", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee", Metadata: map[string]string{"plan": "pro"}})
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var query map[string]string
+			if tt.returnPref != "" {
+				query = map[string]string{"return": tt.returnPref}
+			}
+
+			resp, err := h.DeleteUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodDelete,
+				PathParameters:        map[string]string{"email": tt.email},
+				QueryStringParameters: query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			deleted := decodeResponse[models.User](t, resp)
+			if deleted.Email != stored.Email || deleted.FirstName != stored.FirstName || deleted.Version != stored.Version ||
+				deleted.CreatedAt != stored.CreatedAt || !maps.Equal(deleted.Metadata, stored.Metadata) {
+				t.Errorf("deleted = %+v, want %+v", deleted, *stored)
+			}
+			if gone, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{}); err != nil || gone != nil {
+				t.Errorf("FetchUser after delete = %v, %v, want no user", gone, err)
+			}
+		})
+	}
+}
+
+// existingUserRepository fails every create the way the DynamoDB repository reports a taken email.
+type existingUserRepository struct {
+	repository.UserRepository
+}
+
+func (existingUserRepository) CreateUser(context.Context, models.User) (*models.User, error) {
+	return nil, fmt.Errorf("%w: %w", repository.ErrUserAlreadyExists, errors.New("ConditionalCheckFailedException"))
+}
+
+func TestCreateUserConflict(t *testing.T) {
+	tests := []struct {
+		name  string
+		repo  repository.UserRepository // The in-memory repository holding a@example.com when nil
+		email string
+	}{
+		{name: "same email", email: "a@example.com"},
+		{name: "email in another case", email: "A@Example.com"},
+		{name: "wrapped repository error", repo: existingUserRepository{}, email: "a@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+			if tt.repo != nil {
+				*h = NewUserHandler(tt.repo, UserHandlerOptions{})
+			}
+
+			resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Body:       `{"email":"` + tt.email + `","firstName":"Ann","lastName":"Lee"}`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusConflict {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusConflict, resp.Body)
+			}
+			body := decodeResponse[ErrorBody](t, resp)
+			if body.Code != CodeUserAlreadyExists {
+				t.Errorf("code = %s, want %s", body.Code, CodeUserAlreadyExists)
+			}
+			if body.ErrorMsg == nil || *body.ErrorMsg != "A user with this email already exists" {
+				t.Errorf("error = %v, want the email to be reported as taken", body.ErrorMsg)
+			}
+		})
+	}
+}
+
+func TestCreateTemporaryUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttlSeconds  string // Omitted when empty
+		wantStatus  int
+		wantExpires bool
+	}{
+		{name: "permanent", wantStatus: http.StatusCreated},
+		{name: "one hour", ttlSeconds: "3600", wantStatus: http.StatusCreated, wantExpires: true},
+		{name: "zero", ttlSeconds: "0", wantStatus: http.StatusUnprocessableEntity},
+		{name: "negative", ttlSeconds: "-1", wantStatus: http.StatusUnprocessableEntity},
+		{name: "over a year", ttlSeconds: "31536001", wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+			body := `{"email":"guest@example.com","firstName":"Guest","lastName":"Lee"}`
+			if tt.ttlSeconds != "" {
+				body = strings.TrimSuffix(body, "}") + `,"ttlSeconds":` + tt.ttlSeconds + "}"
+			}
+
+			resp, err := h.CreateUser(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			created := decodeResponse[models.User](t, resp)
+			if (created.ExpiresAt > 0) != tt.wantExpires || created.TTLSeconds != 0 {
+				t.Errorf("expiresAt = %d, ttlSeconds = %d, want an expiry: %v", created.ExpiresAt, created.TTLSeconds, tt.wantExpires)
+			}
+		})
+	}
+}
+
+func TestBatchEndpointsShareTheResultShape(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        func(*UserHandler, context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		body          string
+		wantStatus    int
+		wantSucceeded []string
+		wantFailed    map[string]ErrorCode
+	}{
+		{
+			name:          "create with an existing user",
+			method:        (*UserHandler).CreateUsers,
+			body:          `[{"email":"a@example.com","firstName":"Ann","lastName":"Lee"},{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}]`,
+			wantStatus:    http.StatusMultiStatus,
+			wantSucceeded: []string{"b@example.com"},
+			wantFailed:    map[string]ErrorCode{"a@example.com": CodeUserAlreadyExists},
+		},
+		{
+			name:          "create without failures",
+			method:        (*UserHandler).CreateUsers,
+			body:          `[{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}]`,
+			wantStatus:    http.StatusCreated,
+			wantSucceeded: []string{"b@example.com"},
+			wantFailed:    map[string]ErrorCode{},
+		},
+		{
+			name:          "delete with a missing user",
+			method:        (*UserHandler).DeleteUsers,
+			body:          `["a@example.com","b@example.com"]`,
+			wantStatus:    http.StatusMultiStatus,
+			wantSucceeded: []string{"a@example.com"},
+			wantFailed:    map[string]ErrorCode{"b@example.com": CodeUserNotFound},
+		},
+		{
+			name:          "delete without failures",
+			method:        (*UserHandler).DeleteUsers,
+			body:          `["a@example.com"]`,
+			wantStatus:    http.StatusOK,
+			wantSucceeded: []string{"a@example.com"},
+			wantFailed:    map[string]ErrorCode{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"})
+
+			resp, err := tt.method(h, context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if keys := slices.Sorted(maps.Keys(decodeResponse[map[string]json.RawMessage](t, resp))); !slices.Equal(keys, []string{"failed", "succeeded"}) {
+				t.Errorf("body keys = %v, want failed and succeeded", keys)
+			}
+
+			// Created users are listed in full, deleted ones by email
+			body := decodeResponse[struct {
+				Succeeded []json.RawMessage
+				Failed    []BatchFailure
+			}](t, resp)
+			var succeeded []string
+			for _, item := range body.Succeeded {
+				var user models.User
+				if err := json.Unmarshal(item, &user.Email); err != nil {
+					if err := json.Unmarshal(item, &user); err != nil {
+						t.Fatal(err)
+					}
+				}
+				succeeded = append(succeeded, user.Email)
+			}
+			if !slices.Equal(succeeded, tt.wantSucceeded) {
+				t.Errorf("succeeded = %v, want %v", succeeded, tt.wantSucceeded)
+			}
+			failed := map[string]ErrorCode{}
+			for _, failure := range body.Failed {
+				if failure.Error == "" {
+					t.Errorf("failure %+v has no error message", failure)
+				}
+				failed[failure.Email] = failure.Code
+			}
+			if !maps.Equal(failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+}
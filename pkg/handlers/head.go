@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HeadUser handles HEAD /users/{email} (or /users?email=), a cheap existence check that reads only
+// the user's key: 200 when an active user has the email, 404 otherwise. Like every HEAD response,
+// it carries the headers but never a body.
+func (h *UserHandler) HeadUser(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if invalid := h.unknownQueryParams(req, headUserParams...); invalid != nil {
+		return withoutBody(invalid, nil)
+	}
+	email := requestEmail(req)
+	if email == "" {
+		return withoutBody(apiResponse(http.StatusBadRequest, ErrorBody{
+			ErrorMsg: StringPtr("Email is required"),
+			Code:     CodeInvalidRequest,
+		}))
+	}
+
+	exists, err := h.userRepo.UserExists(ctx, email)
+	if err != nil {
+		return withoutBody(repositoryFailure("HeadUser", err))
+	}
+	if !exists {
+		return withoutBody(apiResponse(http.StatusNotFound, ErrorBody{
+			ErrorMsg: StringPtr("User not found"),
+			Code:     CodeUserNotFound,
+		}))
+	}
+	return &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": h.cacheControl(ctx, req),
+		},
+	}, nil
+}
+
+// withoutBody drops the body of resp, keeping its status and headers, for answering HEAD requests
+// with the same responses as their GET counterparts.
+func withoutBody(resp *events.APIGatewayProxyResponse, err error) (*events.APIGatewayProxyResponse, error) {
+	if resp != nil {
+		resp.Body = ""
+	}
+	return resp, err
+}
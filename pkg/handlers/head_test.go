@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHeadUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       map[string]string
+		query      map[string]string
+		wantStatus int
+	}{
+		{name: "existing user", path: map[string]string{"email": "a@example.com"}, wantStatus: http.StatusOK},
+		{name: "existing user by query", query: map[string]string{"email": "a@example.com"}, wantStatus: http.StatusOK},
+		{name: "email in another case", path: map[string]string{"email": "A@Example.com"}, wantStatus: http.StatusOK},
+		{name: "missing user", path: map[string]string{"email": "b@example.com"}, wantStatus: http.StatusNotFound},
+		{name: "no email", wantStatus: http.StatusBadRequest},
+		{name: "unknown query parameter", path: map[string]string{"email": "a@example.com"}, query: map[string]string{"fields": "email"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann"})
+
+			resp, err := h.HeadUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodHead,
+				PathParameters:        tt.path,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.Body != "" {
+				t.Errorf("body = %q, want none", resp.Body)
+			}
+			if got := resp.Headers["Content-Type"]; got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+		})
+	}
+}
+
+func TestHeadUserSoftDeleted(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{SoftDelete: true})
+	if _, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.DeleteUser(context.Background(), "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	h := NewUserHandler(repo, UserHandlerOptions{})
+
+	resp, err := h.HeadUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:     http.MethodHead,
+		PathParameters: map[string]string{"email": "a@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
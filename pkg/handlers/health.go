@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HealthChecker reports whether a dependency of the service is reachable.
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthStatus is the body returned by the health-check endpoint.
+type HealthStatus struct {
+	Status string  `json:"status"`
+	Error  *string `json:"error,omitempty"`
+}
+
+// HealthHandler serves the readiness probe, independently of the user routes.
+type HealthHandler struct {
+	checker HealthChecker
+}
+
+// NewHealthHandler creates a new HealthHandler instance.
+func NewHealthHandler(checker HealthChecker) HealthHandler {
+	return HealthHandler{
+		checker: checker,
+	}
+}
+
+// Check returns 200 when the checker can reach its dependency and 503 otherwise.
+func (h *HealthHandler) Check(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if err := h.checker.Ping(ctx); err != nil {
+		slog.Warn("Health check failed", slog.String("operation", "HealthCheck"), slog.Any("error", err))
+		return apiResponse(http.StatusServiceUnavailable, HealthStatus{
+			Status: "unavailable",
+			Error:  StringPtr(err.Error()),
+		})
+	}
+	return apiResponse(http.StatusOK, HealthStatus{Status: "ok"})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RequireHTTPS rejects requests that a proxy reports, via X-Forwarded-Proto, as having arrived over
+// plain http, with 403 Forbidden. Requests without the header are let through, since API Gateway only
+// accepts HTTPS itself. It returns nil when the request may proceed.
+func RequireHTTPS(req events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	// A chain of proxies appends its protocols; the first one is what the client used
+	proto, _, _ := strings.Cut(requestHeader(req, "X-Forwarded-Proto"), ",")
+	if !strings.EqualFold(strings.TrimSpace(proto), "http") {
+		return nil
+	}
+	resp, _ := apiResponse(http.StatusForbidden, ErrorBody{
+		ErrorMsg: StringPtr("HTTPS is required"),
+		Code:     CodeHTTPSRequired,
+	})
+	return resp
+}
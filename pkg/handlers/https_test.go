@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		rejected bool
+	}{
+		{name: "https", headers: map[string]string{"X-Forwarded-Proto": "https"}},
+		{name: "http", headers: map[string]string{"X-Forwarded-Proto": "http"}, rejected: true},
+		{name: "http in another case", headers: map[string]string{"x-forwarded-proto": "HTTP"}, rejected: true},
+		{name: "client used http behind an https proxy", headers: map[string]string{"X-Forwarded-Proto": "http, https"}, rejected: true},
+		{name: "client used https behind an http proxy", headers: map[string]string{"X-Forwarded-Proto": "https,http"}},
+		{name: "no header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := RequireHTTPS(events.APIGatewayProxyRequest{Headers: tt.headers})
+			if !tt.rejected {
+				if resp != nil {
+					t.Errorf("rejected with %d, want the request let through", resp.StatusCode)
+				}
+				return
+			}
+			if resp == nil || resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("response = %v, want %d", resp, http.StatusForbidden)
+			}
+			if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeHTTPSRequired {
+				t.Errorf("code = %s, want %s", body.Code, CodeHTTPSRequired)
+			}
+		})
+	}
+}
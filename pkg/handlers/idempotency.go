@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultIdempotencyTTL is used when UserHandlerOptions.IdempotencyTTL is not set.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyReservation is how long a key stays reserved by a request that never completes or
+// releases it, such as one killed by a Lambda timeout: the longest a Lambda invocation can run.
+const idempotencyReservation = 15 * time.Minute
+
+// idempotent runs fn at most once per Idempotency-Key header. A repeated key with the same body
+// replays the stored response; a repeated key with a different body is rejected with 422.
+// The key is reserved before fn runs, so a concurrent request with the same key gets 409 while
+// the first one is in flight, instead of running fn a second time.
+// Requests without the header, or without a configured store, run fn directly.
+func (h *UserHandler) idempotent(ctx context.Context, req events.APIGatewayProxyRequest, fn func() (*events.APIGatewayProxyResponse, error)) (*events.APIGatewayProxyResponse, error) {
+	key := requestHeader(req, "Idempotency-Key")
+	if key == "" || h.opts.IdempotencyStore == nil {
+		return fn()
+	}
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		// Tenants share the store, so keep one tenant's keys from replaying another's responses
+		key = tenant + "#" + key
+	}
+
+	sum := sha256.Sum256([]byte(req.Body))
+	requestHash := hex.EncodeToString(sum[:])
+
+	store := h.opts.IdempotencyStore
+	record, err := store.GetRecord(ctx, key)
+	if err != nil {
+		return idempotencyCheckFailed()
+	}
+	if record != nil {
+		return recordedResponse(record, requestHash)
+	}
+
+	err = store.PutRecord(ctx, repository.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		Pending:     true,
+		ExpiresAt:   time.Now().Add(idempotencyReservation).Unix(),
+	})
+	if errors.Is(err, repository.ErrIdempotencyKeyExists) {
+		// A concurrent request reserved the key since the read
+		record, err = store.GetRecord(ctx, key)
+		if err != nil {
+			return idempotencyCheckFailed()
+		}
+		if record == nil {
+			return idempotencyKeyInUse()
+		}
+		return recordedResponse(record, requestHash)
+	}
+	if err != nil {
+		return idempotencyCheckFailed()
+	}
+
+	resp, err := fn()
+	// Server errors are transient, so the client must be able to retry them with the same key
+	if err != nil || resp == nil || resp.StatusCode >= http.StatusInternalServerError {
+		releaseIdempotencyKey(ctx, store, key)
+		return resp, err
+	}
+
+	err = store.CompleteRecord(ctx, repository.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		StatusCode:  resp.StatusCode,
+		Body:        resp.Body,
+		Location:    resp.Headers["Location"],
+		ExpiresAt:   time.Now().Add(h.opts.IdempotencyTTL).Unix(),
+	})
+	if err != nil {
+		// The request already succeeded; failing to record it only disables the replay, which
+		// should not also keep the key reserved
+		slog.Warn("Could not record idempotency key", slog.String("operation", "idempotent"), slog.Any("error", err))
+		releaseIdempotencyKey(ctx, store, key)
+	}
+	return resp, nil
+}
+
+// recordedResponse answers a request whose key is already recorded: with the stored response, or
+// with 409 while the request that reserved the key is still running. A key recorded for another
+// body is rejected with 422.
+func recordedResponse(record *repository.IdempotencyRecord, requestHash string) (*events.APIGatewayProxyResponse, error) {
+	if record.RequestHash != requestHash {
+		return apiResponse(http.StatusUnprocessableEntity, ErrorBody{
+			ErrorMsg: StringPtr("Idempotency-Key was already used with a different request body"),
+			Code:     CodeIdempotencyKeyReused,
+		})
+	}
+	if record.Pending {
+		return idempotencyKeyInUse()
+	}
+	resp := &events.APIGatewayProxyResponse{
+		StatusCode: record.StatusCode,
+		Headers: map[string]string{
+			"Content-Type":        "application/json",
+			"Idempotent-Replayed": "true",
+		},
+		Body: record.Body,
+	}
+	if record.Location != "" {
+		resp.Headers["Location"] = record.Location
+	}
+	return resp, nil
+}
+
+// idempotencyKeyInUse answers a request whose key is reserved by a request still in flight. The
+// client should retry shortly, to get the first request's response replayed.
+func idempotencyKeyInUse() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusConflict, ErrorBody{
+		ErrorMsg: StringPtr("A request with this Idempotency-Key is still being processed; please retry"),
+		Code:     CodeIdempotencyKeyInUse,
+	}, map[string]string{"Retry-After": "1"})
+}
+
+// idempotencyCheckFailed answers a request whose key could not be read or reserved.
+func idempotencyCheckFailed() (*events.APIGatewayProxyResponse, error) {
+	return apiResponse(http.StatusInternalServerError, ErrorBody{
+		ErrorMsg: StringPtr("Failed to check idempotency key"),
+		Code:     CodeInternalError,
+	})
+}
+
+// releaseIdempotencyKey releases the reservation of key, logging a failure: the key then stays
+// reserved until the reservation expires.
+func releaseIdempotencyKey(ctx context.Context, store repository.IdempotencyStore, key string) {
+	if err := store.ReleaseRecord(ctx, key); err != nil {
+		slog.Warn("Could not release idempotency key", slog.String("operation", "idempotent"), slog.Any("error", err))
+	}
+}
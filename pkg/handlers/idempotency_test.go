@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	const body = `{"email":"a@example.com","firstName":"Ada","lastName":"Lee"}`
+	tests := []struct {
+		name       string
+		firstKey   string
+		retryKey   string
+		retryBody  string
+		wantStatus int
+		wantReplay bool
+	}{
+		{name: "replays the original response", firstKey: "key-1", retryKey: "key-1", retryBody: body, wantStatus: http.StatusCreated, wantReplay: true},
+		{
+			name:       "rejects a key reused with another body",
+			firstKey:   "key-1",
+			retryKey:   "key-1",
+			retryBody:  `{"email":"b@example.com","firstName":"Bea","lastName":"Lee"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{name: "runs again with another key", firstKey: "key-1", retryKey: "key-2", retryBody: body, wantStatus: http.StatusConflict},
+		{name: "runs again without a key", retryBody: body, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+			h.opts.IdempotencyStore = repository.NewInMemoryIdempotencyStore()
+			create := func(key, body string) *events.APIGatewayProxyResponse {
+				t.Helper()
+				req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: body}
+				if key != "" {
+					req.Headers = map[string]string{"Idempotency-Key": key}
+				}
+				resp, err := h.CreateUser(context.Background(), req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return resp
+			}
+
+			first := create(tt.firstKey, body)
+			if first.StatusCode != http.StatusCreated {
+				t.Fatalf("first status = %d, want %d (body %s)", first.StatusCode, http.StatusCreated, first.Body)
+			}
+			retry := create(tt.retryKey, tt.retryBody)
+			if retry.StatusCode != tt.wantStatus {
+				t.Fatalf("retry status = %d, want %d (body %s)", retry.StatusCode, tt.wantStatus, retry.Body)
+			}
+			if replayed := retry.Headers["Idempotent-Replayed"] == "true"; replayed != tt.wantReplay {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && (retry.Body != first.Body || retry.Headers["Location"] != first.Headers["Location"]) {
+				t.Errorf("replay = %q (Location %q), want %q (Location %q)", retry.Body, retry.Headers["Location"], first.Body, first.Headers["Location"])
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyReservedWhileInFlight(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.opts.IdempotencyStore = repository.NewInMemoryIdempotencyStore()
+	req := func(body string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Headers: map[string]string{"Idempotency-Key": "key-1"}, Body: body}
+	}
+	runs := 0
+	created := func() (*events.APIGatewayProxyResponse, error) {
+		runs++
+		return apiResponse(http.StatusCreated, map[string]int{"run": runs})
+	}
+
+	// The first request is still running while the others arrive
+	var during []*events.APIGatewayProxyResponse
+	first, err := h.idempotent(context.Background(), req(`{"a":1}`), func() (*events.APIGatewayProxyResponse, error) {
+		for _, body := range []string{`{"a":1}`, `{"a":2}`} {
+			resp, err := h.idempotent(context.Background(), req(body), created)
+			if err != nil {
+				t.Fatal(err)
+			}
+			during = append(during, resp)
+		}
+		return created()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.StatusCode != http.StatusCreated || runs != 1 {
+		t.Fatalf("first status = %d after %d runs, want %d after 1", first.StatusCode, runs, http.StatusCreated)
+	}
+	if resp := during[0]; resp.StatusCode != http.StatusConflict || decodeResponse[ErrorBody](t, resp).Code != CodeIdempotencyKeyInUse || resp.Headers["Retry-After"] == "" {
+		t.Errorf("concurrent request = %d %s (Retry-After %q), want %d %s", resp.StatusCode, resp.Body, resp.Headers["Retry-After"], http.StatusConflict, CodeIdempotencyKeyInUse)
+	}
+	if resp := during[1]; resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("concurrent request with another body = %d, want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	retry, err := h.idempotent(context.Background(), req(`{"a":1}`), created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Headers["Idempotent-Replayed"] != "true" || retry.Body != first.Body || runs != 1 {
+		t.Errorf("retry = %s (replayed %q) after %d runs, want the first response replayed", retry.Body, retry.Headers["Idempotent-Replayed"], runs)
+	}
+}
+
+func TestIdempotencyKeyReleasedOnServerErrors(t *testing.T) {
+	h, _ := newTestHandler(t)
+	store := repository.NewInMemoryIdempotencyStore()
+	h.opts.IdempotencyStore = store
+	req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Headers: map[string]string{"Idempotency-Key": "key-1"}, Body: `{}`}
+	statuses := []int{http.StatusServiceUnavailable, http.StatusCreated}
+
+	for i, want := range statuses {
+		resp, err := h.idempotent(context.Background(), req, func() (*events.APIGatewayProxyResponse, error) {
+			return apiResponse(statuses[i], struct{}{})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("attempt %d: status = %d, want %d: the failed attempt kept the key reserved", i+1, resp.StatusCode, want)
+		}
+	}
+	record, err := store.GetRecord(context.Background(), "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Pending || record.StatusCode != http.StatusCreated {
+		t.Errorf("record = %+v, want the completed 201", record)
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FieldNaming is the style of the keys in JSON responses.
+type FieldNaming string
+
+const (
+	// FieldNamingCamel keeps the model's camelCase keys, e.g. firstName. It is the default.
+	FieldNamingCamel FieldNaming = "camelCase"
+	// FieldNamingSnake rewrites keys to snake_case, e.g. first_name.
+	FieldNamingSnake FieldNaming = "snake_case"
+)
+
+// opaqueKeys are the objects whose keys are client data rather than field names, and are never renamed.
+var opaqueKeys = []string{"metadata"}
+
+// ResponseFieldNaming returns the key style requested by the Accept-Profile header of req
+// ("snake_case" or "camelCase"), or fallback when the header names neither.
+func ResponseFieldNaming(req events.APIGatewayProxyRequest, fallback FieldNaming) FieldNaming {
+	switch profile := FieldNaming(strings.TrimSpace(requestHeader(req, "Accept-Profile"))); profile {
+	case FieldNamingCamel, FieldNamingSnake:
+		return profile
+	}
+	return fallback
+}
+
+// NormalizeRequestKeys rewrites the snake_case keys of a JSON request body to camelCase, so
+// clients may send either style. Bodies that are not JSON, or have no snake_case keys, are left
+// untouched for the handlers to report as usual.
+func NormalizeRequestKeys(req *events.APIGatewayProxyRequest) {
+	if req.IsBase64Encoded || !strings.Contains(req.Body, "_") {
+		return
+	}
+	body, changed, err := renameKeys([]byte(req.Body), snakeToCamel)
+	if err != nil || !changed {
+		return
+	}
+	req.Body = string(body)
+}
+
+// ApplyFieldNaming rewrites the keys of a JSON response body to naming. Only application/json
+// bodies are rewritten; the NDJSON export and compressed bodies keep the model's keys.
+// It must run before Compress, which replaces the body with its gzipped encoding.
+func ApplyFieldNaming(resp *events.APIGatewayProxyResponse, naming FieldNaming) {
+	if resp == nil || resp.IsBase64Encoded || resp.Body == "" || resp.Headers["Content-Type"] != "application/json" {
+		return
+	}
+	// Caches must key on Accept-Profile whether or not this response is rewritten
+	addVary(resp.Headers, "Accept-Profile")
+	if naming != FieldNamingSnake {
+		return
+	}
+	body, _, err := renameKeys([]byte(resp.Body), camelToSnake)
+	if err != nil {
+		slog.Error("Could not rename response keys", slog.String("operation", "ApplyFieldNaming"), slog.Any("error", err))
+		return
+	}
+	resp.Body = string(body)
+}
+
+// renameKeys applies rename to every object key in the JSON document body, reporting whether any
+// key changed. Numbers are kept as written, so large integers do not lose precision.
+func renameKeys(body []byte, rename func(string) string) ([]byte, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, false, err
+	}
+	changed := false
+	document = renameValue(document, rename, &changed)
+	renamed, err := json.Marshal(document)
+	return renamed, changed, err
+}
+
+// renameValue renames the keys of value and of every object nested in it, except within opaqueKeys.
+func renameValue(value interface{}, rename func(string) string, changed *bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			newKey := rename(key)
+			if newKey != key {
+				*changed = true
+			}
+			if !slices.Contains(opaqueKeys, newKey) {
+				item = renameValue(item, rename, changed)
+			}
+			renamed[newKey] = item
+		}
+		return renamed
+	case []interface{}:
+		for i, item := range v {
+			v[i] = renameValue(item, rename, changed)
+		}
+		return v
+	}
+	return value
+}
+
+// camelToSnake converts a camelCase key to snake_case, e.g. lastEvaluatedKey to last_evaluated_key.
+func camelToSnake(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at a lower-to-upper change, or at the last capital of an acronym
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// snakeToCamel converts a snake_case key to camelCase, e.g. first_name to firstName.
+// Keys without underscores are returned unchanged.
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestKeyConversion(t *testing.T) {
+	tests := []struct {
+		camel string
+		snake string
+	}{
+		{"email", "email"},
+		{"firstName", "first_name"},
+		{"lastEvaluatedKey", "last_evaluated_key"},
+		{"avatarUrl", "avatar_url"},
+		{"createdAt", "created_at"},
+	}
+	for _, tt := range tests {
+		if got := camelToSnake(tt.camel); got != tt.snake {
+			t.Errorf("camelToSnake(%q) = %q, want %q", tt.camel, got, tt.snake)
+		}
+		if got := snakeToCamel(tt.snake); got != tt.camel {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", tt.snake, got, tt.camel)
+		}
+	}
+	// Acronyms end at their last capital
+	if got := camelToSnake("userID"); got != "user_id" {
+		t.Errorf("camelToSnake(%q) = %q, want %q", "userID", got, "user_id")
+	}
+	if got := camelToSnake("HTTPSRequired"); got != "https_required" {
+		t.Errorf("camelToSnake(%q) = %q, want %q", "HTTPSRequired", got, "https_required")
+	}
+	if got := snakeToCamel("trailing_"); got != "trailing" {
+		t.Errorf("snakeToCamel(%q) = %q, want %q", "trailing_", got, "trailing")
+	}
+}
+
+func TestResponseFieldNaming(t *testing.T) {
+	tests := []struct {
+		header   string
+		fallback FieldNaming
+		want     FieldNaming
+	}{
+		{header: "", fallback: FieldNamingCamel, want: FieldNamingCamel},
+		{header: "", fallback: FieldNamingSnake, want: FieldNamingSnake},
+		{header: "snake_case", fallback: FieldNamingCamel, want: FieldNamingSnake},
+		{header: " camelCase ", fallback: FieldNamingSnake, want: FieldNamingCamel},
+		{header: "kebab-case", fallback: FieldNamingCamel, want: FieldNamingCamel},
+	}
+	for _, tt := range tests {
+		req := events.APIGatewayProxyRequest{Headers: map[string]string{"Accept-Profile": tt.header}}
+		if got := ResponseFieldNaming(req, tt.fallback); got != tt.want {
+			t.Errorf("Accept-Profile %q with fallback %s = %s, want %s", tt.header, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestFieldNamingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		naming   FieldNaming
+		wantKeys []string
+	}{
+		{
+			name:     "camelCase",
+			body:     `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","avatarUrl":"https://cdn.example.com/a.png","metadata":{"teamName":"ops"}}`,
+			naming:   FieldNamingCamel,
+			wantKeys: []string{"avatarUrl", "createdAt", "email", "firstName", "id", "lastName", "metadata", "role", "updatedAt", "version"},
+		},
+		{
+			name:     "snake_case",
+			body:     `{"email":"a@example.com","first_name":"Ann","last_name":"Lee","avatar_url":"https://cdn.example.com/a.png","metadata":{"teamName":"ops"}}`,
+			naming:   FieldNamingSnake,
+			wantKeys: []string{"avatar_url", "created_at", "email", "first_name", "id", "last_name", "metadata", "role", "updated_at", "version"},
+		},
+		{
+			name:     "camelCase in, snake_case out",
+			body:     `{"email":"a@example.com","firstName":"Ann","last_name":"Lee","avatarUrl":"https://cdn.example.com/a.png","metadata":{"teamName":"ops"}}`,
+			naming:   FieldNamingSnake,
+			wantKeys: []string{"avatar_url", "created_at", "email", "first_name", "id", "last_name", "metadata", "role", "updated_at", "version"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t)
+			req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: tt.body}
+			NormalizeRequestKeys(&req)
+
+			resp, err := h.CreateUser(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusCreated, resp.Body)
+			}
+			ApplyFieldNaming(resp, tt.naming)
+
+			body := decodeResponse[map[string]json.RawMessage](t, resp)
+			if keys := slices.Sorted(maps.Keys(body)); !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			// Metadata keys are client data, so they are never renamed
+			if got := decodeResponse[struct{ Metadata map[string]string }](t, resp).Metadata; got["teamName"] != "ops" {
+				t.Errorf("metadata = %v, want teamName kept", got)
+			}
+			if resp.Headers["Vary"] != "Accept-Profile" {
+				t.Errorf("Vary = %q, want Accept-Profile", resp.Headers["Vary"])
+			}
+
+			stored, err := repo.FetchUser(context.Background(), "a@example.com", repository.FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.FirstName != "Ann" || stored.LastName != "Lee" || stored.AvatarURL == "" {
+				t.Errorf("stored = %+v, want every field read", stored)
+			}
+		})
+	}
+}
+
+func TestApplyFieldNamingLeavesOtherBodiesAlone(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *events.APIGatewayProxyResponse
+	}{
+		{name: "NDJSON", resp: &events.APIGatewayProxyResponse{Headers: map[string]string{"Content-Type": "application/x-ndjson"}, Body: `{"firstName":"Ann"}`}},
+		{name: "compressed", resp: &events.APIGatewayProxyResponse{Headers: map[string]string{"Content-Type": "application/json"}, Body: "H4sI", IsBase64Encoded: true}},
+		{name: "not JSON", resp: &events.APIGatewayProxyResponse{Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"firstName":`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.resp.Body
+			ApplyFieldNaming(tt.resp, FieldNamingSnake)
+			if tt.resp.Body != body {
+				t.Errorf("body = %q, want %q", tt.resp.Body, body)
+			}
+		})
+	}
+}
+
+func TestNormalizeRequestKeysLeavesValuesAlone(t *testing.T) {
+	req := events.APIGatewayProxyRequest{Body: `{"first_name":"snake_case","metadata":{"team_name":"ops"},"users":[{"last_name":"Lee"}]}`}
+	NormalizeRequestKeys(&req)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(req.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"firstName": "snake_case",
+		"metadata":  map[string]interface{}{"team_name": "ops"},
+		"users":     []interface{}{map[string]interface{}{"lastName": "Lee"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestPageLimit(t *testing.T) {
+	h := NewUserHandler(repository.NewInMemoryUserRepository(repository.DynamoDBOptions{}), UserHandlerOptions{DefaultPageSize: 5, MaxPageSize: 20})
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    int
+		wantErr bool
+	}{
+		{name: "no limit", want: 5},
+		{name: "smallest limit", params: map[string]string{"limit": "1"}, want: 1},
+		{name: "below the maximum", params: map[string]string{"limit": "19"}, want: 19},
+		{name: "the maximum", params: map[string]string{"limit": "20"}, want: 20},
+		{name: "above the maximum", params: map[string]string{"limit": "21"}, want: 20},
+		{name: "far above the maximum", params: map[string]string{"limit": "100000"}, want: 20},
+		// A limit of 0 or below was first defaulted, but is now rejected along with other non-numbers
+		{name: "zero", params: map[string]string{"limit": "0"}, wantErr: true},
+		{name: "negative", params: map[string]string{"limit": "-1"}, wantErr: true},
+		{name: "not a number", params: map[string]string{"limit": "ten"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.pageLimit(events.APIGatewayProxyRequest{QueryStringParameters: tt.params}, "limit")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageSizeOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        UserHandlerOptions
+		wantDefault int
+		wantMax     int
+	}{
+		{name: "unset", wantDefault: defaultPageSize, wantMax: defaultMaxPageSize},
+		{name: "configured", opts: UserHandlerOptions{DefaultPageSize: 25, MaxPageSize: 50}, wantDefault: 25, wantMax: 50},
+		{name: "default above the maximum", opts: UserHandlerOptions{DefaultPageSize: 80, MaxPageSize: 50}, wantDefault: 50, wantMax: 50},
+		{name: "maximum below the fallback default", opts: UserHandlerOptions{MaxPageSize: 3}, wantDefault: 3, wantMax: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewUserHandler(repository.NewInMemoryUserRepository(repository.DynamoDBOptions{}), tt.opts)
+			if h.opts.DefaultPageSize != tt.wantDefault || h.opts.MaxPageSize != tt.wantMax {
+				t.Errorf("page sizes = %d, %d, want %d, %d", h.opts.DefaultPageSize, h.opts.MaxPageSize, tt.wantDefault, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestGetUsersClampsLimit(t *testing.T) {
+	var users []models.User
+	for i := range 5 {
+		users = append(users, models.User{Email: "user" + strconv.Itoa(i) + "@example.com", FirstName: "Ada", LastName: "Lee"})
+	}
+	h, _ := newTestHandler(t, users...)
+	h.opts.MaxPageSize = 3
+
+	resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            http.MethodGet,
+		QueryStringParameters: map[string]string{"limit": "1000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusOK, resp.Body)
+	}
+	page := decodeResponse[struct{ Users []models.User }](t, resp)
+	if len(page.Users) != 3 {
+		t.Errorf("%d users, want 3", len(page.Users))
+	}
+}
+
+func TestGetUsersOrder(t *testing.T) {
+	tests := []struct {
+		order      string
+		wantStatus int
+		want       []string
+	}{
+		{order: "", wantStatus: http.StatusOK, want: []string{"a@example.com", "b@example.com"}},
+		{order: "asc", wantStatus: http.StatusOK, want: []string{"a@example.com", "b@example.com"}},
+		{order: "DESC", wantStatus: http.StatusOK, want: []string{"b@example.com", "a@example.com"}},
+		{order: "random", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			h, _ := newTestHandler(t,
+				models.User{Email: "b@example.com", FirstName: "Bo", LastName: "Lee"},
+				models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"},
+			)
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: map[string]string{"lastName": "Lee", "order": tt.order},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got []string
+			for _, user := range decodeResponse[struct{ Users []models.User }](t, resp).Users {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("emails = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUsersCreatedRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		wantUsers  int
+	}{
+		{name: "no bounds", wantStatus: http.StatusOK, wantUsers: 1},
+		{name: "window around now", query: map[string]string{"createdAfter": "2000-01-01T00:00:00Z", "createdBefore": "2999-01-01T00:00:00+02:00"}, wantStatus: http.StatusOK, wantUsers: 1},
+		{name: "window in the past", query: map[string]string{"createdBefore": "2000-01-01T00:00:00Z"}, wantStatus: http.StatusOK},
+		{name: "date without a time", query: map[string]string{"createdAfter": "2024-01-01"}, wantStatus: http.StatusBadRequest},
+		{name: "not a timestamp", query: map[string]string{"createdBefore": "yesterday"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeInvalidQueryParameter {
+					t.Errorf("code = %s, want %s", body.Code, CodeInvalidQueryParameter)
+				}
+				return
+			}
+			if page := decodeResponse[struct{ Users []models.User }](t, resp); len(page.Users) != tt.wantUsers {
+				t.Errorf("%d users, want %d", len(page.Users), tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestGetUsersPageNumbers(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       map[string]string
+		wantStatus  int
+		wantEmails  []string
+		wantHasMore bool
+	}{
+		{name: "page 1", query: map[string]string{"page": "1", "pageSize": "2"}, wantStatus: http.StatusOK, wantEmails: []string{"a@example.com", "b@example.com"}, wantHasMore: true},
+		{name: "page 2", query: map[string]string{"page": "2", "pageSize": "2"}, wantStatus: http.StatusOK, wantEmails: []string{"c@example.com", "d@example.com"}, wantHasMore: true},
+		{name: "last page", query: map[string]string{"page": "3", "pageSize": "2"}, wantStatus: http.StatusOK, wantEmails: []string{"e@example.com"}},
+		{name: "past the end", query: map[string]string{"page": "4", "pageSize": "2"}, wantStatus: http.StatusOK},
+		{name: "far past the end", query: map[string]string{"page": "100", "pageSize": "1"}, wantStatus: http.StatusOK},
+		{name: "page 0", query: map[string]string{"page": "0"}, wantStatus: http.StatusBadRequest},
+		{name: "above the maximum page", query: map[string]string{"page": "101"}, wantStatus: http.StatusBadRequest},
+		{name: "not a number", query: map[string]string{"page": "two"}, wantStatus: http.StatusBadRequest},
+		{name: "invalid page size", query: map[string]string{"page": "1", "pageSize": "0"}, wantStatus: http.StatusBadRequest},
+		{name: "with a token", query: map[string]string{"page": "2", "lastEvaluatedKey": "a@example.com"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var users []models.User
+			for _, name := range []string{"e", "c", "a", "d", "b"} {
+				users = append(users, models.User{Email: name + "@example.com", FirstName: "Ann", LastName: "Lee"})
+			}
+			h, _ := newTestHandler(t, users...)
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeInvalidQueryParameter {
+					t.Errorf("code = %s, want %s", body.Code, CodeInvalidQueryParameter)
+				}
+				return
+			}
+			page := decodeResponse[struct {
+				Users   []models.User
+				HasMore bool
+			}](t, resp)
+			var got []string
+			for _, user := range page.Users {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.wantEmails) || page.HasMore != tt.wantHasMore {
+				t.Errorf("emails = %v, hasMore = %v, want %v, %v", got, page.HasMore, tt.wantEmails, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestWalkToPageStopsAtTheEnd(t *testing.T) {
+	reads := 0
+	fetchPage := func(limit int, lastEvaluatedKey string) ([]models.User, string, error) {
+		reads++
+		if lastEvaluatedKey == "" {
+			return []models.User{{Email: "a@example.com"}}, "a@example.com", nil
+		}
+		return []models.User{{Email: "b@example.com"}}, "", nil
+	}
+
+	users, next, err := walkToPage(maxPageNumber, 1, fetchPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 0 || next != "" {
+		t.Errorf("page = %v, %q, want an empty last page", users, next)
+	}
+	if reads != 2 {
+		t.Errorf("%d reads, want 2: none past the last page", reads)
+	}
+}
+
+func TestGetUsersRejectsInvalidLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		wantUsers  int
+	}{
+		{name: "no limit", wantStatus: http.StatusOK, wantUsers: 3},
+		{name: "valid", query: map[string]string{"limit": "2"}, wantStatus: http.StatusOK, wantUsers: 2},
+		{name: "not a number", query: map[string]string{"limit": "abc"}, wantStatus: http.StatusBadRequest},
+		{name: "negative", query: map[string]string{"limit": "-5"}, wantStatus: http.StatusBadRequest},
+		{name: "zero", query: map[string]string{"limit": "0"}, wantStatus: http.StatusBadRequest},
+		{name: "empty", query: map[string]string{"limit": ""}, wantStatus: http.StatusBadRequest},
+		{name: "invalid page size", query: map[string]string{"page": "1", "pageSize": "-5"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t,
+				models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"},
+				models.User{Email: "b@example.com", FirstName: "Bo", LastName: "Lee"},
+				models.User{Email: "c@example.com", FirstName: "Cy", LastName: "Lee"},
+			)
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeInvalidQueryParameter {
+					t.Errorf("code = %s, want %s", body.Code, CodeInvalidQueryParameter)
+				}
+				return
+			}
+			if page := decodeResponse[struct{ Users []models.User }](t, resp); len(page.Users) != tt.wantUsers {
+				t.Errorf("%d users, want %d", len(page.Users), tt.wantUsers)
+			}
+		})
+	}
+}
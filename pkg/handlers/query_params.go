@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Query parameters accepted by each UserHandler method. Anything else is rejected unless
+// UserHandlerOptions.LenientQueryParams is set.
+var (
+	getUserParams = []string{
+		"email", "fields", "order", "createdAfter", "createdBefore", "includeDeleted", "consistent",
+		"limit", "lastEvaluatedKey", "page", "pageSize", "lastName", "emailPrefix", "search", "count",
+	}
+	exportUsersParams = []string{"includeDeleted", "lastEvaluatedKey"}
+	lookupUsersParams = []string{"includeDeleted"}
+	updateUserParams  = []string{"email", "upsert"}
+	deleteUserParams  = []string{"email", "return"}
+	bulkUpdateParams  = []string{"dryRun"}
+	headUserParams    = []string{"email"}
+)
+
+// unknownQueryParams rejects a request carrying query parameters outside allowed, listing the
+// offending keys, so a typo such as "emial" fails instead of silently being ignored.
+// It returns nil when every parameter is known or lenient mode is enabled.
+func (h *UserHandler) unknownQueryParams(req events.APIGatewayProxyRequest, allowed ...string) *events.APIGatewayProxyResponse {
+	if h.opts.LenientQueryParams {
+		return nil
+	}
+	var unknown []string
+	for key := range req.QueryStringParameters {
+		if !slices.Contains(allowed, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	resp, _ := apiResponse(http.StatusBadRequest, ErrorBody{
+		ErrorMsg: StringPtr(fmt.Sprintf("Unknown query parameters: %s", strings.Join(unknown, ", "))),
+		Code:     CodeInvalidQueryParameter,
+	})
+	return resp
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestUnknownQueryParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       map[string]string
+		lenient     bool
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "known parameter", query: map[string]string{"email": "a@example.com"}, wantStatus: http.StatusOK},
+		{name: "typo", query: map[string]string{"emial": "a@example.com"}, wantStatus: http.StatusBadRequest, wantMessage: "Unknown query parameters: emial"},
+		{
+			name:        "several unknown parameters, sorted",
+			query:       map[string]string{"sort": "email", "email": "a@example.com", "limt": "5"},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "Unknown query parameters: limt, sort",
+		},
+		{name: "typo in lenient mode", query: map[string]string{"emial": "a@example.com"}, lenient: true, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+			h.opts.LenientQueryParams = tt.lenient
+
+			resp, err := h.GetUser(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			body := decodeResponse[ErrorBody](t, resp)
+			if body.Code != CodeInvalidQueryParameter || body.ErrorMsg == nil || *body.ErrorMsg != tt.wantMessage {
+				t.Errorf("body = %s, want %s: %s", resp.Body, CodeInvalidQueryParameter, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestQueryParamsArePerHandler(t *testing.T) {
+	h, _ := newTestHandler(t, models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee"})
+
+	// limit is valid for listings but not for deletes
+	resp, err := h.DeleteUser(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            http.MethodDelete,
+		QueryStringParameters: map[string]string{"email": "a@example.com", "limit": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusBadRequest, resp.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RateLimiter throttles each caller to the rate configured on its limiter.
+type RateLimiter struct {
+	limiter repository.RateLimiter
+}
+
+// NewRateLimiter creates a RateLimiter backed by limiter.
+func NewRateLimiter(limiter repository.RateLimiter) RateLimiter {
+	return RateLimiter{
+		limiter: limiter,
+	}
+}
+
+// Check takes a token for the caller of req. When the caller is over its limit it returns a 429
+// response with a Retry-After header to send instead of dispatching; otherwise it returns nil.
+// Failures of the limiter itself let the request through, so an outage of the rate-limit table
+// does not take the API down with it.
+func (r RateLimiter) Check(ctx context.Context, req events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	key := callerKey(ctx, req)
+	if key == "" {
+		return nil
+	}
+
+	allowed, wait, err := r.limiter.Allow(ctx, key)
+	if err != nil {
+		slog.Warn("Rate limiter unavailable, allowing request", slog.String("operation", "RateLimit"), slog.Any("error", err))
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+
+	slog.Info("Rate limited request", slog.String("operation", "RateLimit"), slog.String("caller", key))
+	retryAfter := max(1, int(math.Ceil(wait.Seconds())))
+	resp, _ := apiResponse(http.StatusTooManyRequests, ErrorBody{
+		ErrorMsg: StringPtr("Too many requests"),
+		Code:     CodeRateLimited,
+	}, map[string]string{"Retry-After": strconv.Itoa(retryAfter)})
+	return resp
+}
+
+// callerKey identifies the caller of req: the token subject when authenticated, otherwise the source IP.
+func callerKey(ctx context.Context, req events.APIGatewayProxyRequest) string {
+	if claims := auth.ClaimsFromContext(ctx); claims != nil && claims.Subject != "" {
+		return "sub#" + claims.Subject
+	}
+	if ip := req.RequestContext.Identity.SourceIP; ip != "" {
+		return "ip#" + ip
+	}
+	return ""
+}
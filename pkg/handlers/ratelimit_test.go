@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// failingLimiter is a repository.RateLimiter whose table is unavailable.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string) (bool, time.Duration, error) {
+	return false, 0, errors.New("table unavailable")
+}
+
+// fromIP returns a request made from the source IP ip.
+func fromIP(ip string) events.APIGatewayProxyRequest {
+	var req events.APIGatewayProxyRequest
+	req.RequestContext.Identity.SourceIP = ip
+	return req
+}
+
+func TestRateLimiterCheck(t *testing.T) {
+	limiter := NewRateLimiter(repository.NewInMemoryRateLimiter(repository.RateLimitOptions{RequestsPerSecond: 1, Burst: 3}))
+	ctx := context.Background()
+
+	for i := range 3 {
+		if resp := limiter.Check(ctx, fromIP("203.0.113.7")); resp != nil {
+			t.Fatalf("request %d limited with %d, want it allowed", i+1, resp.StatusCode)
+		}
+	}
+	resp := limiter.Check(ctx, fromIP("203.0.113.7"))
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("response over the limit = %v, want %d", resp, http.StatusTooManyRequests)
+	}
+	if got := resp.Headers["Retry-After"]; got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeRateLimited {
+		t.Errorf("code = %s, want %s", body.Code, CodeRateLimited)
+	}
+
+	if resp := limiter.Check(ctx, fromIP("198.51.100.2")); resp != nil {
+		t.Errorf("another IP limited with %d", resp.StatusCode)
+	}
+	// An authenticated caller is limited by subject, not by the IP it shares
+	authenticated := auth.WithClaims(ctx, &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "a@example.com"}})
+	if resp := limiter.Check(authenticated, fromIP("203.0.113.7")); resp != nil {
+		t.Errorf("authenticated caller limited with %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimiterCheckLetsRequestsThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter repository.RateLimiter
+		req     events.APIGatewayProxyRequest
+	}{
+		{name: "limiter unavailable", limiter: failingLimiter{}, req: fromIP("203.0.113.7")},
+		{name: "unidentified caller", limiter: repository.NewInMemoryRateLimiter(repository.RateLimitOptions{RequestsPerSecond: 1, Burst: 0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := NewRateLimiter(tt.limiter).Check(context.Background(), tt.req); resp != nil {
+				t.Errorf("limited with %d, want the request let through", resp.StatusCode)
+			}
+		})
+	}
+}
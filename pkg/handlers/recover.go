@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Recover wraps next, turning a panic in a handler or repository call into a logged stack trace
+// and a 500 response, so one bad request does not crash the invocation without explanation.
+func Recover(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (resp *events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				slog.Error("Recovered from panic", slog.String("operation", "handler"), slog.Any("panic", recovered), slog.String("stack", string(debug.Stack())))
+				resp, err = InternalServerError()
+			}
+		}()
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    HandlerFunc
+		wantStatus int
+	}{
+		{
+			name: "nil pointer",
+			handler: func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				var h *UserHandler
+				return h.GetUser(ctx, req)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "explicit panic",
+			handler: func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				panic("unexpected state")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "no panic",
+			handler: func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return apiResponse(http.StatusTeapot, nil)
+			},
+			wantStatus: http.StatusTeapot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := Recover(tt.handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus == http.StatusInternalServerError {
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeInternalError || resp.Headers["Content-Type"] != "application/json" {
+					t.Errorf("response = %s, want the JSON internal error", resp.Body)
+				}
+			}
+		})
+	}
+}
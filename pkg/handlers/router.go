@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc handles one API Gateway request.
+type HandlerFunc func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+
+// Router dispatches requests to the handler registered for their method and path.
+// Patterns are slash-separated; a segment in braces, such as {email}, matches any single
+// segment and is passed to the handler as a path parameter. When several patterns match,
+// the one with the most literal segments wins, so /users/batch takes precedence over /users/{email}.
+type Router struct {
+	routes []route
+}
+
+// route is one registered method and pattern.
+type route struct {
+	method   string
+	segments []string
+	handler  HandlerFunc
+}
+
+// methodOrder is the order in which methods are listed in the Allow header of a 405 response:
+// reads first, then writes, as HTTP documentation conventionally lists them.
+var methodOrder = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// NewRouter creates a Router without any routes.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for requests with the given method whose path matches pattern.
+func (r *Router) Handle(method, pattern string, handler HandlerFunc) {
+	r.routes = append(r.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+// Route calls the handler registered for req. Paths without any route get 404 Not Found, and
+// known paths without a route for the request method get 405 Method Not Allowed with an Allow header
+// listing every method registered for a pattern matching the path.
+func (r *Router) Route(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	segments := splitPath(req.Path)
+
+	var best *route
+	var bestParams map[string]string
+	bestLiterals := -1
+	var allowed []string
+	for i := range r.routes {
+		rt := &r.routes[i]
+		params, literals, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if !slices.Contains(allowed, rt.method) {
+			allowed = append(allowed, rt.method)
+		}
+		if rt.method == req.HTTPMethod && literals > bestLiterals {
+			best, bestParams, bestLiterals = rt, params, literals
+		}
+	}
+
+	if best != nil {
+		// Parameters extracted by API Gateway take precedence over the ones parsed here
+		for name, value := range bestParams {
+			if req.PathParameters[name] != "" {
+				continue
+			}
+			if req.PathParameters == nil {
+				req.PathParameters = map[string]string{}
+			}
+			req.PathParameters[name] = value
+		}
+		return best.handler(ctx, req)
+	}
+	if len(allowed) == 0 {
+		return apiResponse(http.StatusNotFound, ErrorBody{ErrorMsg: StringPtr("Not Found"), Code: CodeNotFound})
+	}
+	slices.SortFunc(allowed, compareMethods)
+	return UnhandledMethod(allowed...)
+}
+
+// compareMethods orders methods by methodOrder, with any other method after them alphabetically.
+func compareMethods(a, b string) int {
+	i, j := slices.Index(methodOrder, a), slices.Index(methodOrder, b)
+	switch {
+	case i >= 0 && j >= 0:
+		return cmp.Compare(i, j)
+	case i >= 0:
+		return -1
+	case j >= 0:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// match reports whether the route's pattern matches the path segments, returning the
+// path parameters and the number of literal segments that matched.
+func (rt *route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	literals := 0
+	for i, pattern := range rt.segments {
+		if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}") {
+			params[pattern[1:len(pattern)-1]] = segments[i]
+			continue
+		}
+		if pattern != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+// splitPath splits a path into its segments, ignoring leading and trailing slashes.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
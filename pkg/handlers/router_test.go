@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// newTestRouter returns a Router whose handlers answer with the name of the route that was hit,
+// recording the path parameters they received in params.
+func newTestRouter(params *map[string]string) *Router {
+	r := NewRouter()
+	named := func(name string) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			*params = req.PathParameters
+			return apiResponse(http.StatusOK, name)
+		}
+	}
+	r.Handle("GET", "/users", named("list"))
+	r.Handle("POST", "/users", named("create"))
+	r.Handle("GET", "/users/{email}", named("get"))
+	r.Handle("PUT", "/users/{email}", named("update"))
+	r.Handle("DELETE", "/users/{email}", named("delete"))
+	r.Handle("POST", "/users/batch", named("batch"))
+	r.Handle("GET", "/users/{email}/sessions/{id}", named("session"))
+	return r
+}
+
+func TestRouterRoute(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		pathParameters map[string]string // Set by API Gateway
+		wantStatus     int
+		wantBody       string
+		wantParams     map[string]string
+		wantAllow      string
+	}{
+		{name: "collection", method: "GET", path: "/users", wantStatus: http.StatusOK, wantBody: `"list"`},
+		{name: "trailing slash", method: "POST", path: "/users/", wantStatus: http.StatusOK, wantBody: `"create"`},
+		{
+			name: "path parameter", method: "GET", path: "/users/a@example.com",
+			wantStatus: http.StatusOK, wantBody: `"get"`, wantParams: map[string]string{"email": "a@example.com"},
+		},
+		{
+			name: "several path parameters", method: "GET", path: "/users/a@example.com/sessions/42",
+			wantStatus: http.StatusOK, wantBody: `"session"`, wantParams: map[string]string{"email": "a@example.com", "id": "42"},
+		},
+		{name: "literal segment wins", method: "POST", path: "/users/batch", wantStatus: http.StatusOK, wantBody: `"batch"`},
+		{
+			name: "API Gateway parameters win", method: "PUT", path: "/users/a%40example.com",
+			pathParameters: map[string]string{"email": "a@example.com"},
+			wantStatus:     http.StatusOK, wantBody: `"update"`, wantParams: map[string]string{"email": "a@example.com"},
+		},
+		{name: "unknown path", method: "GET", path: "/groups", wantStatus: http.StatusNotFound},
+		{name: "too many segments", method: "GET", path: "/users/a@example.com/roles", wantStatus: http.StatusNotFound},
+		{name: "unknown method", method: "PATCH", path: "/users", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, POST"},
+		{
+			name: "unknown method on an item", method: "POST", path: "/users/a@example.com",
+			wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, PUT, DELETE",
+		},
+		{
+			name: "pattern serves methods the literal path lacks", method: "GET", path: "/users/batch",
+			wantStatus: http.StatusOK, wantBody: `"get"`, wantParams: map[string]string{"email": "batch"},
+		},
+		{
+			name: "Allow lists every matching pattern", method: "PATCH", path: "/users/batch",
+			wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, POST, PUT, DELETE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params map[string]string
+			r := newTestRouter(&params)
+
+			resp, err := r.Route(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     tt.method,
+				Path:           tt.path,
+				PathParameters: tt.pathParameters,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantBody != "" && resp.Body != tt.wantBody {
+				t.Errorf("routed to %s, want %s", resp.Body, tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && !maps.Equal(params, tt.wantParams) {
+				t.Errorf("path parameters = %v, want %v", params, tt.wantParams)
+			}
+			if got := resp.Headers["Allow"]; got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouterAllowOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string // Registered for /users, in this order
+		want    string
+	}{
+		{name: "every user method", methods: []string{"DELETE", "OPTIONS", "PATCH", "PUT", "POST", "HEAD", "GET"}, want: "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"},
+		{name: "registered twice", methods: []string{"POST", "GET", "POST"}, want: "GET, POST"},
+		{name: "other methods last", methods: []string{"PROPFIND", "GET", "COPY"}, want: "GET, COPY, PROPFIND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter()
+			for _, method := range tt.methods {
+				r.Handle(method, "/users", func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+					return apiResponse(http.StatusOK, nil)
+				})
+			}
+
+			resp, err := r.Route(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "TRACE", Path: "/users"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+			}
+			if got := resp.Headers["Allow"]; got != tt.want {
+				t.Errorf("Allow = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/39sanskar/serverless-go/pkg/audit"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SQSHandler processes user operations enqueued on SQS, for writes that do not need
+// to complete within the API request.
+type SQSHandler struct {
+	userRepo         repository.UserRepository
+	nameSanitization validators.NameSanitization
+	validation       validators.ValidationOptions
+}
+
+// NewSQSHandler creates a new SQSHandler instance. nameSanitization and validation apply as in
+// UserHandlerOptions.
+func NewSQSHandler(userRepo repository.UserRepository, nameSanitization validators.NameSanitization, validation validators.ValidationOptions) SQSHandler {
+	return SQSHandler{
+		userRepo:         userRepo,
+		nameSanitization: nameSanitization,
+		validation:       validation,
+	}
+}
+
+// Handle applies the operation in each record's body, a JSON UserOperation such as
+// {"type":"create","user":{...}}. Records are processed independently; the ones that fail are
+// reported as partial batch failures so SQS redelivers only those. This requires
+// ReportBatchItemFailures to be enabled on the event source mapping.
+func (h SQSHandler) Handle(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var resp events.SQSEventResponse
+	for _, record := range event.Records {
+		if err := h.process(ctx, record); err != nil {
+			slog.Error("Failed to process SQS record",
+				slog.String("operation", "SQSHandler.Handle"),
+				slog.String("messageId", record.MessageId),
+				slog.Any("error", err))
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: record.MessageId,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// process decodes, validates and applies a single record. Validation warnings are ignored, since
+// there is no client to report them to.
+func (h SQSHandler) process(ctx context.Context, record events.SQSMessage) error {
+	var op repository.UserOperation
+	if err := json.Unmarshal([]byte(record.Body), &op); err != nil {
+		return fmt.Errorf("invalid message body: %w", err)
+	}
+	op.User.Email = validators.NormalizeEmail(op.User.Email)
+	validators.SanitizeNames(&op.User, h.nameSanitization)
+	ctx = audit.WithMessageID(ctx, record.MessageId)
+
+	switch op.Type {
+	case repository.OperationCreate:
+		if _, err := validators.ValidateUser(op.User, h.validation); err != nil {
+			return err
+		}
+		_, err := h.userRepo.CreateUser(ctx, op.User)
+		return err
+	case repository.OperationUpdate:
+		if _, err := validators.ValidateUser(op.User, h.validation); err != nil {
+			return err
+		}
+		_, err := h.userRepo.UpdateUser(ctx, op.User)
+		return err
+	case repository.OperationDelete:
+		if op.User.Email == "" {
+			return errors.New("email is required")
+		}
+		_, err := h.userRepo.DeleteUser(ctx, op.User.Email)
+		return err
+	default:
+		return fmt.Errorf("%w: type must be one of create, update, delete", repository.ErrInvalidOperation)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestSQSHandlerReportsFailedRecords(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{})
+	if _, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lee"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSQSHandler(repo, validators.NameSanitizationReject, validators.ValidationOptions{})
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		{MessageId: "create", Body: `{"type":"create","user":{"email":"B@Example.com","firstName":"Bea","lastName":"Lee"}}`},
+		{MessageId: "duplicate", Body: `{"type":"create","user":{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}}`},
+		{MessageId: "invalid user", Body: `{"type":"create","user":{"email":"c@example.com","firstName":"","lastName":"Lee"}}`},
+		{MessageId: "malformed", Body: `{"type":`},
+		{MessageId: "unknown type", Body: `{"type":"upsert","user":{"email":"c@example.com"}}`},
+		{MessageId: "missing user", Body: `{"type":"delete","user":{"email":"nobody@example.com"}}`},
+		{MessageId: "update", Body: `{"type":"update","user":{"email":"a@example.com","firstName":"Anna","lastName":"Lee"}}`},
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	for _, failure := range resp.BatchItemFailures {
+		failed = append(failed, failure.ItemIdentifier)
+	}
+	if want := []string{"duplicate", "invalid user", "malformed", "unknown type", "missing user"}; !slices.Equal(failed, want) {
+		t.Errorf("failed = %v, want %v", failed, want)
+	}
+
+	for email, wantFirst := range map[string]string{"a@example.com": "Anna", "b@example.com": "Bea"} {
+		user, err := repo.FetchUser(context.Background(), email, repository.FetchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user == nil || user.FirstName != wantFirst {
+			t.Errorf("%s = %+v, want first name %s", email, user, wantFirst)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/changes"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// StreamHandler turns DynamoDB Streams records of the users table into change events.
+type StreamHandler struct {
+	publisher changes.Publisher
+}
+
+// NewStreamHandler creates a new StreamHandler instance.
+func NewStreamHandler(publisher changes.Publisher) StreamHandler {
+	return StreamHandler{
+		publisher: publisher,
+	}
+}
+
+// Handle publishes one change event per stream record, in order. Processing stops at the first
+// failure, which is reported as a batch item failure so Lambda retries the stream from that
+// record onward without republishing the earlier ones. This requires ReportBatchItemFailures
+// on the event source mapping.
+func (h StreamHandler) Handle(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	var resp events.DynamoDBEventResponse
+	for _, record := range event.Records {
+		if err := h.process(ctx, record); err != nil {
+			slog.Error("Failed to process stream record",
+				slog.String("operation", "StreamHandler.Handle"),
+				slog.String("eventId", record.EventID),
+				slog.Any("error", err))
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+			break
+		}
+	}
+	return resp, nil
+}
+
+// process decodes a record into a change event and publishes it.
+func (h StreamHandler) process(ctx context.Context, record events.DynamoDBEventRecord) error {
+	change, err := changeFromRecord(record)
+	if err != nil {
+		return err
+	}
+	return h.publisher.Publish(ctx, change)
+}
+
+// changeFromRecord builds the change event for a stream record. INSERT records only carry a
+// new image and REMOVE records only an old one; MODIFY records carry both. A MODIFY that sets
+// the soft-delete flag is reported as a deletion.
+func changeFromRecord(record events.DynamoDBEventRecord) (changes.ChangeEvent, error) {
+	oldUser, err := userFromImage(record.Change.OldImage)
+	if err != nil {
+		return changes.ChangeEvent{}, err
+	}
+	newUser, err := userFromImage(record.Change.NewImage)
+	if err != nil {
+		return changes.ChangeEvent{}, err
+	}
+
+	change := changes.ChangeEvent{
+		Old:        oldUser,
+		New:        newUser,
+		OccurredAt: record.Change.ApproximateCreationDateTime.UTC().Format(time.RFC3339),
+	}
+	switch events.DynamoDBOperationType(record.EventName) {
+	case events.DynamoDBOperationTypeInsert:
+		change.Type = changes.ChangeCreated
+	case events.DynamoDBOperationTypeModify:
+		change.Type = changes.ChangeUpdated
+		if newUser != nil && newUser.Deleted && (oldUser == nil || !oldUser.Deleted) {
+			change.Type = changes.ChangeDeleted
+		}
+	case events.DynamoDBOperationTypeRemove:
+		change.Type = changes.ChangeDeleted
+	default:
+		return changes.ChangeEvent{}, fmt.Errorf("unsupported stream event %q", record.EventName)
+	}
+
+	if emailAttr, ok := record.Change.Keys["email"]; ok && emailAttr.DataType() == events.DataTypeString {
+		change.Email = emailAttr.String()
+	}
+	if change.Email == "" {
+		// A table keyed on id (repository.KeySchemaID) only has the email in the images
+		if newUser != nil {
+			change.Email = newUser.Email
+		} else if oldUser != nil {
+			change.Email = oldUser.Email
+		}
+	}
+	return change, nil
+}
+
+// userFromImage decodes a stream image, returning nil when the image is absent
+// (e.g. the old image of an INSERT, or a stream that does not include it).
+func userFromImage(image map[string]events.DynamoDBAttributeValue) (*models.User, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+	user := new(models.User)
+	if err := dynamodbattribute.UnmarshalMap(toAttributeValues(image), user); err != nil {
+		return nil, fmt.Errorf("could not decode stream image: %w", err)
+	}
+	return user, nil
+}
+
+// toAttributeValues converts a stream image to the SDK's attribute values so the regular
+// dynamodbattribute decoding (and the model's tags) can be reused.
+func toAttributeValues(image map[string]events.DynamoDBAttributeValue) map[string]*dynamodb.AttributeValue {
+	values := make(map[string]*dynamodb.AttributeValue, len(image))
+	for name, value := range image {
+		values[name] = toAttributeValue(value)
+	}
+	return values
+}
+
+// toAttributeValue converts a single stream attribute value, recursing into lists and maps.
+func toAttributeValue(value events.DynamoDBAttributeValue) *dynamodb.AttributeValue {
+	av := new(dynamodb.AttributeValue)
+	switch value.DataType() {
+	case events.DataTypeBinary:
+		av.SetB(value.Binary())
+	case events.DataTypeBoolean:
+		av.SetBOOL(value.Boolean())
+	case events.DataTypeBinarySet:
+		av.SetBS(value.BinarySet())
+	case events.DataTypeList:
+		list := value.List()
+		items := make([]*dynamodb.AttributeValue, len(list))
+		for i, item := range list {
+			items[i] = toAttributeValue(item)
+		}
+		av.SetL(items)
+	case events.DataTypeMap:
+		av.SetM(toAttributeValues(value.Map()))
+	case events.DataTypeNumber:
+		av.SetN(value.Number())
+	case events.DataTypeNumberSet:
+		av.SetNS(aws.StringSlice(value.NumberSet()))
+	case events.DataTypeNull:
+		av.SetNULL(true)
+	case events.DataTypeString:
+		av.SetS(value.String())
+	case events.DataTypeStringSet:
+		av.SetSS(aws.StringSlice(value.StringSet()))
+	}
+	return av
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/changes"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// recordingPublisher records the published events, failing for the emails in fail.
+type recordingPublisher struct {
+	published []changes.ChangeEvent
+	fail      map[string]bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event changes.ChangeEvent) error {
+	if p.fail[event.Email] {
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+// streamImage returns a stream image of a user with the given email and first name.
+func streamImage(email, firstName string, deleted bool) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"email":     events.NewStringAttribute(email),
+		"firstName": events.NewStringAttribute(firstName),
+		"version":   events.NewNumberAttribute("2"),
+		"deleted":   events.NewBooleanAttribute(deleted),
+	}
+}
+
+// streamRecord returns a stream record of the users table keyed on email.
+func streamRecord(eventName events.DynamoDBOperationType, sequence string, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   sequence,
+		EventName: string(eventName),
+		Change: events.DynamoDBStreamRecord{
+			Keys:                        map[string]events.DynamoDBAttributeValue{"email": events.NewStringAttribute("a@example.com")},
+			OldImage:                    oldImage,
+			NewImage:                    newImage,
+			SequenceNumber:              sequence,
+			ApproximateCreationDateTime: events.SecondsEpochTime{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+	}
+}
+
+func TestStreamHandlerPublishesChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    events.DynamoDBEventRecord
+		wantType  changes.ChangeType
+		wantOld   string // first name in the old user, "" if absent
+		wantNew   string
+		wantError bool
+	}{
+		{
+			name:     "INSERT",
+			record:   streamRecord(events.DynamoDBOperationTypeInsert, "1", nil, streamImage("a@example.com", "Ada", false)),
+			wantType: changes.ChangeCreated,
+			wantNew:  "Ada",
+		},
+		{
+			name:     "MODIFY",
+			record:   streamRecord(events.DynamoDBOperationTypeModify, "2", streamImage("a@example.com", "Ada", false), streamImage("a@example.com", "Ann", false)),
+			wantType: changes.ChangeUpdated,
+			wantOld:  "Ada",
+			wantNew:  "Ann",
+		},
+		{
+			name:     "MODIFY setting the soft-delete flag",
+			record:   streamRecord(events.DynamoDBOperationTypeModify, "3", streamImage("a@example.com", "Ada", false), streamImage("a@example.com", "Ada", true)),
+			wantType: changes.ChangeDeleted,
+			wantOld:  "Ada",
+			wantNew:  "Ada",
+		},
+		{
+			name:     "MODIFY of a stream with new images only",
+			record:   streamRecord(events.DynamoDBOperationTypeModify, "4", nil, streamImage("a@example.com", "Ann", false)),
+			wantType: changes.ChangeUpdated,
+			wantNew:  "Ann",
+		},
+		{
+			name:     "REMOVE",
+			record:   streamRecord(events.DynamoDBOperationTypeRemove, "5", streamImage("a@example.com", "Ada", false), nil),
+			wantType: changes.ChangeDeleted,
+			wantOld:  "Ada",
+		},
+		{
+			name:      "unknown event",
+			record:    streamRecord("TRUNCATE", "6", nil, nil),
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &recordingPublisher{}
+			resp, err := NewStreamHandler(publisher).Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{tt.record}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantError {
+				if len(resp.BatchItemFailures) != 1 || len(publisher.published) != 0 {
+					t.Errorf("failures = %v, published = %v, want the record failed", resp.BatchItemFailures, publisher.published)
+				}
+				return
+			}
+			if len(resp.BatchItemFailures) != 0 || len(publisher.published) != 1 {
+				t.Fatalf("failures = %v, published = %v, want one event", resp.BatchItemFailures, publisher.published)
+			}
+			change := publisher.published[0]
+			if change.Type != tt.wantType || change.Email != "a@example.com" || change.OccurredAt != "2026-01-02T03:04:05Z" {
+				t.Errorf("change = %+v", change)
+			}
+			var gotOld, gotNew string
+			if change.Old != nil {
+				gotOld = change.Old.FirstName
+			}
+			if change.New != nil {
+				gotNew = change.New.FirstName
+			}
+			if gotOld != tt.wantOld || gotNew != tt.wantNew {
+				t.Errorf("old, new = %q, %q, want %q, %q", gotOld, gotNew, tt.wantOld, tt.wantNew)
+			}
+		})
+	}
+}
+
+func TestStreamHandlerStopsAtTheFirstFailure(t *testing.T) {
+	publisher := &recordingPublisher{fail: map[string]bool{"b@example.com": true}}
+	records := []events.DynamoDBEventRecord{
+		streamRecord(events.DynamoDBOperationTypeInsert, "1", nil, streamImage("a@example.com", "Ada", false)),
+		streamRecord(events.DynamoDBOperationTypeInsert, "2", nil, streamImage("b@example.com", "Bea", false)),
+		streamRecord(events.DynamoDBOperationTypeInsert, "3", nil, streamImage("c@example.com", "Cy", false)),
+	}
+	// Key the records on id, as with repository.KeySchemaID, so the email comes from the images
+	for i := range records {
+		records[i].Change.Keys = map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute(records[i].EventID)}
+	}
+
+	resp, err := NewStreamHandler(publisher).Handle(context.Background(), events.DynamoDBEvent{Records: records})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Errorf("failures = %v, want sequence 2", resp.BatchItemFailures)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].Email != "a@example.com" {
+		t.Errorf("published = %v, want only a@example.com", publisher.published)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// defaultSuggestionScanLimit is used when UserHandlerOptions.SuggestionScanLimit is not set.
+	defaultSuggestionScanLimit = 1000
+	// suggestionPageSize is how many emails are read per page while looking for suggestions.
+	suggestionPageSize = 100
+	// maxSuggestions caps the suggestions returned with a 404.
+	maxSuggestions = 5
+	// maxSuggestionDistance is the most edits a suggestion may be away from the requested email.
+	maxSuggestionDistance = 2
+)
+
+// UserNotFoundBody is the 404 response structure of GetUser, with the emails of existing users
+// close to the requested one when email suggestions are enabled.
+type UserNotFoundBody struct {
+	ErrorBody
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// userNotFound answers GetUser for an email without a user. Admins get suggestions when
+// UserHandlerOptions.EmailSuggestions is set; if they cannot be computed the plain 404 is returned.
+func (h *UserHandler) userNotFound(ctx context.Context, email string) (*events.APIGatewayProxyResponse, error) {
+	body := UserNotFoundBody{ErrorBody: ErrorBody{
+		ErrorMsg: StringPtr("User not found"),
+		Code:     CodeUserNotFound,
+	}}
+	if h.opts.EmailSuggestions && isAdmin(ctx) {
+		suggestions, err := h.suggestEmails(ctx, validators.NormalizeEmail(email))
+		if err != nil {
+			slog.Warn("Could not compute email suggestions", slog.String("operation", "GetUser"), slog.Any("error", err))
+		}
+		body.Suggestions = suggestions
+	}
+	return apiResponse(http.StatusNotFound, body)
+}
+
+// suggestEmails returns the emails of live users within maxSuggestionDistance edits of email,
+// closest first. Only the first SuggestionScanLimit users of the table are compared, since each
+// suggestion costs a scan.
+func (h *UserHandler) suggestEmails(ctx context.Context, email string) ([]string, error) {
+	type suggestion struct {
+		email    string
+		distance int
+	}
+	var suggestions []suggestion
+	opts := repository.FetchOptions{Fields: []string{"email"}}
+	scanned, lastEvaluatedKey := 0, ""
+	for scanned < h.opts.SuggestionScanLimit {
+		// The page limit bounds the records read, of which deleted users are filtered out
+		limit := min(suggestionPageSize, h.opts.SuggestionScanLimit-scanned)
+		users, next, err := h.userRepo.FetchUsers(ctx, limit, lastEvaluatedKey, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if distance := editDistance(email, user.Email); distance <= maxSuggestionDistance {
+				suggestions = append(suggestions, suggestion{email: user.Email, distance: distance})
+			}
+		}
+		scanned += limit
+		if next == "" {
+			break
+		}
+		lastEvaluatedKey = next
+	}
+
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), strings.Compare(a.email, b.email))
+	})
+	emails := make([]string, 0, min(len(suggestions), maxSuggestions))
+	for _, s := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		emails = append(emails, s.email)
+	}
+	return emails, nil
+}
+
+// editDistance is the Levenshtein distance between a and b, counting a swap of two adjacent
+// characters, a common typo such as "gmial", as one edit rather than two.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// Rows i-2, i-1 and i of the distance matrix
+	before, prev, curr := make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				curr[j] = min(curr[j], before[j-2]+1)
+			}
+		}
+		before, prev, curr = prev, curr, before
+	}
+	return prev[len(t)]
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "", b: "abc", want: 3},
+		{a: "same", b: "same", want: 0},
+		{a: "jane@example.com", b: "jane@exmple.com", want: 1},   // Deletion
+		{a: "jane@example.com", b: "janes@example.com", want: 1}, // Insertion
+		{a: "jane@example.com", b: "jane@exbmple.com", want: 1},  // Substitution
+		{a: "jane@gmial.com", b: "jane@gmail.com", want: 1},      // Adjacent swap
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "zoë@example.com", b: "zoe@example.com", want: 1}, // Runes, not bytes
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := editDistance(tt.b, tt.a); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUserSuggestions(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		role      string // Of the caller; unauthenticated when empty
+		email     string
+		scanLimit int
+		want      []string
+	}{
+		{name: "near miss", enabled: true, email: "jane@gmial.com", want: []string{"jane@gmail.com", "jan@gmail.com"}},
+		{name: "near miss in another case", enabled: true, email: "Jane@Gmial.com", want: []string{"jane@gmail.com", "jan@gmail.com"}},
+		{name: "admin", enabled: true, role: "admin", email: "jane@gmial.com", want: []string{"jane@gmail.com", "jan@gmail.com"}},
+		{name: "no near match", enabled: true, email: "someone@else.org"},
+		{name: "disabled", email: "jane@gmial.com"},
+		{name: "not an admin", enabled: true, role: "editor", email: "jane@gmial.com"},
+		{name: "beyond the scan limit", enabled: true, email: "zoe@gmial.com", scanLimit: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t,
+				models.User{Email: "jane@gmail.com", FirstName: "Jane"},
+				models.User{Email: "jan@gmail.com", FirstName: "Jan"},
+				models.User{Email: "john@example.com", FirstName: "John"},
+				models.User{Email: "zoe@gmail.com", FirstName: "Zoe"},
+			)
+			h.opts.EmailSuggestions = tt.enabled
+			if tt.scanLimit > 0 {
+				h.opts.SuggestionScanLimit = tt.scanLimit
+			}
+			ctx := context.Background()
+			if tt.role != "" {
+				ctx = auth.WithClaims(ctx, &auth.Claims{Role: tt.role, RegisteredClaims: jwt.RegisteredClaims{Subject: "caller@example.com"}})
+			}
+
+			resp, err := h.GetUser(ctx, events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"email": tt.email},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, http.StatusNotFound, resp.Body)
+			}
+			body := decodeResponse[UserNotFoundBody](t, resp)
+			if body.Code != CodeUserNotFound {
+				t.Errorf("code = %s, want %s", body.Code, CodeUserNotFound)
+			}
+			if !slices.Equal(body.Suggestions, tt.want) {
+				t.Errorf("suggestions = %v, want %v", body.Suggestions, tt.want)
+			}
+			if tt.want == nil && strings.Contains(resp.Body, "suggestions") {
+				t.Errorf("body = %s, want the suggestions omitted", resp.Body)
+			}
+		})
+	}
+}
+
+func TestSuggestEmailsReturnsTheClosest(t *testing.T) {
+	var users []models.User
+	for _, name := range []string{"ann", "anm", "am", "bnn", "cnn", "dnn", "enn"} {
+		users = append(users, models.User{Email: name + "@example.com", FirstName: "Ann"})
+	}
+	h, _ := newTestHandler(t, users...)
+
+	// One edit away, then two, with ties in email order
+	got, err := h.suggestEmails(context.Background(), "anx@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"anm@example.com", "ann@example.com", "am@example.com", "bnn@example.com", "cnn@example.com"}
+	if len(got) != maxSuggestions || !slices.Equal(got, want) {
+		t.Errorf("suggestions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultTenantHeader is used when NewTenantResolver is given no header name.
+const defaultTenantHeader = "X-Tenant-ID"
+
+type tenantKey struct{}
+
+// TenantResolver identifies the tenant of a request and checks it against an allowlist.
+type TenantResolver struct {
+	allowed []string
+	header  string
+}
+
+// NewTenantResolver creates a TenantResolver accepting only the allowed tenants.
+// The tenant is read from header, or X-Tenant-ID when header is empty.
+func NewTenantResolver(allowed []string, header string) TenantResolver {
+	if header == "" {
+		header = defaultTenantHeader
+	}
+	return TenantResolver{
+		allowed: allowed,
+		header:  header,
+	}
+}
+
+// Resolve determines the tenant of req. The tenant claim of a verified token takes precedence
+// over the header, so an authenticated caller cannot reach another tenant's data. On success it
+// returns ctx carrying the tenant; otherwise it returns a 403 response to send instead of dispatching.
+func (t TenantResolver) Resolve(ctx context.Context, req events.APIGatewayProxyRequest) (context.Context, *events.APIGatewayProxyResponse) {
+	tenant := requestHeader(req, t.header)
+	if claims := auth.ClaimsFromContext(ctx); claims != nil && claims.Tenant != "" {
+		tenant = claims.Tenant
+	}
+	if !slices.Contains(t.allowed, tenant) {
+		slog.Info("Rejected request for unknown tenant", slog.String("operation", "ResolveTenant"), slog.String("tenant", tenant))
+		resp, _ := forbidden("Unknown tenant")
+		return ctx, resp
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant), nil
+}
+
+// TenantFromContext returns the tenant resolved for the request, or "" without multi-tenancy.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
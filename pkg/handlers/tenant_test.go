@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/auth"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestTenantResolverResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string // Header the resolver reads; X-Tenant-ID when empty
+		headers     map[string]string
+		claimTenant string
+		wantTenant  string
+	}{
+		{name: "allowed tenant", headers: map[string]string{"X-Tenant-ID": "acme"}, wantTenant: "acme"},
+		{name: "header in lower case", headers: map[string]string{"x-tenant-id": "globex"}, wantTenant: "globex"},
+		{name: "custom header", header: "X-Org", headers: map[string]string{"X-Org": "acme"}, wantTenant: "acme"},
+		{name: "claim wins over header", headers: map[string]string{"X-Tenant-ID": "globex"}, claimTenant: "acme", wantTenant: "acme"},
+		{name: "unknown tenant", headers: map[string]string{"X-Tenant-ID": "initech"}},
+		{name: "unknown tenant in claim", headers: map[string]string{"X-Tenant-ID": "acme"}, claimTenant: "initech"},
+		{name: "no tenant"},
+		{name: "tenant IDs are case-sensitive", headers: map[string]string{"X-Tenant-ID": "ACME"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewTenantResolver([]string{"acme", "globex"}, tt.header)
+			ctx := context.Background()
+			if tt.claimTenant != "" {
+				ctx = auth.WithClaims(ctx, &auth.Claims{Tenant: tt.claimTenant})
+			}
+
+			ctx, resp := resolver.Resolve(ctx, events.APIGatewayProxyRequest{Headers: tt.headers})
+			if tt.wantTenant == "" {
+				if resp == nil || resp.StatusCode != http.StatusForbidden {
+					t.Fatalf("response = %v, want %d", resp, http.StatusForbidden)
+				}
+				if body := decodeResponse[ErrorBody](t, resp); body.Code != CodeForbidden {
+					t.Errorf("code = %s, want %s", body.Code, CodeForbidden)
+				}
+				return
+			}
+			if resp != nil {
+				t.Fatalf("rejected with %d (body %s)", resp.StatusCode, resp.Body)
+			}
+			if got := TenantFromContext(ctx); got != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", got, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestTenantFromContextWithoutTenancy(t *testing.T) {
+	if got := TenantFromContext(context.Background()); got != "" {
+		t.Errorf("tenant = %q, want none", got)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// versionPattern matches an API version, such as v1, in a path prefix or an Accept-Version header.
+// The v is optional in the header, so Accept-Version: 1 asks for v1 too.
+var versionPattern = regexp.MustCompile(`^[vV]?([0-9]+)$`)
+
+// VersionedRouter dispatches requests to the Router of the API version they ask for, either with a
+// version path prefix such as /v1/users or with an Accept-Version header. Requests asking for neither
+// get the default version, so clients written before versioning keep working. A version without a
+// Router, or a prefix and header asking for different versions, is answered with 400 Bad Request.
+type VersionedRouter struct {
+	routers        map[string]*Router
+	defaultVersion string
+}
+
+// NewVersionedRouter creates a VersionedRouter serving requests without a version with defaultVersion,
+// whose Router must be registered with Version.
+func NewVersionedRouter(defaultVersion string) *VersionedRouter {
+	return &VersionedRouter{
+		routers:        map[string]*Router{},
+		defaultVersion: defaultVersion,
+	}
+}
+
+// Version registers router for the requests asking for version, written like v1.
+func (v *VersionedRouter) Version(version string, router *Router) {
+	v.routers[version] = router
+}
+
+// Route calls the Router of the version req asks for, with the version prefix removed from the path.
+func (v *VersionedRouter) Route(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	var version string
+	segments := splitPath(req.Path)
+	if len(segments) > 0 && versionPattern.MatchString(segments[0]) {
+		version = "v" + versionPattern.FindStringSubmatch(segments[0])[1]
+		req.Path = "/" + strings.Join(segments[1:], "/")
+	}
+	if header := strings.TrimSpace(requestHeader(req, "Accept-Version")); header != "" {
+		match := versionPattern.FindStringSubmatch(header)
+		if match == nil {
+			return v.unsupportedVersion(header)
+		}
+		if version != "" && version != "v"+match[1] {
+			return apiResponse(http.StatusBadRequest, ErrorBody{
+				ErrorMsg: StringPtr("The Accept-Version header " + header + " contradicts the " + version + " path prefix"),
+				Code:     CodeUnsupportedVersion,
+			})
+		}
+		version = "v" + match[1]
+	}
+	if version == "" {
+		version = v.defaultVersion
+	}
+
+	router, ok := v.routers[version]
+	if !ok {
+		return v.unsupportedVersion(version)
+	}
+	return router.Route(ctx, req)
+}
+
+// unsupportedVersion answers a request for an API version without a Router, listing the supported ones.
+func (v *VersionedRouter) unsupportedVersion(version string) (*events.APIGatewayProxyResponse, error) {
+	versions := make([]string, 0, len(v.routers))
+	for supported := range v.routers {
+		versions = append(versions, supported)
+	}
+	slices.Sort(versions)
+	return apiResponse(http.StatusBadRequest, ErrorBody{
+		ErrorMsg: StringPtr("Unsupported API version " + version + "; supported versions: " + strings.Join(versions, ", ")),
+		Code:     CodeUnsupportedVersion,
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestVersionedRouter(t *testing.T) {
+	// Each version's handlers answer with the version and the path they were routed
+	versioned := func(version string) *Router {
+		r := NewRouter()
+		handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return apiResponse(http.StatusOK, version+" "+req.Path)
+		}
+		r.Handle("GET", "/users", handler)
+		r.Handle("GET", "/users/{email}", handler)
+		return r
+	}
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+		wantError  string // Part of the error message of a 400
+	}{
+		{name: "no version", path: "/users", wantStatus: http.StatusOK, wantBody: `"v1 /users"`},
+		{name: "v1 prefix", path: "/v1/users", wantStatus: http.StatusOK, wantBody: `"v1 /users"`},
+		{name: "v2 prefix", path: "/v2/users/a@example.com", wantStatus: http.StatusOK, wantBody: `"v2 /users/a@example.com"`},
+		{name: "upper-case prefix", path: "/V2/users", wantStatus: http.StatusOK, wantBody: `"v2 /users"`},
+		{name: "header", path: "/users", headers: map[string]string{"Accept-Version": "v2"}, wantStatus: http.StatusOK, wantBody: `"v2 /users"`},
+		{name: "header without the v", path: "/users", headers: map[string]string{"accept-version": " 2 "}, wantStatus: http.StatusOK, wantBody: `"v2 /users"`},
+		{name: "header agreeing with the prefix", path: "/v2/users", headers: map[string]string{"Accept-Version": "2"}, wantStatus: http.StatusOK, wantBody: `"v2 /users"`},
+		{name: "header contradicting the prefix", path: "/v1/users", headers: map[string]string{"Accept-Version": "v2"}, wantStatus: http.StatusBadRequest, wantError: "contradicts"},
+		{name: "unsupported prefix", path: "/v3/users", wantStatus: http.StatusBadRequest, wantError: "Unsupported API version v3; supported versions: v1, v2"},
+		{name: "unsupported header", path: "/users", headers: map[string]string{"Accept-Version": "v9"}, wantStatus: http.StatusBadRequest, wantError: "Unsupported API version v9"},
+		{name: "malformed header", path: "/users", headers: map[string]string{"Accept-Version": "latest"}, wantStatus: http.StatusBadRequest, wantError: "Unsupported API version latest"},
+		{name: "unknown path of a version", path: "/v1/groups", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVersionedRouter("v1")
+			v.Version("v1", versioned("v1"))
+			v.Version("v2", versioned("v2"))
+
+			resp, err := v.Route(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tt.path, Headers: tt.headers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantBody != "" && resp.Body != tt.wantBody {
+				t.Errorf("routed to %s, want %s", resp.Body, tt.wantBody)
+			}
+			if tt.wantStatus != http.StatusBadRequest {
+				return
+			}
+			body := decodeResponse[ErrorBody](t, resp)
+			if body.Code != CodeUnsupportedVersion || body.ErrorMsg == nil || !strings.Contains(*body.ErrorMsg, tt.wantError) {
+				t.Errorf("error = %s, want %s mentioning %q", resp.Body, CodeUnsupportedVersion, tt.wantError)
+			}
+		})
+	}
+}
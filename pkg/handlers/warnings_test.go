@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWriteWarnings(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       func(*UserHandler, context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		httpMethod   string
+		path         map[string]string
+		body         string
+		wantStatus   int
+		wantWarnings []string
+		wantStored   map[string]string // Last names by email
+	}{
+		{
+			name:         "create at a disposable domain",
+			method:       (*UserHandler).CreateUser,
+			httpMethod:   http.MethodPost,
+			body:         `{"email":"b@mailinator.com","firstName":"Bo","lastName":"Lee"}`,
+			wantStatus:   http.StatusCreated,
+			wantWarnings: []string{"email"},
+			wantStored:   map[string]string{"b@mailinator.com": "Lee"},
+		},
+		{
+			name:       "create at a regular domain",
+			method:     (*UserHandler).CreateUser,
+			httpMethod: http.MethodPost,
+			body:       `{"email":"b@example.com","firstName":"Bo","lastName":"Lee"}`,
+			wantStatus: http.StatusCreated,
+			wantStored: map[string]string{"b@example.com": "Lee"},
+		},
+		{
+			name:         "update at a disposable domain",
+			method:       (*UserHandler).UpdateUser,
+			httpMethod:   http.MethodPut,
+			path:         map[string]string{"email": "a@mailinator.com"},
+			body:         `{"email":"a@mailinator.com","firstName":"Ann","lastName":"Kim"}`,
+			wantStatus:   http.StatusOK,
+			wantWarnings: []string{"email"},
+			wantStored:   map[string]string{"a@mailinator.com": "Kim"},
+		},
+		{
+			name:         "batch create",
+			method:       (*UserHandler).CreateUsers,
+			httpMethod:   http.MethodPost,
+			body:         `[{"email":"b@example.com","firstName":"Bo","lastName":"Lee"},{"email":"c@mailinator.com","firstName":"Cy","lastName":"Lee"}]`,
+			wantStatus:   http.StatusCreated,
+			wantWarnings: []string{"[1].email"},
+			wantStored:   map[string]string{"b@example.com": "Lee", "c@mailinator.com": "Lee"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, repo := newTestHandler(t, models.User{Email: "a@mailinator.com", FirstName: "Ann", LastName: "Lee"})
+			h.opts.Validation.DisposableEmailDomains = []string{"mailinator.com"}
+
+			resp, err := tt.method(h, context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     tt.httpMethod,
+				PathParameters: tt.path,
+				Body:           tt.body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			body := decodeResponse[struct {
+				Warnings validators.ValidationWarnings
+			}](t, resp)
+			var fields []string
+			for _, warning := range body.Warnings {
+				fields = append(fields, warning.Field)
+			}
+			if !slices.Equal(fields, tt.wantWarnings) {
+				t.Errorf("warnings = %v, want fields %v", body.Warnings, tt.wantWarnings)
+			}
+			if len(tt.wantWarnings) == 0 && strings.Contains(resp.Body, `"warnings"`) {
+				t.Errorf("body = %s, want no warnings", resp.Body)
+			}
+
+			// Warned users are written all the same
+			for email, lastName := range tt.wantStored {
+				user, err := repo.FetchUser(context.Background(), email, repository.FetchOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if user == nil || user.LastName != lastName {
+					t.Errorf("%s = %+v, want last name %s", email, user, lastName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+)
+
+// New creates a JSON logger that writes one object per line to w.
+// Each line carries "time", "level" and "message" plus any attributes such as
+// "operation", "error", "requestId" and "correlationId", which makes it queryable in CloudWatch Logs Insights.
+func New(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.MessageKey {
+				attr.Key = "message"
+			}
+			return attr
+		},
+	}))
+}
+
+// WithRequestID returns a copy of logger that tags every line with the invocation's request ID.
+func WithRequestID(logger *slog.Logger, requestID string) *slog.Logger {
+	return logger.With(slog.String("requestId", requestID))
+}
+
+// WithCorrelationID returns a copy of logger that tags every line with the client's correlation ID,
+// so one request can be followed across services.
+func WithCorrelationID(logger *slog.Logger, correlationID string) *slog.Logger {
+	return logger.With(slog.String("correlationId", correlationID))
+}
@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := WithCorrelationID(WithRequestID(New(&buf), "req-1"), "abc-123")
+
+	logger.Info("first")
+	logger.Info("second")
+
+	dec := json.NewDecoder(&buf)
+	for _, want := range []string{"first", "second"} {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		if line["message"] != want || line["requestId"] != "req-1" || line["correlationId"] != "abc-123" {
+			t.Errorf("line = %v, want message %q tagged with the request and correlation IDs", line, want)
+		}
+	}
+}
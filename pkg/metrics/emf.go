@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace is the CloudWatch namespace the service publishes its metrics under.
+const DefaultNamespace = "ServerlessGo/Users"
+
+// Metric names emitted for every recorded operation.
+const (
+	MetricLatency = "Latency"
+	MetricSuccess = "Success"
+	MetricError   = "Error"
+)
+
+// Emitter writes metrics in CloudWatch Embedded Metric Format (EMF).
+// Lambda forwards stdout to CloudWatch Logs, which extracts the metrics from each line,
+// so no PutMetricData call (and no extra latency or IAM permission) is needed.
+type Emitter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	now       func() time.Time
+}
+
+// NewEmitter creates an Emitter that writes one EMF object per line to w.
+func NewEmitter(w io.Writer, namespace string) *Emitter {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &Emitter{
+		w:         w,
+		namespace: namespace,
+		now:       time.Now,
+	}
+}
+
+// emfMetadata is the "_aws" envelope that tells CloudWatch which fields are metrics.
+type emfMetadata struct {
+	Timestamp         int64              `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricsTarget `json:"CloudWatchMetrics"`
+}
+
+type emfMetricsTarget struct {
+	Namespace  string          `json:"Namespace"`
+	Dimensions [][]string      `json:"Dimensions"`
+	Metrics    []emfDefinition `json:"Metrics"`
+}
+
+type emfDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// RecordOperation emits the latency and the success/error count of one operation,
+// dimensioned by the operation name.
+func (e *Emitter) RecordOperation(operation string, latency time.Duration, err error) {
+	success, failure := 1, 0
+	if err != nil {
+		success, failure = 0, 1
+	}
+
+	line := map[string]interface{}{
+		"_aws": emfMetadata{
+			Timestamp: e.now().UnixMilli(),
+			CloudWatchMetrics: []emfMetricsTarget{{
+				Namespace:  e.namespace,
+				Dimensions: [][]string{{"Operation"}},
+				Metrics: []emfDefinition{
+					{Name: MetricLatency, Unit: "Milliseconds"},
+					{Name: MetricSuccess, Unit: "Count"},
+					{Name: MetricError, Unit: "Count"},
+				},
+			}},
+		},
+		"Operation":   operation,
+		MetricLatency: float64(latency.Microseconds()) / 1000,
+		MetricSuccess: success,
+		MetricError:   failure,
+	}
+
+	// Serialize writes so concurrent invocations never interleave lines
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := json.NewEncoder(e.w).Encode(line); err != nil {
+		slog.Error("Failed to write EMF metrics", slog.String("operation", operation), slog.Any("error", err))
+	}
+}
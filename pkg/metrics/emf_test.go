@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+)
+
+// emfLine is the parsed form of one line written by an Emitter.
+type emfLine struct {
+	AWS       emfMetadata `json:"_aws"`
+	Operation string
+	Latency   float64
+	Success   int
+	Error     int
+}
+
+// decodeLines parses the EMF lines written to buf.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []emfLine {
+	t.Helper()
+	var lines []emfLine
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var line emfLine
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRecordOperation(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespace   string
+		err         error
+		wantNS      string
+		wantSuccess int
+		wantError   int
+	}{
+		{name: "success", namespace: "Custom/Users", wantNS: "Custom/Users", wantSuccess: 1},
+		{name: "error", namespace: "Custom/Users", err: errors.New("unavailable"), wantNS: "Custom/Users", wantError: 1},
+		{name: "default namespace", wantNS: DefaultNamespace, wantSuccess: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			emitter := NewEmitter(&buf, tt.namespace)
+			emitter.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+			emitter.RecordOperation("FetchUser", 1500*time.Microsecond, tt.err)
+
+			lines := decodeLines(t, &buf)
+			if len(lines) != 1 {
+				t.Fatalf("%d lines, want 1", len(lines))
+			}
+			line := lines[0]
+			if line.AWS.Timestamp != 1700000000000 || len(line.AWS.CloudWatchMetrics) != 1 {
+				t.Fatalf("_aws = %+v", line.AWS)
+			}
+			target := line.AWS.CloudWatchMetrics[0]
+			if target.Namespace != tt.wantNS {
+				t.Errorf("namespace = %q, want %q", target.Namespace, tt.wantNS)
+			}
+			if len(target.Dimensions) != 1 || len(target.Dimensions[0]) != 1 || target.Dimensions[0][0] != "Operation" {
+				t.Errorf("dimensions = %v, want [[Operation]]", target.Dimensions)
+			}
+			wantMetrics := []emfDefinition{{MetricLatency, "Milliseconds"}, {MetricSuccess, "Count"}, {MetricError, "Count"}}
+			if len(target.Metrics) != len(wantMetrics) {
+				t.Fatalf("metrics = %v, want %v", target.Metrics, wantMetrics)
+			}
+			for i, metric := range target.Metrics {
+				if metric != wantMetrics[i] {
+					t.Errorf("metric %d = %v, want %v", i, metric, wantMetrics[i])
+				}
+			}
+			if line.Operation != "FetchUser" || line.Latency != 1.5 || line.Success != tt.wantSuccess || line.Error != tt.wantError {
+				t.Errorf("values = %+v", line)
+			}
+		})
+	}
+}
+
+func TestInstrumentedUserRepositoryRecordsOperations(t *testing.T) {
+	var buf bytes.Buffer
+	repo := NewInstrumentedUserRepository(repository.NewInMemoryUserRepository(repository.DynamoDBOptions{}), NewEmitter(&buf, ""))
+	ctx := context.Background()
+
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"}); !errors.Is(err, repository.ErrUserAlreadyExists) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrUserAlreadyExists)
+	}
+	if _, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		operation string
+		success   int
+	}{{"CreateUser", 1}, {"CreateUser", 0}, {"FetchUser", 1}}
+	lines := decodeLines(t, &buf)
+	if len(lines) != len(want) {
+		t.Fatalf("%d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if line.Operation != want[i].operation || line.Success != want[i].success || line.Error != 1-want[i].success {
+			t.Errorf("line %d = %+v, want %s with success %d", i, line, want[i].operation, want[i].success)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+)
+
+// InstrumentedUserRepository decorates a UserRepository, recording the latency and outcome
+// of every call through an Emitter.
+type InstrumentedUserRepository struct {
+	repository.UserRepository
+	emitter *Emitter
+}
+
+// NewInstrumentedUserRepository wraps repo so that each operation emits metrics.
+func NewInstrumentedUserRepository(repo repository.UserRepository, emitter *Emitter) *InstrumentedUserRepository {
+	return &InstrumentedUserRepository{
+		UserRepository: repo,
+		emitter:        emitter,
+	}
+}
+
+// record emits metrics for an operation that started at start and finished with err.
+func (r *InstrumentedUserRepository) record(operation string, start time.Time, err error) {
+	r.emitter.RecordOperation(operation, time.Since(start), err)
+}
+
+// FetchUser records metrics for UserRepository.FetchUser.
+func (r *InstrumentedUserRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (user *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("FetchUser", start, err) }()
+	return r.UserRepository.FetchUser(ctx, email, opts)
+}
+
+// UserExists records metrics for UserRepository.UserExists.
+func (r *InstrumentedUserRepository) UserExists(ctx context.Context, email string) (exists bool, err error) {
+	start := time.Now()
+	defer func() { r.record("UserExists", start, err) }()
+	return r.UserRepository.UserExists(ctx, email)
+}
+
+// FetchUsers records metrics for UserRepository.FetchUsers.
+func (r *InstrumentedUserRepository) FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	start := time.Now()
+	defer func() { r.record("FetchUsers", start, err) }()
+	return r.UserRepository.FetchUsers(ctx, limit, lastEvaluatedKey, opts)
+}
+
+// FetchUsersByLastName records metrics for UserRepository.FetchUsersByLastName.
+func (r *InstrumentedUserRepository) FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	start := time.Now()
+	defer func() { r.record("FetchUsersByLastName", start, err) }()
+	return r.UserRepository.FetchUsersByLastName(ctx, lastName, limit, lastEvaluatedKey, opts)
+}
+
+// FetchUsersByEmailPrefix records metrics for UserRepository.FetchUsersByEmailPrefix.
+func (r *InstrumentedUserRepository) FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	start := time.Now()
+	defer func() { r.record("FetchUsersByEmailPrefix", start, err) }()
+	return r.UserRepository.FetchUsersByEmailPrefix(ctx, prefix, limit, lastEvaluatedKey, opts)
+}
+
+// SearchUsers records metrics for UserRepository.SearchUsers.
+func (r *InstrumentedUserRepository) SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	start := time.Now()
+	defer func() { r.record("SearchUsers", start, err) }()
+	return r.UserRepository.SearchUsers(ctx, query, limit, lastEvaluatedKey, opts)
+}
+
+// FetchUsersByEmails records metrics for UserRepository.FetchUsersByEmails.
+func (r *InstrumentedUserRepository) FetchUsersByEmails(ctx context.Context, emails []string, opts repository.FetchOptions) (result *repository.BatchFetchResult, err error) {
+	start := time.Now()
+	defer func() { r.record("FetchUsersByEmails", start, err) }()
+	return r.UserRepository.FetchUsersByEmails(ctx, emails, opts)
+}
+
+// ScanAll records metrics for UserRepository.ScanAll, including the time spent in fn.
+func (r *InstrumentedUserRepository) ScanAll(ctx context.Context, lastEvaluatedKey string, opts repository.FetchOptions, fn func(user models.User) error) (err error) {
+	start := time.Now()
+	defer func() { r.record("ScanAll", start, err) }()
+	return r.UserRepository.ScanAll(ctx, lastEvaluatedKey, opts, fn)
+}
+
+// CountUsers records metrics for UserRepository.CountUsers.
+func (r *InstrumentedUserRepository) CountUsers(ctx context.Context, opts repository.FetchOptions) (total int64, err error) {
+	start := time.Now()
+	defer func() { r.record("CountUsers", start, err) }()
+	return r.UserRepository.CountUsers(ctx, opts)
+}
+
+// CreateUser records metrics for UserRepository.CreateUser.
+func (r *InstrumentedUserRepository) CreateUser(ctx context.Context, user models.User) (created *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("CreateUser", start, err) }()
+	return r.UserRepository.CreateUser(ctx, user)
+}
+
+// CreateUsers records metrics for UserRepository.CreateUsers.
+func (r *InstrumentedUserRepository) CreateUsers(ctx context.Context, users []models.User) (result *repository.BatchCreateResult, err error) {
+	start := time.Now()
+	defer func() { r.record("CreateUsers", start, err) }()
+	return r.UserRepository.CreateUsers(ctx, users)
+}
+
+// UpdateUser records metrics for UserRepository.UpdateUser.
+func (r *InstrumentedUserRepository) UpdateUser(ctx context.Context, user models.User) (updated *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("UpdateUser", start, err) }()
+	return r.UserRepository.UpdateUser(ctx, user)
+}
+
+// UpsertUser records metrics for UserRepository.UpsertUser.
+func (r *InstrumentedUserRepository) UpsertUser(ctx context.Context, user models.User) (upserted *models.User, created bool, err error) {
+	start := time.Now()
+	defer func() { r.record("UpsertUser", start, err) }()
+	return r.UserRepository.UpsertUser(ctx, user)
+}
+
+// DeleteUser records metrics for UserRepository.DeleteUser.
+func (r *InstrumentedUserRepository) DeleteUser(ctx context.Context, email string) (deleted *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("DeleteUser", start, err) }()
+	return r.UserRepository.DeleteUser(ctx, email)
+}
+
+// DeleteUsers records metrics for UserRepository.DeleteUsers.
+func (r *InstrumentedUserRepository) DeleteUsers(ctx context.Context, emails []string) (result *repository.BatchDeleteResult, err error) {
+	start := time.Now()
+	defer func() { r.record("DeleteUsers", start, err) }()
+	return r.UserRepository.DeleteUsers(ctx, emails)
+}
+
+// TransactWriteUsers records metrics for UserRepository.TransactWriteUsers.
+func (r *InstrumentedUserRepository) TransactWriteUsers(ctx context.Context, ops []repository.UserOperation) (err error) {
+	start := time.Now()
+	defer func() { r.record("TransactWriteUsers", start, err) }()
+	return r.UserRepository.TransactWriteUsers(ctx, ops)
+}
+
+// RestoreUser records metrics for UserRepository.RestoreUser.
+func (r *InstrumentedUserRepository) RestoreUser(ctx context.Context, email string) (restored *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("RestoreUser", start, err) }()
+	return r.UserRepository.RestoreUser(ctx, email)
+}
+
+// ChangeEmail records metrics for UserRepository.ChangeEmail.
+func (r *InstrumentedUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (moved *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("ChangeEmail", start, err) }()
+	return r.UserRepository.ChangeEmail(ctx, oldEmail, newEmail)
+}
+
+// MergeUsers records metrics for UserRepository.MergeUsers.
+func (r *InstrumentedUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (merged *models.User, err error) {
+	start := time.Now()
+	defer func() { r.record("MergeUsers", start, err) }()
+	return r.UserRepository.MergeUsers(ctx, primaryEmail, duplicateEmail)
+}
+
+// VerifyPassword records metrics for UserRepository.VerifyPassword.
+func (r *InstrumentedUserRepository) VerifyPassword(ctx context.Context, email, password string) (err error) {
+	start := time.Now()
+	defer func() { r.record("VerifyPassword", start, err) }()
+	return r.UserRepository.VerifyPassword(ctx, email, password)
+}
+
+// DeleteAllUsers records metrics for UserRepository.DeleteAllUsers.
+func (r *InstrumentedUserRepository) DeleteAllUsers(ctx context.Context) (deleted int, err error) {
+	start := time.Now()
+	defer func() { r.record("DeleteAllUsers", start, err) }()
+	return r.UserRepository.DeleteAllUsers(ctx)
+}
+
+// MigrateUsers records metrics for UserRepository.MigrateUsers.
+func (r *InstrumentedUserRepository) MigrateUsers(ctx context.Context) (migrated int, err error) {
+	start := time.Now()
+	defer func() { r.record("MigrateUsers", start, err) }()
+	return r.UserRepository.MigrateUsers(ctx)
+}
+
+// UpdateUsersWhere records metrics for UserRepository.UpdateUsersWhere.
+func (r *InstrumentedUserRepository) UpdateUsersWhere(ctx context.Context, filter repository.UserFilter, patch repository.UserPatch, dryRun bool) (result repository.BulkUpdateResult, err error) {
+	start := time.Now()
+	defer func() { r.record("UpdateUsersWhere", start, err) }()
+	return r.UserRepository.UpdateUsersWhere(ctx, filter, patch, dryRun)
+}
+
+// PurgeDeletedUsers records metrics for UserRepository.PurgeDeletedUsers.
+func (r *InstrumentedUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (result repository.PurgeResult, err error) {
+	start := time.Now()
+	defer func() { r.record("PurgeDeletedUsers", start, err) }()
+	return r.UserRepository.PurgeDeletedUsers(ctx, deletedBefore)
+}
+
+// Ping records metrics for the health check when the wrapped repository supports one.
+func (r *InstrumentedUserRepository) Ping(ctx context.Context) (err error) {
+	pinger, ok := r.UserRepository.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	defer func() { r.record("Ping", start, err) }()
+	return pinger.Ping(ctx)
+}
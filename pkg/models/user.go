@@ -1,8 +1,60 @@
 package models
 
+// Role is the permission level of a user.
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// Roles lists every valid role. RoleViewer is the default for new users.
+var Roles = []Role{RoleAdmin, RoleEditor, RoleViewer}
+
 // User represents a user entity stored in the database.
 type User struct {
+	// ID is a random UUID set on insert. Unlike the email, which changes with ChangeEmail, it
+	// identifies the user for life, and it is the table's key with the id key schema.
+	ID        string `json:"id,omitempty" dynamodbav:"id,omitempty"`
 	Email     string `json:"email"`
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
-}
\ No newline at end of file
+	Phone     string `json:"phone,omitempty"`     // Optional, E.164 format
+	Role      Role   `json:"role,omitempty"`      // Defaults to RoleViewer on create
+	AvatarURL string `json:"avatarUrl,omitempty"` // Optional, absolute http(s) URL
+	Locale    string `json:"locale,omitempty"`    // Optional, BCP 47 language tag such as en-US
+	Timezone  string `json:"timezone,omitempty"`  // Optional, IANA time zone such as America/New_York
+	// Metadata holds extra deployment-specific attributes, stored as a DynamoDB map.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt string            `json:"createdAt,omitempty"` // RFC3339, set on insert
+	UpdatedAt string            `json:"updatedAt,omitempty"` // RFC3339, refreshed on every write
+	Version   int               `json:"version,omitempty"`   // Optimistic-locking counter, starts at 1
+	Deleted   bool              `json:"deleted,omitempty"`
+	DeletedAt string            `json:"deletedAt,omitempty"`
+	// ExpiresAt is the Unix time (in seconds) after which a temporary user is gone. It is the table's
+	// TTL attribute, so DynamoDB deletes the record some time after it passes. Zero means never.
+	ExpiresAt int64 `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
+	// TTLSeconds is the lifetime accepted in create requests for a temporary user. It sets ExpiresAt
+	// on insert and is never stored or returned.
+	TTLSeconds int64 `json:"ttlSeconds,omitempty" dynamodbav:"-"`
+
+	// Password is the plaintext password accepted in create/update requests. It is hashed into
+	// PasswordHash before storage and is never stored or returned.
+	Password string `json:"password,omitempty" dynamodbav:"-"`
+	// PasswordHash is the bcrypt hash of the password. It is stored but never exposed through the API.
+	PasswordHash string `json:"-" dynamodbav:"passwordHash,omitempty"`
+
+	// NormalizedEmail is the lowercased email, stored for the case-insensitive lookup index
+	// but never exposed through the API.
+	NormalizedEmail string `json:"-" dynamodbav:"normalizedEmail,omitempty"`
+	// SearchTokens are the lowercased words of the names, kept up to date on every write for
+	// SearchUsers. They are never exposed through the API.
+	SearchTokens []string `json:"-" dynamodbav:"searchTokens,omitempty"`
+}
+
+// UserFields lists the fields of User that clients may select, by their JSON (and DynamoDB attribute) name.
+var UserFields = []string{
+	"id", "email", "firstName", "lastName", "phone", "role", "avatarUrl", "locale", "timezone", "metadata",
+	"createdAt", "updatedAt", "version", "deleted", "deletedAt", "expiresAt",
+}
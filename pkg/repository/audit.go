@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// AuditRecord describes one change to a user: who made it, when, and the user before and after it.
+type AuditRecord struct {
+	Email     string `json:"email"`   // The user's email after the change, or before it for a delete
+	AuditID   string `json:"auditId"` // Timestamp-prefixed, so the records of a user sort chronologically
+	Operation string `json:"operation"`
+	Actor     string `json:"actor,omitempty"`     // Subject of the caller's token; empty when unauthenticated
+	ActorRole string `json:"actorRole,omitempty"` // Role claim of the caller's token
+	Table     string `json:"table"`
+	RequestID string `json:"requestId,omitempty"` // Lambda request ID of the invocation that made the change
+	MessageID string `json:"messageId,omitempty"` // ID of the SQS message that requested a queued change
+	Timestamp string `json:"timestamp"`           // RFC3339 with nanoseconds
+	// Before and After are the stored user around the change, without password hash. Before is
+	// empty for a creation and After for a deletion.
+	Before *models.User `json:"before,omitempty"`
+	After  *models.User `json:"after,omitempty"`
+	// PasswordChanged reports that the change set a new password, whose hash is not recorded.
+	PasswordChanged bool `json:"passwordChanged,omitempty"`
+}
+
+// AuditLog is an append-only store of AuditRecords.
+type AuditLog interface {
+	// AppendRecord stores record. Stored records are never updated or deleted.
+	AppendRecord(ctx context.Context, record AuditRecord) error
+}
+
+// ChangeRecorder receives a user changed by an operation, as stored before and after the change.
+// before is nil for a creation and after for a deletion.
+type ChangeRecorder func(before, after *models.User)
+
+type changeRecorderKey struct{}
+
+// WithChangeRecorder returns a copy of ctx under which MergeUsers, UpdateUsersWhere, DeleteAllUsers,
+// PurgeDeletedUsers and MigrateUsers pass every user they change to record, once its change is
+// stored. Those operations change users the caller does not know in advance, so this is how they are
+// audited.
+func WithChangeRecorder(ctx context.Context, record ChangeRecorder) context.Context {
+	return context.WithValue(ctx, changeRecorderKey{}, record)
+}
+
+// changeRecorder returns the ChangeRecorder of ctx, or nil if there is none.
+func changeRecorder(ctx context.Context) ChangeRecorder {
+	record, _ := ctx.Value(changeRecorderKey{}).(ChangeRecorder)
+	return record
+}
+
+// recordChange passes a change to the ChangeRecorder of ctx, if there is one.
+func recordChange(ctx context.Context, before, after *models.User) {
+	if record := changeRecorder(ctx); record != nil {
+		record(before, after)
+	}
+}
+
+// DynamoDBAuditLog implements AuditLog with a dedicated DynamoDB table, keyed on email (string
+// partition key) and auditId (string sort key), so a Query on an email returns its history in order.
+// Records are only ever put, never overwritten, so the function needs no other permission on the table.
+type DynamoDBAuditLog struct {
+	client        dynamodbiface.DynamoDBAPI
+	tableName     string
+	retryStrategy RetryStrategy
+}
+
+// NewDynamoDBAuditLog creates a new DynamoDBAuditLog instance.
+// Only the retry options are relevant; the table is retried with the on-demand strategy.
+func NewDynamoDBAuditLog(client dynamodbiface.DynamoDBAPI, tableName string, opts DynamoDBOptions) *DynamoDBAuditLog {
+	return &DynamoDBAuditLog{
+		client:        client,
+		tableName:     tableName,
+		retryStrategy: RetryStrategyFor(dynamodb.BillingModePayPerRequest, retryOverrides(opts)),
+	}
+}
+
+// AppendRecord puts record, conditional on no record with its key existing.
+func (log *DynamoDBAuditLog) AppendRecord(ctx context.Context, record AuditRecord) error {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "AppendAuditRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           aws.String(log.tableName),
+		ConditionExpression: aws.String("attribute_not_exists(auditId)"),
+	}
+	err = retry(ctx, log.retryStrategy, "AppendAuditRecord", func() error {
+		_, err := log.client.PutItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB PutItem failed", slog.String("operation", "AppendAuditRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return nil
+}
+
+// InMemoryAuditLog implements AuditLog with a slice, for local development.
+type InMemoryAuditLog struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+// NewInMemoryAuditLog creates an empty InMemoryAuditLog.
+func NewInMemoryAuditLog() *InMemoryAuditLog {
+	return &InMemoryAuditLog{}
+}
+
+// AppendRecord appends record to the log.
+func (log *InMemoryAuditLog) AppendRecord(ctx context.Context, record AuditRecord) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	log.records = append(log.records, record)
+	return nil
+}
+
+// Records returns the records appended so far, oldest first.
+func (log *InMemoryAuditLog) Records() []AuditRecord {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return slices.Clone(log.records)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestFetchUsersByEmails(t *testing.T) {
+	stored := []models.User{
+		{Email: "a@example.com", FirstName: "A"},
+		{Email: "b@example.com", FirstName: "B"},
+		{Email: "gone@example.com", FirstName: "G", Deleted: true},
+	}
+	tests := []struct {
+		name         string
+		emails       []string
+		opts         FetchOptions
+		wantUsers    []string
+		wantNotFound []string
+	}{
+		{
+			name:         "existing and missing",
+			emails:       []string{"b@example.com", "nobody@example.com", "a@example.com"},
+			wantUsers:    []string{"b@example.com", "a@example.com"},
+			wantNotFound: []string{"nobody@example.com"},
+		},
+		{
+			name:         "duplicates and mixed case",
+			emails:       []string{"A@Example.com", "a@example.com", " a@example.com "},
+			wantUsers:    []string{"a@example.com"},
+			wantNotFound: []string{},
+		},
+		{
+			name:         "soft-deleted user",
+			emails:       []string{"gone@example.com"},
+			wantUsers:    []string{},
+			wantNotFound: []string{"gone@example.com"},
+		},
+		{
+			name:         "soft-deleted user included",
+			emails:       []string{"gone@example.com"},
+			opts:         FetchOptions{IncludeDeleted: true},
+			wantUsers:    []string{"gone@example.com"},
+			wantNotFound: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+			for _, user := range stored {
+				if _, err := memory.CreateUser(context.Background(), models.User{Email: user.Email, FirstName: user.FirstName}); err != nil {
+					t.Fatal(err)
+				}
+				if user.Deleted {
+					if _, err := memory.DeleteUser(context.Background(), user.Email); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			repos := map[string]UserRepository{
+				"DynamoDB":  NewDynamoDBUserRepository(&mockDynamoDB{batchGetItem: batchGetFrom(t, stored...)}, testTable, DynamoDBOptions{}),
+				"in memory": memory,
+			}
+			for name, repo := range repos {
+				result, err := repo.FetchUsersByEmails(context.Background(), tt.emails, tt.opts)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				users := []string{}
+				for _, user := range result.Users {
+					users = append(users, user.Email)
+				}
+				if !slices.Equal(users, tt.wantUsers) || !slices.Equal(result.NotFound, tt.wantNotFound) {
+					t.Errorf("%s: users = %v, not found = %v, want %v and %v", name, users, result.NotFound, tt.wantUsers, tt.wantNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchUsersByEmailsChunksAndRetries(t *testing.T) {
+	var stored []models.User
+	var emails []string
+	for i := range 250 {
+		email := fmt.Sprintf("user%03d@example.com", i)
+		stored = append(stored, models.User{Email: email})
+		emails = append(emails, email)
+	}
+	found := batchGetFrom(t, stored...)
+	var chunkSizes []int
+	held := false
+	client := &mockDynamoDB{
+		batchGetItem: func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			keys := input.RequestItems[testTable].Keys
+			chunkSizes = append(chunkSizes, len(keys))
+			if held {
+				return found(input)
+			}
+			// Leave the last two keys of the first request unprocessed
+			held = true
+			output, err := found(&dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{testTable: {Keys: keys[:len(keys)-2]}}})
+			output.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{testTable: {Keys: keys[len(keys)-2:]}}
+			return output, err
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	result, err := repo.FetchUsersByEmails(context.Background(), emails, FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 250 || len(result.NotFound) != 0 {
+		t.Errorf("%d users and %d not found, want 250 and 0", len(result.Users), len(result.NotFound))
+	}
+	if want := []int{100, 2, 100, 50}; !slices.Equal(chunkSizes, want) {
+		t.Errorf("chunk sizes = %v, want %v", chunkSizes, want)
+	}
+}
+
+func TestFetchUsersByEmailsGivesUpOnUnprocessedKeys(t *testing.T) {
+	calls := 0
+	client := &mockDynamoDB{
+		batchGetItem: func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			calls++
+			return &dynamodb.BatchGetItemOutput{UnprocessedKeys: input.RequestItems}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	_, err := repo.FetchUsersByEmails(context.Background(), []string{"a@example.com"}, FetchOptions{})
+	if !errors.Is(err, ErrCouldNotBatchGetItems) {
+		t.Errorf("err = %v, want %v", err, ErrCouldNotBatchGetItems)
+	}
+	if calls != maxBatchAttempts {
+		t.Errorf("%d calls, want %d", calls, maxBatchAttempts)
+	}
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// UserFilter selects the users changed by UpdateUsersWhere. Each non-empty field must equal the
+// user's attribute; soft-deleted users never match.
+type UserFilter struct {
+	Role     models.Role `json:"role,omitempty"`
+	Locale   string      `json:"locale,omitempty"`
+	Timezone string      `json:"timezone,omitempty"`
+}
+
+// IsEmpty reports whether the filter has no conditions, and so would match every user.
+func (f UserFilter) IsEmpty() bool {
+	return f.Role == "" && f.Locale == "" && f.Timezone == ""
+}
+
+// matches reports whether user is live and has every attribute the filter sets.
+func (f UserFilter) matches(user models.User) bool {
+	return !user.Deleted && !expired(user) &&
+		(f.Role == "" || user.Role == f.Role) &&
+		(f.Locale == "" || user.Locale == f.Locale) &&
+		(f.Timezone == "" || user.Timezone == f.Timezone)
+}
+
+// UserPatch is the partial update applied by UpdateUsersWhere. Only its non-empty fields are
+// written; every other attribute of the users is kept.
+type UserPatch struct {
+	Role     models.Role `json:"role,omitempty"`
+	Locale   string      `json:"locale,omitempty"`
+	Timezone string      `json:"timezone,omitempty"`
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p UserPatch) IsEmpty() bool {
+	return p.Role == "" && p.Locale == "" && p.Timezone == ""
+}
+
+// apply writes the non-empty fields of the patch onto user.
+func (p UserPatch) apply(user *models.User) {
+	if p.Role != "" {
+		user.Role = p.Role
+	}
+	if p.Locale != "" {
+		user.Locale = p.Locale
+	}
+	if p.Timezone != "" {
+		user.Timezone = p.Timezone
+	}
+}
+
+// attributes lists the attribute names and values set by the patch or the filter.
+func attributes(role models.Role, locale, timezone string) map[string]string {
+	attrs := map[string]string{}
+	if role != "" {
+		attrs["role"] = string(role)
+	}
+	if locale != "" {
+		attrs["locale"] = locale
+	}
+	if timezone != "" {
+		attrs["timezone"] = timezone
+	}
+	return attrs
+}
+
+// BulkUpdateResult reports the outcome of UpdateUsersWhere.
+type BulkUpdateResult struct {
+	// Matched is how many users matched the filter.
+	Matched int `json:"matched"`
+	// Updated is how many of them were changed. It is zero for a dry run, and lower than Matched
+	// when users changed concurrently so that they no longer matched.
+	Updated int `json:"updated"`
+	// Complete is false when the run stopped early to stay within its deadline; running the same
+	// update again picks up the remaining users.
+	Complete bool `json:"complete"`
+}
+
+// UpdateUsersWhere applies patch to every live user matching filter and reports how many matched
+// and were updated. With dryRun set, the matching users are only counted.
+//
+// The table is scanned page by page for matching records (reading only the keys) and each match is
+// updated with its own UpdateItem, conditional on the user still matching, which also refreshes
+// updatedAt and increments version. Like PurgeDeletedUsers, no further pages are started when the
+// context's deadline is near, and the result is reported as incomplete. Since the patched users
+// usually no longer match, re-running the update continues where it stopped. Every updated user is
+// passed to the ChangeRecorder of ctx, if any, its former attributes returned by its UpdateItem.
+func (repo *DynamoDBUserRepository) UpdateUsersWhere(ctx context.Context, filter UserFilter, patch UserPatch, dryRun bool) (BulkUpdateResult, error) {
+	if filter.IsEmpty() || patch.IsEmpty() {
+		return BulkUpdateResult{}, fmt.Errorf("%w: a bulk update needs a filter and at least one field to change", ErrInvalidOperation)
+	}
+
+	condition, names, values := filterCondition(filter)
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(repo.tableName),
+		ProjectionExpression:      aws.String(names.list(repo.keyAttributes())),
+		FilterExpression:          aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	var result BulkUpdateResult
+	if nearDeadline(ctx) {
+		return result, nil
+	}
+	err := repo.scanPages(ctx, "UpdateUsersWhere", input, func(page *dynamodb.ScanOutput) error {
+		result.Matched += len(page.Items)
+		if !dryRun {
+			for _, item := range page.Items {
+				updated, err := repo.patchUser(ctx, repo.itemKey(item), filter, patch)
+				if err != nil {
+					return err
+				}
+				if updated {
+					result.Updated++
+				}
+			}
+		}
+		if len(page.LastEvaluatedKey) > 0 && nearDeadline(ctx) {
+			return errNearDeadline
+		}
+		return nil
+	})
+	if errors.Is(err, errNearDeadline) {
+		return result, nil
+	}
+	result.Complete = err == nil
+	return result, err
+}
+
+// filterCondition builds the expression matching the live users selected by filter, with the
+// names and values it refers to.
+func filterCondition(filter UserFilter) (string, expressionNames, map[string]*dynamodb.AttributeValue) {
+	names := expressionNames{"#deleted": aws.String("deleted"), "#expiresAt": aws.String("expiresAt")}
+	values := map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}, ":now": nowValue()}
+	condition := "(" + notDeletedFilter + ") AND (" + notExpiredFilter + ")"
+	for attr, value := range attributes(filter.Role, filter.Locale, filter.Timezone) {
+		condition += " AND " + names.name(attr) + " = :filter_" + attr
+		values[":filter_"+attr] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	return condition, names, values
+}
+
+// patchUser applies patch to the user with key, provided it still exists and matches filter. It
+// reports false when the user changed concurrently and no longer matches.
+func (repo *DynamoDBUserRepository) patchUser(ctx context.Context, key map[string]*dynamodb.AttributeValue, filter UserFilter, patch UserPatch) (bool, error) {
+	condition, names, values := filterCondition(filter)
+	version := names.name("version")
+	sets := []string{
+		names.name("updatedAt") + " = :updatedAt",
+		version + " = if_not_exists(" + version + ", :zero) + :one",
+	}
+	now := timestamp()
+	values[":updatedAt"] = &dynamodb.AttributeValue{S: aws.String(now)}
+	values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	for attr, value := range attributes(patch.Role, patch.Locale, patch.Timezone) {
+		sets = append(sets, names.name(attr)+" = :patch_"+attr)
+		values[":patch_"+attr] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(" + names.name("email") + ") AND " + condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if changeRecorder(ctx) != nil {
+		input.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	}
+
+	var result *dynamodb.UpdateItemOutput
+	err := repo.withRetry(ctx, "UpdateUsersWhere", func() (err error) {
+		result, err = repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return false, nil
+		}
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "UpdateUsersWhere"), slog.Any("error", err))
+		return false, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	if changeRecorder(ctx) != nil {
+		var before models.User
+		if err := dynamodbattribute.UnmarshalMap(result.Attributes, &before); err != nil {
+			slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "UpdateUsersWhere"), slog.Any("error", err))
+			return true, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+		}
+		after := before
+		patch.apply(&after)
+		after.UpdatedAt = now
+		after.Version++
+		recordChange(ctx, &before, &after)
+	}
+	return true, nil
+}
+
+// UpdateUsersWhere applies patch to every live user matching filter, like the DynamoDB version.
+// The in-memory repository always completes in one run.
+func (repo *InMemoryUserRepository) UpdateUsersWhere(ctx context.Context, filter UserFilter, patch UserPatch, dryRun bool) (BulkUpdateResult, error) {
+	if filter.IsEmpty() || patch.IsEmpty() {
+		return BulkUpdateResult{}, fmt.Errorf("%w: a bulk update needs a filter and at least one field to change", ErrInvalidOperation)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := timestamp()
+	result := BulkUpdateResult{Complete: true}
+	for email, user := range repo.users {
+		if !filter.matches(user) {
+			continue
+		}
+		result.Matched++
+		if dryRun {
+			continue
+		}
+		before := user
+		patch.apply(&user)
+		user.UpdatedAt = now
+		user.Version++
+		repo.users[email] = user
+		result.Updated++
+		recordChange(ctx, &before, &user)
+	}
+	return result, nil
+}
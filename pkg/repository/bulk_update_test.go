@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestUpdateUsersWhere(t *testing.T) {
+	filter := UserFilter{Role: models.RoleEditor}
+	patch := UserPatch{Role: models.RoleViewer}
+	tests := []struct {
+		name        string
+		filter      UserFilter
+		patch       UserPatch
+		dryRun      bool
+		changed     []string // Users changed after the scan, whose conditional update fails
+		updateErr   error
+		want        BulkUpdateResult
+		wantUpdated []string
+		wantErr     error
+	}{
+		{
+			name:        "updates every match",
+			filter:      filter,
+			patch:       patch,
+			want:        BulkUpdateResult{Matched: 2, Updated: 2, Complete: true},
+			wantUpdated: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			name:   "dry run",
+			filter: filter,
+			patch:  patch,
+			dryRun: true,
+			want:   BulkUpdateResult{Matched: 2, Complete: true},
+		},
+		{
+			name:        "skips a user changed after the scan",
+			filter:      filter,
+			patch:       patch,
+			changed:     []string{"a@example.com"},
+			want:        BulkUpdateResult{Matched: 2, Updated: 1, Complete: true},
+			wantUpdated: []string{"b@example.com"},
+		},
+		{
+			name:      "fails on other errors",
+			filter:    filter,
+			patch:     patch,
+			updateErr: awserr.New("ValidationException", "invalid", nil),
+			want:      BulkUpdateResult{Matched: 2},
+			wantErr:   ErrCouldNotDynamoPutItem,
+		},
+		{name: "no filter", patch: patch, wantErr: ErrInvalidOperation},
+		{name: "no patch", filter: filter, wantErr: ErrInvalidOperation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated []string
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if got := aws.StringValue(input.FilterExpression); !strings.HasSuffix(got, " AND #role = :filter_role") {
+						t.Errorf("filter = %q", got)
+					}
+					if got := aws.StringValue(input.ExpressionAttributeValues[":filter_role"].S); got != "editor" {
+						t.Errorf(":filter_role = %q", got)
+					}
+					if got := projectedAttributes(t, input.ProjectionExpression, input.ExpressionAttributeNames); !slices.Equal(got, []string{"email"}) {
+						t.Errorf("projection = %v, want only the key", got)
+					}
+					return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+						userKey("a@example.com"), userKey("b@example.com"),
+					}}, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); !strings.HasPrefix(got, "attribute_exists(#email) AND ") || !strings.HasSuffix(got, " AND #role = :filter_role") {
+						t.Errorf("condition = %q", got)
+					}
+					if got := aws.StringValue(input.ExpressionAttributeValues[":patch_role"].S); got != "viewer" {
+						t.Errorf(":patch_role = %q", got)
+					}
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					email := aws.StringValue(input.Key["email"].S)
+					if slices.Contains(tt.changed, email) {
+						return nil, errConditionFailed
+					}
+					updated = append(updated, email)
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			result, err := repo.UpdateUsersWhere(context.Background(), tt.filter, tt.patch, tt.dryRun)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if result != tt.want {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+			if !slices.Equal(updated, tt.wantUpdated) {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestInMemoryUpdateUsersWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    UserFilter
+		patch     UserPatch
+		dryRun    bool
+		want      BulkUpdateResult
+		wantRoles map[string]models.Role
+	}{
+		{
+			name:   "changes only matching users",
+			filter: UserFilter{Role: models.RoleEditor},
+			patch:  UserPatch{Role: models.RoleViewer},
+			want:   BulkUpdateResult{Matched: 2, Updated: 2, Complete: true},
+			wantRoles: map[string]models.Role{
+				"editor1@example.com": models.RoleViewer,
+				"editor2@example.com": models.RoleViewer,
+				"admin@example.com":   models.RoleAdmin,
+				"deleted@example.com": models.RoleEditor,
+			},
+		},
+		{
+			name:   "every filter field must match",
+			filter: UserFilter{Role: models.RoleEditor, Locale: "de-DE"},
+			patch:  UserPatch{Role: models.RoleViewer},
+			want:   BulkUpdateResult{Matched: 1, Updated: 1, Complete: true},
+			wantRoles: map[string]models.Role{
+				"editor1@example.com": models.RoleEditor,
+				"editor2@example.com": models.RoleViewer,
+				"admin@example.com":   models.RoleAdmin,
+				"deleted@example.com": models.RoleEditor,
+			},
+		},
+		{
+			name:   "dry run",
+			filter: UserFilter{Role: models.RoleEditor},
+			patch:  UserPatch{Role: models.RoleViewer},
+			dryRun: true,
+			want:   BulkUpdateResult{Matched: 2, Complete: true},
+			wantRoles: map[string]models.Role{
+				"editor1@example.com": models.RoleEditor,
+				"editor2@example.com": models.RoleEditor,
+				"admin@example.com":   models.RoleAdmin,
+				"deleted@example.com": models.RoleEditor,
+			},
+		},
+		{
+			name:   "no match",
+			filter: UserFilter{Timezone: "Asia/Tokyo"},
+			patch:  UserPatch{Role: models.RoleViewer},
+			want:   BulkUpdateResult{Complete: true},
+			wantRoles: map[string]models.Role{
+				"editor1@example.com": models.RoleEditor,
+				"editor2@example.com": models.RoleEditor,
+				"admin@example.com":   models.RoleAdmin,
+				"deleted@example.com": models.RoleEditor,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+			ctx := context.Background()
+			for _, user := range []models.User{
+				{Email: "editor1@example.com", Role: models.RoleEditor, Locale: "en-US"},
+				{Email: "editor2@example.com", Role: models.RoleEditor, Locale: "de-DE"},
+				{Email: "admin@example.com", Role: models.RoleAdmin, Locale: "de-DE"},
+				{Email: "deleted@example.com", Role: models.RoleEditor, Locale: "de-DE"},
+			} {
+				if _, err := repo.CreateUser(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := repo.DeleteUser(ctx, "deleted@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			before := maps.Clone(repo.users)
+
+			result, err := repo.UpdateUsersWhere(ctx, tt.filter, tt.patch, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+			for email, want := range tt.wantRoles {
+				user := repo.users[email]
+				if user.Role != want {
+					t.Errorf("%s role = %s, want %s", email, user.Role, want)
+				}
+				// Every patched user changes role here, and only patched users get a new version
+				if changed := user.Role != before[email].Role; (user.Version != before[email].Version) != changed {
+					t.Errorf("%s version %d -> %d, role changed: %v", email, before[email].Version, user.Version, changed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// CapacityLoggingClient wraps a DynamoDB client, requesting the consumed capacity (TOTAL) on every
+// data-plane call the repositories make and logging it, to help diagnose hot partitions.
+// Reporting capacity adds to every response, so it is meant for debugging only.
+type CapacityLoggingClient struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+// NewCapacityLoggingClient wraps client in a CapacityLoggingClient.
+func NewCapacityLoggingClient(client dynamodbiface.DynamoDBAPI) *CapacityLoggingClient {
+	return &CapacityLoggingClient{DynamoDBAPI: client}
+}
+
+// GetItemWithContext calls GetItem and logs its consumed capacity.
+func (c *CapacityLoggingClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("GetItem", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// PutItemWithContext calls PutItem and logs its consumed capacity.
+func (c *CapacityLoggingClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("PutItem", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// UpdateItemWithContext calls UpdateItem and logs its consumed capacity.
+func (c *CapacityLoggingClient) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("UpdateItem", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// DeleteItemWithContext calls DeleteItem and logs its consumed capacity.
+func (c *CapacityLoggingClient) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("DeleteItem", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// QueryWithContext calls Query and logs its consumed capacity.
+func (c *CapacityLoggingClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.QueryWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("Query", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// ScanWithContext calls Scan and logs its consumed capacity.
+func (c *CapacityLoggingClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.ScanWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("Scan", output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+// ScanPagesWithContext calls Scan page by page and logs the consumed capacity of each page.
+func (c *CapacityLoggingClient) ScanPagesWithContext(ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option) error {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	return c.DynamoDBAPI.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		logConsumedCapacity("Scan", page.ConsumedCapacity)
+		return fn(page, lastPage)
+	}, opts...)
+}
+
+// BatchGetItemWithContext calls BatchGetItem and logs its consumed capacity per table.
+func (c *CapacityLoggingClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.BatchGetItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("BatchGetItem", output.ConsumedCapacity...)
+	}
+	return output, err
+}
+
+// BatchWriteItemWithContext calls BatchWriteItem and logs its consumed capacity per table.
+func (c *CapacityLoggingClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("BatchWriteItem", output.ConsumedCapacity...)
+	}
+	return output, err
+}
+
+// TransactWriteItemsWithContext calls TransactWriteItems and logs its consumed capacity per table.
+func (c *CapacityLoggingClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	output, err := c.DynamoDBAPI.TransactWriteItemsWithContext(ctx, input, opts...)
+	if err == nil {
+		logConsumedCapacity("TransactWriteItems", output.ConsumedCapacity...)
+	}
+	return output, err
+}
+
+// logConsumedCapacity writes one log line per table with the capacity units a call consumed.
+func logConsumedCapacity(call string, capacities ...*dynamodb.ConsumedCapacity) {
+	for _, capacity := range capacities {
+		if capacity == nil {
+			continue
+		}
+		slog.Info("DynamoDB consumed capacity",
+			slog.String("operation", call),
+			slog.String("table", aws.StringValue(capacity.TableName)),
+			slog.Float64("capacityUnits", aws.Float64Value(capacity.CapacityUnits)),
+			slog.Float64("readCapacityUnits", aws.Float64Value(capacity.ReadCapacityUnits)),
+			slog.Float64("writeCapacityUnits", aws.Float64Value(capacity.WriteCapacityUnits)))
+	}
+}
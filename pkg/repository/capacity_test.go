@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func TestCapacityLoggingClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		wantTotal bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", enabled: true, wantTotal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			record := func(call string, returnConsumedCapacity *string) {
+				requested = append(requested, call+"="+aws.StringValue(returnConsumedCapacity))
+			}
+			consumed := &dynamodb.ConsumedCapacity{TableName: aws.String(testTable), CapacityUnits: aws.Float64(1.5)}
+			mock := &mockDynamoDB{
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					record("GetItem", input.ReturnConsumedCapacity)
+					return &dynamodb.GetItemOutput{ConsumedCapacity: consumed}, nil
+				},
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					record("PutItem", input.ReturnConsumedCapacity)
+					return &dynamodb.PutItemOutput{ConsumedCapacity: consumed}, nil
+				},
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					record("Scan", input.ReturnConsumedCapacity)
+					return &dynamodb.ScanOutput{ConsumedCapacity: consumed}, nil
+				},
+			}
+			var client dynamodbiface.DynamoDBAPI = mock
+			if tt.enabled {
+				client = NewCapacityLoggingClient(mock)
+			}
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+			ctx := context.Background()
+
+			if _, err := repo.FetchUser(ctx, "a@example.com", FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{"GetItem=", "PutItem=", "Scan="}
+			if tt.wantTotal {
+				want = []string{"GetItem=TOTAL", "PutItem=TOTAL", "Scan=TOTAL"}
+			}
+			if !slices.Equal(requested, want) {
+				t.Errorf("ReturnConsumedCapacity = %v, want %v", requested, want)
+			}
+			logged := strings.Count(logs.String(), "DynamoDB consumed capacity")
+			if tt.wantTotal && (logged != 3 || !strings.Contains(logs.String(), "capacityUnits=1.5")) {
+				t.Errorf("logged %d capacity lines, want 3 with 1.5 units:\n%s", logged, logs.String())
+			}
+			if !tt.wantTotal && logged != 0 {
+				t.Errorf("logged %d capacity lines, want none", logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ChangeEmail moves the user stored under oldEmail to newEmail, keeping its ID, creation time,
+// password and every other attribute, and returns the moved user.
+//
+// The email is the table's key, so the record is copied to the new key and the old one deleted in
+// a single TransactWriteItems call: the copy is conditional on newEmail being free, or only held by
+// an expired user, as for CreateUser (which is what keeps emails unique; a secondary index could not) and the delete on the user being unchanged since
+// it was read. A taken newEmail fails with ErrUserAlreadyExists, a concurrent write with
+// ErrVersionConflict. The move appears in the table's stream as a creation and a deletion.
+//
+// With KeySchemaID the record stays under its id and only its email is updated, in the same
+// transaction that reserves newEmail and releases oldEmail in the email table; the change appears in
+// the stream as a modification.
+func (repo *DynamoDBUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	oldEmail = validators.NormalizeEmail(oldEmail)
+	newEmail = validators.NormalizeEmail(newEmail)
+	if oldEmail == newEmail {
+		return nil, fmt.Errorf("%w: the new email is the current one", ErrInvalidOperation)
+	}
+	if repo.keyedByID() {
+		return repo.changeEmailByID(ctx, oldEmail, newEmail)
+	}
+
+	input := &dynamodb.GetItemInput{
+		TableName:      aws.String(repo.tableName),
+		Key:            userKey(oldEmail),
+		ConsistentRead: aws.Bool(true),
+	}
+	var result *dynamodb.GetItemOutput
+	err := repo.withRetry(ctx, "ChangeEmail", func() (err error) {
+		result, err = repo.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", "ChangeEmail"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+	item := result.Item
+	if item == nil {
+		return nil, ErrUserDoesNotExist
+	}
+
+	// The delete only succeeds if the version read is still the stored one
+	deleteCondition := "attribute_not_exists(#version)"
+	values := map[string]*dynamodb.AttributeValue{}
+	if version, ok := item["version"]; ok {
+		deleteCondition = "#version = :version"
+		values[":version"] = version
+	}
+
+	moved, err := movedUser(item, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if moved.Deleted || expired(*moved) {
+		return nil, ErrUserDoesNotExist
+	}
+	movedItem, err := dynamodbattribute.MarshalMap(moved)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+	// Attributes the model does not know about are carried over as they are
+	for attr, value := range item {
+		if _, ok := movedItem[attr]; !ok && attr != "deleted" && attr != "deletedAt" {
+			movedItem[attr] = value
+		}
+	}
+
+	// Like CreateUser, the new email may only be taken by an expired user DynamoDB has yet to delete
+	putCondition, putNames, putValues := newUserCondition()
+	transaction := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:                 aws.String(repo.tableName),
+				Item:                      movedItem,
+				ConditionExpression:       aws.String(putCondition),
+				ExpressionAttributeNames:  putNames,
+				ExpressionAttributeValues: putValues,
+			}},
+			{Delete: &dynamodb.Delete{
+				TableName:                 aws.String(repo.tableName),
+				Key:                       userKey(oldEmail),
+				ConditionExpression:       aws.String(deleteCondition),
+				ExpressionAttributeNames:  map[string]*string{"#version": aws.String("version")},
+				ExpressionAttributeValues: nilIfEmpty(values),
+			}},
+		},
+	}
+	err = repo.withRetry(ctx, "ChangeEmail", func() error {
+		_, err := repo.client.TransactWriteItemsWithContext(ctx, transaction)
+		return err
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			return nil, changeEmailCancellation(oldEmail, newEmail, canceled.CancellationReasons)
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "ChangeEmail"), slog.Any("error", err))
+		return nil, fmt.Errorf("could not write transaction to DynamoDB: %w", err)
+	}
+	return moved, nil
+}
+
+// changeEmailByID is ChangeEmail with KeySchemaID, for normalized emails.
+func (repo *DynamoDBUserRepository) changeEmailByID(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	key, err := repo.keyOf(ctx, "ChangeEmail", oldEmail)
+	if err != nil {
+		return nil, err
+	}
+	item, err := repo.getUserItem(ctx, "ChangeEmail", key)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item["email"] == nil || aws.StringValue(item["email"].S) != oldEmail {
+		return nil, ErrUserDoesNotExist
+	}
+	moved, err := movedUser(item, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if moved.Deleted || expired(*moved) {
+		return nil, ErrUserDoesNotExist
+	}
+	reservation, err := repo.reserveEmail(*moved)
+	if err != nil {
+		return nil, err
+	}
+
+	// The update only succeeds if the user still has oldEmail and the version read
+	condition, values := unchangedCondition(item)
+	values = maps.Clone(values)
+	if values == nil {
+		values = map[string]*dynamodb.AttributeValue{}
+	}
+	values[":email"] = stringValue(newEmail)
+	values[":oldEmail"] = stringValue(oldEmail)
+	values[":updatedAt"] = stringValue(moved.UpdatedAt)
+	values[":newVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(moved.Version))}
+	err = repo.transactWrite(ctx, "ChangeEmail", []*dynamodb.TransactWriteItem{
+		reservation,
+		{Update: &dynamodb.Update{
+			TableName:           aws.String(repo.tableName),
+			Key:                 key,
+			UpdateExpression:    aws.String("SET #email = :email, #normalizedEmail = :email, #updatedAt = :updatedAt, #version = :newVersion"),
+			ConditionExpression: aws.String("#email = :oldEmail AND " + condition),
+			ExpressionAttributeNames: map[string]*string{
+				"#email":           aws.String("email"),
+				"#normalizedEmail": aws.String("normalizedEmail"),
+				"#updatedAt":       aws.String("updatedAt"),
+				"#version":         aws.String("version"),
+			},
+			ExpressionAttributeValues: values,
+		}},
+		repo.releaseEmail(oldEmail, moved.ID),
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if conditionFailedAt(err, 2) {
+			return nil, ErrVersionConflict
+		}
+		if errors.As(err, &canceled) {
+			return nil, changeEmailCancellation(oldEmail, newEmail, canceled.CancellationReasons)
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "ChangeEmail"), slog.Any("error", err))
+		return nil, fmt.Errorf("could not write transaction to DynamoDB: %w", err)
+	}
+	return moved, nil
+}
+
+// movedUser returns the user stored as item as it is written under newEmail: with a new
+// updatedAt and version, and an ID if it was written before users had one.
+func movedUser(item map[string]*dynamodb.AttributeValue, newEmail string) (*models.User, error) {
+	user := new(models.User)
+	if err := dynamodbattribute.UnmarshalMap(item, user); err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "ChangeEmail"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	user.Email = newEmail
+	user.NormalizedEmail = newEmail
+	user.UpdatedAt = timestamp()
+	user.Version++
+	if user.ID == "" {
+		user.ID = newUserID()
+	}
+	return user, nil
+}
+
+// changeEmailCancellation maps the reasons of a cancelled ChangeEmail transaction, whose first
+// action writes the new email and second deletes (or, with KeySchemaID, updates) the user under the
+// old one, to the repository errors.
+func changeEmailCancellation(oldEmail, newEmail string, reasons []*dynamodb.CancellationReason) error {
+	failed := func(i int) bool {
+		return i < len(reasons) && aws.StringValue(reasons[i].Code) == "ConditionalCheckFailed"
+	}
+	switch {
+	case failed(0):
+		return ErrUserAlreadyExists
+	case failed(1):
+		return ErrVersionConflict
+	}
+	ops := []UserOperation{
+		{Type: OperationCreate, User: models.User{Email: newEmail}},
+		{Type: OperationDelete, User: models.User{Email: oldEmail}},
+	}
+	return fmt.Errorf("%w: %s", ErrTransactionCanceled, describeCancellation(ops, reasons))
+}
+
+// nilIfEmpty returns values, or nil when it is empty, since DynamoDB rejects empty
+// ExpressionAttributeValues.
+func nilIfEmpty(values map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// ChangeEmail moves a user to a new email, like the DynamoDB version.
+func (repo *InMemoryUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error) {
+	oldEmail = validators.NormalizeEmail(oldEmail)
+	newEmail = validators.NormalizeEmail(newEmail)
+	if oldEmail == newEmail {
+		return nil, fmt.Errorf("%w: the new email is the current one", ErrInvalidOperation)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.users[oldEmail]
+	if !ok || user.Deleted || expired(user) {
+		return nil, ErrUserDoesNotExist
+	}
+	if existing, taken := repo.users[newEmail]; taken && !expired(existing) {
+		return nil, ErrUserAlreadyExists
+	}
+	user.Email = newEmail
+	user.NormalizedEmail = newEmail
+	user.UpdatedAt = timestamp()
+	user.Version++
+	if user.ID == "" {
+		user.ID = newUserID()
+	}
+	delete(repo.users, oldEmail)
+	repo.users[newEmail] = user
+	return &user, nil
+}
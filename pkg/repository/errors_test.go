@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestErrorsWrapSentinels(t *testing.T) {
+	outage := awserr.New(dynamodb.ErrCodeInternalServerError, "Internal server error", nil)
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		client     *mockDynamoDB
+		call       func(repo *DynamoDBUserRepository) error
+		wantErr    error
+		wantAWSErr string // Code of the DynamoDB error that should stay reachable with errors.As
+	}{
+		{
+			name: "fetch outage",
+			client: &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) { return nil, outage },
+			},
+			call: func(repo *DynamoDBUserRepository) error {
+				_, err := repo.FetchUser(ctx, "a@example.com", FetchOptions{})
+				return err
+			},
+			wantErr:    ErrFailedToFetchRecord,
+			wantAWSErr: dynamodb.ErrCodeInternalServerError,
+		},
+		{
+			name: "scan outage",
+			client: &mockDynamoDB{
+				scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) { return nil, outage },
+			},
+			call: func(repo *DynamoDBUserRepository) error {
+				_, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{})
+				return err
+			},
+			wantErr:    ErrCouldNotScanItems,
+			wantAWSErr: dynamodb.ErrCodeInternalServerError,
+		},
+		{
+			name: "create taken email",
+			client: &mockDynamoDB{
+				putItem: func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) { return nil, errConditionFailed },
+			},
+			call: func(repo *DynamoDBUserRepository) error {
+				_, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"})
+				return err
+			},
+			wantErr: ErrUserAlreadyExists,
+		},
+		{
+			name: "delete missing user",
+			client: &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) { return &dynamodb.GetItemOutput{}, nil },
+			},
+			call: func(repo *DynamoDBUserRepository) error {
+				_, err := repo.DeleteUser(ctx, "a@example.com")
+				return err
+			},
+			wantErr: ErrUserDoesNotExist,
+		},
+		{
+			name:   "bad pagination token",
+			client: &mockDynamoDB{},
+			call: func(repo *DynamoDBUserRepository) error {
+				_, _, err := repo.FetchUsers(ctx, 10, "not a token", FetchOptions{})
+				return err
+			},
+			wantErr: ErrInvalidLastEvaluatedKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewDynamoDBUserRepository(tt.client, testTable, DynamoDBOptions{MaxAttempts: 1})
+
+			err := tt.call(repo)
+			// Callers may wrap the error again; it must still match
+			wrapped := fmt.Errorf("handling request: %w", err)
+			if !errors.Is(err, tt.wantErr) || !errors.Is(wrapped, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantAWSErr == "" {
+				return
+			}
+			var awsErr awserr.Error
+			if !errors.As(wrapped, &awsErr) || awsErr.Code() != tt.wantAWSErr {
+				t.Errorf("err = %v, want it to wrap %s", err, tt.wantAWSErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestUserExists(t *testing.T) {
+	expiredAt := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name    string
+		stored  *models.User
+		readErr error
+		want    bool
+		wantErr error
+	}{
+		{name: "existing user", stored: &models.User{Email: "a@example.com"}, want: true},
+		{name: "missing user"},
+		{name: "soft-deleted user", stored: &models.User{Email: "a@example.com", Deleted: true}},
+		{name: "expired user", stored: &models.User{Email: "a@example.com", ExpiresAt: expiredAt}},
+		{name: "read failure", readErr: awserr.New("ValidationException", "invalid", nil), wantErr: ErrFailedToFetchRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					// Only the key and what decides whether the user is live are read
+					if got := projectedAttributes(t, input.ProjectionExpression, input.ExpressionAttributeNames); !slices.Equal(got, []string{"deleted", "email", "expiresAt"}) {
+						t.Errorf("projection = %v, want the email, soft-delete flag and expiry only", got)
+					}
+					if got := aws.StringValue(input.Key["email"].S); got != "a@example.com" {
+						t.Errorf("key = %q, want the normalized email", got)
+					}
+					if tt.readErr != nil {
+						return nil, tt.readErr
+					}
+					if tt.stored == nil {
+						return &dynamodb.GetItemOutput{}, nil
+					}
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, *tt.stored)}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{MaxAttempts: 1})
+
+			exists, err := repo.UserExists(context.Background(), "A@Example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if exists != tt.want {
+				t.Errorf("exists = %v, want %v", exists, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryUserExists(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+	for _, email := range []string{"a@example.com", "deleted@example.com"} {
+		if _, err := repo.CreateUser(context.Background(), models.User{Email: email, FirstName: "Ann"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.DeleteUser(context.Background(), "deleted@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	for email, want := range map[string]bool{"a@example.com": true, "A@EXAMPLE.COM": true, "deleted@example.com": false, "missing@example.com": false} {
+		if exists, err := repo.UserExists(context.Background(), email); err != nil || exists != want {
+			t.Errorf("UserExists(%q) = %v, %v, want %v", email, exists, err, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// expired reports whether user is a temporary user whose expiry has passed. DynamoDB deletes
+// expired records in the background, usually within a few days, so until then they are hidden
+// on reads and may be overwritten by a create.
+func expired(user models.User) bool {
+	return user.ExpiresAt > 0 && user.ExpiresAt <= time.Now().Unix()
+}
+
+// newUserCondition builds the condition of a write inserting a user: no record may have its
+// email, unless the record has expired. It returns the expression with the names and values it
+// refers to.
+func newUserCondition() (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	return "attribute_not_exists(email) OR #expiresAt <= :now",
+		map[string]*string{"#expiresAt": aws.String("expiresAt")},
+		map[string]*dynamodb.AttributeValue{":now": nowValue()}
+}
+
+// notExpiredFilter matches the records expired does not: those without an expiry, or whose
+// expiry has yet to pass. It refers to #expiresAt and :now.
+const notExpiredFilter = "attribute_not_exists(#expiresAt) OR #expiresAt > :now"
+
+// nowValue returns the current time in Unix seconds, the :now of expiry conditions.
+func nowValue() *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))}
+}
+
+// addNotExpiredFilter ANDs notExpiredFilter onto a Scan or Query filter expression. Expired users
+// are hidden from listings whatever the options, just as FetchUser hides them.
+func addNotExpiredFilter(filter **string, names *map[string]*string, values *map[string]*dynamodb.AttributeValue) {
+	expression := notExpiredFilter
+	if *filter != nil {
+		expression = "(" + aws.StringValue(*filter) + ") AND (" + expression + ")"
+	}
+	*filter = aws.String(expression)
+	if *names == nil {
+		*names = map[string]*string{}
+	}
+	(*names)["#expiresAt"] = aws.String("expiresAt")
+	if *values == nil {
+		*values = map[string]*dynamodb.AttributeValue{}
+	}
+	(*values)[":now"] = nowValue()
+}
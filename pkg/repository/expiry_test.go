@@ -0,0 +1,376 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestFetchUserHidesExpiredUsers(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name      string
+		expiresAt int64
+		wantFound bool
+	}{
+		{name: "permanent user", wantFound: true},
+		{name: "expiry ahead", expiresAt: now + 60, wantFound: true},
+		{name: "expiry passed", expiresAt: now - 60},
+		{name: "expiring now", expiresAt: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := models.User{Email: "a@example.com", FirstName: "Ann", ExpiresAt: tt.expiresAt}
+			client := &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, stored)}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			user, err := repo.FetchUser(context.Background(), "a@example.com", FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (user != nil) != tt.wantFound {
+				t.Errorf("user = %+v, want found: %v", user, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCreateUserSetsExpiresAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttlSeconds int64
+		wantTTL    bool
+	}{
+		{name: "permanent user"},
+		{name: "temporary user", ttlSeconds: 3600, wantTTL: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored map[string]*dynamodb.AttributeValue
+			client := &mockDynamoDB{
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); got != "attribute_not_exists(email) OR #expiresAt <= :now" {
+						t.Errorf("condition = %q, want expired users overwritten", got)
+					}
+					stored = input.Item
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			before := time.Now().Unix()
+			created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ann", TTLSeconds: tt.ttlSeconds})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := stored["ttlSeconds"]; ok {
+				t.Error("ttlSeconds stored")
+			}
+			if !tt.wantTTL {
+				if created.ExpiresAt != 0 || stored["expiresAt"] != nil {
+					t.Errorf("expiresAt = %d, %v, want none", created.ExpiresAt, stored["expiresAt"])
+				}
+				return
+			}
+			if created.ExpiresAt < before+tt.ttlSeconds || created.ExpiresAt > time.Now().Unix()+tt.ttlSeconds {
+				t.Errorf("expiresAt = %d, want %d seconds from now", created.ExpiresAt, tt.ttlSeconds)
+			}
+			if got := aws.StringValue(stored["expiresAt"].N); got != fmt.Sprint(created.ExpiresAt) {
+				t.Errorf("stored expiresAt = %s, want %d as a number", got, created.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestInMemoryExpiredUsers(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Guest", TTLSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	guest := repo.users["a@example.com"]
+	guest.ExpiresAt = time.Now().Unix() - 1
+	repo.users["a@example.com"] = guest
+
+	if user, err := repo.FetchUser(ctx, "a@example.com", FetchOptions{}); err != nil || user != nil {
+		t.Errorf("FetchUser = %+v, %v, want no user", user, err)
+	}
+	if exists, err := repo.UserExists(ctx, "a@example.com"); err != nil || exists {
+		t.Errorf("UserExists = %v, %v, want false", exists, err)
+	}
+	if _, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ann", Version: 1}); !errors.Is(err, ErrUserDoesNotExist) {
+		t.Errorf("UpdateUser err = %v, want %v", err, ErrUserDoesNotExist)
+	}
+	if _, err := repo.DeleteUser(ctx, "a@example.com"); !errors.Is(err, ErrUserDoesNotExist) {
+		t.Errorf("DeleteUser err = %v, want %v", err, ErrUserDoesNotExist)
+	}
+	if result, err := repo.DeleteUsers(ctx, []string{"a@example.com"}); err != nil || len(result.Deleted) != 0 {
+		t.Errorf("DeleteUsers = %+v, %v, want the user not found", result, err)
+	}
+
+	// The email is free again before the record is deleted
+	created, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ExpiresAt != 0 {
+		t.Errorf("expiresAt = %d, want a permanent user", created.ExpiresAt)
+	}
+}
+
+func TestListingsHideExpiredUsers(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(repo *DynamoDBUserRepository) error
+	}{
+		{name: "FetchUsers", call: func(repo *DynamoDBUserRepository) error {
+			_, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{})
+			return err
+		}},
+		{name: "FetchUsers with deleted users", call: func(repo *DynamoDBUserRepository) error {
+			_, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{IncludeDeleted: true})
+			return err
+		}},
+		{name: "SearchUsers", call: func(repo *DynamoDBUserRepository) error {
+			_, _, err := repo.SearchUsers(ctx, "ann", 10, "", FetchOptions{})
+			return err
+		}},
+		{name: "ScanAll", call: func(repo *DynamoDBUserRepository) error {
+			return repo.ScanAll(ctx, "", FetchOptions{}, func(models.User) error { return nil })
+		}},
+		{name: "CountUsers", call: func(repo *DynamoDBUserRepository) error {
+			_, err := repo.CountUsers(ctx, FetchOptions{})
+			return err
+		}},
+		{name: "FetchUsersByLastName", call: func(repo *DynamoDBUserRepository) error {
+			_, _, err := repo.FetchUsersByLastName(ctx, "Lee", 10, "", FetchOptions{IncludeDeleted: true})
+			return err
+		}},
+		{name: "UpdateUsersWhere", call: func(repo *DynamoDBUserRepository) error {
+			_, err := repo.UpdateUsersWhere(ctx, UserFilter{Role: models.RoleEditor}, UserPatch{Role: models.RoleViewer}, true)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				filter string
+				names  map[string]*string
+				values map[string]*dynamodb.AttributeValue
+			)
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					filter, names, values = aws.StringValue(input.FilterExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues
+					return &dynamodb.ScanOutput{Count: aws.Int64(0)}, nil
+				},
+				query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					filter, names, values = aws.StringValue(input.FilterExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues
+					return &dynamodb.QueryOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{LastNameIndex: "lastName-index"})
+
+			before := time.Now().Unix()
+			if err := tt.call(repo); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(filter, notExpiredFilter) {
+				t.Errorf("filter = %q, want expired users filtered out", filter)
+			}
+			if got := aws.StringValue(names["#expiresAt"]); got != "expiresAt" {
+				t.Errorf("#expiresAt = %q, want expiresAt", got)
+			}
+			if now, err := strconv.ParseInt(aws.StringValue(values[":now"].N), 10, 64); err != nil || now < before || now > time.Now().Unix() {
+				t.Errorf(":now = %v, want the current Unix time", values[":now"])
+			}
+		})
+	}
+}
+
+func TestInMemoryListingsHideExpiredUsers(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	for _, email := range []string{"ann@example.com", "guest@example.com"} {
+		if _, err := repo.CreateUser(ctx, models.User{Email: email, FirstName: "Ann", LastName: "Lee", Role: models.RoleEditor, TTLSeconds: 60}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	guest := repo.users["guest@example.com"]
+	guest.ExpiresAt = time.Now().Unix() - 1
+	repo.users["guest@example.com"] = guest
+
+	tests := []struct {
+		name string
+		list func() ([]models.User, error)
+	}{
+		{name: "FetchUsers", list: func() ([]models.User, error) {
+			users, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{IncludeDeleted: true})
+			return users, err
+		}},
+		{name: "FetchUsersByLastName", list: func() ([]models.User, error) {
+			users, _, err := repo.FetchUsersByLastName(ctx, "Lee", 10, "", FetchOptions{})
+			return users, err
+		}},
+		{name: "FetchUsersByEmailPrefix", list: func() ([]models.User, error) {
+			users, _, err := repo.FetchUsersByEmailPrefix(ctx, "", 10, "", FetchOptions{})
+			return users, err
+		}},
+		{name: "SearchUsers", list: func() ([]models.User, error) {
+			users, _, err := repo.SearchUsers(ctx, "ann", 10, "", FetchOptions{})
+			return users, err
+		}},
+		{name: "FetchUsersByEmails", list: func() ([]models.User, error) {
+			result, err := repo.FetchUsersByEmails(ctx, []string{"ann@example.com", "guest@example.com"}, FetchOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Equal(result.NotFound, []string{"guest@example.com"}) {
+				t.Errorf("not found = %v, want the expired user", result.NotFound)
+			}
+			return result.Users, nil
+		}},
+		{name: "ScanAll", list: func() ([]models.User, error) {
+			var users []models.User
+			err := repo.ScanAll(ctx, "", FetchOptions{}, func(user models.User) error {
+				users = append(users, user)
+				return nil
+			})
+			return users, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := tt.list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != 1 || users[0].Email != "ann@example.com" {
+				t.Errorf("users = %+v, want only ann@example.com", users)
+			}
+		})
+	}
+
+	if total, err := repo.CountUsers(ctx, FetchOptions{}); err != nil || total != 1 {
+		t.Errorf("CountUsers = %d, %v, want 1", total, err)
+	}
+	result, err := repo.UpdateUsersWhere(ctx, UserFilter{Role: models.RoleEditor}, UserPatch{Role: models.RoleViewer}, false)
+	if err != nil || result.Matched != 1 {
+		t.Errorf("UpdateUsersWhere = %+v, %v, want 1 match", result, err)
+	}
+	if role := repo.users["guest@example.com"].Role; role != models.RoleEditor {
+		t.Errorf("expired user's role = %s, want it untouched", role)
+	}
+}
+
+func TestInMemoryUpsertReplacesExpiredUsers(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Guest", TTLSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	guest := repo.users["a@example.com"]
+	guest.ExpiresAt = time.Now().Unix() - 1
+	guest.CreatedAt = "2000-01-01T00:00:00Z"
+	guest.Version = 7
+	repo.users["a@example.com"] = guest
+
+	upserted, created, err := repo.UpsertUser(ctx, models.User{Email: "a@example.com", FirstName: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Error("created = false, want the expired user replaced by a new one")
+	}
+	if upserted.ExpiresAt != 0 || upserted.CreatedAt == guest.CreatedAt || upserted.Version != 1 {
+		t.Errorf("upserted = %+v, want a new permanent user", upserted)
+	}
+	if user, err := repo.FetchUser(ctx, "a@example.com", FetchOptions{}); err != nil || user == nil || user.FirstName != "Ann" {
+		t.Errorf("FetchUser = %+v, %v, want the upserted user", user, err)
+	}
+}
+
+func TestDeleteUsersSkipsExpiredUsers(t *testing.T) {
+	expiredUser := models.User{Email: "b@example.com", ExpiresAt: time.Now().Unix() - 1}
+	var deleted []string
+	client := &mockDynamoDB{
+		batchGetItem: func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				testTable: {marshalUser(t, models.User{Email: "a@example.com"}), marshalUser(t, expiredUser)},
+			}}, nil
+		},
+		batchWriteItem: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, request := range input.RequestItems[testTable] {
+				deleted = append(deleted, aws.StringValue(request.DeleteRequest.Key["email"].S))
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	result, err := repo.DeleteUsers(context.Background(), []string{"a@example.com", "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(deleted, []string{"a@example.com"}) || !slices.Equal(result.NotFound, []string{"b@example.com"}) {
+		t.Errorf("deleted %v, not found %v, want the expired user not found", deleted, result.NotFound)
+	}
+}
+
+func TestChangeEmailReplacesExpiredUsers(t *testing.T) {
+	ctx := context.Background()
+	var putCondition string
+	client := &mockDynamoDB{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: marshalUser(t, models.User{Email: "a@example.com", FirstName: "Ann", Version: 2})}, nil
+		},
+		transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			put := input.TransactItems[0].Put
+			putCondition = aws.StringValue(put.ConditionExpression)
+			if aws.StringValue(put.ExpressionAttributeNames["#expiresAt"]) != "expiresAt" || put.ExpressionAttributeValues[":now"] == nil {
+				t.Errorf("condition names %v and values %v, want #expiresAt and :now", put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+			}
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+	if _, err := repo.ChangeEmail(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if want, _, _ := newUserCondition(); putCondition != want {
+		t.Errorf("condition = %q, want %q, as for a new user", putCondition, want)
+	}
+
+	inMemory := NewInMemoryUserRepository(DynamoDBOptions{})
+	for _, user := range []models.User{{Email: "a@example.com", FirstName: "Ann"}, {Email: "b@example.com", FirstName: "Guest", TTLSeconds: 60}} {
+		if _, err := inMemory.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := inMemory.ChangeEmail(ctx, "a@example.com", "b@example.com"); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("err = %v, want %v while the temporary user is live", err, ErrUserAlreadyExists)
+	}
+	guest := inMemory.users["b@example.com"]
+	guest.ExpiresAt = time.Now().Unix() - 1
+	inMemory.users["b@example.com"] = guest
+	moved, err := inMemory.ChangeEmail(ctx, "a@example.com", "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.FirstName != "Ann" || moved.ExpiresAt != 0 {
+		t.Errorf("moved = %+v, want Ann in place of the expired user", moved)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// expressionNames holds the ExpressionAttributeNames of a request. Every attribute an expression
+// refers to goes through name, so attributes that are DynamoDB reserved words (such as "name",
+// "status" or "role") never break an expression.
+type expressionNames map[string]*string
+
+// name returns the #placeholder standing for attr, registering it. Characters that are not allowed
+// in a placeholder are replaced, and a numeric suffix keeps placeholders of different attributes apart.
+func (names expressionNames) name(attr string) string {
+	base := "#" + strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, attr)
+
+	placeholder := base
+	for i := 1; ; i++ {
+		existing, ok := names[placeholder]
+		if !ok {
+			names[placeholder] = aws.String(attr)
+			return placeholder
+		}
+		if aws.StringValue(existing) == attr {
+			return placeholder
+		}
+		placeholder = base + "_" + strconv.Itoa(i)
+	}
+}
+
+// list returns the placeholders of attrs joined with commas, as in a ProjectionExpression
+// or a REMOVE clause. Repeated attributes are listed once.
+func (names expressionNames) list(attrs []string) string {
+	placeholders := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		placeholder := names.name(attr)
+		if !slices.Contains(placeholders, placeholder) {
+			placeholders = append(placeholders, placeholder)
+		}
+	}
+	return strings.Join(placeholders, ", ")
+}
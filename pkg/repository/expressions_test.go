@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestExpressionNamesName(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []string
+		want  []string
+	}{
+		{name: "reserved words", attrs: []string{"name", "status", "role"}, want: []string{"#name", "#status", "#role"}},
+		{name: "same attribute twice", attrs: []string{"role", "role"}, want: []string{"#role", "#role"}},
+		{name: "characters not allowed in a placeholder", attrs: []string{"first-name", "a.b"}, want: []string{"#first_name", "#a_b"}},
+		{name: "attributes sharing a placeholder", attrs: []string{"first-name", "first_name", "first.name"}, want: []string{"#first_name", "#first_name_1", "#first_name_2"}},
+		{name: "suffix clashing with an attribute", attrs: []string{"a-b", "a_b", "a_b_1"}, want: []string{"#a_b", "#a_b_1", "#a_b_1_1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := expressionNames{}
+			for i, attr := range tt.attrs {
+				got := names.name(attr)
+				if got != tt.want[i] {
+					t.Errorf("name(%q) = %q, want %q", attr, got, tt.want[i])
+				}
+				if resolved := aws.StringValue(names[got]); resolved != attr {
+					t.Errorf("%s stands for %q, want %q", got, resolved, attr)
+				}
+			}
+		})
+	}
+}
+
+func TestExpressionNamesList(t *testing.T) {
+	names := expressionNames{"#deleted": aws.String("deleted")}
+
+	if got, want := names.list([]string{"name", "status", "name", "deleted"}), "#name, #status, #deleted"; got != want {
+		t.Errorf("list = %q, want %q", got, want)
+	}
+	if len(names) != 3 {
+		t.Errorf("names = %v, want 3 placeholders", names)
+	}
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key header.
+type IdempotencyRecord struct {
+	Key         string `json:"idempotencyKey"`
+	RequestHash string `json:"requestHash"` // Hash of the request body the key was first used with
+	StatusCode  int    `json:"statusCode"`
+	Body        string `json:"body"`
+	Location    string `json:"location,omitempty"` // Location header of the response, if any
+	ExpiresAt   int64  `json:"expiresAt"`          // Unix seconds; the table's TTL attribute
+	// Pending marks a key reserved by a request that is still running, which has no response yet.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// IdempotencyStore persists the responses of idempotent requests so retries can be replayed.
+// A request first reserves its key with a Pending record, then completes the record with its
+// response, or releases it when the request may be retried.
+type IdempotencyStore interface {
+	// GetRecord returns the unexpired record for key, or nil if there is none.
+	GetRecord(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// PutRecord stores record unless its key is already recorded.
+	PutRecord(ctx context.Context, record IdempotencyRecord) error
+	// CompleteRecord replaces the pending record of record.Key with record, failing with
+	// ErrIdempotencyKeyExists when the key is no longer pending.
+	CompleteRecord(ctx context.Context, record IdempotencyRecord) error
+	// ReleaseRecord deletes the pending record of key, if any. Completed records are kept.
+	ReleaseRecord(ctx context.Context, key string) error
+}
+
+// DynamoDBIdempotencyStore implements IdempotencyStore with a dedicated DynamoDB table.
+// The table is keyed on idempotencyKey (string) and should have TTL enabled on expiresAt.
+type DynamoDBIdempotencyStore struct {
+	client        dynamodbiface.DynamoDBAPI
+	tableName     string
+	retryStrategy RetryStrategy
+}
+
+// NewDynamoDBIdempotencyStore creates a new DynamoDBIdempotencyStore instance.
+// Only the retry options are relevant; the table is retried with the on-demand strategy.
+func NewDynamoDBIdempotencyStore(client dynamodbiface.DynamoDBAPI, tableName string, opts DynamoDBOptions) *DynamoDBIdempotencyStore {
+	return &DynamoDBIdempotencyStore{
+		client:        client,
+		tableName:     tableName,
+		retryStrategy: RetryStrategyFor(dynamodb.BillingModePayPerRequest, retryOverrides(opts)),
+	}
+}
+
+// GetRecord retrieves the record for key. DynamoDB deletes expired items lazily,
+// so records past ExpiresAt are treated as missing.
+func (store *DynamoDBIdempotencyStore) GetRecord(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"idempotencyKey": {S: aws.String(key)},
+		},
+		TableName:      aws.String(store.tableName),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var result *dynamodb.GetItemOutput
+	err := retry(ctx, store.retryStrategy, "GetIdempotencyRecord", func() (err error) {
+		result, err = store.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", "GetIdempotencyRecord"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	record := new(IdempotencyRecord)
+	if err := dynamodbattribute.UnmarshalMap(result.Item, record); err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "GetIdempotencyRecord"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	if record.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// PutRecord stores record, failing with ErrIdempotencyKeyExists when an unexpired record
+// with the same key was written first (e.g. by a concurrent retry).
+func (store *DynamoDBIdempotencyStore) PutRecord(ctx context.Context, record IdempotencyRecord) error {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "PutIdempotencyRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           aws.String(store.tableName),
+		ConditionExpression: aws.String("attribute_not_exists(idempotencyKey) OR expiresAt <= :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		},
+	}
+
+	err = retry(ctx, store.retryStrategy, "PutIdempotencyRecord", func() error {
+		_, err := store.client.PutItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrIdempotencyKeyExists
+		}
+		slog.Error("DynamoDB PutItem failed", slog.String("operation", "PutIdempotencyRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return nil
+}
+
+// CompleteRecord replaces the pending record of record.Key with record, failing with
+// ErrIdempotencyKeyExists when the key is no longer pending, such as when its reservation expired
+// and another request took the key.
+func (store *DynamoDBIdempotencyStore) CompleteRecord(ctx context.Context, record IdempotencyRecord) error {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "CompleteIdempotencyRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 aws.String(store.tableName),
+		ConditionExpression:       aws.String("pending = :true"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}},
+	}
+
+	err = retry(ctx, store.retryStrategy, "CompleteIdempotencyRecord", func() error {
+		_, err := store.client.PutItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrIdempotencyKeyExists
+		}
+		slog.Error("DynamoDB PutItem failed", slog.String("operation", "CompleteIdempotencyRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return nil
+}
+
+// ReleaseRecord deletes the pending record of key, leaving a completed record (or none) alone.
+func (store *DynamoDBIdempotencyStore) ReleaseRecord(ctx context.Context, key string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"idempotencyKey": {S: aws.String(key)},
+		},
+		TableName:                 aws.String(store.tableName),
+		ConditionExpression:       aws.String("pending = :true"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}},
+	}
+
+	err := retry(ctx, store.retryStrategy, "ReleaseIdempotencyRecord", func() error {
+		_, err := store.client.DeleteItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil && !isConditionalCheckFailed(err) {
+		slog.Error("DynamoDB DeleteItem failed", slog.String("operation", "ReleaseIdempotencyRecord"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotDeleteItem, err)
+	}
+	return nil
+}
+
+// InMemoryIdempotencyStore implements IdempotencyStore with a map, for local development.
+type InMemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewInMemoryIdempotencyStore creates an empty InMemoryIdempotencyStore.
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+	}
+}
+
+// GetRecord retrieves the unexpired record for key.
+func (store *InMemoryIdempotencyStore) GetRecord(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	record, ok := store.records[key]
+	if !ok || record.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// PutRecord stores record unless an unexpired record with the same key exists.
+func (store *InMemoryIdempotencyStore) PutRecord(ctx context.Context, record IdempotencyRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if existing, ok := store.records[record.Key]; ok && existing.ExpiresAt > time.Now().Unix() {
+		return ErrIdempotencyKeyExists
+	}
+	store.records[record.Key] = record
+	return nil
+}
+
+// CompleteRecord replaces the pending record of record.Key with record, failing with
+// ErrIdempotencyKeyExists when the key is no longer pending.
+func (store *InMemoryIdempotencyStore) CompleteRecord(ctx context.Context, record IdempotencyRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if existing, ok := store.records[record.Key]; !ok || !existing.Pending {
+		return ErrIdempotencyKeyExists
+	}
+	store.records[record.Key] = record
+	return nil
+}
+
+// ReleaseRecord deletes the pending record of key, if any.
+func (store *InMemoryIdempotencyStore) ReleaseRecord(ctx context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if existing, ok := store.records[key]; ok && existing.Pending {
+		delete(store.records, key)
+	}
+	return nil
+}
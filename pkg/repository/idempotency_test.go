@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestIdempotencyStoreGetRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    *IdempotencyRecord
+		wantFound bool
+	}{
+		{name: "no record"},
+		{name: "unexpired record", stored: &IdempotencyRecord{Key: "key-1", StatusCode: 201, ExpiresAt: time.Now().Add(time.Hour).Unix()}, wantFound: true},
+		// DynamoDB deletes expired items up to a few days late
+		{name: "expired record", stored: &IdempotencyRecord{Key: "key-1", StatusCode: 201, ExpiresAt: time.Now().Add(-time.Hour).Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					if tt.stored == nil {
+						return &dynamodb.GetItemOutput{}, nil
+					}
+					item, err := dynamodbattribute.MarshalMap(tt.stored)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return &dynamodb.GetItemOutput{Item: item}, nil
+				},
+			}
+			stores := map[string]IdempotencyStore{
+				"DynamoDB":  NewDynamoDBIdempotencyStore(client, "idempotency", DynamoDBOptions{}),
+				"in memory": NewInMemoryIdempotencyStore(),
+			}
+			if tt.stored != nil {
+				stores["in memory"].(*InMemoryIdempotencyStore).records[tt.stored.Key] = *tt.stored
+			}
+			for name, store := range stores {
+				record, err := store.GetRecord(context.Background(), "key-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found := record != nil; found != tt.wantFound {
+					t.Errorf("%s: record = %+v, want found: %v", name, record, tt.wantFound)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyStorePutRecordIsConditional(t *testing.T) {
+	tests := []struct {
+		name    string
+		putErr  error
+		wantErr error
+	}{
+		{name: "new key"},
+		{name: "key written first by a concurrent retry", putErr: errConditionFailed, wantErr: ErrIdempotencyKeyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				putItem: func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					return &dynamodb.PutItemOutput{}, tt.putErr
+				},
+			}
+			store := NewDynamoDBIdempotencyStore(client, "idempotency", DynamoDBOptions{})
+
+			err := store.PutRecord(context.Background(), IdempotencyRecord{Key: "key-1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoreCompleteRecord(t *testing.T) {
+	pending := IdempotencyRecord{Key: "key-1", Pending: true, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	completed := IdempotencyRecord{Key: "key-1", StatusCode: 201, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	tests := []struct {
+		name    string
+		stored  *IdempotencyRecord
+		wantErr error
+	}{
+		{name: "pending key", stored: &pending},
+		{name: "completed key", stored: &completed, wantErr: ErrIdempotencyKeyExists},
+		{name: "released key", wantErr: ErrIdempotencyKeyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); got != "pending = :true" {
+						t.Errorf("condition = %q, want the key pending", got)
+					}
+					if tt.stored == nil || !tt.stored.Pending {
+						return nil, errConditionFailed
+					}
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			}
+			stores := map[string]IdempotencyStore{
+				"DynamoDB":  NewDynamoDBIdempotencyStore(client, "idempotency", DynamoDBOptions{}),
+				"in memory": NewInMemoryIdempotencyStore(),
+			}
+			if tt.stored != nil {
+				stores["in memory"].(*InMemoryIdempotencyStore).records[tt.stored.Key] = *tt.stored
+			}
+			for name, store := range stores {
+				if err := store.CompleteRecord(context.Background(), completed); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: err = %v, want %v", name, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoreReleaseRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    IdempotencyRecord
+		wantKept  bool
+		deleteErr error
+	}{
+		{name: "pending key", stored: IdempotencyRecord{Key: "key-1", Pending: true}},
+		{name: "completed key", stored: IdempotencyRecord{Key: "key-1", StatusCode: 201}, wantKept: true, deleteErr: errConditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.stored.ExpiresAt = time.Now().Add(time.Hour).Unix()
+			client := &mockDynamoDB{
+				deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); got != "pending = :true" {
+						t.Errorf("condition = %q, want only pending keys released", got)
+					}
+					return &dynamodb.DeleteItemOutput{}, tt.deleteErr
+				},
+			}
+			if err := NewDynamoDBIdempotencyStore(client, "idempotency", DynamoDBOptions{}).ReleaseRecord(context.Background(), "key-1"); err != nil {
+				t.Errorf("DynamoDB: err = %v", err)
+			}
+
+			store := NewInMemoryIdempotencyStore()
+			store.records[tt.stored.Key] = tt.stored
+			if err := store.ReleaseRecord(context.Background(), "key-1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, kept := store.records["key-1"]; kept != tt.wantKept {
+				t.Errorf("in memory: record kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
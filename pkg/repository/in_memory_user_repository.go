@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// InMemoryUserRepository implements UserRepository with a map guarded by a mutex.
+// It mirrors the DynamoDB semantics (normalized emails, versions, soft delete and
+// pagination tokens) so handlers can run locally and in tests without AWS credentials.
+type InMemoryUserRepository struct {
+	mu         sync.RWMutex
+	users      map[string]models.User
+	softDelete bool
+
+	allowDestructiveOps bool
+}
+
+// NewInMemoryUserRepository creates an empty InMemoryUserRepository.
+// Only the SoftDelete and AllowDestructiveOps options are relevant; the others configure DynamoDB specifics.
+func NewInMemoryUserRepository(opts DynamoDBOptions) *InMemoryUserRepository {
+	return &InMemoryUserRepository{
+		users:      make(map[string]models.User),
+		softDelete: opts.SoftDelete,
+
+		allowDestructiveOps: opts.AllowDestructiveOps,
+	}
+}
+
+// FetchUser retrieves a single user by email.
+func (repo *InMemoryUserRepository) FetchUser(ctx context.Context, email string, opts FetchOptions) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.users[validators.NormalizeEmail(email)]
+	if !ok || (user.Deleted && !opts.IncludeDeleted) || expired(user) {
+		return nil, nil // User not found
+	}
+	user = projectUser(user, opts.Fields)
+	return &user, nil
+}
+
+// UserExists reports whether an active (not soft-deleted) user has the given email.
+func (repo *InMemoryUserRepository) UserExists(ctx context.Context, email string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.users[validators.NormalizeEmail(email)]
+	return ok && !user.Deleted && !expired(user), nil
+}
+
+// FetchUsers retrieves users ordered by email, using the same pagination token format as DynamoDB.
+func (repo *InMemoryUserRepository) FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	// Like a DynamoDB Scan, listings do not honor Descending
+	opts.Descending = false
+	return repo.page(limit, lastEvaluatedKey, opts, func(models.User) bool { return true })
+}
+
+// FetchUsersByLastName retrieves users with the given last name, ordered by email
+// (descending when opts.Descending is set).
+func (repo *InMemoryUserRepository) FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	return repo.page(limit, lastEvaluatedKey, opts, func(user models.User) bool { return user.LastName == lastName })
+}
+
+// FetchUsersByEmailPrefix retrieves users whose email starts with prefix, ordered by email.
+func (repo *InMemoryUserRepository) FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	prefix = validators.NormalizeEmail(prefix)
+	opts.Descending = false
+	return repo.page(limit, lastEvaluatedKey, opts, func(user models.User) bool { return strings.HasPrefix(user.Email, prefix) })
+}
+
+// FetchUsersByEmails retrieves many users at once, reporting the emails without a user as not found.
+func (repo *InMemoryUserRepository) FetchUsersByEmails(ctx context.Context, emails []string, opts FetchOptions) (*BatchFetchResult, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return collectFetched(uniqueEmails(emails), repo.users, opts), nil
+}
+
+// CountUsers returns the number of stored users.
+func (repo *InMemoryUserRepository) CountUsers(ctx context.Context, opts FetchOptions) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var total int64
+	for _, user := range repo.users {
+		if (!user.Deleted || opts.IncludeDeleted) && !expired(user) && createdInRange(user, opts) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// CreateUser stores a new user, failing with ErrUserAlreadyExists if the email is taken.
+func (repo *InMemoryUserRepository) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.createLocked(user)
+}
+
+// createLocked implements CreateUser; the caller must hold the write lock.
+func (repo *InMemoryUserRepository) createLocked(user models.User) (*models.User, error) {
+	user.Email = validators.NormalizeEmail(user.Email)
+	if existing, ok := repo.users[user.Email]; ok && !expired(existing) {
+		return nil, ErrUserAlreadyExists
+	}
+	stampNewUser(&user)
+	if err := hashPassword(&user); err != nil {
+		return nil, err
+	}
+	repo.users[user.Email] = user
+	return &user, nil
+}
+
+// CreateUsers stores many users, reporting the emails that already have a user as existing.
+// Nothing can fail to be processed, so the failed list is always empty.
+func (repo *InMemoryUserRepository) CreateUsers(ctx context.Context, users []models.User) (*BatchCreateResult, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	result := &BatchCreateResult{Created: []models.User{}, Existing: []string{}, Failed: []string{}}
+	for _, user := range users {
+		created, err := repo.createLocked(user)
+		switch {
+		case errors.Is(err, ErrUserAlreadyExists):
+			result.Existing = append(result.Existing, validators.NormalizeEmail(user.Email))
+		case err != nil:
+			return nil, err
+		default:
+			result.Created = append(result.Created, *created)
+		}
+	}
+	return result, nil
+}
+
+// UpdateUser updates the mutable fields of an existing user, honoring the optimistic-locking version.
+func (repo *InMemoryUserRepository) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.updateLocked(user)
+}
+
+// UpsertUser creates the user when absent or expired and otherwise updates it, reviving a
+// soft-deleted one. user.Version is ignored. The returned flag reports whether the user was created.
+func (repo *InMemoryUserRepository) UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	email := validators.NormalizeEmail(user.Email)
+	current, ok := repo.users[email]
+	if !ok || expired(current) {
+		created, err := repo.createLocked(user)
+		return created, err == nil, err
+	}
+	current.Deleted = false
+	current.DeletedAt = ""
+	repo.users[email] = current
+
+	user.Version = 0
+	updated, err := repo.updateLocked(user)
+	return updated, false, err
+}
+
+// updateLocked implements UpdateUser; the caller must hold the write lock.
+func (repo *InMemoryUserRepository) updateLocked(user models.User) (*models.User, error) {
+	current, ok := repo.users[validators.NormalizeEmail(user.Email)]
+	if !ok || current.Deleted || expired(current) {
+		return nil, ErrUserDoesNotExist
+	}
+	if user.Version > 0 && user.Version != current.Version {
+		return nil, ErrVersionConflict
+	}
+
+	if err := hashPassword(&user); err != nil {
+		return nil, err
+	}
+	if user.PasswordHash != "" {
+		current.PasswordHash = user.PasswordHash
+	}
+	current.FirstName = user.FirstName
+	current.LastName = user.LastName
+	indexUser(&current)
+	current.Phone = user.Phone
+	current.AvatarURL = user.AvatarURL
+	current.Locale = user.Locale
+	current.Timezone = user.Timezone
+	current.Metadata = maps.Clone(user.Metadata)
+	if user.Role != "" {
+		current.Role = user.Role
+	}
+	current.UpdatedAt = timestamp()
+	current.Version++
+	repo.users[current.Email] = current
+	return &current, nil
+}
+
+// DeleteUser removes a user, or flags it as deleted when soft delete is enabled.
+func (repo *InMemoryUserRepository) DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.deleteLocked(email)
+}
+
+// deleteLocked implements DeleteUser, returning the user as it was before the delete.
+// The caller must hold the write lock.
+func (repo *InMemoryUserRepository) deleteLocked(email string) (*models.User, error) {
+	email = validators.NormalizeEmail(email)
+	current, ok := repo.users[email]
+	if !ok || current.Deleted || expired(current) {
+		return nil, ErrUserDoesNotExist
+	}
+
+	if repo.softDelete {
+		deleted := current
+		deleted.Deleted = true
+		deleted.DeletedAt = timestamp()
+		repo.users[email] = deleted
+		return &current, nil
+	}
+	delete(repo.users, email)
+	return &current, nil
+}
+
+// DeleteUsers deletes many users, reporting which emails were deleted and which were not found.
+func (repo *InMemoryUserRepository) DeleteUsers(ctx context.Context, emails []string) (*BatchDeleteResult, error) {
+	result := &BatchDeleteResult{Deleted: []string{}, NotFound: []string{}, Failed: []string{}}
+	seen := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		email = validators.NormalizeEmail(email)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		if _, err := repo.DeleteUser(ctx, email); err != nil {
+			result.NotFound = append(result.NotFound, email)
+			continue
+		}
+		result.Deleted = append(result.Deleted, email)
+	}
+	return result, nil
+}
+
+// RestoreUser clears the soft-delete flag on a user.
+func (repo *InMemoryUserRepository) RestoreUser(ctx context.Context, email string) (*models.User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	email = validators.NormalizeEmail(email)
+	current, ok := repo.users[email]
+	if !ok || !current.Deleted {
+		return nil, ErrUserDoesNotExist
+	}
+	current.Deleted = false
+	current.DeletedAt = ""
+	repo.users[email] = current
+	return &current, nil
+}
+
+// TransactWriteUsers applies all operations atomically: they are replayed against a copy
+// of the store, which only replaces the live data when every operation succeeded.
+func (repo *InMemoryUserRepository) TransactWriteUsers(ctx context.Context, ops []UserOperation) error {
+	if len(ops) == 0 || len(ops) > maxTransactionItems {
+		return fmt.Errorf("%w: a transaction must contain between 1 and %d operations", ErrInvalidOperation, maxTransactionItems)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	staged := &InMemoryUserRepository{users: maps.Clone(repo.users), softDelete: repo.softDelete}
+	var reasons []string
+	for i, op := range ops {
+		var err error
+		switch op.Type {
+		case OperationCreate:
+			_, err = staged.createLocked(op.User)
+		case OperationUpdate:
+			_, err = staged.updateLocked(op.User)
+		case OperationDelete:
+			_, err = staged.deleteLocked(op.User.Email)
+		default:
+			return fmt.Errorf("operation %d: %w: unknown type %q", i, ErrInvalidOperation, op.Type)
+		}
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("operation %d (%s %s): %s", i, op.Type, validators.NormalizeEmail(op.User.Email), err.Error()))
+		}
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%w: %s", ErrTransactionCanceled, strings.Join(reasons, "; "))
+	}
+
+	repo.users = staged.users
+	return nil
+}
+
+// Ping always succeeds, since the store lives in process memory.
+func (repo *InMemoryUserRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// page returns up to limit users matching match in email order, starting after the email encoded in lastEvaluatedKey.
+func (repo *InMemoryUserRepository) page(limit int, lastEvaluatedKey string, opts FetchOptions, match func(models.User) bool) ([]models.User, string, error) {
+	startKey, err := decodeLastEvaluatedKey(lastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+	var after string
+	if startKey != nil && startKey["email"] != nil {
+		after = aws.StringValue(startKey["email"].S)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	emails := make([]string, 0, len(repo.users))
+	for email, user := range repo.users {
+		pastStart := after == "" || email > after
+		if opts.Descending {
+			pastStart = after == "" || email < after
+		}
+		if pastStart && (!user.Deleted || opts.IncludeDeleted) && !expired(user) && createdInRange(user, opts) && match(user) {
+			emails = append(emails, email)
+		}
+	}
+	sort.Strings(emails)
+	if opts.Descending {
+		slices.Reverse(emails)
+	}
+
+	users := []models.User{}
+	for i, email := range emails {
+		if len(users) == limit {
+			// More items remain, so hand out a token for the last one returned
+			token, err := encodeLastEvaluatedKey(userKey(emails[i-1]))
+			return users, token, err
+		}
+		users = append(users, projectUser(repo.users[email], opts.Fields))
+	}
+	return users, "", nil
+}
+
+// DeleteAllUsers removes every user and returns how many were removed.
+// Like the DynamoDB version it requires AllowDestructiveOps.
+func (repo *InMemoryUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	if !repo.allowDestructiveOps {
+		return 0, ErrDestructiveOpsDisabled
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := len(repo.users)
+	for _, user := range repo.users {
+		recordChange(ctx, &user, nil)
+	}
+	clear(repo.users)
+	return deleted, nil
+}
+
+// createdInRange reports whether user was created inside the window of opts,
+// comparing timestamps the same way the DynamoDB filter does.
+func createdInRange(user models.User, opts FetchOptions) bool {
+	if !opts.CreatedAfter.IsZero() && user.CreatedAt <= opts.CreatedAfter.UTC().Format(time.RFC3339) {
+		return false
+	}
+	if !opts.CreatedBefore.IsZero() && user.CreatedAt >= opts.CreatedBefore.UTC().Format(time.RFC3339) {
+		return false
+	}
+	return true
+}
+
+// projectUser keeps only the given fields of user, like a DynamoDB ProjectionExpression.
+// Empty fields keep the whole user.
+func projectUser(user models.User, fields []string) models.User {
+	if len(fields) == 0 {
+		return user
+	}
+	var projected models.User
+	for _, field := range fields {
+		switch field {
+		case "id":
+			projected.ID = user.ID
+		case "email":
+			projected.Email = user.Email
+		case "firstName":
+			projected.FirstName = user.FirstName
+		case "lastName":
+			projected.LastName = user.LastName
+		case "phone":
+			projected.Phone = user.Phone
+		case "role":
+			projected.Role = user.Role
+		case "avatarUrl":
+			projected.AvatarURL = user.AvatarURL
+		case "locale":
+			projected.Locale = user.Locale
+		case "timezone":
+			projected.Timezone = user.Timezone
+		case "metadata":
+			projected.Metadata = user.Metadata
+		case "createdAt":
+			projected.CreatedAt = user.CreatedAt
+		case "updatedAt":
+			projected.UpdatedAt = user.UpdatedAt
+		case "version":
+			projected.Version = user.Version
+		case "deleted":
+			projected.Deleted = user.Deleted
+		case "deletedAt":
+			projected.DeletedAt = user.DeletedAt
+		case "expiresAt":
+			projected.ExpiresAt = user.ExpiresAt
+		}
+	}
+	return projected
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// KeyMigrationResult reports the outcome of CopyUsers.
+type KeyMigrationResult struct {
+	// Copied is how many users were written to the target.
+	Copied int `json:"copied"`
+	// Expired is how many expired users were left behind.
+	Expired int `json:"expired"`
+	// Conflicts lists the emails whose reservation in the target is held by another user, whose
+	// source users were not copied. Without prune, these may be emails that changed hands since an
+	// earlier pass; the pass with prune retries them once stale reservations are gone. What is left
+	// are emails held by more than one source user, such as legacy records that only differ in case,
+	// which must be merged with MergeUsers in the source before the migration is finished.
+	Conflicts []string `json:"conflicts"`
+	// Pruned is how many users were deleted from the target because the source no longer has them.
+	Pruned int `json:"pruned"`
+}
+
+// CopyUsers copies every live user of source, a table with KeySchemaEmail, to target, a table with
+// KeySchemaID and its email table, and reports what it did. It is the core of the key migration:
+// run it while the service still writes to source to copy the bulk of the users, then again with
+// prune set once writes are stopped, so the target ends up exactly like the source.
+//
+// Each user is written with its email normalized, together with the reservation of the email, in one
+// transaction. Users are overwritten, so every pass picks up the changes made since the last one.
+// Source users without an ID get one first, written back to the source, so all passes agree on it.
+// With prune set, target users the source no longer has, and reservations for emails their user no
+// longer has, are deleted afterwards, and users whose email was reserved by a stale reservation are
+// copied again.
+func CopyUsers(ctx context.Context, source, target *DynamoDBUserRepository, prune bool) (KeyMigrationResult, error) {
+	result := KeyMigrationResult{Conflicts: []string{}}
+	if source.keyedByID() || !target.keyedByID() {
+		return result, fmt.Errorf("%w: users are copied from a table keyed on email to one keyed on id", ErrInvalidOperation)
+	}
+
+	copied := map[string]string{} // Email of each copied user, by id
+	var conflicts []models.User
+	items := map[string]map[string]*dynamodb.AttributeValue{} // Source item of each conflicting user, by id
+	input := &dynamodb.ScanInput{TableName: aws.String(source.tableName)}
+	err := source.scanPages(ctx, "CopyUsers", input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var user models.User
+			if err := dynamodbattribute.UnmarshalMap(item, &user); err != nil {
+				slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "CopyUsers"), slog.Any("error", err))
+				return fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			if expired(user) {
+				result.Expired++
+				continue
+			}
+			if user.ID == "" {
+				var err error
+				if item, err = source.backfillID(ctx, user.Email); err != nil {
+					return err
+				}
+				user.ID = aws.StringValue(item["id"].S)
+			}
+
+			conflict, err := target.copyUser(ctx, item, user)
+			if err != nil {
+				return err
+			}
+			if conflict {
+				conflicts = append(conflicts, user)
+				items[user.ID] = item
+				continue
+			}
+			copied[user.ID] = validators.NormalizeEmail(user.Email)
+			result.Copied++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if prune {
+		if result.Pruned, err = target.pruneCopies(ctx, copied); err != nil {
+			return result, err
+		}
+		retried := conflicts[:0]
+		for _, user := range conflicts {
+			conflict, err := target.copyUser(ctx, items[user.ID], user)
+			if err != nil {
+				return result, err
+			}
+			if conflict {
+				retried = append(retried, user)
+				continue
+			}
+			result.Copied++
+		}
+		conflicts = retried
+	}
+	for _, user := range conflicts {
+		email := validators.NormalizeEmail(user.Email)
+		slog.Warn("Email is held by another user", slog.String("operation", "CopyUsers"), slog.String("email", email))
+		result.Conflicts = append(result.Conflicts, email)
+	}
+	return result, nil
+}
+
+// backfillID gives the user stored under the exact key email an ID, unless it got one concurrently,
+// and returns the stored item.
+func (repo *DynamoDBUserRepository) backfillID(ctx context.Context, email string) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       userKey(email),
+		UpdateExpression:          aws.String("SET #id = if_not_exists(#id, :id)"),
+		ConditionExpression:       aws.String("attribute_exists(#email)"),
+		ExpressionAttributeNames:  map[string]*string{"#id": aws.String("id"), "#email": aws.String("email")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":id": {S: aws.String(newUserID())}},
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	}
+	var result *dynamodb.UpdateItemOutput
+	err := repo.withRetry(ctx, "CopyUsers", func() (err error) {
+		result, err = repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "CopyUsers"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return result.Attributes, nil
+}
+
+// copyUser writes the source item of user, which has an ID, to the target table with KeySchemaID,
+// together with the reservation of its normalized email. It reports true, and writes nothing, when
+// the email is reserved for another user.
+func (repo *DynamoDBUserRepository) copyUser(ctx context.Context, item map[string]*dynamodb.AttributeValue, user models.User) (bool, error) {
+	email := validators.NormalizeEmail(user.Email)
+	copied := maps.Clone(item)
+	copied["email"] = stringValue(email)
+	copied["normalizedEmail"] = stringValue(email)
+
+	reservation, err := dynamodbattribute.MarshalMap(emailReservation{Email: email, UserID: user.ID, ExpiresAt: user.ExpiresAt})
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+	err = repo.transactWrite(ctx, "CopyUsers", []*dynamodb.TransactWriteItem{
+		{Put: &dynamodb.Put{
+			TableName:                 aws.String(repo.emailTableName),
+			Item:                      reservation,
+			ConditionExpression:       aws.String("attribute_not_exists(#email) OR #userId = :userId"),
+			ExpressionAttributeNames:  map[string]*string{"#email": aws.String("email"), "#userId": aws.String("userId")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":userId": {S: aws.String(user.ID)}},
+		}},
+		{Put: &dynamodb.Put{
+			TableName: aws.String(repo.tableName),
+			Item:      copied,
+		}},
+	})
+	if conditionFailedAt(err, 0) {
+		return true, nil
+	}
+	if err != nil {
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "CopyUsers"), slog.Any("error", err))
+		return false, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return false, nil
+}
+
+// pruneCopies deletes the users of the target table with KeySchemaID whose id is not in copied, and
+// the reservations of emails that are not the email copied for their user, and returns how many
+// users were deleted.
+func (repo *DynamoDBUserRepository) pruneCopies(ctx context.Context, copied map[string]string) (int, error) {
+	var stale []map[string]*dynamodb.AttributeValue
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(repo.tableName),
+		ProjectionExpression:     aws.String("#id"),
+		ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+	}
+	err := repo.scanPages(ctx, "CopyUsers", input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			if _, ok := copied[aws.StringValue(item["id"].S)]; !ok {
+				stale = append(stale, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	removed, err := repo.deleteItems(ctx, "CopyUsers", repo.tableName, stale)
+	pruned := len(removed)
+	if err != nil {
+		return pruned, err
+	}
+
+	stale = nil
+	input = &dynamodb.ScanInput{TableName: aws.String(repo.emailTableName)}
+	err = repo.scanPages(ctx, "CopyUsers", input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var reservation emailReservation
+			if err := dynamodbattribute.UnmarshalMap(item, &reservation); err != nil {
+				return fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			if copied[reservation.UserID] != reservation.Email {
+				stale = append(stale, userKey(reservation.Email))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return pruned, err
+	}
+	_, err = repo.deleteItems(ctx, "CopyUsers", repo.emailTableName, stale)
+	return pruned, err
+}
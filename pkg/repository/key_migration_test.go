@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestCopyUsers(t *testing.T) {
+	const sourceTable = "users-by-email"
+	expiredAt := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name          string
+		source        []models.User
+		reservations  map[string]string // Reservations already in the target, ids by email
+		targetIDs     []string          // Users already in the target
+		prune         bool
+		wantResult    KeyMigrationResult
+		wantReserved  map[string]string
+		wantTargetIDs []string
+	}{
+		{
+			name:          "copies live users with normalized emails",
+			source:        []models.User{{Email: "A@Example.com", ID: "user-1"}, {Email: "b@example.com", ID: "user-2", ExpiresAt: expiredAt}},
+			wantResult:    KeyMigrationResult{Copied: 1, Expired: 1, Conflicts: []string{}},
+			wantReserved:  map[string]string{"a@example.com": "user-1"},
+			wantTargetIDs: []string{"user-1"},
+		},
+		{
+			name:          "gives users without an id one",
+			source:        []models.User{{Email: "a@example.com"}},
+			wantResult:    KeyMigrationResult{Copied: 1, Conflicts: []string{}},
+			wantReserved:  map[string]string{"a@example.com": "backfilled"},
+			wantTargetIDs: []string{"backfilled"},
+		},
+		{
+			name:          "reports emails reserved for another user",
+			source:        []models.User{{Email: "a@example.com", ID: "user-1"}, {Email: "b@example.com", ID: "user-2"}},
+			reservations:  map[string]string{"a@example.com": "user-2"},
+			wantResult:    KeyMigrationResult{Copied: 1, Conflicts: []string{"a@example.com"}},
+			wantReserved:  map[string]string{"a@example.com": "user-2", "b@example.com": "user-2"},
+			wantTargetIDs: []string{"user-2"},
+		},
+		{
+			name:          "prunes stale users and reservations, then copies the conflicts again",
+			source:        []models.User{{Email: "a@example.com", ID: "user-1"}, {Email: "b@example.com", ID: "user-2"}},
+			reservations:  map[string]string{"a@example.com": "user-2", "gone@example.com": "user-9"},
+			targetIDs:     []string{"user-9"},
+			prune:         true,
+			wantResult:    KeyMigrationResult{Copied: 2, Conflicts: []string{}, Pruned: 1},
+			wantReserved:  map[string]string{"a@example.com": "user-1", "b@example.com": "user-2"},
+			wantTargetIDs: []string{"user-1", "user-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved := maps.Clone(tt.reservations)
+			if reserved == nil {
+				reserved = map[string]string{}
+			}
+			targetIDs := slices.Clone(tt.targetIDs)
+
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					var items []map[string]*dynamodb.AttributeValue
+					switch aws.StringValue(input.TableName) {
+					case sourceTable:
+						for _, user := range tt.source {
+							items = append(items, marshalUser(t, user))
+						}
+					case testTable:
+						for _, id := range targetIDs {
+							items = append(items, idKey(id))
+						}
+					case testEmailTable:
+						for email, id := range reserved {
+							item, err := dynamodbattribute.MarshalMap(emailReservation{Email: email, UserID: id})
+							if err != nil {
+								t.Fatal(err)
+							}
+							items = append(items, item)
+						}
+					}
+					return &dynamodb.ScanOutput{Items: items}, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if aws.StringValue(input.TableName) != sourceTable {
+						t.Errorf("id backfilled in %s", aws.StringValue(input.TableName))
+					}
+					user := models.User{Email: aws.StringValue(input.Key["email"].S), ID: "backfilled"}
+					return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, user)}, nil
+				},
+				transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					reservation, user := input.TransactItems[0].Put, input.TransactItems[1].Put
+					email, id := aws.StringValue(reservation.Item["email"].S), aws.StringValue(reservation.Item["userId"].S)
+					if holder, ok := reserved[email]; ok && holder != id {
+						return nil, canceledAt(2, 0)
+					}
+					if got := aws.StringValue(user.Item["email"].S); got != email {
+						t.Errorf("user copied with email %q, reserved %q", got, email)
+					}
+					reserved[email] = id
+					if !slices.Contains(targetIDs, id) {
+						targetIDs = append(targetIDs, id)
+					}
+					return &dynamodb.TransactWriteItemsOutput{}, nil
+				},
+				batchWriteItem: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+					for _, request := range input.RequestItems[testTable] {
+						id := aws.StringValue(request.DeleteRequest.Key["id"].S)
+						targetIDs = slices.DeleteFunc(targetIDs, func(target string) bool { return target == id })
+					}
+					for _, request := range input.RequestItems[testEmailTable] {
+						delete(reserved, aws.StringValue(request.DeleteRequest.Key["email"].S))
+					}
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				},
+			}
+			source := NewDynamoDBUserRepository(client, sourceTable, DynamoDBOptions{})
+			target := NewDynamoDBUserRepository(client, testTable, idOptions)
+
+			result, err := CopyUsers(context.Background(), source, target, tt.prune)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Copied != tt.wantResult.Copied || result.Expired != tt.wantResult.Expired || result.Pruned != tt.wantResult.Pruned ||
+				!slices.Equal(result.Conflicts, tt.wantResult.Conflicts) {
+				t.Errorf("result = %+v, want %+v", result, tt.wantResult)
+			}
+			if !maps.Equal(reserved, tt.wantReserved) {
+				t.Errorf("reserved = %v, want %v", reserved, tt.wantReserved)
+			}
+			slices.Sort(targetIDs)
+			if !slices.Equal(targetIDs, tt.wantTargetIDs) {
+				t.Errorf("target ids = %v, want %v", targetIDs, tt.wantTargetIDs)
+			}
+		})
+	}
+}
+
+func TestCopyUsersChecksKeySchemas(t *testing.T) {
+	client := &mockDynamoDB{}
+	byEmail := NewDynamoDBUserRepository(client, "users-by-email", DynamoDBOptions{})
+	byID := NewDynamoDBUserRepository(client, testTable, idOptions)
+	if _, err := CopyUsers(context.Background(), byID, byEmail, false); !errors.Is(err, ErrInvalidOperation) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidOperation)
+	}
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// KeySchema names the primary key of the user table.
+type KeySchema string
+
+const (
+	// KeySchemaEmail keys users on their normalized email, which keeps emails unique by construction
+	// but makes changing one a move of the record to a new key. It is the default.
+	KeySchemaEmail KeySchema = "email"
+	// KeySchemaID keys users on their UUID id. Users are found by email through a GSI partitioned on
+	// email, and since a GSI cannot enforce uniqueness, each email is also reserved for the id of its
+	// user in a separate email table, written in the same transaction as the user.
+	KeySchemaID KeySchema = "id"
+)
+
+// emailReservation is an item of the email table of KeySchemaID: Email is taken by the user with
+// UserID. ExpiresAt mirrors the user's, so the email of an expired user can be taken again, and lets
+// DynamoDB delete the reservation along with the user if TTL is enabled on the email table too.
+type emailReservation struct {
+	Email     string `dynamodbav:"email"`
+	UserID    string `dynamodbav:"userId"`
+	ExpiresAt int64  `dynamodbav:"expiresAt,omitempty"`
+}
+
+// keyedByID reports whether the user table uses KeySchemaID.
+func (repo *DynamoDBUserRepository) keyedByID() bool {
+	return repo.keySchema == KeySchemaID
+}
+
+// keyAttributes lists the attributes of the user table's primary key.
+func (repo *DynamoDBUserRepository) keyAttributes() []string {
+	if repo.keyedByID() {
+		return []string{"id"}
+	}
+	return []string{"email"}
+}
+
+// idKey builds the DynamoDB primary key of a user in the KeySchemaID layout.
+func idKey(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(id),
+		},
+	}
+}
+
+// userItemKey returns the primary key of the stored user.
+func (repo *DynamoDBUserRepository) userItemKey(user models.User) map[string]*dynamodb.AttributeValue {
+	if repo.keyedByID() {
+		return idKey(user.ID)
+	}
+	return userKey(user.Email)
+}
+
+// itemKey returns the primary key of a stored item, which must hold the key attributes.
+func (repo *DynamoDBUserRepository) itemKey(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	key := make(map[string]*dynamodb.AttributeValue, 1)
+	for _, attr := range repo.keyAttributes() {
+		key[attr] = item[attr]
+	}
+	return key
+}
+
+// keyOf returns the primary key of the user with the (normalized) email. With KeySchemaEmail that
+// is the email itself. With KeySchemaID the id is read from the email table, consistently, so a
+// user created or moved just before is found; an email nobody has reserved yields
+// ErrUserDoesNotExist. The user may have changed its email since, so writes through the key are
+// conditioned on the email with emailCondition.
+func (repo *DynamoDBUserRepository) keyOf(ctx context.Context, operation, email string) (map[string]*dynamodb.AttributeValue, error) {
+	if !repo.keyedByID() {
+		return userKey(email), nil
+	}
+
+	input := &dynamodb.GetItemInput{
+		TableName:      aws.String(repo.emailTableName),
+		Key:            userKey(email),
+		ConsistentRead: aws.Bool(true),
+	}
+	var result *dynamodb.GetItemOutput
+	err := repo.withRetry(ctx, operation, func() (err error) {
+		result, err = repo.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", operation), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+	var reservation emailReservation
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &reservation); err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", operation), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	if reservation.UserID == "" {
+		return nil, ErrUserDoesNotExist
+	}
+	return idKey(reservation.UserID), nil
+}
+
+// emailCondition returns the condition a write through a key from keyOf needs with KeySchemaID, so
+// it fails if the user's email changed after the key was read, registering the name and value it
+// refers to. With KeySchemaEmail the key is the email, and the condition is empty.
+func (repo *DynamoDBUserRepository) emailCondition(email string, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	if !repo.keyedByID() {
+		return ""
+	}
+	names["#email"] = aws.String("email")
+	values[":email"] = &dynamodb.AttributeValue{S: aws.String(email)}
+	return "#email = :email"
+}
+
+// reserveEmail returns the transaction action that reserves the email of user for its id in the
+// email table, conditional on the email being free or only held by an expired user, like
+// newUserCondition.
+func (repo *DynamoDBUserRepository) reserveEmail(user models.User) (*dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(emailReservation{Email: user.Email, UserID: user.ID, ExpiresAt: user.ExpiresAt})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+	condition, names, values := newUserCondition()
+	return &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:                 aws.String(repo.emailTableName),
+		Item:                      item,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}}, nil
+}
+
+// releaseEmail returns the transaction action that deletes the reservation of email, conditional on
+// it still being held by the user with id (or already being gone).
+func (repo *DynamoDBUserRepository) releaseEmail(email, id string) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+		TableName:                 aws.String(repo.emailTableName),
+		Key:                       userKey(email),
+		ConditionExpression:       aws.String("attribute_not_exists(#email) OR #userId = :userId"),
+		ExpressionAttributeNames:  map[string]*string{"#email": aws.String("email"), "#userId": aws.String("userId")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":userId": {S: aws.String(id)}},
+	}}
+}
+
+// transactWrite runs the actions in one TransactWriteItems call, with retries. Errors are returned
+// as they are, so callers can map a cancellation with conditionFailedAt.
+func (repo *DynamoDBUserRepository) transactWrite(ctx context.Context, operation string, items []*dynamodb.TransactWriteItem) error {
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: items}
+	return repo.withRetry(ctx, operation, func() error {
+		_, err := repo.client.TransactWriteItemsWithContext(ctx, input)
+		return err
+	})
+}
+
+// conditionFailedAt reports whether err is a cancelled transaction whose action i failed its condition.
+func conditionFailedAt(err error, i int) bool {
+	var canceled *dynamodb.TransactionCanceledException
+	return errors.As(err, &canceled) && i < len(canceled.CancellationReasons) &&
+		aws.StringValue(canceled.CancellationReasons[i].Code) == "ConditionalCheckFailed"
+}
+
+// getUserItem reads the user item with key, consistently, returning nil if there is none.
+func (repo *DynamoDBUserRepository) getUserItem(ctx context.Context, operation string, key map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.GetItemInput{
+		TableName:      aws.String(repo.tableName),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	}
+	var result *dynamodb.GetItemOutput
+	err := repo.withRetry(ctx, operation, func() (err error) {
+		result, err = repo.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", operation), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+	return result.Item, nil
+}
+
+// fetchByEmailIndex looks up the user with email through the email index of KeySchemaID, returning
+// nil if none matches. Emails are reserved for one user, but a user that expired and whose email was
+// taken again stays in the index until DynamoDB deletes it, so a live user is preferred.
+func (repo *DynamoDBUserRepository) fetchByEmailIndex(ctx context.Context, email string, opts FetchOptions) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(repo.tableName),
+		IndexName:                aws.String(repo.emailIndex),
+		KeyConditionExpression:   aws.String("#email = :email"),
+		ExpressionAttributeNames: map[string]*string{"#email": aws.String("email")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":email": {S: aws.String(email)},
+		},
+	}
+	if len(opts.Fields) > 0 {
+		// Like FetchUser, the soft-delete flag and expiry are always read
+		input.ProjectionExpression = projection(append(slices.Clip(opts.Fields), "deleted", "expiresAt"), input.ExpressionAttributeNames)
+	}
+
+	var result *dynamodb.QueryOutput
+	err := repo.withRetry(ctx, "FetchUser", func() (err error) {
+		result, err = repo.client.QueryWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB Query failed", slog.String("operation", "FetchUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotQueryItems, err)
+	}
+	if len(result.Items) == 0 {
+		return nil, nil
+	}
+	for _, item := range result.Items {
+		var user models.User
+		if err := dynamodbattribute.UnmarshalMap(item, &user); err == nil && !expired(user) {
+			return item, nil
+		}
+	}
+	return result.Items[0], nil
+}
+
+// scanStartKey decodes the pagination token of a Scan over the user table, keeping only the
+// attributes of the table's key: tokens from ResumeToken carry both the email and the id. A token
+// without the key attributes was issued for another key schema.
+func (repo *DynamoDBUserRepository) scanStartKey(token string) (map[string]*dynamodb.AttributeValue, error) {
+	key, err := decodeLastEvaluatedKey(token)
+	if err != nil || key == nil {
+		return key, err
+	}
+	startKey := make(map[string]*dynamodb.AttributeValue, 1)
+	for _, attr := range repo.keyAttributes() {
+		value, ok := key[attr]
+		if !ok || value == nil || value.S == nil {
+			return nil, ErrInvalidLastEvaluatedKey
+		}
+		startKey[attr] = value
+	}
+	return startKey, nil
+}
+
+// createUserByID writes user, marshalled as item, with KeySchemaID: the reservation of its email and
+// the user are put in one transaction, so an email taken by another user fails the create with
+// ErrUserAlreadyExists and leaves nothing behind.
+func (repo *DynamoDBUserRepository) createUserByID(ctx context.Context, user models.User, item map[string]*dynamodb.AttributeValue) (*models.User, error) {
+	reservation, err := repo.reserveEmail(user)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "CreateUser"), slog.Any("error", err))
+		return nil, err
+	}
+	err = repo.transactWrite(ctx, "CreateUser", []*dynamodb.TransactWriteItem{
+		reservation,
+		{Put: &dynamodb.Put{
+			TableName:                aws.String(repo.tableName),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+			ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+		}},
+	})
+	if err != nil {
+		if conditionFailedAt(err, 0) {
+			return nil, ErrUserAlreadyExists
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "CreateUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return &user, nil
+}
+
+// upsertUserByID is UpsertUser with KeySchemaID, for a user whose password is already hashed. The
+// update is conditional on the user still having its email and not having expired; otherwise the
+// email is free (or about to be), and the user is created with CreateUser. A user created
+// concurrently in between fails the upsert with ErrVersionConflict.
+func (repo *DynamoDBUserRepository) upsertUserByID(ctx context.Context, user models.User) (*models.User, bool, error) {
+	key, err := repo.keyOf(ctx, "UpsertUser", user.Email)
+	if err != nil && !errors.Is(err, ErrUserDoesNotExist) {
+		return nil, false, err
+	}
+	if err == nil {
+		update := buildUserUpdate(user, true, true)
+		expiresAt := expressionNames(update.names).name("expiresAt")
+		update.condition = "attribute_exists(" + expressionNames(update.names).name("id") + ") AND " + update.condition +
+			" AND (attribute_not_exists(" + expiresAt + ") OR " + expiresAt + " > :now)"
+		update.values[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))}
+		input := &dynamodb.UpdateItemInput{
+			Key:                       key,
+			TableName:                 aws.String(repo.tableName),
+			UpdateExpression:          aws.String(update.expression),
+			ConditionExpression:       aws.String(update.condition),
+			ExpressionAttributeNames:  update.names,
+			ExpressionAttributeValues: update.values,
+			ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+		}
+		var result *dynamodb.UpdateItemOutput
+		err = repo.withRetry(ctx, "UpsertUser", func() (err error) {
+			result, err = repo.client.UpdateItemWithContext(ctx, input)
+			return err
+		})
+		switch {
+		case err == nil:
+			upserted := new(models.User)
+			if err := dynamodbattribute.UnmarshalMap(result.Attributes, upserted); err != nil {
+				slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "UpsertUser"), slog.Any("error", err))
+				return nil, false, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			return upserted, false, nil
+		case !isConditionalCheckFailed(err):
+			slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "UpsertUser"), slog.Any("error", err))
+			return nil, false, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+		}
+	}
+
+	created, err := repo.CreateUser(ctx, user)
+	if errors.Is(err, ErrUserAlreadyExists) {
+		return nil, false, ErrVersionConflict
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return created, true, nil
+}
+
+// deleteUserByID hard-deletes the user with email in the KeySchemaID layout. The user and the
+// reservation of its email are deleted in one transaction, conditional on the user being unchanged
+// since it was read, which is also how the deleted user is returned: a transaction cannot return
+// the items it deleted. A concurrent write fails the delete with ErrVersionConflict.
+func (repo *DynamoDBUserRepository) deleteUserByID(ctx context.Context, email string) (*models.User, error) {
+	key, err := repo.keyOf(ctx, "DeleteUser", email)
+	if err != nil {
+		return nil, err
+	}
+	item, err := repo.getUserItem(ctx, "DeleteUser", key)
+	if err != nil {
+		return nil, err
+	}
+	user, err := deletedUser(item)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != email || user.Deleted || expired(*user) {
+		return nil, ErrUserDoesNotExist
+	}
+
+	condition, values := unchangedCondition(item)
+	err = repo.transactWrite(ctx, "DeleteUser", []*dynamodb.TransactWriteItem{
+		{Delete: &dynamodb.Delete{
+			TableName:                 aws.String(repo.tableName),
+			Key:                       key,
+			ConditionExpression:       aws.String("attribute_exists(#id) AND " + condition),
+			ExpressionAttributeNames:  map[string]*string{"#id": aws.String("id"), "#version": aws.String("version")},
+			ExpressionAttributeValues: nilIfEmpty(values),
+		}},
+		repo.releaseEmail(email, user.ID),
+	})
+	if err != nil {
+		if conditionFailedAt(err, 0) || conditionFailedAt(err, 1) {
+			return nil, ErrVersionConflict
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "DeleteUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDeleteItem, err)
+	}
+	return user, nil
+}
+
+// validateEmailTable checks that the email table of KeySchemaID has the string partition key "email".
+func (repo *DynamoDBUserRepository) validateEmailTable(ctx context.Context) error {
+	var result *dynamodb.DescribeTableOutput
+	err := repo.withRetry(ctx, "ValidateSchema", func() (err error) {
+		result, err = repo.client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(repo.emailTableName),
+		})
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB DescribeTable failed", slog.String("operation", "ValidateSchema"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrTableNotReachable, err)
+	}
+	if !hashKeyIs(result.Table.KeySchema, "email") {
+		return fmt.Errorf("%w: email table %s must have only the partition key \"email\"", ErrTableSchemaMismatch, repo.emailTableName)
+	}
+	return nil
+}
+
+// hashKeyIs reports whether keySchema consists of the partition key attr only.
+func hashKeyIs(keySchema []*dynamodb.KeySchemaElement, attr string) bool {
+	return len(keySchema) == 1 &&
+		aws.StringValue(keySchema[0].AttributeName) == attr &&
+		aws.StringValue(keySchema[0].KeyType) == dynamodb.KeyTypeHash
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// testEmailTable is the email table the repositories under test with KeySchemaID are configured with.
+const testEmailTable = "user-emails"
+
+// idOptions configures a repository with KeySchemaID.
+var idOptions = DynamoDBOptions{KeySchema: KeySchemaID, EmailIndex: "email-index", EmailTableName: testEmailTable}
+
+// canceledAt returns the error of a transaction cancelled because action i failed its condition.
+func canceledAt(n, i int) error {
+	reasons := make([]*dynamodb.CancellationReason, n)
+	for j := range reasons {
+		reasons[j] = &dynamodb.CancellationReason{Code: aws.String("None")}
+	}
+	reasons[i].Code = aws.String("ConditionalCheckFailed")
+	return &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+}
+
+// getItemByID answers consistent GetItem calls on the email table with the given reservations, ids by
+// email, and on the user table with the stored users by id.
+func getItemByID(t *testing.T, reservations map[string]string, stored ...models.User) func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		if !aws.BoolValue(input.ConsistentRead) {
+			t.Errorf("GetItem on %s is not consistent", aws.StringValue(input.TableName))
+		}
+		if aws.StringValue(input.TableName) == testEmailTable {
+			email := aws.StringValue(input.Key["email"].S)
+			if id, ok := reservations[email]; ok {
+				item, err := dynamodbattribute.MarshalMap(emailReservation{Email: email, UserID: id})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return &dynamodb.GetItemOutput{Item: item}, nil
+			}
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		for _, user := range stored {
+			if aws.StringValue(input.Key["id"].S) == user.ID {
+				return &dynamodb.GetItemOutput{Item: marshalUser(t, user)}, nil
+			}
+		}
+		return &dynamodb.GetItemOutput{}, nil
+	}
+}
+
+func TestCreateUserByID(t *testing.T) {
+	tests := []struct {
+		name     string
+		writeErr error
+		wantErr  error
+	}{
+		{name: "free email"},
+		{name: "email taken", writeErr: canceledAt(2, 0), wantErr: ErrUserAlreadyExists},
+		{name: "other errors", writeErr: errors.New("unavailable"), wantErr: ErrCouldNotDynamoPutItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					if len(input.TransactItems) != 2 {
+						t.Fatalf("%d actions, want 2", len(input.TransactItems))
+					}
+					reservation, user := input.TransactItems[0].Put, input.TransactItems[1].Put
+					if aws.StringValue(reservation.TableName) != testEmailTable || aws.StringValue(reservation.Item["email"].S) != "a@example.com" {
+						t.Errorf("reservation = %v", reservation)
+					}
+					if aws.StringValue(reservation.Item["userId"].S) != aws.StringValue(user.Item["id"].S) {
+						t.Errorf("email reserved for %v, user has id %v", reservation.Item["userId"], user.Item["id"])
+					}
+					if got := aws.StringValue(user.ConditionExpression); got != "attribute_not_exists(#id)" {
+						t.Errorf("user condition = %q", got)
+					}
+					return &dynamodb.TransactWriteItemsOutput{}, tt.writeErr
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, idOptions)
+
+			created, err := repo.CreateUser(context.Background(), models.User{Email: "A@Example.com"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (created.Email != "a@example.com" || created.ID == "") {
+				t.Errorf("created = %+v", created)
+			}
+		})
+	}
+}
+
+func TestChangeEmailByID(t *testing.T) {
+	stored := models.User{Email: "old@example.com", ID: "user-1", FirstName: "Jane", Version: 3}
+	tests := []struct {
+		name     string
+		oldEmail string
+		writeErr error
+		wantErr  error
+	}{
+		{name: "moves the user", oldEmail: "old@example.com"},
+		{name: "new email taken", oldEmail: "old@example.com", writeErr: canceledAt(3, 0), wantErr: ErrUserAlreadyExists},
+		{name: "user changed", oldEmail: "old@example.com", writeErr: canceledAt(3, 1), wantErr: ErrVersionConflict},
+		{name: "old email reserved again", oldEmail: "old@example.com", writeErr: canceledAt(3, 2), wantErr: ErrVersionConflict},
+		{name: "missing user", oldEmail: "nobody@example.com", wantErr: ErrUserDoesNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				getItem: getItemByID(t, map[string]string{stored.Email: stored.ID}, stored),
+				transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					if len(input.TransactItems) != 3 {
+						t.Fatalf("%d actions, want 3", len(input.TransactItems))
+					}
+					reserve, update, release := input.TransactItems[0].Put, input.TransactItems[1].Update, input.TransactItems[2].Delete
+					if aws.StringValue(reserve.TableName) != testEmailTable || aws.StringValue(reserve.Item["email"].S) != "new@example.com" ||
+						aws.StringValue(reserve.Item["userId"].S) != "user-1" {
+						t.Errorf("reservation = %v", reserve.Item)
+					}
+					if aws.StringValue(update.Key["id"].S) != "user-1" || aws.StringValue(update.ExpressionAttributeValues[":oldEmail"].S) != "old@example.com" ||
+						aws.StringValue(update.ExpressionAttributeValues[":version"].N) != "3" {
+						t.Errorf("update key = %v, values = %v", update.Key, update.ExpressionAttributeValues)
+					}
+					if aws.StringValue(release.TableName) != testEmailTable || aws.StringValue(release.Key["email"].S) != "old@example.com" {
+						t.Errorf("release = %v", release)
+					}
+					return &dynamodb.TransactWriteItemsOutput{}, tt.writeErr
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, idOptions)
+
+			moved, err := repo.ChangeEmail(context.Background(), tt.oldEmail, "New@Example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if moved.Email != "new@example.com" || moved.ID != "user-1" || moved.FirstName != "Jane" || moved.Version != 4 {
+				t.Errorf("moved = %+v", moved)
+			}
+		})
+	}
+}
+
+func TestFetchUserByEmailIndex(t *testing.T) {
+	expiredAt := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name    string
+		indexed []models.User
+		wantID  string
+	}{
+		{name: "no user"},
+		{name: "one user", indexed: []models.User{{Email: "a@example.com", ID: "user-1"}}, wantID: "user-1"},
+		{
+			name:    "expired user whose email was taken again",
+			indexed: []models.User{{Email: "a@example.com", ID: "user-1", ExpiresAt: expiredAt}, {Email: "a@example.com", ID: "user-2"}},
+			wantID:  "user-2",
+		},
+		{name: "only an expired user", indexed: []models.User{{Email: "a@example.com", ID: "user-1", ExpiresAt: expiredAt}}},
+		{name: "soft-deleted user", indexed: []models.User{{Email: "a@example.com", ID: "user-1", Deleted: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					if aws.StringValue(input.IndexName) != "email-index" || aws.StringValue(input.ExpressionAttributeValues[":email"].S) != "a@example.com" {
+						t.Errorf("query = %v", input)
+					}
+					var items []map[string]*dynamodb.AttributeValue
+					for _, user := range tt.indexed {
+						items = append(items, marshalUser(t, user))
+					}
+					return &dynamodb.QueryOutput{Items: items}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, idOptions)
+
+			user, err := repo.FetchUser(context.Background(), "A@Example.com", FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotID string
+			if user != nil {
+				gotID = user.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("id = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestFetchUserByIDConsistent(t *testing.T) {
+	reservations := map[string]string{"a@example.com": "user-1"}
+	tests := []struct {
+		name   string
+		email  string
+		stored models.User
+		wantID string
+	}{
+		{name: "reserved email", email: "a@example.com", stored: models.User{Email: "a@example.com", ID: "user-1"}, wantID: "user-1"},
+		{name: "unreserved email", email: "b@example.com", stored: models.User{Email: "a@example.com", ID: "user-1"}},
+		// The reservation was read just before the user changed its email
+		{name: "user moved since", email: "a@example.com", stored: models.User{Email: "c@example.com", ID: "user-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{getItem: getItemByID(t, reservations, tt.stored)}
+			repo := NewDynamoDBUserRepository(client, testTable, idOptions)
+
+			user, err := repo.FetchUser(context.Background(), tt.email, FetchOptions{ConsistentRead: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotID string
+			if user != nil {
+				gotID = user.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("id = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
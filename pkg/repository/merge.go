@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// mergedUser returns primary with the gaps filled in from duplicate, as written by MergeUsers.
+//
+// The primary always wins: a field it has set is kept, and only its empty fields (names, phone,
+// avatarUrl, locale, timezone, role and createdAt) take the duplicate's value. Metadata is merged key
+// by key, the primary's value winning for a key both have. Credentials are never merged: a primary
+// without a password keeps none, so the duplicate's password cannot be used to sign in as the
+// primary. The primary keeps its id, email and expiry, gets a new updatedAt and version, and its
+// search tokens follow the merged names.
+func mergedUser(primary, duplicate models.User) models.User {
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&primary.FirstName, duplicate.FirstName)
+	fill(&primary.LastName, duplicate.LastName)
+	fill(&primary.Phone, duplicate.Phone)
+	fill(&primary.AvatarURL, duplicate.AvatarURL)
+	fill(&primary.Locale, duplicate.Locale)
+	fill(&primary.Timezone, duplicate.Timezone)
+	fill(&primary.CreatedAt, duplicate.CreatedAt)
+	if primary.Role == "" {
+		primary.Role = duplicate.Role
+	}
+	if len(duplicate.Metadata) > 0 {
+		metadata := maps.Clone(duplicate.Metadata)
+		maps.Copy(metadata, primary.Metadata)
+		primary.Metadata = metadata
+	}
+
+	primary.NormalizedEmail = validators.NormalizeEmail(primary.Email)
+	indexUser(&primary)
+	primary.UpdatedAt = timestamp()
+	primary.Version++
+	if primary.ID == "" {
+		primary.ID = newUserID()
+	}
+	return primary
+}
+
+// MergeUsers merges the user stored under duplicateEmail into the one under primaryEmail (see
+// mergedUser for which values are kept), deletes the duplicate and returns the merged user. It is
+// meant for the records whose emails only differ in case, so both emails are the table keys exactly as
+// stored, and are not normalized.
+//
+// Both records are read consistently, and the merged primary is written and the duplicate deleted in
+// a single TransactWriteItems call, each conditional on its record being unchanged since it was read:
+// a concurrent write fails the merge with ErrVersionConflict and changes nothing. The primary must be
+// a live user; a soft-deleted duplicate is merged (and removed) like any other. A missing user fails
+// with ErrUserDoesNotExist. With KeySchemaID the duplicate's email is released in the same transaction.
+// Both users are passed to the ChangeRecorder of ctx, if any.
+func (repo *DynamoDBUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error) {
+	if primaryEmail == duplicateEmail {
+		return nil, fmt.Errorf("%w: a user cannot be merged into itself", ErrInvalidOperation)
+	}
+	primaryItem, err := repo.getItemForMerge(ctx, primaryEmail)
+	if err != nil {
+		return nil, err
+	}
+	duplicateItem, err := repo.getItemForMerge(ctx, duplicateEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary, duplicate models.User
+	if err := dynamodbattribute.UnmarshalMap(primaryItem, &primary); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	if err := dynamodbattribute.UnmarshalMap(duplicateItem, &duplicate); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	if primary.Deleted || expired(primary) {
+		return nil, fmt.Errorf("%w: %s", ErrUserDoesNotExist, primaryEmail)
+	}
+
+	merged := mergedUser(primary, duplicate)
+	mergedItem, err := dynamodbattribute.MarshalMap(merged)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+	// Attributes the model does not know about are kept as the primary has them
+	for attr, value := range primaryItem {
+		if _, ok := mergedItem[attr]; !ok {
+			mergedItem[attr] = value
+		}
+	}
+
+	primaryCondition, primaryValues := unchangedCondition(primaryItem)
+	duplicateCondition, duplicateValues := unchangedCondition(duplicateItem)
+	names := map[string]*string{"#version": aws.String("version")}
+	transaction := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:                 aws.String(repo.tableName),
+				Item:                      mergedItem,
+				ConditionExpression:       aws.String("attribute_exists(email) AND " + primaryCondition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: nilIfEmpty(primaryValues),
+			}},
+			{Delete: &dynamodb.Delete{
+				TableName:                 aws.String(repo.tableName),
+				Key:                       repo.itemKey(duplicateItem),
+				ConditionExpression:       aws.String("attribute_exists(email) AND " + duplicateCondition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: nilIfEmpty(duplicateValues),
+			}},
+		},
+	}
+	if repo.keyedByID() {
+		transaction.TransactItems = append(transaction.TransactItems, repo.releaseEmail(duplicateEmail, duplicate.ID))
+	}
+	err = repo.withRetry(ctx, "MergeUsers", func() error {
+		_, err := repo.client.TransactWriteItemsWithContext(ctx, transaction)
+		return err
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			return nil, mergeCancellation(primaryEmail, duplicateEmail, canceled.CancellationReasons)
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "MergeUsers"), slog.Any("error", err))
+		return nil, fmt.Errorf("could not write transaction to DynamoDB: %w", err)
+	}
+	recordChange(ctx, &primary, &merged)
+	recordChange(ctx, &duplicate, nil)
+	return &merged, nil
+}
+
+// getItemForMerge reads the record stored under the exact email, consistently.
+func (repo *DynamoDBUserRepository) getItemForMerge(ctx context.Context, email string) (map[string]*dynamodb.AttributeValue, error) {
+	key, err := repo.keyOf(ctx, "MergeUsers", email)
+	if errors.Is(err, ErrUserDoesNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrUserDoesNotExist, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	item, err := repo.getUserItem(ctx, "MergeUsers", key)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item["email"] == nil || aws.StringValue(item["email"].S) != email {
+		return nil, fmt.Errorf("%w: %s", ErrUserDoesNotExist, email)
+	}
+	return item, nil
+}
+
+// unchangedCondition builds the condition that item's version is still the stored one, with the
+// value it refers to; it expects #version to name the version attribute.
+func unchangedCondition(item map[string]*dynamodb.AttributeValue) (string, map[string]*dynamodb.AttributeValue) {
+	if version, ok := item["version"]; ok {
+		return "#version = :version", map[string]*dynamodb.AttributeValue{":version": version}
+	}
+	return "attribute_not_exists(#version)", nil
+}
+
+// mergeCancellation maps the reasons of a cancelled MergeUsers transaction, whose first action writes
+// the primary, second deletes the duplicate and third, with KeySchemaID, releases its email, to the
+// repository errors.
+func mergeCancellation(primaryEmail, duplicateEmail string, reasons []*dynamodb.CancellationReason) error {
+	for _, reason := range reasons {
+		if aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+			return ErrVersionConflict
+		}
+	}
+	ops := []UserOperation{
+		{Type: OperationUpdate, User: models.User{Email: primaryEmail}},
+		{Type: OperationDelete, User: models.User{Email: duplicateEmail}},
+	}
+	return fmt.Errorf("%w: %s", ErrTransactionCanceled, describeCancellation(ops, reasons))
+}
+
+// MergeUsers merges the user stored under duplicateEmail into the one under primaryEmail and deletes
+// the duplicate, like the DynamoDB version. Both emails are the exact keys of the stored users.
+func (repo *InMemoryUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error) {
+	if primaryEmail == duplicateEmail {
+		return nil, fmt.Errorf("%w: a user cannot be merged into itself", ErrInvalidOperation)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	primary, ok := repo.users[primaryEmail]
+	if !ok || primary.Deleted || expired(primary) {
+		return nil, fmt.Errorf("%w: %s", ErrUserDoesNotExist, primaryEmail)
+	}
+	duplicate, ok := repo.users[duplicateEmail]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUserDoesNotExist, duplicateEmail)
+	}
+	merged := mergedUser(primary, duplicate)
+	repo.users[primaryEmail] = merged
+	delete(repo.users, duplicateEmail)
+	recordChange(ctx, &primary, &merged)
+	recordChange(ctx, &duplicate, nil)
+	return &merged, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+)
+
+func TestMergedUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		primary   models.User
+		duplicate models.User
+		check     func(t *testing.T, merged models.User)
+	}{
+		{
+			name:      "primary wins",
+			primary:   models.User{Email: "a@example.com", FirstName: "Ann", Phone: "+1"},
+			duplicate: models.User{Email: "A@example.com", FirstName: "Anne", Phone: "+2", LastName: "Lee"},
+			check: func(t *testing.T, merged models.User) {
+				if merged.FirstName != "Ann" || merged.Phone != "+1" {
+					t.Errorf("primary fields overwritten: %+v", merged)
+				}
+				if merged.LastName != "Lee" {
+					t.Errorf("lastName = %q, want it filled from the duplicate", merged.LastName)
+				}
+				if merged.Email != "a@example.com" {
+					t.Errorf("email = %q, want the primary's", merged.Email)
+				}
+			},
+		},
+		{
+			name:      "password is not taken from the duplicate",
+			primary:   models.User{Email: "a@example.com"},
+			duplicate: models.User{Email: "A@example.com", PasswordHash: "$2a$10$duplicate"},
+			check: func(t *testing.T, merged models.User) {
+				if merged.PasswordHash != "" {
+					t.Errorf("passwordHash = %q, want none", merged.PasswordHash)
+				}
+			},
+		},
+		{
+			name:      "primary keeps its password",
+			primary:   models.User{Email: "a@example.com", PasswordHash: "$2a$10$primary"},
+			duplicate: models.User{Email: "A@example.com", PasswordHash: "$2a$10$duplicate"},
+			check: func(t *testing.T, merged models.User) {
+				if merged.PasswordHash != "$2a$10$primary" {
+					t.Errorf("passwordHash = %q, want the primary's", merged.PasswordHash)
+				}
+			},
+		},
+		{
+			name:      "metadata is merged key by key",
+			primary:   models.User{Email: "a@example.com", Version: 3, Metadata: map[string]string{"plan": "pro"}},
+			duplicate: models.User{Email: "A@example.com", Metadata: map[string]string{"plan": "free", "team": "x"}},
+			check: func(t *testing.T, merged models.User) {
+				if merged.Metadata["plan"] != "pro" || merged.Metadata["team"] != "x" {
+					t.Errorf("metadata = %v", merged.Metadata)
+				}
+				if merged.Version != 4 {
+					t.Errorf("version = %d, want 4", merged.Version)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, mergedUser(tt.primary, tt.duplicate))
+		})
+	}
+}
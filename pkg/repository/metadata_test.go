@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestMetadataRoundTrips(t *testing.T) {
+	metadata := map[string]string{
+		"plan":           "pro",
+		"billing.region": "eu-west-1",
+		"settings":       `{"theme":{"dark":true}}`, // Nested values are stored as opaque strings
+		"empty":          "",
+	}
+	var stored map[string]*dynamodb.AttributeValue
+	client := &mockDynamoDB{
+		putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			stored = input.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: stored}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	if _, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ann", Metadata: metadata}); err != nil {
+		t.Fatal(err)
+	}
+	attr := stored["metadata"]
+	if attr == nil || attr.M == nil {
+		t.Fatalf("metadata stored as %v, want a map attribute", attr)
+	}
+	for key, value := range metadata {
+		if got := attr.M[key]; got == nil || aws.StringValue(got.S) != value {
+			t.Errorf("metadata[%q] stored as %v, want %q", key, got, value)
+		}
+	}
+
+	fetched, err := repo.FetchUser(context.Background(), "a@example.com", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(fetched.Metadata, metadata) {
+		t.Errorf("metadata = %v, want %v", fetched.Metadata, metadata)
+	}
+}
+
+func TestUpdateUserReplacesMetadata(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   map[string]string
+		wantRemove bool
+	}{
+		{name: "set", metadata: map[string]string{"plan": "pro", "team": "ops"}},
+		{name: "empty", metadata: map[string]string{}, wantRemove: true},
+		{name: "omitted", wantRemove: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := buildUserUpdate(models.User{Email: "a@example.com", FirstName: "Ann", Metadata: tt.metadata}, false, false)
+
+			value, set := update.values[":metadata"]
+			if set == tt.wantRemove {
+				t.Fatalf("update %q sets metadata: %v, want %v", update.expression, set, !tt.wantRemove)
+			}
+			if tt.wantRemove {
+				_, remove, _ := strings.Cut(update.expression, "REMOVE ")
+				var removed bool
+				for _, name := range strings.Split(remove, ",") {
+					removed = removed || aws.StringValue(update.names[strings.TrimSpace(name)]) == "metadata"
+				}
+				if !removed {
+					t.Errorf("update %q does not remove metadata", update.expression)
+				}
+				return
+			}
+			got := make(map[string]string, len(value.M))
+			for key, attr := range value.M {
+				got[key] = aws.StringValue(attr.S)
+			}
+			if !maps.Equal(got, tt.metadata) {
+				t.Errorf("metadata = %v, want %v", got, tt.metadata)
+			}
+		})
+	}
+}
+
+func TestInMemoryUpdateUserReplacesMetadata(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ann", Metadata: map[string]string{"plan": "pro"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.UpdateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Ann", Version: created.Version, Metadata: map[string]string{"team": "ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"team": "ops"}; !maps.Equal(updated.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", updated.Metadata, want)
+	}
+}
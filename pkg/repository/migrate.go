@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// unmigratedFilter matches records written before the attributes stampNewUser sets existed.
+const unmigratedFilter = "attribute_not_exists(createdAt) OR attribute_not_exists(updatedAt) OR " +
+	"attribute_not_exists(version) OR attribute_not_exists(#role) OR attribute_not_exists(normalizedEmail) OR " +
+	"attribute_not_exists(#id) OR attribute_not_exists(searchTokens)"
+
+// migrateUpdate backfills the missing attributes without touching the ones already present.
+const migrateUpdate = "SET createdAt = if_not_exists(createdAt, :now), updatedAt = if_not_exists(updatedAt, :now), " +
+	"version = if_not_exists(version, :one), #role = if_not_exists(#role, :defaultRole), " +
+	"normalizedEmail = if_not_exists(normalizedEmail, :normalizedEmail), " +
+	"searchTokens = if_not_exists(searchTokens, :searchTokens)"
+
+// migrateIDUpdate backfills the ID. With KeySchemaID it is the key, which every user has and no
+// update may set.
+const migrateIDUpdate = ", #id = if_not_exists(#id, :id)"
+
+// MigrateUsers backfills the attributes that records written by older versions lack, giving them
+// the values a new user gets: createdAt and updatedAt (the time of the migration), version 1,
+// the viewer role, the normalized email, a new ID and the search tokens of the names. It returns how
+// many users were updated.
+//
+// Only records missing an attribute are read, and each update is conditional on an attribute still
+// being missing, so re-running the migration (even concurrently) never changes a migrated user.
+// Every updated user is passed to the ChangeRecorder of ctx, if any.
+func (repo *DynamoDBUserRepository) MigrateUsers(ctx context.Context) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(repo.tableName),
+		ProjectionExpression: aws.String("#email, #id, firstName, lastName"),
+		FilterExpression:     aws.String(unmigratedFilter),
+		ExpressionAttributeNames: map[string]*string{
+			"#email": aws.String("email"),
+			"#role":  aws.String("role"),
+			"#id":    aws.String("id"),
+		},
+	}
+
+	now := timestamp()
+	migrated := 0
+	err := repo.scanPages(ctx, "MigrateUsers", input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var user models.User
+			if err := dynamodbattribute.UnmarshalMap(item, &user); err != nil {
+				slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "MigrateUsers"), slog.Any("error", err))
+				return fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			updated, err := repo.migrateUser(ctx, user, now)
+			if err != nil {
+				return err
+			}
+			if updated {
+				migrated++
+			}
+		}
+		return nil
+	})
+	return migrated, err
+}
+
+// migrateUser backfills the missing attributes of one user. It reports false when the user was
+// already migrated, e.g. by a concurrent run.
+func (repo *DynamoDBUserRepository) migrateUser(ctx context.Context, user models.User, now string) (bool, error) {
+	// An empty list is stored for names without words, so the user does not match unmigratedFilter again
+	searchTokens := &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+	for _, token := range SearchTokens(user.FirstName, user.LastName) {
+		searchTokens.L = append(searchTokens.L, &dynamodb.AttributeValue{S: aws.String(token)})
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 repo.userItemKey(user),
+		UpdateExpression:    aws.String(migrateUpdate),
+		ConditionExpression: aws.String("attribute_exists(email) AND (" + unmigratedFilter + ")"),
+		ExpressionAttributeNames: map[string]*string{
+			"#role": aws.String("role"),
+			"#id":   aws.String("id"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":             {S: aws.String(now)},
+			":one":             {N: aws.String("1")},
+			":defaultRole":     {S: aws.String(string(models.RoleViewer))},
+			":normalizedEmail": {S: aws.String(validators.NormalizeEmail(user.Email))},
+			":searchTokens":    searchTokens,
+		},
+	}
+	id := user.ID
+	if !repo.keyedByID() {
+		id = newUserID()
+		input.UpdateExpression = aws.String(migrateUpdate + migrateIDUpdate)
+		input.ExpressionAttributeValues[":id"] = &dynamodb.AttributeValue{S: aws.String(id)}
+	}
+	if changeRecorder(ctx) != nil {
+		input.ReturnValues = aws.String(dynamodb.ReturnValueAllOld)
+	}
+
+	var result *dynamodb.UpdateItemOutput
+	err := repo.withRetry(ctx, "MigrateUsers", func() (err error) {
+		result, err = repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return false, nil
+		}
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "MigrateUsers"), slog.Any("error", err))
+		return false, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	if changeRecorder(ctx) != nil {
+		var before models.User
+		if err := dynamodbattribute.UnmarshalMap(result.Attributes, &before); err != nil {
+			slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "MigrateUsers"), slog.Any("error", err))
+			return true, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+		}
+		after := migratedUser(before, now, id)
+		recordChange(ctx, &before, &after)
+	}
+	return true, nil
+}
+
+// unmigrated reports whether user lacks an attribute MigrateUsers backfills.
+func unmigrated(user models.User) bool {
+	return user.CreatedAt == "" || user.UpdatedAt == "" || user.Version == 0 || user.Role == "" ||
+		user.NormalizedEmail == "" || user.ID == "" || user.SearchTokens == nil
+}
+
+// migratedUser returns user as MigrateUsers leaves it: each missing attribute backfilled, the
+// timestamps with now and the ID with id.
+func migratedUser(user models.User, now, id string) models.User {
+	if user.CreatedAt == "" {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt == "" {
+		user.UpdatedAt = now
+	}
+	if user.Version == 0 {
+		user.Version = 1
+	}
+	if user.Role == "" {
+		user.Role = models.RoleViewer
+	}
+	if user.NormalizedEmail == "" {
+		user.NormalizedEmail = validators.NormalizeEmail(user.Email)
+	}
+	if user.ID == "" {
+		user.ID = id
+	}
+	if user.SearchTokens == nil {
+		// Non-nil even for names without words, like the empty list stored in DynamoDB
+		user.SearchTokens = append([]string{}, SearchTokens(user.FirstName, user.LastName)...)
+	}
+	return user
+}
+
+// MigrateUsers backfills the attributes missing from stored users, like the DynamoDB version,
+// and returns how many users were updated.
+func (repo *InMemoryUserRepository) MigrateUsers(ctx context.Context) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := timestamp()
+	migrated := 0
+	for email, user := range repo.users {
+		if !unmigrated(user) {
+			continue
+		}
+		after := migratedUser(user, now, newUserID())
+		repo.users[email] = after
+		migrated++
+		recordChange(ctx, &user, &after)
+	}
+	return migrated, nil
+}
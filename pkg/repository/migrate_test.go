@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestMigrateUsers(t *testing.T) {
+	pages := [][]models.User{
+		{{Email: "a@example.com", FirstName: "Ann"}, {Email: "b@example.com", FirstName: "Bo"}},
+		{{Email: "c@example.com", FirstName: "Cy"}},
+	}
+	tests := []struct {
+		name            string
+		alreadyMigrated map[string]bool // Users migrated by a concurrent run, failing the condition
+		updateErr       error
+		want            int
+		wantErr         error
+	}{
+		{name: "every user", want: 3},
+		{name: "some already migrated", alreadyMigrated: map[string]bool{"b@example.com": true}, want: 2},
+		{name: "update fails", updateErr: awserr.New("ValidationException", "invalid", nil), wantErr: ErrCouldNotDynamoPutItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := 0
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if aws.StringValue(input.FilterExpression) != unmigratedFilter {
+						t.Errorf("filter = %q, want only unmigrated users", aws.StringValue(input.FilterExpression))
+					}
+					var items []map[string]*dynamodb.AttributeValue
+					for _, user := range pages[scans] {
+						items = append(items, marshalUser(t, user))
+					}
+					scans++
+					output := &dynamodb.ScanOutput{Items: items}
+					if scans < len(pages) {
+						output.LastEvaluatedKey = userKey(pages[scans-1][len(pages[scans-1])-1].Email)
+					}
+					return output, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if condition := aws.StringValue(input.ConditionExpression); !strings.Contains(condition, unmigratedFilter) {
+						t.Errorf("condition = %q, want the update to apply only to unmigrated users", condition)
+					}
+					if !strings.Contains(aws.StringValue(input.UpdateExpression), "createdAt = if_not_exists(createdAt, :now)") {
+						t.Errorf("update %q overwrites createdAt", aws.StringValue(input.UpdateExpression))
+					}
+					if input.ExpressionAttributeValues[":id"] == nil {
+						t.Error("no ID is backfilled")
+					}
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					if tt.alreadyMigrated[aws.StringValue(input.Key["email"].S)] {
+						return nil, errConditionFailed
+					}
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			migrated, err := repo.MigrateUsers(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if migrated != tt.want {
+				t.Errorf("migrated = %d, want %d", migrated, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateUsersKeyedByID(t *testing.T) {
+	client := &mockDynamoDB{
+		scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+				marshalUser(t, models.User{Email: "a@example.com", ID: "user-1"}),
+			}}, nil
+		},
+		updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if got := aws.StringValue(input.Key["id"].S); got != "user-1" {
+				t.Errorf("key id = %q, want user-1", got)
+			}
+			// The ID is the key, so the update must not set it
+			if _, ok := input.ExpressionAttributeValues[":id"]; ok {
+				t.Errorf("update %q sets the key", aws.StringValue(input.UpdateExpression))
+			}
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{KeySchema: KeySchemaID})
+
+	if migrated, err := repo.MigrateUsers(context.Background()); err != nil || migrated != 1 {
+		t.Errorf("MigrateUsers = %d, %v, want 1", migrated, err)
+	}
+}
+
+func TestInMemoryMigrateUsers(t *testing.T) {
+	const createdAt = "2020-01-01T00:00:00Z"
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	migrated, err := repo.CreateUser(context.Background(), models.User{Email: "new@example.com", FirstName: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Records written by older versions, one of them with part of the attributes
+	repo.users["old@example.com"] = models.User{Email: "old@example.com", FirstName: "Ada", LastName: "Lee"}
+	repo.users["partial@example.com"] = models.User{Email: "partial@example.com", CreatedAt: createdAt, Version: 4, Role: models.RoleAdmin}
+
+	count, err := repo.MigrateUsers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("migrated = %d, want 2", count)
+	}
+	for email, user := range repo.users {
+		if unmigrated(user) {
+			t.Errorf("%s is still unmigrated: %+v", email, user)
+		}
+	}
+	if old := repo.users["old@example.com"]; old.Version != 1 || old.Role != models.RoleViewer || len(old.SearchTokens) == 0 {
+		t.Errorf("old user = %+v, want version 1, the viewer role and search tokens", old)
+	}
+	if partial := repo.users["partial@example.com"]; partial.CreatedAt != createdAt || partial.Version != 4 || partial.Role != models.RoleAdmin {
+		t.Errorf("partial user = %+v, want its attributes kept", partial)
+	}
+	if current := repo.users["new@example.com"]; current.UpdatedAt != migrated.UpdatedAt || current.Version != migrated.Version {
+		t.Errorf("migrated user changed: %+v", current)
+	}
+
+	// Re-running finds nothing left to migrate
+	if count, err := repo.MigrateUsers(context.Background()); err != nil || count != 0 {
+		t.Errorf("second MigrateUsers = %d, %v, want 0", count, err)
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// testTable is the user table the repositories under test are configured with.
+const testTable = "users"
+
+// errConditionFailed is what DynamoDB answers a write whose condition does not hold.
+var errConditionFailed = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+
+// mockDynamoDB implements dynamodbiface.DynamoDBAPI with a function per call a test expects.
+// Calling any other method panics on the nil embedded interface.
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	getItem            func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	putItem            func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	updateItem         func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	deleteItem         func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	query              func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	scan               func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	batchGetItem       func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	batchWriteItem     func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	transactWriteItems func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+	describeTable      func(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+}
+
+func (m *mockDynamoDB) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return m.getItem(input)
+}
+
+func (m *mockDynamoDB) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return m.putItem(input)
+}
+
+func (m *mockDynamoDB) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(input)
+}
+
+func (m *mockDynamoDB) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(input)
+}
+
+func (m *mockDynamoDB) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	return m.query(input)
+}
+
+func (m *mockDynamoDB) ScanWithContext(_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	return m.scan(input)
+}
+
+func (m *mockDynamoDB) BatchGetItemWithContext(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	return m.batchGetItem(input)
+}
+
+func (m *mockDynamoDB) BatchWriteItemWithContext(_ aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.batchWriteItem(input)
+}
+
+func (m *mockDynamoDB) TransactWriteItemsWithContext(_ aws.Context, input *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.transactWriteItems(input)
+}
+
+func (m *mockDynamoDB) DescribeTableWithContext(_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(input)
+}
+
+// marshalUser returns user as a DynamoDB item.
+func marshalUser(t *testing.T, user models.User) map[string]*dynamodb.AttributeValue {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+// batchGetFrom answers BatchGetItem calls with the stored users among the requested keys.
+func batchGetFrom(t *testing.T, stored ...models.User) func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	byEmail := make(map[string]models.User, len(stored))
+	for _, user := range stored {
+		byEmail[user.Email] = user
+	}
+	return func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+		var items []map[string]*dynamodb.AttributeValue
+		for _, key := range input.RequestItems[testTable].Keys {
+			if user, ok := byEmail[aws.StringValue(key["email"].S)]; ok {
+				items = append(items, marshalUser(t, user))
+			}
+		}
+		return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{testTable: items}}, nil
+	}
+}
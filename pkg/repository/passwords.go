@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPassword replaces a plaintext user.Password with its bcrypt hash in user.PasswordHash,
+// so the plaintext is never stored or echoed back. Users without a password are left as is.
+func hashPassword(user *models.User) error {
+	if user.Password == "" {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCouldNotHashPassword, err)
+	}
+	user.PasswordHash = string(hash)
+	user.Password = ""
+	return nil
+}
+
+// checkPassword compares password with the stored hash of user. Missing users, users without
+// a password and wrong passwords all yield the same ErrInvalidCredentials, so callers cannot
+// learn which emails exist.
+func checkPassword(user *models.User, password string) error {
+	if user == nil || user.PasswordHash == "" {
+		// Spend the same time as a real comparison to avoid leaking which users exist
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// dummyPasswordHash is compared against when there is no stored hash. It is computed on first
+// use rather than at init, so cold starts that never verify a password don't pay for it.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("no password set"), bcrypt.DefaultCost)
+	return hash
+})
+
+// VerifyPassword checks a login attempt against the stored password hash of the user.
+// It returns nil on success and ErrInvalidCredentials otherwise.
+func (repo *DynamoDBUserRepository) VerifyPassword(ctx context.Context, email, password string) error {
+	user, err := repo.FetchUser(ctx, email, FetchOptions{ConsistentRead: true})
+	if err != nil {
+		return err
+	}
+	return checkPassword(user, password)
+}
+
+// VerifyPassword checks a login attempt against the stored password hash of the user.
+func (repo *InMemoryUserRepository) VerifyPassword(ctx context.Context, email, password string) error {
+	user, err := repo.FetchUser(ctx, email, FetchOptions{})
+	if err != nil {
+		return err
+	}
+	return checkPassword(user, password)
+}
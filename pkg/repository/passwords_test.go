@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateUserStoresOnlyThePasswordHash(t *testing.T) {
+	var stored map[string]*dynamodb.AttributeValue
+	client := &mockDynamoDB{
+		putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			stored = input.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Password != "" {
+		t.Error("plaintext password returned")
+	}
+	if _, ok := stored["password"]; ok {
+		t.Error("plaintext password stored")
+	}
+	hash := aws.StringValue(stored["passwordHash"].S)
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret123")); err != nil {
+		t.Errorf("stored hash %q does not match the password: %v", hash, err)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "correct password", email: "a@example.com", password: "secret123"},
+		{name: "mixed-case email", email: "A@Example.com", password: "secret123"},
+		{name: "wrong password", email: "a@example.com", password: "secret124", wantErr: ErrInvalidCredentials},
+		{name: "user without a password", email: "b@example.com", password: "secret123", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", email: "nobody@example.com", password: "secret123", wantErr: ErrInvalidCredentials},
+	}
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	for _, user := range []models.User{{Email: "a@example.com", Password: "secret123"}, {Email: "b@example.com"}} {
+		if _, err := repo.CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.VerifyPassword(context.Background(), tt.email, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRehashesPassword(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", Password: "secret123"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", Password: "changed456", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.VerifyPassword(ctx, "a@example.com", "secret123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password: err = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := repo.VerifyPassword(ctx, "a@example.com", "changed456"); err != nil {
+		t.Errorf("new password: err = %v, want none", err)
+	}
+}
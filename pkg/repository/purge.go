@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DeleteAllUsers removes every user from the table, including soft-deleted ones, and returns
+// how many were removed. It is meant for test teardown and refuses to run unless
+// DynamoDBOptions.AllowDestructiveOps is set.
+//
+// The table is scanned page by page (reading only the keys) and each page is removed with
+// BatchWriteItem, so tables of any size are fully cleared. With KeySchemaID the email table is
+// cleared the same way afterwards. With a ChangeRecorder in ctx, the users are read in full instead,
+// and every deleted user is passed to it.
+func (repo *DynamoDBUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	if !repo.allowDestructiveOps {
+		return 0, ErrDestructiveOpsDisabled
+	}
+
+	names := expressionNames{}
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(repo.tableName),
+		ProjectionExpression:     aws.String(names.list(repo.keyAttributes())),
+		ExpressionAttributeNames: names,
+	}
+	if changeRecorder(ctx) != nil {
+		// The deleted users are recorded in full
+		input.ProjectionExpression, input.ExpressionAttributeNames = nil, nil
+	}
+
+	deleted := 0
+	err := repo.scanPages(ctx, "DeleteAllUsers", input, func(page *dynamodb.ScanOutput) error {
+		items := make(map[string]map[string]*dynamodb.AttributeValue, len(page.Items))
+		keys := make([]map[string]*dynamodb.AttributeValue, len(page.Items))
+		for i, item := range page.Items {
+			keys[i] = repo.itemKey(item)
+			items[keyString(keys[i])] = item
+		}
+		removed, err := repo.deleteItems(ctx, "DeleteAllUsers", repo.tableName, keys)
+		deleted += len(removed)
+		if changeRecorder(ctx) != nil {
+			for _, key := range removed {
+				var user models.User
+				if err := dynamodbattribute.UnmarshalMap(items[keyString(key)], &user); err != nil {
+					slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "DeleteAllUsers"), slog.Any("error", err))
+					return fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+				}
+				recordChange(ctx, &user, nil)
+			}
+		}
+		return err
+	})
+	if err != nil || !repo.keyedByID() {
+		return deleted, err
+	}
+
+	input = &dynamodb.ScanInput{
+		TableName:                aws.String(repo.emailTableName),
+		ProjectionExpression:     aws.String("#email"),
+		ExpressionAttributeNames: map[string]*string{"#email": aws.String("email")},
+	}
+	err = repo.scanPages(ctx, "DeleteAllUsers", input, func(page *dynamodb.ScanOutput) error {
+		_, err := repo.deleteItems(ctx, "DeleteAllUsers", repo.emailTableName, page.Items)
+		return err
+	})
+	return deleted, err
+}
+
+// scanDeadlineReserve is the time left before the context deadline at which PurgeDeletedUsers and
+// UpdateUsersWhere stop starting new pages, so the invocation can still finish and report its progress.
+const scanDeadlineReserve = 2 * time.Second
+
+// PurgeResult reports the outcome of PurgeDeletedUsers.
+type PurgeResult struct {
+	// Purged is how many soft-deleted users were removed.
+	Purged int
+	// Complete is false when the run stopped early to stay within its deadline; the remaining
+	// users are picked up by the next run.
+	Complete bool
+}
+
+// PurgeDeletedUsers hard-deletes the soft-deleted users whose deletedAt is before deletedBefore.
+//
+// The table is scanned page by page for matching records (reading only the keys) and each record is
+// removed with a DeleteItem conditioned on the same filter, so a user restored or recreated between
+// the scan and its delete is skipped rather than lost. With KeySchemaID the reservation of the
+// user's email is deleted in the same transaction. When the context's deadline is near, no
+// further pages are started and the result is reported as incomplete; since purged records are
+// gone, the next run simply scans again. With a ChangeRecorder in ctx, the users are read in full
+// instead, and every purged user is passed to it.
+func (repo *DynamoDBUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (PurgeResult, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(repo.tableName),
+		ProjectionExpression: aws.String("#email, #id"),
+		FilterExpression:     aws.String("#deleted = :true AND #deletedAt < :deletedBefore"),
+		ExpressionAttributeNames: map[string]*string{
+			"#email":     aws.String("email"),
+			"#id":        aws.String("id"),
+			"#deleted":   aws.String("deleted"),
+			"#deletedAt": aws.String("deletedAt"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":true":          {BOOL: aws.Bool(true)},
+			":deletedBefore": {S: aws.String(deletedBefore.UTC().Format(time.RFC3339))},
+		},
+	}
+	if changeRecorder(ctx) != nil {
+		// The purged users are recorded in full
+		input.ProjectionExpression = nil
+		delete(input.ExpressionAttributeNames, "#email")
+		delete(input.ExpressionAttributeNames, "#id")
+	}
+
+	var result PurgeResult
+	if nearDeadline(ctx) {
+		return result, nil
+	}
+	err := repo.scanPages(ctx, "PurgeDeletedUsers", input, func(page *dynamodb.ScanOutput) error {
+		purged, err := repo.purgeItems(ctx, page.Items, input.FilterExpression, input.ExpressionAttributeValues)
+		result.Purged += purged
+		if err != nil {
+			return err
+		}
+		if len(page.LastEvaluatedKey) > 0 && nearDeadline(ctx) {
+			return errNearDeadline
+		}
+		return nil
+	})
+	if errors.Is(err, errNearDeadline) {
+		return result, nil
+	}
+	result.Complete = err == nil
+	return result, err
+}
+
+// deleteItems removes the items with the given keys from table using BatchWriteItem, in chunks of
+// 25, and returns the keys of the removed ones. Items still unprocessed after the retries fail the call.
+func (repo *DynamoDBUserRepository) deleteItems(ctx context.Context, operation, table string, keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	var deleted []map[string]*dynamodb.AttributeValue
+	for start := 0; start < len(keys); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(keys))
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: key},
+			})
+		}
+
+		unprocessed, err := repo.batchWrite(ctx, table, requests)
+		if err != nil {
+			slog.Error("DynamoDB BatchWriteItem failed", slog.String("operation", operation), slog.Any("error", err))
+			return deleted, fmt.Errorf("%w: %w", ErrCouldNotBatchWriteItems, err)
+		}
+		pending := make(map[string]bool, len(unprocessed))
+		for _, request := range unprocessed {
+			pending[keyString(request.DeleteRequest.Key)] = true
+		}
+		for _, key := range keys[start:end] {
+			if !pending[keyString(key)] {
+				deleted = append(deleted, key)
+			}
+		}
+		if len(unprocessed) > 0 {
+			return deleted, fmt.Errorf("%w: %d items were not processed", ErrCouldNotBatchWriteItems, len(unprocessed))
+		}
+	}
+	return deleted, nil
+}
+
+// keyString identifies a key of string attributes, as a map index.
+func keyString(key map[string]*dynamodb.AttributeValue) string {
+	attrs := slices.Sorted(maps.Keys(key))
+	for i, attr := range attrs {
+		attrs[i] = attr + "=" + aws.StringValue(key[attr].S)
+	}
+	return strings.Join(attrs, "\x00")
+}
+
+// purgeItems removes the given items one at a time, each conditioned on condition still holding, and
+// returns how many were removed. Items that no longer match are skipped.
+func (repo *DynamoDBUserRepository) purgeItems(ctx context.Context, items []map[string]*dynamodb.AttributeValue, condition *string, values map[string]*dynamodb.AttributeValue) (int, error) {
+	purged := 0
+	for _, item := range items {
+		input := &dynamodb.DeleteItemInput{
+			TableName:           aws.String(repo.tableName),
+			Key:                 repo.itemKey(item),
+			ConditionExpression: condition,
+			ExpressionAttributeNames: map[string]*string{
+				"#deleted":   aws.String("deleted"),
+				"#deletedAt": aws.String("deletedAt"),
+			},
+			ExpressionAttributeValues: values,
+		}
+		var err error
+		if repo.keyedByID() {
+			err = repo.purgeUserByID(ctx, item, input)
+		} else {
+			err = repo.withRetry(ctx, "PurgeDeletedUsers", func() error {
+				_, err := repo.client.DeleteItemWithContext(ctx, input)
+				return err
+			})
+		}
+		if isConditionalCheckFailed(err) {
+			slog.Info("Skipped purging a user that was restored or recreated", slog.String("operation", "PurgeDeletedUsers"))
+			continue
+		}
+		if err != nil {
+			slog.Error("DynamoDB DeleteItem failed", slog.String("operation", "PurgeDeletedUsers"), slog.Any("error", err))
+			return purged, fmt.Errorf("%w: %w", ErrCouldNotDeleteItem, err)
+		}
+		purged++
+		if changeRecorder(ctx) != nil {
+			var user models.User
+			if err := dynamodbattribute.UnmarshalMap(item, &user); err != nil {
+				slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "PurgeDeletedUsers"), slog.Any("error", err))
+				return purged, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			recordChange(ctx, &user, nil)
+		}
+	}
+	return purged, nil
+}
+
+// purgeUserByID runs the delete of PurgeDeletedUsers with KeySchemaID, in a transaction with the
+// release of the user's email, which is only left out when the user expired and its email was taken
+// by another user since. A failed condition on the user is reported as a ConditionalCheckFailedException.
+func (repo *DynamoDBUserRepository) purgeUserByID(ctx context.Context, item map[string]*dynamodb.AttributeValue, input *dynamodb.DeleteItemInput) error {
+	items := []*dynamodb.TransactWriteItem{{Delete: &dynamodb.Delete{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}}
+	if email, id := item["email"], item["id"]; email != nil && id != nil {
+		items = append(items, repo.releaseEmail(aws.StringValue(email.S), aws.StringValue(id.S)))
+	}
+	err := repo.transactWrite(ctx, "PurgeDeletedUsers", items)
+	switch {
+	case conditionFailedAt(err, 0):
+		return &dynamodb.ConditionalCheckFailedException{}
+	case conditionFailedAt(err, 1):
+		return repo.withRetry(ctx, "PurgeDeletedUsers", func() error {
+			_, err := repo.client.DeleteItemWithContext(ctx, input)
+			return err
+		})
+	}
+	return err
+}
+
+// PurgeDeletedUsers hard-deletes the soft-deleted users whose deletedAt is before deletedBefore.
+// The in-memory repository always completes in one run.
+func (repo *InMemoryUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (PurgeResult, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	cutoff := deletedBefore.UTC().Format(time.RFC3339)
+	result := PurgeResult{Complete: true}
+	for email, user := range repo.users {
+		if user.Deleted && user.DeletedAt < cutoff {
+			delete(repo.users, email)
+			result.Purged++
+			recordChange(ctx, &user, nil)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		scanned     []string
+		restored    []string // Users restored after the scan, whose conditional delete fails
+		deleteErr   error
+		wantPurged  int
+		wantDeleted []string
+		wantErr     error
+	}{
+		{
+			name:        "purges every scanned user",
+			scanned:     []string{"a@example.com", "b@example.com"},
+			wantPurged:  2,
+			wantDeleted: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			name:        "skips a user restored after the scan",
+			scanned:     []string{"a@example.com", "b@example.com"},
+			restored:    []string{"a@example.com"},
+			wantPurged:  1,
+			wantDeleted: []string{"b@example.com"},
+		},
+		{
+			name:      "fails on other errors",
+			scanned:   []string{"a@example.com"},
+			deleteErr: awserr.New("ValidationException", "invalid", nil),
+			wantErr:   ErrCouldNotDeleteItem,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					var items []map[string]*dynamodb.AttributeValue
+					for _, email := range tt.scanned {
+						items = append(items, userKey(email))
+					}
+					return &dynamodb.ScanOutput{Items: items}, nil
+				},
+				deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); got != "#deleted = :true AND #deletedAt < :deletedBefore" {
+						t.Errorf("condition = %q", got)
+					}
+					if got := aws.StringValue(input.ExpressionAttributeValues[":deletedBefore"].S); got != "2024-01-01T00:00:00Z" {
+						t.Errorf(":deletedBefore = %q", got)
+					}
+					email := aws.StringValue(input.Key["email"].S)
+					if tt.deleteErr != nil {
+						return nil, tt.deleteErr
+					}
+					if slices.Contains(tt.restored, email) {
+						return nil, errConditionFailed
+					}
+					deleted = append(deleted, email)
+					return &dynamodb.DeleteItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			result, err := repo.PurgeDeletedUsers(context.Background(), cutoff)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if result.Purged != tt.wantPurged {
+				t.Errorf("purged = %d, want %d", result.Purged, tt.wantPurged)
+			}
+			if !slices.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if result.Complete != (tt.wantErr == nil) {
+				t.Errorf("complete = %v", result.Complete)
+			}
+		})
+	}
+}
+
+func TestDeleteAllUsersRecordsDeletedUsers(t *testing.T) {
+	tests := []struct {
+		name         string
+		unprocessed  []string // Users DynamoDB never gets to delete
+		wantRecorded []string
+		wantErr      error
+	}{
+		{name: "every user", wantRecorded: []string{"a@example.com", "b@example.com"}},
+		{name: "unprocessed user", unprocessed: []string{"a@example.com"}, wantRecorded: []string{"b@example.com"}, wantErr: ErrCouldNotBatchWriteItems},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if input.ProjectionExpression != nil {
+						t.Errorf("projection = %q, want whole users", aws.StringValue(input.ProjectionExpression))
+					}
+					return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+						marshalUser(t, models.User{Email: "a@example.com", FirstName: "A"}),
+						marshalUser(t, models.User{Email: "b@example.com", FirstName: "B"}),
+					}}, nil
+				},
+				batchWriteItem: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+					var unprocessed []*dynamodb.WriteRequest
+					for _, request := range input.RequestItems[testTable] {
+						if len(request.DeleteRequest.Key) != 1 {
+							t.Errorf("key = %v", request.DeleteRequest.Key)
+						}
+						if slices.Contains(tt.unprocessed, aws.StringValue(request.DeleteRequest.Key["email"].S)) {
+							// DynamoDB answers with copies of the requests
+							unprocessed = append(unprocessed, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{
+								Key: userKey(aws.StringValue(request.DeleteRequest.Key["email"].S)),
+							}})
+						}
+					}
+					return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{testTable: unprocessed}}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{AllowDestructiveOps: true})
+
+			var recorded []string
+			ctx := WithChangeRecorder(context.Background(), func(before, after *models.User) {
+				if before == nil || after != nil || before.FirstName == "" {
+					t.Errorf("recorded %+v -> %+v", before, after)
+					return
+				}
+				recorded = append(recorded, before.Email)
+			})
+			deleted, err := repo.DeleteAllUsers(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if deleted != len(tt.wantRecorded) || !slices.Equal(recorded, tt.wantRecorded) {
+				t.Errorf("deleted %d, recorded %v, want %v", deleted, recorded, tt.wantRecorded)
+			}
+		})
+	}
+}
+
+func TestInMemoryDeleteAllUsers(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     bool
+		wantDeleted int
+		wantLeft    int
+		wantErr     error
+	}{
+		{name: "enabled", allowed: true, wantDeleted: 3},
+		{name: "disabled", wantLeft: 3, wantErr: ErrDestructiveOpsDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewInMemoryUserRepository(DynamoDBOptions{AllowDestructiveOps: tt.allowed})
+			ctx := context.Background()
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				if _, err := repo.CreateUser(ctx, models.User{Email: email}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			deleted, err := repo.DeleteAllUsers(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted = %d, want %d", deleted, tt.wantDeleted)
+			}
+			users, _, err := repo.FetchUsers(ctx, 10, "", FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.wantLeft {
+				t.Errorf("%d users left, want %d", len(users), tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestDeleteAllUsersScansEveryPage(t *testing.T) {
+	pages := [][]string{{"a@example.com", "b@example.com"}, {"c@example.com"}}
+	var scans int
+	var deletedEmails []string
+	client := &mockDynamoDB{
+		scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			page := scans
+			scans++
+			if page > 0 && input.ExclusiveStartKey == nil {
+				t.Errorf("scan %d did not continue from the previous page", page)
+			}
+			output := &dynamodb.ScanOutput{}
+			for _, email := range pages[page] {
+				output.Items = append(output.Items, userKey(email))
+			}
+			if page < len(pages)-1 {
+				output.LastEvaluatedKey = userKey(pages[page][len(pages[page])-1])
+			}
+			return output, nil
+		},
+		batchWriteItem: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, request := range input.RequestItems[testTable] {
+				deletedEmails = append(deletedEmails, aws.StringValue(request.DeleteRequest.Key["email"].S))
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{AllowDestructiveOps: true})
+
+	deleted, err := repo.DeleteAllUsers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 || scans != 2 {
+		t.Errorf("deleted %d users in %d scans, want 3 in 2", deleted, scans)
+	}
+	if want := []string{"a@example.com", "b@example.com", "c@example.com"}; !slices.Equal(deletedEmails, want) {
+		t.Errorf("deleted %v, want %v", deletedEmails, want)
+	}
+}
+
+func TestPurgeDeletedUsersPages(t *testing.T) {
+	pages := [][]string{{"a@example.com", "b@example.com"}, {"c@example.com"}, {"d@example.com"}}
+	tests := []struct {
+		name         string
+		timeLeft     time.Duration // Before the context deadline; none when zero
+		slowDeletes  bool          // Each delete runs the clock 100ms closer to the deadline
+		wantScans    int
+		wantPurged   int
+		wantComplete bool
+	}{
+		{name: "no deadline", wantScans: 3, wantPurged: 4, wantComplete: true},
+		{name: "deadline far off", timeLeft: time.Minute, wantScans: 3, wantPurged: 4, wantComplete: true},
+		{name: "deadline reached during the first page", timeLeft: scanDeadlineReserve + 100*time.Millisecond, slowDeletes: true, wantScans: 1, wantPurged: 2},
+		{name: "deadline already near", timeLeft: scanDeadlineReserve / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := 0
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if scans > 0 && input.ExclusiveStartKey == nil {
+						t.Error("page read without the previous page's LastEvaluatedKey")
+					}
+					var items []map[string]*dynamodb.AttributeValue
+					for _, email := range pages[scans] {
+						items = append(items, userKey(email))
+					}
+					scans++
+					output := &dynamodb.ScanOutput{Items: items}
+					if scans < len(pages) {
+						output.LastEvaluatedKey = items[len(items)-1]
+					}
+					return output, nil
+				},
+				deleteItem: func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					if tt.slowDeletes {
+						time.Sleep(100 * time.Millisecond)
+					}
+					return &dynamodb.DeleteItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+			ctx := context.Background()
+			if tt.timeLeft > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeLeft)
+				defer cancel()
+			}
+
+			result, err := repo.PurgeDeletedUsers(ctx, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scans != tt.wantScans || result.Purged != tt.wantPurged || result.Complete != tt.wantComplete {
+				t.Errorf("%d scans, result = %+v, want %d scans, %d purged, complete %v",
+					scans, result, tt.wantScans, tt.wantPurged, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestInMemoryPurgeDeletedUsers(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+	for _, email := range []string{"old@example.com", "recent@example.com", "live@example.com"} {
+		if _, err := repo.CreateUser(context.Background(), models.User{Email: email, FirstName: "Ann"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, email := range []string{"old@example.com", "recent@example.com"} {
+		if _, err := repo.DeleteUser(context.Background(), email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := repo.users["old@example.com"]
+	old.DeletedAt = "2020-01-01T00:00:00Z"
+	repo.users["old@example.com"] = old
+
+	result, err := repo.PurgeDeletedUsers(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (PurgeResult{Purged: 1, Complete: true}) {
+		t.Errorf("result = %+v, want 1 purged", result)
+	}
+	left := slices.Sorted(maps.Keys(repo.users))
+	if want := []string{"live@example.com", "recent@example.com"}; !slices.Equal(left, want) {
+		t.Errorf("users left = %v, want %v", left, want)
+	}
+}
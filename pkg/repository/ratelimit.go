@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// RateLimitOptions configures the token bucket of every caller.
+type RateLimitOptions struct {
+	// RequestsPerSecond is the rate at which a caller's bucket refills.
+	RequestsPerSecond int
+	// Burst is the bucket capacity, i.e. how many requests a caller may make at once after idling.
+	Burst int
+}
+
+// RateLimiter decides whether a caller may make another request.
+type RateLimiter interface {
+	// Allow takes a token from the bucket of key. When the bucket is empty it returns false
+	// and how long the caller should wait before the next token is available.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// tokenBucket is the stored state of one caller's bucket.
+type tokenBucket struct {
+	Key       string  `json:"rateLimitKey"`
+	Tokens    float64 `json:"tokens"`
+	UpdatedAt int64   `json:"updatedAt"` // Unix milliseconds of the last refill
+	ExpiresAt int64   `json:"expiresAt"` // Unix seconds; the table's TTL attribute
+	Version   int64   `json:"version"`   // Incremented on every write, for optimistic locking
+}
+
+// take refills the bucket for the time elapsed since its last update and then tries to take a token.
+// A bucket that has never been used starts full.
+func (b *tokenBucket) take(now time.Time, opts RateLimitOptions) (bool, time.Duration) {
+	rate := float64(opts.RequestsPerSecond)
+	capacity := float64(opts.Burst)
+	if b.UpdatedAt == 0 {
+		b.Tokens = capacity
+	} else if elapsed := now.UnixMilli() - b.UpdatedAt; elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+rate*float64(elapsed)/1000)
+	}
+	b.UpdatedAt = now.UnixMilli()
+
+	// Once the bucket has had time to refill completely its item carries no information
+	refill := time.Duration(capacity / rate * float64(time.Second))
+	b.ExpiresAt = now.Add(refill).Add(time.Minute).Unix()
+
+	if b.Tokens < 1 {
+		wait := time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+	b.Tokens--
+	return true, 0
+}
+
+// DynamoDBRateLimiter implements RateLimiter with one item per caller in a dedicated DynamoDB table,
+// so the limit holds across concurrent Lambda instances. The table is keyed on rateLimitKey (string)
+// and should have TTL enabled on expiresAt.
+type DynamoDBRateLimiter struct {
+	client        dynamodbiface.DynamoDBAPI
+	tableName     string
+	retryStrategy RetryStrategy
+	opts          RateLimitOptions
+}
+
+// NewDynamoDBRateLimiter creates a new DynamoDBRateLimiter instance.
+// Only the retry repository options are relevant; the table is retried with the on-demand strategy.
+func NewDynamoDBRateLimiter(client dynamodbiface.DynamoDBAPI, tableName string, opts RateLimitOptions, repoOpts DynamoDBOptions) *DynamoDBRateLimiter {
+	return &DynamoDBRateLimiter{
+		client:        client,
+		tableName:     tableName,
+		retryStrategy: RetryStrategyFor(dynamodb.BillingModePayPerRequest, retryOverrides(repoOpts)),
+		opts:          opts,
+	}
+}
+
+// Allow reads the caller's bucket, takes a token and writes it back on the condition that no
+// concurrent request updated it in between. Lost races are retried with the fresh state.
+func (limiter *DynamoDBRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	for attempt := 1; ; attempt++ {
+		bucket, err := limiter.getBucket(ctx, key)
+		if err != nil {
+			return false, 0, err
+		}
+		allowed, wait := bucket.take(time.Now(), limiter.opts)
+		if !allowed {
+			return false, wait, nil
+		}
+
+		err = limiter.putBucket(ctx, bucket)
+		if err == nil {
+			return true, 0, nil
+		}
+		if !errors.Is(err, ErrRateLimitConflict) || attempt >= limiter.retryStrategy.MaxAttempts {
+			return false, 0, err
+		}
+	}
+}
+
+// getBucket retrieves the bucket for key, returning an empty bucket if it does not exist yet.
+func (limiter *DynamoDBRateLimiter) getBucket(ctx context.Context, key string) (*tokenBucket, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"rateLimitKey": {S: aws.String(key)},
+		},
+		TableName:      aws.String(limiter.tableName),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var result *dynamodb.GetItemOutput
+	err := retry(ctx, limiter.retryStrategy, "GetRateLimitBucket", func() (err error) {
+		result, err = limiter.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", "GetRateLimitBucket"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+
+	bucket := &tokenBucket{Key: key}
+	if result.Item == nil {
+		return bucket, nil
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, bucket); err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "GetRateLimitBucket"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	return bucket, nil
+}
+
+// putBucket stores bucket with an incremented version if the stored item still has the version
+// it was read with (or does not exist for a new bucket), failing with ErrRateLimitConflict otherwise.
+func (limiter *DynamoDBRateLimiter) putBucket(ctx context.Context, bucket *tokenBucket) error {
+	previous := bucket.Version
+	bucket.Version++
+	av, err := dynamodbattribute.MarshalMap(bucket)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "PutRateLimitBucket"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String(limiter.tableName),
+	}
+	if previous == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(rateLimitKey)")
+	} else {
+		input.ConditionExpression = aws.String("version = :previous")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":previous": {N: aws.String(strconv.FormatInt(previous, 10))},
+		}
+	}
+
+	err = retry(ctx, limiter.retryStrategy, "PutRateLimitBucket", func() error {
+		_, err := limiter.client.PutItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrRateLimitConflict
+		}
+		slog.Error("DynamoDB PutItem failed", slog.String("operation", "PutRateLimitBucket"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return nil
+}
+
+// InMemoryRateLimiter implements RateLimiter with a map, for local development.
+// Its buckets are per process, so it does not limit across Lambda instances.
+type InMemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	opts    RateLimitOptions
+}
+
+// NewInMemoryRateLimiter creates an InMemoryRateLimiter with empty buckets.
+func NewInMemoryRateLimiter(opts RateLimitOptions) *InMemoryRateLimiter {
+	return &InMemoryRateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		opts:    opts,
+	}
+}
+
+// Allow takes a token from the bucket of key.
+func (limiter *InMemoryRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{Key: key}
+		limiter.buckets[key] = bucket
+	}
+	allowed, wait := bucket.take(time.Now(), limiter.opts)
+	return allowed, wait, nil
+}
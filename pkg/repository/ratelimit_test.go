@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	opts := RateLimitOptions{RequestsPerSecond: 2, Burst: 3}
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		at        time.Duration // When the last request arrives; the others all arrive at start
+		wantAllow []bool
+		wantWait  time.Duration // Wait reported for the last request
+	}{
+		{name: "burst within capacity", wantAllow: []bool{true, true, true}},
+		{name: "burst over capacity", wantAllow: []bool{true, true, true, false, false}, wantWait: 500 * time.Millisecond},
+		{name: "refills over time", at: 500 * time.Millisecond, wantAllow: []bool{true, true, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &tokenBucket{Key: "ip#203.0.113.7"}
+			var wait time.Duration
+			for i, want := range tt.wantAllow {
+				now := start
+				if i == len(tt.wantAllow)-1 {
+					now = now.Add(tt.at)
+				}
+				var allowed bool
+				allowed, wait = bucket.take(now, opts)
+				if allowed != want {
+					t.Fatalf("request %d allowed = %v, want %v", i+1, allowed, want)
+				}
+			}
+			if wait != tt.wantWait {
+				t.Errorf("wait = %v, want %v", wait, tt.wantWait)
+			}
+			if want := start.Add(tt.at).Add(1500 * time.Millisecond).Add(time.Minute).Unix(); bucket.ExpiresAt != want {
+				t.Errorf("expiresAt = %d, want %d", bucket.ExpiresAt, want)
+			}
+		})
+	}
+}
+
+func TestInMemoryRateLimiterKeepsCallersApart(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(RateLimitOptions{RequestsPerSecond: 1, Burst: 2})
+	ctx := context.Background()
+	for i, want := range []bool{true, true, false} {
+		allowed, wait, err := limiter.Allow(ctx, "sub#a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != want {
+			t.Errorf("request %d allowed = %v, want %v", i+1, allowed, want)
+		}
+		if !allowed && wait <= 0 {
+			t.Errorf("request %d wait = %v, want a positive wait", i+1, wait)
+		}
+	}
+	if allowed, _, _ := limiter.Allow(ctx, "sub#b@example.com"); !allowed {
+		t.Error("another caller was limited")
+	}
+}
+
+func TestDynamoDBRateLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    *tokenBucket
+		conflicts int // PutItem calls that lose a race before one succeeds
+		wantAllow bool
+		wantPuts  int
+		wantErr   error
+	}{
+		{name: "new caller", wantAllow: true, wantPuts: 1},
+		{
+			name:      "empty bucket",
+			stored:    &tokenBucket{Tokens: 0, UpdatedAt: time.Now().Add(time.Hour).UnixMilli(), Version: 4},
+			wantAllow: false,
+		},
+		{name: "lost race is retried", conflicts: 1, wantAllow: true, wantPuts: 2},
+		{name: "races lost on every attempt", conflicts: 3, wantPuts: 3, wantErr: ErrRateLimitConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			puts := 0
+			client := &mockDynamoDB{
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					if !aws.BoolValue(input.ConsistentRead) {
+						t.Error("bucket read without ConsistentRead")
+					}
+					if tt.stored == nil {
+						return &dynamodb.GetItemOutput{}, nil
+					}
+					item, err := dynamodbattribute.MarshalMap(tt.stored)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return &dynamodb.GetItemOutput{Item: item}, nil
+				},
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					puts++
+					if got := aws.StringValue(input.ConditionExpression); got != "attribute_not_exists(rateLimitKey)" {
+						t.Errorf("condition = %q", got)
+					}
+					if puts <= tt.conflicts {
+						return nil, errConditionFailed
+					}
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			}
+			limiter := NewDynamoDBRateLimiter(client, "rate-limits", RateLimitOptions{RequestsPerSecond: 1, Burst: 5}, DynamoDBOptions{MaxAttempts: 3})
+
+			allowed, _, err := limiter.Allow(context.Background(), "ip#203.0.113.7")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if allowed != tt.wantAllow || puts != tt.wantPuts {
+				t.Errorf("allowed = %v after %d puts, want %v after %d", allowed, puts, tt.wantAllow, tt.wantPuts)
+			}
+		})
+	}
+}
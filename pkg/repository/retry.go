@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// defaultMaxAttempts is used when DynamoDBOptions.MaxAttempts is not set.
+	defaultMaxAttempts = 3
+)
+
+// RetryStrategy controls how throttled and failed (5xx) DynamoDB calls are retried.
+type RetryStrategy struct {
+	// MaxAttempts bounds how often a call is attempted, including the first attempt.
+	MaxAttempts int
+	// BaseDelay is the backoff ceiling before the first retry; it doubles on every attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff so a retry never eats a large share of the Lambda budget.
+	MaxDelay time.Duration
+}
+
+var (
+	// onDemandRetryStrategy retries quickly: an on-demand table throttles only briefly while it
+	// scales, so short delays recover fastest.
+	onDemandRetryStrategy = RetryStrategy{MaxAttempts: defaultMaxAttempts, BaseDelay: 25 * time.Millisecond, MaxDelay: time.Second}
+	// provisionedRetryStrategy backs off harder: a provisioned table throttles because its capacity is
+	// used up, and quick retries only consume the capacity other requests are waiting for.
+	provisionedRetryStrategy = RetryStrategy{MaxAttempts: 2, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+)
+
+// RetryStrategyFor returns the retry strategy for a table with the given billing mode
+// (dynamodb.BillingModePayPerRequest or dynamodb.BillingModeProvisioned), with the non-zero
+// fields of overrides taking precedence. An unknown or empty billing mode gets the on-demand strategy.
+func RetryStrategyFor(billingMode string, overrides RetryStrategy) RetryStrategy {
+	strategy := onDemandRetryStrategy
+	if billingMode == dynamodb.BillingModeProvisioned {
+		strategy = provisionedRetryStrategy
+	}
+	if overrides.MaxAttempts > 0 {
+		strategy.MaxAttempts = overrides.MaxAttempts
+	}
+	if overrides.BaseDelay > 0 {
+		strategy.BaseDelay = overrides.BaseDelay
+	}
+	if overrides.MaxDelay > 0 {
+		strategy.MaxDelay = overrides.MaxDelay
+	}
+	strategy.BaseDelay = min(strategy.BaseDelay, strategy.MaxDelay)
+	return strategy
+}
+
+// retryableErrorCodes lists the DynamoDB error codes that indicate a transient condition.
+var retryableErrorCodes = map[string]bool{
+	dynamodb.ErrCodeProvisionedThroughputExceededException: true,
+	dynamodb.ErrCodeRequestLimitExceeded:                   true,
+	dynamodb.ErrCodeInternalServerError:                    true,
+	"ThrottlingException":                                  true,
+	"ServiceUnavailable":                                   true,
+	// The request never got an answer, e.g. the connection was reset. The DynamoDB client does not
+	// retry on its own, so these are retried here.
+	request.ErrCodeRequestError: true,
+}
+
+// retryOverrides collects the retry settings of opts; zero fields keep the strategy's defaults.
+func retryOverrides(opts DynamoDBOptions) RetryStrategy {
+	return RetryStrategy{
+		MaxAttempts: opts.MaxAttempts,
+		BaseDelay:   opts.RetryBaseDelay,
+		MaxDelay:    opts.RetryMaxDelay,
+	}
+}
+
+// withRetry runs fn, retrying throttling and 5xx errors with exponential backoff and full jitter.
+// Other errors, such as validation or conditional check failures, are returned immediately.
+func (repo *DynamoDBUserRepository) withRetry(ctx context.Context, operation string, fn func() error) error {
+	return retry(ctx, repo.retryStrategy, operation, fn)
+}
+
+// retry runs fn up to strategy.MaxAttempts times, backing off between retryable failures.
+func retry(ctx context.Context, strategy RetryStrategy, operation string, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) || attempt >= strategy.MaxAttempts {
+			return err
+		}
+
+		delay := strategy.backoffDelay(attempt)
+		slog.Warn("Retrying throttled DynamoDB call",
+			slog.String("operation", operation),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// backoffDelay picks a random delay in [0, min(MaxDelay, BaseDelay*2^(attempt-1))).
+// The ceiling stops doubling once it reaches MaxDelay, so it cannot overflow however many attempts
+// are configured.
+func (strategy RetryStrategy) backoffDelay(attempt int) time.Duration {
+	ceiling := strategy.BaseDelay
+	for i := 1; i < attempt && ceiling < strategy.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	ceiling = max(min(ceiling, strategy.MaxDelay), 1)
+	return time.Duration(rand.Int64N(int64(ceiling)) + 1)
+}
+
+// isRetryable reports whether err is a transient DynamoDB failure worth retrying.
+func isRetryable(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	if retryableErrorCodes[aerr.Code()] {
+		return true
+	}
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() >= http.StatusInternalServerError
+}
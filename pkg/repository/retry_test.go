@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	strategy := RetryStrategy{MaxAttempts: 200, BaseDelay: 25 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt     int
+		wantCeiling time.Duration
+	}{
+		{1, 25 * time.Millisecond},
+		{2, 50 * time.Millisecond},
+		{4, 200 * time.Millisecond},
+		{10, time.Second},
+		// Shifting BaseDelay this far would overflow into a negative ceiling
+		{64, time.Second},
+		{200, time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			delay := strategy.backoffDelay(tt.attempt)
+			if delay <= 0 || delay > tt.wantCeiling {
+				t.Fatalf("backoffDelay(%d) = %v, want in (0, %v]", tt.attempt, delay, tt.wantCeiling)
+			}
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	throttled := awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+	conditionFailed := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+	invalid := awserr.New("ValidationException", "invalid", nil)
+	unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "unavailable", nil), 503, "request-1")
+	tests := []struct {
+		name      string
+		errs      []error // returned by successive attempts; nil afterwards
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds at once", nil, 1, nil},
+		{"retries throttling", []error{throttled, throttled}, 3, nil},
+		{"gives up after MaxAttempts", []error{throttled, throttled, throttled, throttled}, 3, throttled},
+		{"retries server errors", []error{unavailable}, 2, nil},
+		{"does not retry a failed condition", []error{conditionFailed}, 1, conditionFailed},
+		{"does not retry validation errors", []error{invalid}, 1, invalid},
+	}
+	strategy := RetryStrategy{MaxAttempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Microsecond}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retry(context.Background(), strategy, "Test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRepositoryRetriesThrottledCalls(t *testing.T) {
+	throttled := awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+	tests := []struct {
+		name      string
+		failures  int // calls failing with throttled before GetItem succeeds
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no throttling", wantCalls: 1},
+		{name: "transient throttling", failures: 2, wantCalls: 3},
+		{name: "persistent throttling", failures: 10, wantCalls: 4, wantErr: ErrFailedToFetchRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					calls++
+					if calls <= tt.failures {
+						return nil, throttled
+					}
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, models.User{Email: "a@example.com"})}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{MaxAttempts: 4, RetryBaseDelay: time.Microsecond, RetryMaxDelay: time.Microsecond})
+
+			user, err := repo.FetchUser(context.Background(), "a@example.com", FetchOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user == nil {
+				t.Error("user not found")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStrategyFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		billingMode string
+		overrides   RetryStrategy
+		want        RetryStrategy
+	}{
+		{name: "on-demand", billingMode: dynamodb.BillingModePayPerRequest, want: onDemandRetryStrategy},
+		{name: "provisioned", billingMode: dynamodb.BillingModeProvisioned, want: provisionedRetryStrategy},
+		{name: "unknown", billingMode: "", want: onDemandRetryStrategy},
+		{
+			name:        "overridden attempts",
+			billingMode: dynamodb.BillingModeProvisioned,
+			overrides:   RetryStrategy{MaxAttempts: 5},
+			want:        RetryStrategy{MaxAttempts: 5, BaseDelay: provisionedRetryStrategy.BaseDelay, MaxDelay: provisionedRetryStrategy.MaxDelay},
+		},
+		{
+			name:        "base delay capped by an overridden max delay",
+			billingMode: dynamodb.BillingModeProvisioned,
+			overrides:   RetryStrategy{MaxDelay: 50 * time.Millisecond},
+			want:        RetryStrategy{MaxAttempts: provisionedRetryStrategy.MaxAttempts, BaseDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryStrategyFor(tt.billingMode, tt.overrides); got != tt.want {
+				t.Errorf("strategy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectBillingMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		summary      *dynamodb.BillingModeSummary
+		opts         DynamoDBOptions
+		want         string
+		wantStrategy RetryStrategy
+	}{
+		{
+			name:         "on-demand",
+			summary:      &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModePayPerRequest)},
+			want:         dynamodb.BillingModePayPerRequest,
+			wantStrategy: onDemandRetryStrategy,
+		},
+		{
+			name:         "provisioned",
+			summary:      &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModeProvisioned)},
+			want:         dynamodb.BillingModeProvisioned,
+			wantStrategy: provisionedRetryStrategy,
+		},
+		{name: "table older than on-demand capacity", want: dynamodb.BillingModeProvisioned, wantStrategy: provisionedRetryStrategy},
+		{
+			name:         "overrides kept",
+			summary:      &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModeProvisioned)},
+			opts:         DynamoDBOptions{MaxAttempts: 6},
+			want:         dynamodb.BillingModeProvisioned,
+			wantStrategy: RetryStrategy{MaxAttempts: 6, BaseDelay: provisionedRetryStrategy.BaseDelay, MaxDelay: provisionedRetryStrategy.MaxDelay},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockDynamoDB{
+				describeTable: func(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+					calls++
+					return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{BillingModeSummary: tt.summary}}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, tt.opts)
+
+			for range 2 {
+				billingMode, err := repo.DetectBillingMode(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if billingMode != tt.want {
+					t.Errorf("billing mode = %q, want %q", billingMode, tt.want)
+				}
+			}
+			if repo.retryStrategy != tt.wantStrategy {
+				t.Errorf("strategy = %+v, want %+v", repo.retryStrategy, tt.wantStrategy)
+			}
+			if calls != 1 {
+				t.Errorf("DescribeTable called %d times, want once", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// errNearDeadline stops a scan of PurgeDeletedUsers or UpdateUsersWhere when the context's deadline
+// is near; the result is then reported as incomplete.
+var errNearDeadline = errors.New("stopped before the context deadline")
+
+// nearDeadline reports whether less than scanDeadlineReserve is left before the context's deadline.
+func nearDeadline(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < scanDeadlineReserve
+}
+
+// scanPages runs the Scan of input page by page, calling fn with each page until the table is
+// exhausted or fn returns an error, which is returned as it is. Every page is read with retries; a
+// read that still fails is logged for operation and returned wrapped in ErrCouldNotScanItems.
+func (repo *DynamoDBUserRepository) scanPages(ctx context.Context, operation string, input *dynamodb.ScanInput, fn func(page *dynamodb.ScanOutput) error) error {
+	for {
+		var page *dynamodb.ScanOutput
+		err := repo.withRetry(ctx, operation, func() (err error) {
+			page, err = repo.client.ScanWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			slog.Error("DynamoDB Scan failed", slog.String("operation", operation), slog.Any("error", err))
+			return fmt.Errorf("%w: %w", ErrCouldNotScanItems, err)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// ScanAll calls fn with every user FetchUsers would list with opts, starting after lastEvaluatedKey
+// (from the beginning when empty), and handles the pagination itself. It stops at the first error
+// returned by fn and returns it as it is. Users are visited as the Scan returns them, a page at a
+// time, so the table is never held in memory; like any Scan it reads the whole table.
+func (repo *DynamoDBUserRepository) ScanAll(ctx context.Context, lastEvaluatedKey string, opts FetchOptions, fn func(user models.User) error) error {
+	input := repo.scanInput(scanFilter{}, opts)
+	startKey, err := repo.scanStartKey(lastEvaluatedKey)
+	if err != nil {
+		return err
+	}
+	input.ExclusiveStartKey = startKey
+
+	return repo.scanPages(ctx, "ScanAll", input, func(page *dynamodb.ScanOutput) error {
+		users, _, err := unmarshalUserPage(page.Items, nil)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ScanAll calls fn with every user FetchUsers would list with opts, in email order, starting after
+// lastEvaluatedKey. The users are copied before the first call, so fn may use the repository.
+func (repo *InMemoryUserRepository) ScanAll(ctx context.Context, lastEvaluatedKey string, opts FetchOptions, fn func(user models.User) error) error {
+	opts.Descending = false
+	users, _, err := repo.page(math.MaxInt, lastEvaluatedKey, opts, func(models.User) bool { return true })
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResumeToken returns the lastEvaluatedKey that continues a listing or ScanAll after user, for
+// callers that stop part way through a page. It carries both the email and the id, so it resumes a
+// Scan with either KeySchema.
+func ResumeToken(user models.User) (string, error) {
+	key := userKey(user.Email)
+	if user.ID != "" {
+		maps.Copy(key, idKey(user.ID))
+	}
+	return encodeLastEvaluatedKey(key)
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestScanAll(t *testing.T) {
+	pages := [][]string{{"a@example.com", "b@example.com"}, {"c@example.com"}, {"d@example.com", "e@example.com"}}
+	errStop := errors.New("stop")
+	tests := []struct {
+		name        string
+		stopAt      string // The email fn fails on; none when empty
+		scanErr     error
+		wantVisited []string
+		wantScans   int
+		wantErr     error
+	}{
+		{
+			name:        "every page",
+			wantVisited: []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"},
+			wantScans:   3,
+		},
+		{
+			name:        "stops at the first error",
+			stopAt:      "c@example.com",
+			wantVisited: []string{"a@example.com", "b@example.com", "c@example.com"},
+			wantScans:   2,
+			wantErr:     errStop,
+		},
+		{
+			name:      "scan failure",
+			scanErr:   awserr.New("ValidationException", "invalid", nil),
+			wantScans: 1,
+			wantErr:   ErrCouldNotScanItems,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := 0
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if scans > 0 && aws.StringValue(input.ExclusiveStartKey["email"].S) != pages[scans-1][len(pages[scans-1])-1] {
+						t.Errorf("scan %d did not continue from the previous page", scans)
+					}
+					page := scans
+					scans++
+					if tt.scanErr != nil {
+						return nil, tt.scanErr
+					}
+					output := &dynamodb.ScanOutput{}
+					for _, email := range pages[page] {
+						output.Items = append(output.Items, marshalUser(t, models.User{Email: email, FirstName: "Ann"}))
+					}
+					if page < len(pages)-1 {
+						output.LastEvaluatedKey = userKey(pages[page][len(pages[page])-1])
+					}
+					return output, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{MaxAttempts: 1})
+
+			var visited []string
+			err := repo.ScanAll(context.Background(), "", FetchOptions{}, func(user models.User) error {
+				visited = append(visited, user.Email)
+				if user.Email == tt.stopAt {
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(visited, tt.wantVisited) {
+				t.Errorf("visited %v, want %v", visited, tt.wantVisited)
+			}
+			if scans != tt.wantScans {
+				t.Errorf("%d scans, want %d", scans, tt.wantScans)
+			}
+		})
+	}
+}
+
+func TestScanAllResumes(t *testing.T) {
+	var startKey map[string]*dynamodb.AttributeValue
+	client := &mockDynamoDB{
+		scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			startKey = input.ExclusiveStartKey
+			return &dynamodb.ScanOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+	token, err := ResumeToken(models.User{Email: "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.ScanAll(context.Background(), token, FetchOptions{}, func(models.User) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(startKey["email"].S); got != "b@example.com" || len(startKey) != 1 {
+		t.Errorf("ExclusiveStartKey = %v, want b@example.com", startKey)
+	}
+
+	err = repo.ScanAll(context.Background(), "not a token", FetchOptions{}, func(models.User) error { return nil })
+	if !errors.Is(err, ErrInvalidLastEvaluatedKey) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidLastEvaluatedKey)
+	}
+}
+
+func TestInMemoryScanAll(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+	ctx := context.Background()
+	for _, email := range []string{"c@example.com", "a@example.com", "d@example.com", "b@example.com"} {
+		if _, err := repo.CreateUser(ctx, models.User{Email: email, FirstName: "Ann"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.DeleteUser(ctx, "d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := ResumeToken(models.User{Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		token string
+		opts  FetchOptions
+		want  []string
+	}{
+		{name: "live users in email order", want: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{name: "with deleted users", opts: FetchOptions{IncludeDeleted: true}, want: []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}},
+		{name: "resumed", token: token, want: []string{"b@example.com", "c@example.com"}},
+		{name: "descending is ignored", opts: FetchOptions{Descending: true}, want: []string{"a@example.com", "b@example.com", "c@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			err := repo.ScanAll(ctx, tt.token, tt.opts, func(user models.User) error {
+				visited = append(visited, user.Email)
+				// fn may use the repository while the scan runs
+				_, err := repo.FetchUser(ctx, user.Email, FetchOptions{IncludeDeleted: true})
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(visited, tt.want) {
+				t.Errorf("visited %v, want %v", visited, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// keySchema returns a key schema with attr as the partition key and, if given, rangeAttr as the sort key.
+func keySchema(attr string, rangeAttr ...string) []*dynamodb.KeySchemaElement {
+	schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(attr), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+	for _, name := range rangeAttr {
+		schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(name), KeyType: aws.String(dynamodb.KeyTypeRange)})
+	}
+	return schema
+}
+
+// stringAttr defines attr as a string attribute.
+func stringAttr(attr string) []*dynamodb.AttributeDefinition {
+	return []*dynamodb.AttributeDefinition{{AttributeName: aws.String(attr), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)}}
+}
+
+// emailIndex describes an index on email projecting projection.
+func emailIndex(projection string) []*dynamodb.GlobalSecondaryIndexDescription {
+	return []*dynamodb.GlobalSecondaryIndexDescription{{
+		IndexName:  aws.String("email-index"),
+		KeySchema:  keySchema("email"),
+		Projection: &dynamodb.Projection{ProjectionType: aws.String(projection)},
+	}}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        DynamoDBOptions
+		table       *dynamodb.TableDescription
+		emailTable  *dynamodb.TableDescription
+		describeErr error
+		wantErr     error
+	}{
+		{name: "email key", table: &dynamodb.TableDescription{KeySchema: keySchema("email"), AttributeDefinitions: stringAttr("email")}},
+		{name: "other key", table: &dynamodb.TableDescription{KeySchema: keySchema("userId"), AttributeDefinitions: stringAttr("userId")}, wantErr: ErrTableSchemaMismatch},
+		{name: "sort key", table: &dynamodb.TableDescription{KeySchema: keySchema("email", "createdAt"), AttributeDefinitions: stringAttr("email")}, wantErr: ErrTableSchemaMismatch},
+		{
+			name: "numeric key",
+			table: &dynamodb.TableDescription{
+				KeySchema:            keySchema("email"),
+				AttributeDefinitions: []*dynamodb.AttributeDefinition{{AttributeName: aws.String("email"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)}},
+			},
+			wantErr: ErrTableSchemaMismatch,
+		},
+		{name: "DescribeTable denied", describeErr: errors.New("AccessDeniedException"), wantErr: ErrTableNotReachable},
+		{
+			name:       "id key with the email index and table",
+			opts:       idOptions,
+			table:      &dynamodb.TableDescription{KeySchema: keySchema("id"), AttributeDefinitions: stringAttr("id"), GlobalSecondaryIndexes: emailIndex(dynamodb.ProjectionTypeAll)},
+			emailTable: &dynamodb.TableDescription{KeySchema: keySchema("email")},
+		},
+		{name: "id key expected", opts: idOptions, table: &dynamodb.TableDescription{KeySchema: keySchema("email")}, wantErr: ErrTableSchemaMismatch},
+		{name: "id key without the email index", opts: idOptions, table: &dynamodb.TableDescription{KeySchema: keySchema("id")}, wantErr: ErrTableSchemaMismatch},
+		{
+			name:    "email index projecting only keys",
+			opts:    idOptions,
+			table:   &dynamodb.TableDescription{KeySchema: keySchema("id"), GlobalSecondaryIndexes: emailIndex(dynamodb.ProjectionTypeKeysOnly)},
+			wantErr: ErrTableSchemaMismatch,
+		},
+		{
+			name:       "email table keyed on id",
+			opts:       idOptions,
+			table:      &dynamodb.TableDescription{KeySchema: keySchema("id"), GlobalSecondaryIndexes: emailIndex(dynamodb.ProjectionTypeAll)},
+			emailTable: &dynamodb.TableDescription{KeySchema: keySchema("id")},
+			wantErr:    ErrTableSchemaMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				describeTable: func(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+					if tt.describeErr != nil {
+						return nil, tt.describeErr
+					}
+					if aws.StringValue(input.TableName) == testEmailTable {
+						return &dynamodb.DescribeTableOutput{Table: tt.emailTable}, nil
+					}
+					return &dynamodb.DescribeTableOutput{Table: tt.table}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, tt.opts)
+
+			if err := repo.ValidateSchema(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SearchTokens splits texts into the lowercased words stored as a user's searchTokens, dropping
+// duplicates. Words are runs of letters and digits, so "Mary-Jane O'Neil" gives mary, jane, o and neil.
+func SearchTokens(texts ...string) []string {
+	var tokens []string
+	for _, text := range texts {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			if !slices.Contains(tokens, word) {
+				tokens = append(tokens, word)
+			}
+		}
+	}
+	return tokens
+}
+
+// indexUser sets the search tokens of user from its names. Every write of the names must call it.
+func indexUser(user *models.User) {
+	user.SearchTokens = SearchTokens(user.FirstName, user.LastName)
+}
+
+// SearchUsers retrieves the users whose names contain every word of query, compared
+// case-insensitively, paginated like FetchUsers. Words match whole name tokens only: "ada" finds
+// Ada Lovelace but "lov" does not.
+//
+// DynamoDB cannot index the elements of a list, so this is a Scan with a contains filter on
+// searchTokens: like FetchUsersByEmailPrefix it reads (and is billed for) the whole table, however
+// few users match. Tables searched often should index the tokens instead, writing an item per token
+// with a GSI partitioned on the token, or feed the table's stream to a search service.
+func (repo *DynamoDBUserRepository) SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return nil, "", fmt.Errorf("%w: the search query has no words", ErrInvalidOperation)
+	}
+	conditions := make([]string, len(tokens))
+	values := make(map[string]*dynamodb.AttributeValue, len(tokens))
+	for i, token := range tokens {
+		placeholder := ":token" + strconv.Itoa(i)
+		conditions[i] = "contains(#searchTokens, " + placeholder + ")"
+		values[placeholder] = &dynamodb.AttributeValue{S: aws.String(token)}
+	}
+	filter := scanFilter{
+		expression: strings.Join(conditions, " AND "),
+		names:      map[string]*string{"#searchTokens": aws.String("searchTokens")},
+		values:     values,
+	}
+	return repo.scanUsers(ctx, "SearchUsers", filter, limit, lastEvaluatedKey, opts)
+}
+
+// SearchUsers retrieves the users whose names contain every word of query, ordered by email.
+func (repo *InMemoryUserRepository) SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return nil, "", fmt.Errorf("%w: the search query has no words", ErrInvalidOperation)
+	}
+	opts.Descending = false
+	return repo.page(limit, lastEvaluatedKey, opts, func(user models.User) bool {
+		for _, token := range tokens {
+			if !slices.Contains(user.SearchTokens, token) {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{name: "none"},
+		{name: "simple names", texts: []string{"Ada", "Lovelace"}, want: []string{"ada", "lovelace"}},
+		{name: "punctuation", texts: []string{"Mary-Jane", "O'Neil"}, want: []string{"mary", "jane", "o", "neil"}},
+		{name: "duplicates", texts: []string{"Lee Lee", "LEE"}, want: []string{"lee"}},
+		{name: "digits and accents", texts: []string{"Zoë", "Smith 2nd"}, want: []string{"zoë", "smith", "2nd"}},
+		{name: "no words", texts: []string{" - ", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchTokens(tt.texts...); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchTokens(%q) = %q, want %q", tt.texts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritesStoreSearchTokens(t *testing.T) {
+	var created map[string]*dynamodb.AttributeValue
+	var updated *dynamodb.UpdateItemInput
+	client := &mockDynamoDB{
+		putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			created = input.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			updated = input
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+	ctx := context.Background()
+
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "King-Noel"}); err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	if err := dynamodbattribute.Unmarshal(created["searchTokens"], &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ada", "king", "noel"}; !slices.Equal(tokens, want) {
+		t.Errorf("created searchTokens = %q, want %q", tokens, want)
+	}
+
+	if _, err := repo.UpdateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ada", LastName: "Lovelace"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamodbattribute.Unmarshal(updated.ExpressionAttributeValues[":searchTokens"], &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ada", "lovelace"}; !slices.Equal(tokens, want) {
+		t.Errorf("updated searchTokens = %q, want %q", tokens, want)
+	}
+	if !strings.Contains(aws.StringValue(updated.UpdateExpression), " = :searchTokens") {
+		t.Errorf("update = %q, want searchTokens set", aws.StringValue(updated.UpdateExpression))
+	}
+}
+
+func TestSearchUsersFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter string
+		wantTokens []string
+		wantErr    error
+	}{
+		{name: "one word", query: "Ada", wantFilter: "contains(#searchTokens, :token0)", wantTokens: []string{"ada"}},
+		{
+			name: "several words", query: "LOVELACE, ada",
+			wantFilter: "contains(#searchTokens, :token0) AND contains(#searchTokens, :token1)",
+			wantTokens: []string{"lovelace", "ada"},
+		},
+		{name: "no words", query: " ?! ", wantErr: ErrInvalidOperation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					if got := aws.StringValue(input.FilterExpression); !strings.Contains(got, tt.wantFilter) {
+						t.Errorf("filter = %q, want it to contain %q", got, tt.wantFilter)
+					}
+					if got := aws.StringValue(input.ExpressionAttributeNames["#searchTokens"]); got != "searchTokens" {
+						t.Errorf("#searchTokens = %q", got)
+					}
+					for i, token := range tt.wantTokens {
+						placeholder := ":token" + strconv.Itoa(i)
+						if got := aws.StringValue(input.ExpressionAttributeValues[placeholder].S); got != token {
+							t.Errorf("%s = %q, want %q", placeholder, got, token)
+						}
+					}
+					return &dynamodb.ScanOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			_, _, err := repo.SearchUsers(context.Background(), tt.query, 10, "", FetchOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemorySearchUsers(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "ada", want: []string{"ada@example.com", "byron@example.com"}},
+		{query: "ADA", want: []string{"ada@example.com", "byron@example.com"}},
+		{query: "ada lovelace", want: []string{"ada@example.com"}},
+		{query: "Lovelace Ada", want: []string{"ada@example.com"}},
+		{query: "mary-jane", want: []string{"mj@example.com"}},
+		{query: "lov"},
+		{query: "grace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			repo := NewInMemoryUserRepository(DynamoDBOptions{})
+			ctx := context.Background()
+			for _, user := range []models.User{
+				{Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace"},
+				{Email: "byron@example.com", FirstName: "Ada", LastName: "Byron"},
+				{Email: "mj@example.com", FirstName: "Mary-Jane", LastName: "Watson"},
+			} {
+				if _, err := repo.CreateUser(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			users, _, err := repo.SearchUsers(ctx, tt.query, 10, "", FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, user := range users {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
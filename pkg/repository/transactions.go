@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// maxTransactionItems is the maximum number of actions DynamoDB accepts in one TransactWriteItems call.
+const maxTransactionItems = 100
+
+// OperationType discriminates the kind of write in a UserOperation.
+type OperationType string
+
+const (
+	OperationCreate OperationType = "create"
+	OperationUpdate OperationType = "update"
+	OperationDelete OperationType = "delete"
+)
+
+// UserOperation is a single write within an atomic multi-user operation.
+// Delete operations only need User.Email.
+type UserOperation struct {
+	Type OperationType `json:"type"`
+	User models.User   `json:"user"`
+}
+
+// TransactWriteUsers applies all operations atomically with TransactWriteItems:
+// either every create/update/delete succeeds or none of them is applied.
+// Operations carry the same conditions as their single-user counterparts, and a
+// cancelled transaction is reported as ErrTransactionCanceled with the per-item reasons.
+// With KeySchemaID creates and hard deletes also write the reservation of the email,
+// which counts towards the limit of 100 actions.
+func (repo *DynamoDBUserRepository) TransactWriteUsers(ctx context.Context, ops []UserOperation) error {
+	if len(ops) == 0 || len(ops) > maxTransactionItems {
+		return fmt.Errorf("%w: a transaction must contain between 1 and %d operations", ErrInvalidOperation, maxTransactionItems)
+	}
+
+	items := make([]*dynamodb.TransactWriteItem, 0, len(ops))
+	itemOps := make([]int, 0, len(ops)) // The index of the operation each action belongs to
+	for i, op := range ops {
+		opItems, err := repo.transactItems(ctx, op)
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+		for range opItems {
+			itemOps = append(itemOps, i)
+		}
+		items = append(items, opItems...)
+	}
+	if len(items) > maxTransactionItems {
+		return fmt.Errorf("%w: the operations need %d actions, more than the %d of a transaction", ErrInvalidOperation, len(items), maxTransactionItems)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: items}
+	err := repo.withRetry(ctx, "TransactWriteUsers", func() error {
+		_, err := repo.client.TransactWriteItemsWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			// Report the first failed action of each operation
+			reasons := make([]*dynamodb.CancellationReason, len(ops))
+			for i := range reasons {
+				reasons[i] = &dynamodb.CancellationReason{}
+			}
+			for i, reason := range canceled.CancellationReasons {
+				code := aws.StringValue(reason.Code)
+				if i < len(itemOps) && reasons[itemOps[i]].Code == nil && code != "" && code != "None" {
+					reasons[itemOps[i]] = reason
+				}
+			}
+			return fmt.Errorf("%w: %s", ErrTransactionCanceled, describeCancellation(ops, reasons))
+		}
+		slog.Error("DynamoDB TransactWriteItems failed", slog.String("operation", "TransactWriteUsers"), slog.Any("error", err))
+		return fmt.Errorf("could not write transaction to DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// transactItems converts a UserOperation into the matching TransactWriteItems. With KeySchemaID the
+// key of an updated or deleted user is read first, and a missing user cancels the transaction
+// before it is written.
+func (repo *DynamoDBUserRepository) transactItems(ctx context.Context, op UserOperation) ([]*dynamodb.TransactWriteItem, error) {
+	user := op.User
+	user.Email = validators.NormalizeEmail(user.Email)
+
+	var key map[string]*dynamodb.AttributeValue
+	if op.Type == OperationUpdate || op.Type == OperationDelete {
+		var err error
+		key, err = repo.keyOf(ctx, "TransactWriteUsers", user.Email)
+		if errors.Is(err, ErrUserDoesNotExist) {
+			return nil, fmt.Errorf("%w: %s %s: user does not exist", ErrTransactionCanceled, op.Type, user.Email)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Type {
+	case OperationCreate:
+		stampNewUser(&user)
+		if err := hashPassword(&user); err != nil {
+			return nil, err
+		}
+		av, err := dynamodbattribute.MarshalMap(user)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+		}
+		if repo.keyedByID() {
+			reservation, err := repo.reserveEmail(user)
+			if err != nil {
+				return nil, err
+			}
+			return []*dynamodb.TransactWriteItem{reservation, {Put: &dynamodb.Put{
+				TableName:                aws.String(repo.tableName),
+				Item:                     av,
+				ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+				ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+			}}}, nil
+		}
+		condition, names, values := newUserCondition()
+		return []*dynamodb.TransactWriteItem{{Put: &dynamodb.Put{
+			TableName:                 aws.String(repo.tableName),
+			Item:                      av,
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}}}, nil
+	case OperationUpdate:
+		if err := hashPassword(&user); err != nil {
+			return nil, err
+		}
+		update := buildUserUpdate(user, false, repo.keyedByID())
+		return []*dynamodb.TransactWriteItem{{Update: &dynamodb.Update{
+			TableName:                 aws.String(repo.tableName),
+			Key:                       key,
+			UpdateExpression:          aws.String(update.expression),
+			ConditionExpression:       aws.String(update.condition),
+			ExpressionAttributeNames:  update.names,
+			ExpressionAttributeValues: update.values,
+		}}}, nil
+	case OperationDelete:
+		condition := "attribute_exists(email) AND (" + notDeletedFilter + ")"
+		if repo.softDelete {
+			update := softDeleteUpdate()
+			if clause := repo.emailCondition(user.Email, update.names, update.values); clause != "" {
+				condition += " AND " + clause
+			}
+			return []*dynamodb.TransactWriteItem{{Update: &dynamodb.Update{
+				TableName:                 aws.String(repo.tableName),
+				Key:                       key,
+				UpdateExpression:          aws.String(update.expression),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  update.names,
+				ExpressionAttributeValues: update.values,
+			}}}, nil
+		}
+		names := map[string]*string{"#deleted": aws.String("deleted")}
+		values := map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}}
+		if clause := repo.emailCondition(user.Email, names, values); clause != "" {
+			condition += " AND " + clause
+		}
+		items := []*dynamodb.TransactWriteItem{{Delete: &dynamodb.Delete{
+			TableName:                 aws.String(repo.tableName),
+			Key:                       key,
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}}}
+		if repo.keyedByID() {
+			items = append(items, repo.releaseEmail(user.Email, aws.StringValue(key["id"].S)))
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidOperation, op.Type)
+	}
+}
+
+// describeCancellation lists the reason each failed operation of a cancelled transaction was rejected.
+func describeCancellation(ops []UserOperation, reasons []*dynamodb.CancellationReason) string {
+	var parts []string
+	for i, reason := range reasons {
+		code := aws.StringValue(reason.Code)
+		if code == "" || code == "None" || i >= len(ops) {
+			continue
+		}
+		part := fmt.Sprintf("operation %d (%s %s): %s", i, ops[i].Type, validators.NormalizeEmail(ops[i].User.Email), code)
+		if message := aws.StringValue(reason.Message); message != "" {
+			part += " - " + message
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "no reason reported"
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestTransactWriteUsers(t *testing.T) {
+	ops := []UserOperation{
+		{Type: OperationCreate, User: models.User{Email: "C@Example.com", FirstName: "Cy"}},
+		{Type: OperationUpdate, User: models.User{Email: "a@example.com", FirstName: "Anna", Version: 1}},
+		{Type: OperationDelete, User: models.User{Email: "b@example.com"}},
+	}
+	stored := []models.User{{Email: "a@example.com", ID: "user-a"}, {Email: "b@example.com", ID: "user-b"}}
+	reservations := map[string]string{"a@example.com": "user-a", "b@example.com": "user-b"}
+	invalid := awserr.New("ValidationException", "invalid", nil)
+	tests := []struct {
+		name        string
+		opts        DynamoDBOptions
+		ops         []UserOperation
+		wantActions int
+		writeErr    error
+		wantErr     error
+		wantReasons []string // expected in the error message
+	}{
+		{name: "all succeed", ops: ops, wantActions: 3},
+		{
+			name:        "one operation fails its condition",
+			ops:         ops,
+			wantActions: 3,
+			writeErr:    canceledAt(3, 1),
+			wantErr:     ErrTransactionCanceled,
+			wantReasons: []string{"operation 1 (update a@example.com): ConditionalCheckFailed"},
+		},
+		{
+			// A create and a hard delete each write two actions; the failure of the second is
+			// reported against its operation
+			name:        "id schema reports actions by operation",
+			opts:        idOptions,
+			ops:         ops,
+			wantActions: 5,
+			writeErr:    canceledAt(5, 4),
+			wantErr:     ErrTransactionCanceled,
+			wantReasons: []string{"operation 2 (delete b@example.com): ConditionalCheckFailed"},
+		},
+		{
+			name:        "id schema with a missing user",
+			opts:        idOptions,
+			ops:         []UserOperation{{Type: OperationDelete, User: models.User{Email: "nobody@example.com"}}},
+			wantErr:     ErrTransactionCanceled,
+			wantReasons: []string{"delete nobody@example.com: user does not exist"},
+		},
+		{name: "no operations", wantErr: ErrInvalidOperation},
+		{name: "unknown type", ops: []UserOperation{{Type: "upsert", User: models.User{Email: "a@example.com"}}}, wantErr: ErrInvalidOperation},
+		{name: "other errors", ops: ops, wantActions: 3, writeErr: invalid, wantErr: invalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockDynamoDB{
+				getItem: getItemByID(t, reservations, stored...),
+				transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					calls++
+					if len(input.TransactItems) != tt.wantActions {
+						t.Errorf("%d actions, want %d", len(input.TransactItems), tt.wantActions)
+					}
+					return &dynamodb.TransactWriteItemsOutput{}, tt.writeErr
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, tt.opts)
+
+			err := repo.TransactWriteUsers(context.Background(), tt.ops)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			for _, reason := range tt.wantReasons {
+				if !strings.Contains(err.Error(), reason) {
+					t.Errorf("err = %v, want it to contain %q", err, reason)
+				}
+			}
+			if wantCalls := min(tt.wantActions, 1); calls != wantCalls {
+				t.Errorf("%d transactions written, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestInMemoryTransactWriteUsersRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com", FirstName: "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.TransactWriteUsers(ctx, []UserOperation{
+		{Type: OperationUpdate, User: models.User{Email: "a@example.com", FirstName: "Anna", Version: 1}},
+		{Type: OperationCreate, User: models.User{Email: "b@example.com"}},
+		{Type: OperationDelete, User: models.User{Email: "nobody@example.com"}},
+	})
+	if !errors.Is(err, ErrTransactionCanceled) || !strings.Contains(err.Error(), "operation 2 (delete nobody@example.com)") {
+		t.Fatalf("err = %v, want %v for operation 2", err, ErrTransactionCanceled)
+	}
+	user, err := repo.FetchUser(ctx, "a@example.com", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FirstName != "Ada" {
+		t.Errorf("first name = %q, want the update rolled back", user.FirstName)
+	}
+	if user, err := repo.FetchUser(ctx, "b@example.com", FetchOptions{}); err != nil || user != nil {
+		t.Errorf("b@example.com = %+v, %v, want the create rolled back", user, err)
+	}
+}
@@ -1,215 +1,1531 @@
 package repository
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log" // For logging repository errors
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/validators"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 var (
-	ErrorFailedToUnmarshalRecord  = "failed to unmarshal record"
-	ErrorFailedToFetchRecord      = "failed to fetch record from DynamoDB"
-	ErrorInvalidUserData          = "invalid user data"
-	ErrorCouldNotMarshalItem      = "could not marshal item"
-	ErrorCouldNotDeleteItem       = "could not delete item"
-	ErrorCouldNotDynamoPutItem    = "could not put item into DynamoDB"
-	ErrorUserAlreadyExists        = "user already exists"
-	ErrorUserDoesNotExist         = "user does not exist"
-	ErrorCouldNotScanItems        = "could not scan items from DynamoDB"
-	ErrorInvalidLastEvaluatedKey  = "invalid last evaluated key for pagination"
+	ErrorFailedToUnmarshalRecord = "failed to unmarshal record"
+	ErrorFailedToFetchRecord     = "failed to fetch record from DynamoDB"
+	ErrorInvalidUserData         = "invalid user data"
+	ErrorCouldNotMarshalItem     = "could not marshal item"
+	ErrorCouldNotDeleteItem      = "could not delete item"
+	ErrorCouldNotDynamoPutItem   = "could not put item into DynamoDB"
+	ErrorUserAlreadyExists       = "user already exists"
+	ErrorUserDoesNotExist        = "user does not exist"
+	ErrorCouldNotScanItems       = "could not scan items from DynamoDB"
+	ErrorInvalidLastEvaluatedKey = "invalid last evaluated key for pagination"
+	ErrorVersionConflict         = "user was modified concurrently; version conflict"
+	ErrorCouldNotQueryItems      = "could not query items from DynamoDB"
+	ErrorIndexNotConfigured      = "secondary index is not configured"
+	ErrorCouldNotBatchWriteItems = "could not batch write items to DynamoDB"
+	ErrorTableNotReachable       = "could not describe DynamoDB table"
+	ErrorCouldNotBatchGetItems   = "could not batch get items from DynamoDB"
+	ErrorTransactionCanceled     = "transaction canceled"
+	ErrorInvalidOperation        = "invalid operation"
+	ErrorIdempotencyKeyExists    = "idempotency key already recorded"
+	ErrorTableSchemaMismatch     = "DynamoDB table key schema does not match"
+	ErrorCouldNotHashPassword    = "could not hash password"
+	ErrorInvalidCredentials      = "invalid email or password"
+	ErrorDestructiveOpsDisabled  = "destructive operations are disabled"
+	ErrorRateLimitConflict       = "rate limit bucket was updated concurrently"
 )
 
+// Sentinel errors for the messages above. Repository methods return or wrap these, so callers
+// can match them with errors.Is regardless of any context added to the message.
+var (
+	ErrFailedToUnmarshalRecord = errors.New(ErrorFailedToUnmarshalRecord)
+	ErrFailedToFetchRecord     = errors.New(ErrorFailedToFetchRecord)
+	ErrInvalidUserData         = errors.New(ErrorInvalidUserData)
+	ErrCouldNotMarshalItem     = errors.New(ErrorCouldNotMarshalItem)
+	ErrCouldNotDeleteItem      = errors.New(ErrorCouldNotDeleteItem)
+	ErrCouldNotDynamoPutItem   = errors.New(ErrorCouldNotDynamoPutItem)
+	ErrUserAlreadyExists       = errors.New(ErrorUserAlreadyExists)
+	ErrUserDoesNotExist        = errors.New(ErrorUserDoesNotExist)
+	ErrCouldNotScanItems       = errors.New(ErrorCouldNotScanItems)
+	ErrInvalidLastEvaluatedKey = errors.New(ErrorInvalidLastEvaluatedKey)
+	ErrVersionConflict         = errors.New(ErrorVersionConflict)
+	ErrCouldNotQueryItems      = errors.New(ErrorCouldNotQueryItems)
+	ErrIndexNotConfigured      = errors.New(ErrorIndexNotConfigured)
+	ErrCouldNotBatchWriteItems = errors.New(ErrorCouldNotBatchWriteItems)
+	ErrTableNotReachable       = errors.New(ErrorTableNotReachable)
+	ErrCouldNotBatchGetItems   = errors.New(ErrorCouldNotBatchGetItems)
+	ErrTransactionCanceled     = errors.New(ErrorTransactionCanceled)
+	ErrInvalidOperation        = errors.New(ErrorInvalidOperation)
+	ErrIdempotencyKeyExists    = errors.New(ErrorIdempotencyKeyExists)
+	ErrTableSchemaMismatch     = errors.New(ErrorTableSchemaMismatch)
+	ErrCouldNotHashPassword    = errors.New(ErrorCouldNotHashPassword)
+	ErrInvalidCredentials      = errors.New(ErrorInvalidCredentials)
+	ErrDestructiveOpsDisabled  = errors.New(ErrorDestructiveOpsDisabled)
+	ErrRateLimitConflict       = errors.New(ErrorRateLimitConflict)
+)
+
+const (
+	// batchWriteLimit is the maximum number of requests DynamoDB accepts in one BatchWriteItem call.
+	batchWriteLimit = 25
+	// batchGetLimit is the maximum number of keys DynamoDB accepts in one BatchGetItem call.
+	batchGetLimit = 100
+	// maxBatchAttempts bounds how often unprocessed batch items are resubmitted.
+	maxBatchAttempts = 5
+	// batchBaseDelay is the backoff before the first resubmission of unprocessed items.
+	batchBaseDelay = 50 * time.Millisecond
+)
+
+// BatchCreateResult reports the outcome of CreateUsers per user.
+type BatchCreateResult struct {
+	Created  []models.User `json:"created"`
+	Existing []string      `json:"existing"` // Emails that already had a user, which was left as it is
+	Failed   []string      `json:"failed"`   // Emails that could not be written
+}
+
+// BatchDeleteResult reports the outcome of DeleteUsers per email.
+type BatchDeleteResult struct {
+	Deleted  []string `json:"deleted"`
+	NotFound []string `json:"notFound"`
+	Failed   []string `json:"failed"`
+}
+
+// BatchFetchResult reports the outcome of FetchUsersByEmails.
+type BatchFetchResult struct {
+	Users    []models.User `json:"users"`
+	NotFound []string      `json:"notFound"`
+}
+
+// FetchOptions controls how users are read from the repository.
+type FetchOptions struct {
+	// IncludeDeleted returns soft-deleted users alongside active ones.
+	IncludeDeleted bool
+	// ConsistentRead makes FetchUser use a strongly consistent read, so a write made just before
+	// is always visible. It costs twice the read capacity, so reads are eventually consistent by default.
+	ConsistentRead bool
+	// Fields restricts the returned attributes to these models.UserFields names. Empty returns every field.
+	Fields []string
+	// Descending reverses the index sort order of FetchUsersByLastName. A Scan has no defined
+	// order, so FetchUsers ignores it.
+	Descending bool
+	// CreatedAfter and CreatedBefore restrict listings and counts to users created strictly
+	// inside this window. A zero time leaves that side unbounded.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
 // UserRepository defines the interface for user data operations.
 type UserRepository interface {
-	FetchUser(email string) (*models.User, error)
-	FetchUsers(limit int, lastEvaluatedKey string) ([]models.User, string, error)
-	CreateUser(user models.User) (*models.User, error)
-	UpdateUser(user models.User) (*models.User, error)
-	DeleteUser(email string) error
+	FetchUser(ctx context.Context, email string, opts FetchOptions) (*models.User, error)
+	UserExists(ctx context.Context, email string) (bool, error)
+	FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error)
+	FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error)
+	FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error)
+	SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error)
+	FetchUsersByEmails(ctx context.Context, emails []string, opts FetchOptions) (*BatchFetchResult, error)
+	ScanAll(ctx context.Context, lastEvaluatedKey string, opts FetchOptions, fn func(user models.User) error) error
+	CountUsers(ctx context.Context, opts FetchOptions) (int64, error)
+	CreateUser(ctx context.Context, user models.User) (*models.User, error)
+	CreateUsers(ctx context.Context, users []models.User) (*BatchCreateResult, error)
+	UpdateUser(ctx context.Context, user models.User) (*models.User, error)
+	UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error)
+	DeleteUser(ctx context.Context, email string) (*models.User, error)
+	DeleteUsers(ctx context.Context, emails []string) (*BatchDeleteResult, error)
+	TransactWriteUsers(ctx context.Context, ops []UserOperation) error
+	RestoreUser(ctx context.Context, email string) (*models.User, error)
+	ChangeEmail(ctx context.Context, oldEmail, newEmail string) (*models.User, error)
+	MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (*models.User, error)
+	VerifyPassword(ctx context.Context, email, password string) error
+	DeleteAllUsers(ctx context.Context) (int, error)
+	MigrateUsers(ctx context.Context) (int, error)
+	UpdateUsersWhere(ctx context.Context, filter UserFilter, patch UserPatch, dryRun bool) (BulkUpdateResult, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (PurgeResult, error)
+}
+
+// DynamoDBOptions configures optional behavior of DynamoDBUserRepository.
+type DynamoDBOptions struct {
+	// SoftDelete makes DeleteUser flag records as deleted instead of removing them.
+	SoftDelete bool
+	// LastNameIndex is the name of the GSI partitioned on lastName, used by FetchUsersByLastName.
+	LastNameIndex string
+	// MaxAttempts bounds how often a throttled or failed (5xx) DynamoDB call is attempted.
+	// Zero uses the default of the table's billing mode (see RetryStrategyFor).
+	MaxAttempts int
+	// RetryBaseDelay and RetryMaxDelay override the backoff of the billing mode's retry strategy when non-zero.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// BillingMode selects the retry strategy without asking DynamoDB. When empty, the on-demand strategy
+	// is used until DetectBillingMode reads the actual billing mode of the table.
+	BillingMode string
+	// NormalizedEmailIndex is the name of the GSI partitioned on normalizedEmail. When set,
+	// FetchUser falls back to it to find legacy records stored under a mixed-case email.
+	NormalizedEmailIndex string
+	// AllowDestructiveOps enables DeleteAllUsers. It must stay off in production.
+	AllowDestructiveOps bool
+	// SkipExistenceCheck makes DeleteUser rely on a condition on the write instead of reading the
+	// user first. CreateUser and UpdateUser always rely on conditions and never read first.
+	SkipExistenceCheck bool
+	// KeySchema selects the primary key of the table. Empty means KeySchemaEmail.
+	KeySchema KeySchema
+	// EmailIndex is the name of the GSI partitioned on email that serves FetchUser with KeySchemaID.
+	EmailIndex string
+	// EmailTableName is the table reserving each email for one user with KeySchemaID.
+	EmailTableName string
 }
 
 // DynamoDBUserRepository implements UserRepository for DynamoDB.
 type DynamoDBUserRepository struct {
-	client    dynamodbiface.DynamoDBAPI
-	tableName string
+	client        dynamodbiface.DynamoDBAPI
+	tableName     string
+	softDelete    bool
+	lastNameIndex string
+
+	retryStrategy  RetryStrategy
+	retryOverrides RetryStrategy
+	table          *dynamodb.TableDescription // Cached by describeTable
+
+	normalizedEmailIndex string
+	allowDestructiveOps  bool
+	skipExistenceCheck   bool
+
+	keySchema      KeySchema
+	emailIndex     string
+	emailTableName string
 }
 
 // NewDynamoDBUserRepository creates a new DynamoDBUserRepository.
-func NewDynamoDBUserRepository(client dynamodbiface.DynamoDBAPI, tableName string) *DynamoDBUserRepository {
+func NewDynamoDBUserRepository(client dynamodbiface.DynamoDBAPI, tableName string, opts DynamoDBOptions) *DynamoDBUserRepository {
+	overrides := retryOverrides(opts)
 	return &DynamoDBUserRepository{
-		client:    client,
-		tableName: tableName,
+		client:        client,
+		tableName:     tableName,
+		softDelete:    opts.SoftDelete,
+		lastNameIndex: opts.LastNameIndex,
+
+		retryStrategy:  RetryStrategyFor(opts.BillingMode, overrides),
+		retryOverrides: overrides,
+
+		normalizedEmailIndex: opts.NormalizedEmailIndex,
+		allowDestructiveOps:  opts.AllowDestructiveOps,
+		skipExistenceCheck:   opts.SkipExistenceCheck,
+
+		keySchema:      opts.KeySchema,
+		emailIndex:     opts.EmailIndex,
+		emailTableName: opts.EmailTableName,
 	}
 }
 
-// FetchUser retrieves a single user by email.
-func (repo *DynamoDBUserRepository) FetchUser(email string) (*models.User, error) {
-	input := &dynamodb.GetItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"email": {
-				S: aws.String(email),
-			},
-		},
-		TableName: aws.String(repo.tableName),
-	}
+// FetchUser retrieves a single user by email. The email is normalized before lookup.
+// Soft-deleted users are treated as missing unless opts.IncludeDeleted is set.
+func (repo *DynamoDBUserRepository) FetchUser(ctx context.Context, email string, opts FetchOptions) (*models.User, error) {
+	email = validators.NormalizeEmail(email)
 
-	result, err := repo.client.GetItem(input)
+	var record map[string]*dynamodb.AttributeValue
+	var err error
+	switch {
+	case repo.keyedByID() && !opts.ConsistentRead:
+		record, err = repo.fetchByEmailIndex(ctx, email, opts)
+	case repo.keyedByID():
+		// GSIs only support eventually consistent reads, so the id is read from the email table
+		// instead, and the user by its key
+		record, err = repo.fetchByID(ctx, email, opts)
+	default:
+		record, err = repo.getItem(ctx, userKey(email), opts)
+		if err == nil && record == nil && repo.normalizedEmailIndex != "" {
+			// Records written before emails were normalized are keyed by their original casing.
+			// GSIs only support eventually consistent reads, so ConsistentRead does not apply here.
+			record, err = repo.fetchByNormalizedEmail(ctx, email, opts)
+		}
+	}
 	if err != nil {
-		log.Printf("DynamoDB GetItem error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorFailedToFetchRecord, err)
+		return nil, err
 	}
-
-	if result.Item == nil {
+	if record == nil {
 		return nil, nil // User not found
 	}
 
 	item := new(models.User)
-	err = dynamodbattribute.UnmarshalMap(result.Item, item)
+	err = dynamodbattribute.UnmarshalMap(record, item)
 	if err != nil {
-		log.Printf("DynamoDB UnmarshalMap error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorFailedToUnmarshalRecord, err)
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "FetchUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	if item.Deleted && !opts.IncludeDeleted {
+		return nil, nil // Soft-deleted users are hidden by default
+	}
+	if expired(*item) {
+		return nil, nil // DynamoDB has yet to delete the expired record
 	}
 	return item, nil
 }
 
+// getItem reads the user item with key for FetchUser, returning nil if there is none.
+func (repo *DynamoDBUserRepository) getItem(ctx context.Context, key map[string]*dynamodb.AttributeValue, opts FetchOptions) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.GetItemInput{
+		Key:            key,
+		TableName:      aws.String(repo.tableName),
+		ConsistentRead: aws.Bool(opts.ConsistentRead),
+	}
+	if len(opts.Fields) > 0 {
+		// The soft-delete flag and expiry are always read so deleted and expired users can still be hidden
+		input.ExpressionAttributeNames = map[string]*string{}
+		input.ProjectionExpression = projection(append(slices.Clip(opts.Fields), "deleted", "expiresAt"), input.ExpressionAttributeNames)
+	}
+
+	var result *dynamodb.GetItemOutput
+	err := repo.withRetry(ctx, "FetchUser", func() (err error) {
+		result, err = repo.client.GetItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB GetItem failed", slog.String("operation", "FetchUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFetchRecord, err)
+	}
+	return result.Item, nil
+}
+
+// fetchByID reads the user with email by its id with KeySchemaID, returning nil if there is none.
+// A user that changed its email between both reads no longer has email, and is not returned.
+func (repo *DynamoDBUserRepository) fetchByID(ctx context.Context, email string, opts FetchOptions) (map[string]*dynamodb.AttributeValue, error) {
+	key, err := repo.keyOf(ctx, "FetchUser", email)
+	if errors.Is(err, ErrUserDoesNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Fields) > 0 {
+		opts.Fields = append(slices.Clip(opts.Fields), "email")
+	}
+	record, err := repo.getItem(ctx, key, opts)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if stored := record["email"]; stored == nil || aws.StringValue(stored.S) != email {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// UserExists reports whether an active (not soft-deleted) user has the given email. Only the key
+// and the soft-delete flag are read, so it is cheaper than FetchUser when the user is not needed.
+func (repo *DynamoDBUserRepository) UserExists(ctx context.Context, email string) (bool, error) {
+	user, err := repo.FetchUser(ctx, email, FetchOptions{Fields: []string{"email"}})
+	if err != nil {
+		return false, err
+	}
+	return user != nil, nil
+}
+
+// fetchByNormalizedEmail looks up a user through the normalizedEmail index, returning nil if none matches.
+func (repo *DynamoDBUserRepository) fetchByNormalizedEmail(ctx context.Context, email string, opts FetchOptions) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		IndexName:              aws.String(repo.normalizedEmailIndex),
+		KeyConditionExpression: aws.String("normalizedEmail = :normalizedEmail"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":normalizedEmail": {S: aws.String(email)},
+		},
+		Limit: aws.Int64(1),
+	}
+	if len(opts.Fields) > 0 {
+		input.ExpressionAttributeNames = map[string]*string{}
+		// Like FetchUser, the soft-delete flag and expiry are always read
+		input.ProjectionExpression = projection(append(slices.Clip(opts.Fields), "deleted", "expiresAt"), input.ExpressionAttributeNames)
+	}
+
+	var result *dynamodb.QueryOutput
+	err := repo.withRetry(ctx, "FetchUser", func() (err error) {
+		result, err = repo.client.QueryWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB Query failed", slog.String("operation", "FetchUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotQueryItems, err)
+	}
+	if len(result.Items) == 0 {
+		return nil, nil
+	}
+	return result.Items[0], nil
+}
+
 // FetchUsers retrieves multiple users with pagination.
 // Returns a list of users, the last evaluated key for next page, and an error.
-func (repo *DynamoDBUserRepository) FetchUsers(limit int, lastEvaluatedKey string) ([]models.User, string, error) {
+// Soft-deleted users are filtered out server-side unless opts.IncludeDeleted is set.
+func (repo *DynamoDBUserRepository) FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	return repo.scanUsers(ctx, "FetchUsers", scanFilter{}, limit, lastEvaluatedKey, opts)
+}
+
+// FetchUsersByEmailPrefix retrieves users whose email starts with prefix, paginated like FetchUsers.
+// email is the partition key, which cannot be range-queried, so this is a Scan with a begins_with
+// filter: it reads (and is billed for) the whole table, however few users match. Tables where prefix
+// searches are frequent should add a GSI with a constant partition key and email as sort key instead.
+func (repo *DynamoDBUserRepository) FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	filter := scanFilter{
+		expression: "begins_with(#email, :emailPrefix)",
+		names:      map[string]*string{"#email": aws.String("email")},
+		values:     map[string]*dynamodb.AttributeValue{":emailPrefix": {S: aws.String(validators.NormalizeEmail(prefix))}},
+	}
+	return repo.scanUsers(ctx, "FetchUsersByEmailPrefix", filter, limit, lastEvaluatedKey, opts)
+}
+
+// scanFilter is an additional filter of scanUsers, with the names and values it refers to.
+type scanFilter struct {
+	expression string
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+}
+
+// scanUsers reads one page of a Scan over the users table, optionally restricted by filter.
+func (repo *DynamoDBUserRepository) scanUsers(ctx context.Context, operation string, filter scanFilter, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	input := repo.scanInput(filter, opts)
+	input.Limit = aws.Int64(int64(limit))
+
+	// Add ExclusiveStartKey for pagination if lastEvaluatedKey is provided
+	startKey, err := repo.scanStartKey(lastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+	input.ExclusiveStartKey = startKey
+
+	var result *dynamodb.ScanOutput
+	err = repo.withRetry(ctx, operation, func() (err error) {
+		result, err = repo.client.ScanWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB Scan failed", slog.String("operation", operation), slog.Any("error", err))
+		return nil, "", fmt.Errorf("%w: %w", ErrCouldNotScanItems, err)
+	}
+
+	return unmarshalUserPage(result.Items, result.LastEvaluatedKey)
+}
+
+// scanInput builds a Scan over the users selected by opts, optionally restricted by filter.
+func (repo *DynamoDBUserRepository) scanInput(filter scanFilter, opts FetchOptions) *dynamodb.ScanInput {
 	input := &dynamodb.ScanInput{
 		TableName: aws.String(repo.tableName),
-		Limit:     aws.Int64(int64(limit)),
 	}
+	if !opts.IncludeDeleted {
+		input.FilterExpression = aws.String(notDeletedFilter)
+		input.ExpressionAttributeNames = map[string]*string{"#deleted": aws.String("deleted")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}}
+	}
+	addNotExpiredFilter(&input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+	addCreatedAtFilter(opts, &input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+	if filter.expression != "" {
+		expression := filter.expression
+		if input.FilterExpression != nil {
+			expression = "(" + aws.StringValue(input.FilterExpression) + ") AND (" + expression + ")"
+		}
+		input.FilterExpression = aws.String(expression)
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = map[string]*string{}
+		}
+		maps.Copy(input.ExpressionAttributeNames, filter.names)
+		if input.ExpressionAttributeValues == nil {
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+		}
+		maps.Copy(input.ExpressionAttributeValues, filter.values)
+	}
+	if len(opts.Fields) > 0 {
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = map[string]*string{}
+		}
+		input.ProjectionExpression = projection(opts.Fields, input.ExpressionAttributeNames)
+	}
+	return input
+}
 
-	// Add ExclusiveStartKey for pagination if lastEvaluatedKey is provided
-	if lastEvaluatedKey != "" {
-		var startKey map[string]*dynamodb.AttributeValue
-		err := json.Unmarshal([]byte(lastEvaluatedKey), &startKey)
-		if err != nil {
-			log.Printf("Invalid lastEvaluatedKey JSON: %v", err)
-			return nil, "", errors.New(ErrorInvalidLastEvaluatedKey)
+// CountUsers returns the total number of users, paging through the whole table with Select: COUNT.
+// This reads every item and is therefore expensive on large tables.
+// Soft-deleted users are excluded unless opts.IncludeDeleted is set.
+func (repo *DynamoDBUserRepository) CountUsers(ctx context.Context, opts FetchOptions) (int64, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(repo.tableName),
+		Select:    aws.String(dynamodb.SelectCount),
+	}
+	if !opts.IncludeDeleted {
+		input.FilterExpression = aws.String(notDeletedFilter)
+		input.ExpressionAttributeNames = map[string]*string{"#deleted": aws.String("deleted")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":true": {BOOL: aws.Bool(true)}}
+	}
+	addNotExpiredFilter(&input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+	addCreatedAtFilter(opts, &input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+
+	var total int64
+	err := repo.scanPages(ctx, "CountUsers", input, func(page *dynamodb.ScanOutput) error {
+		total += aws.Int64Value(page.Count)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// FetchUsersByLastName retrieves users with the given last name by querying the last-name GSI.
+// Pagination follows the same lastEvaluatedKey contract as FetchUsers.
+func (repo *DynamoDBUserRepository) FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts FetchOptions) ([]models.User, string, error) {
+	if repo.lastNameIndex == "" {
+		return nil, "", ErrIndexNotConfigured
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		IndexName:              aws.String(repo.lastNameIndex),
+		KeyConditionExpression: aws.String("lastName = :lastName"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":lastName": {S: aws.String(lastName)},
+		},
+		Limit:            aws.Int64(int64(limit)),
+		ScanIndexForward: aws.Bool(!opts.Descending),
+	}
+	if !opts.IncludeDeleted {
+		input.FilterExpression = aws.String(notDeletedFilter)
+		input.ExpressionAttributeNames = map[string]*string{"#deleted": aws.String("deleted")}
+		input.ExpressionAttributeValues[":true"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	}
+	addNotExpiredFilter(&input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+	addCreatedAtFilter(opts, &input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues)
+	if len(opts.Fields) > 0 {
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = map[string]*string{}
 		}
-		input.ExclusiveStartKey = startKey
+		input.ProjectionExpression = projection(opts.Fields, input.ExpressionAttributeNames)
 	}
 
-	result, err := repo.client.Scan(input)
+	startKey, err := decodeLastEvaluatedKey(lastEvaluatedKey)
 	if err != nil {
-		log.Printf("DynamoDB Scan error: %v", err)
-		return nil, "", fmt.Errorf("%s: %w", ErrorCouldNotScanItems, err)
+		return nil, "", err
 	}
+	input.ExclusiveStartKey = startKey
 
-	users := new([]models.User)
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, users)
+	var result *dynamodb.QueryOutput
+	err = repo.withRetry(ctx, "FetchUsersByLastName", func() (err error) {
+		result, err = repo.client.QueryWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
-		log.Printf("DynamoDB UnmarshalListOfMaps error: %v", err)
-		return nil, "", fmt.Errorf("%s: %w", ErrorFailedToUnmarshalRecord, err)
+		slog.Error("DynamoDB Query failed", slog.String("operation", "FetchUsersByLastName"), slog.Any("error", err))
+		return nil, "", fmt.Errorf("%w: %w", ErrCouldNotQueryItems, err)
+	}
+
+	return unmarshalUserPage(result.Items, result.LastEvaluatedKey)
+}
+
+// CreateUser creates a new user in DynamoDB.
+// Uniqueness is enforced atomically by a condition on the write, so concurrent
+// creates for the same email cannot overwrite each other. Soft-deleted users
+// still occupy the key and therefore count as existing. With KeySchemaID the
+// condition is on the reservation of the email, written with the user in one transaction.
+func (repo *DynamoDBUserRepository) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	user.Email = validators.NormalizeEmail(user.Email)
+	stampNewUser(&user)
+	if err := hashPassword(&user); err != nil {
+		return nil, err
 	}
 
-	// Marshal LastEvaluatedKey for the next page
-	var newLastEvaluatedKey string
-	if result.LastEvaluatedKey != nil {
-		keyBytes, err := json.Marshal(result.LastEvaluatedKey)
+	av, err := dynamodbattribute.MarshalMap(user)
+	if err != nil {
+		slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "CreateUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+	}
+	if repo.keyedByID() {
+		return repo.createUserByID(ctx, user, av)
+	}
+
+	// Ensure user doesn't exist, or only as an expired record DynamoDB has yet to delete
+	condition, names, values := newUserCondition()
+	input := &dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 aws.String(repo.tableName),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	err = repo.withRetry(ctx, "CreateUser", func() error {
+		_, err := repo.client.PutItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil, ErrUserAlreadyExists
+		}
+		slog.Error("DynamoDB PutItem failed", slog.String("operation", "CreateUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
+	}
+	return &user, nil
+}
+
+// CreateUsers creates many users using BatchWriteItem, in chunks of 25.
+// BatchWriteItem does not support conditions, so existence is checked up front with BatchGetItem, like
+// DeleteUsers does: emails that already have a user (that has not expired) are reported as existing
+// and left untouched. Unprocessed items are resubmitted with exponential backoff; emails that still
+// fail are reported as failed. A user created by someone else between the check and the write is
+// still overwritten. With KeySchemaID each user is created with CreateUser instead, since the
+// reservation of its email has to be written in the same transaction.
+func (repo *DynamoDBUserRepository) CreateUsers(ctx context.Context, users []models.User) (*BatchCreateResult, error) {
+	result := &BatchCreateResult{Created: []models.User{}, Existing: []string{}, Failed: []string{}}
+	if repo.keyedByID() {
+		for _, user := range users {
+			created, err := repo.CreateUser(ctx, user)
+			switch {
+			case err == nil:
+				result.Created = append(result.Created, *created)
+			case errors.Is(err, ErrUserAlreadyExists):
+				result.Existing = append(result.Existing, validators.NormalizeEmail(user.Email))
+			default:
+				slog.Warn("Could not create user", slog.String("operation", "CreateUsers"), slog.Any("error", err))
+				result.Failed = append(result.Failed, validators.NormalizeEmail(user.Email))
+			}
+		}
+		return result, nil
+	}
+
+	emails := make([]string, len(users))
+	for i, user := range users {
+		emails[i] = user.Email
+	}
+	existing, err := repo.batchGet(ctx, uniqueEmails(emails))
+	if err != nil {
+		return nil, err
+	}
+	toCreate := make([]models.User, 0, len(users))
+	for _, user := range users {
+		user.Email = validators.NormalizeEmail(user.Email)
+		if stored, ok := existing[user.Email]; ok && !expired(stored) {
+			result.Existing = append(result.Existing, user.Email)
+			continue
+		}
+		toCreate = append(toCreate, user)
+	}
+
+	for start := 0; start < len(toCreate); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(toCreate))
+		chunk := make(map[string]models.User, end-start)
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, user := range toCreate[start:end] {
+			stampNewUser(&user)
+			if err := hashPassword(&user); err != nil {
+				return nil, err
+			}
+			av, err := dynamodbattribute.MarshalMap(user)
+			if err != nil {
+				slog.Error("DynamoDB MarshalMap failed", slog.String("operation", "CreateUsers"), slog.Any("error", err))
+				return nil, fmt.Errorf("%w: %w", ErrCouldNotMarshalItem, err)
+			}
+			chunk[user.Email] = user
+			requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}})
+		}
+
+		unprocessed, err := repo.batchWrite(ctx, repo.tableName, requests)
 		if err != nil {
-			log.Printf("Error marshaling LastEvaluatedKey: %v", err)
-			return nil, "", fmt.Errorf("could not marshal LastEvaluatedKey: %w", err)
+			return nil, err
+		}
+		for _, request := range unprocessed {
+			email := aws.StringValue(request.PutRequest.Item["email"].S)
+			result.Failed = append(result.Failed, email)
+			delete(chunk, email)
+		}
+		for _, user := range toCreate[start:end] {
+			if stored, ok := chunk[user.Email]; ok {
+				result.Created = append(result.Created, stored)
+			}
 		}
-		newLastEvaluatedKey = string(keyBytes)
 	}
+	return result, nil
+}
 
-	return *users, newLastEvaluatedKey, nil
+// batchWrite submits write requests for table, resubmitting unprocessed items with backoff.
+// It returns the requests DynamoDB still had not processed after the final attempt.
+func (repo *DynamoDBUserRepository) batchWrite(ctx context.Context, table string, requests []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	pending := requests
+	for attempt := 0; attempt < maxBatchAttempts && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, batchBaseDelay<<(attempt-1)); err != nil {
+				return nil, err
+			}
+		}
+		input := &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{table: pending},
+		}
+		var result *dynamodb.BatchWriteItemOutput
+		err := repo.withRetry(ctx, "batchWrite", func() (err error) {
+			result, err = repo.client.BatchWriteItemWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			slog.Error("DynamoDB BatchWriteItem failed", slog.String("operation", "batchWrite"), slog.Any("error", err))
+			return nil, fmt.Errorf("%w: %w", ErrCouldNotBatchWriteItems, err)
+		}
+		pending = result.UnprocessedItems[table]
+	}
+	return pending, nil
 }
 
-// CreateUser creates a new user in DynamoDB.
-func (repo *DynamoDBUserRepository) CreateUser(user models.User) (*models.User, error) {
-	// Check if user already exists
-	currentUser, err := repo.FetchUser(user.Email)
+// batchGet loads the users with the given (normalized) emails using BatchGetItem, in chunks of 100.
+// With KeySchemaID the emails' reservations are read first, and then the users by id. Missing users
+// are simply absent from the returned map.
+func (repo *DynamoDBUserRepository) batchGet(ctx context.Context, emails []string) (map[string]models.User, error) {
+	keys := make([]map[string]*dynamodb.AttributeValue, len(emails))
+	for i, email := range emails {
+		keys[i] = userKey(email)
+	}
+	if repo.keyedByID() {
+		items, err := repo.batchGetItems(ctx, repo.emailTableName, keys)
+		if err != nil {
+			return nil, err
+		}
+		keys = keys[:0]
+		for _, item := range items {
+			var reservation emailReservation
+			if err := dynamodbattribute.UnmarshalMap(item, &reservation); err != nil {
+				slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "batchGet"), slog.Any("error", err))
+				return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+			}
+			keys = append(keys, idKey(reservation.UserID))
+		}
+	}
+
+	items, err := repo.batchGetItems(ctx, repo.tableName, keys)
 	if err != nil {
-		return nil, err // Propagate original error
+		return nil, err
 	}
-	if currentUser != nil {
-		return nil, errors.New(ErrorUserAlreadyExists)
+	var users []models.User
+	if err := dynamodbattribute.UnmarshalListOfMaps(items, &users); err != nil {
+		slog.Error("DynamoDB UnmarshalListOfMaps failed", slog.String("operation", "batchGet"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
 	}
+	requested := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		requested[email] = true
+	}
+	found := make(map[string]models.User, len(users))
+	for _, user := range users {
+		// With KeySchemaID, a user that changed its email after its reservation was read is skipped
+		if requested[user.Email] {
+			found[user.Email] = user
+		}
+	}
+	return found, nil
+}
 
-	av, err := dynamodbattribute.MarshalMap(user)
+// batchGetItems reads the items with keys from table using BatchGetItem, in chunks of 100.
+// Unprocessed keys are resubmitted with backoff. Missing items are simply absent from the result.
+func (repo *DynamoDBUserRepository) batchGetItems(ctx context.Context, table string, keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	found := make([]map[string]*dynamodb.AttributeValue, 0, len(keys))
+	for start := 0; start < len(keys); start += batchGetLimit {
+		end := min(start+batchGetLimit, len(keys))
+
+		pending := map[string]*dynamodb.KeysAndAttributes{table: {Keys: keys[start:end]}}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt >= maxBatchAttempts {
+				return nil, fmt.Errorf("%w: unprocessed keys remained after %d attempts", ErrCouldNotBatchGetItems, maxBatchAttempts)
+			}
+			if attempt > 0 {
+				if err := sleepContext(ctx, batchBaseDelay<<(attempt-1)); err != nil {
+					return nil, err
+				}
+			}
+			input := &dynamodb.BatchGetItemInput{RequestItems: pending}
+			var result *dynamodb.BatchGetItemOutput
+			err := repo.withRetry(ctx, "batchGet", func() (err error) {
+				result, err = repo.client.BatchGetItemWithContext(ctx, input)
+				return err
+			})
+			if err != nil {
+				slog.Error("DynamoDB BatchGetItem failed", slog.String("operation", "batchGet"), slog.Any("error", err))
+				return nil, fmt.Errorf("%w: %w", ErrCouldNotBatchGetItems, err)
+			}
+			found = append(found, result.Responses[table]...)
+			pending = result.UnprocessedKeys
+		}
+	}
+	return found, nil
+}
+
+// FetchUsersByEmails loads many users at once with BatchGetItem, in chunks of 100, reporting the
+// emails that have no (or, unless opts.IncludeDeleted is set, only a soft-deleted) user as not found.
+// Users are returned in the order of emails; duplicates are looked up once. Other options are ignored.
+func (repo *DynamoDBUserRepository) FetchUsersByEmails(ctx context.Context, emails []string, opts FetchOptions) (*BatchFetchResult, error) {
+	normalized := uniqueEmails(emails)
+	existing, err := repo.batchGet(ctx, normalized)
 	if err != nil {
-		log.Printf("DynamoDB MarshalMap error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorCouldNotMarshalItem, err)
+		return nil, err
 	}
+	return collectFetched(normalized, existing, opts), nil
+}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(repo.tableName),
-		// ConditionExpression: aws.String("attribute_not_exists(email)"), // Ensure user doesn't exist
+// collectFetched splits the requested emails into the found users, in request order, and the missing emails.
+func collectFetched(emails []string, existing map[string]models.User, opts FetchOptions) *BatchFetchResult {
+	result := &BatchFetchResult{Users: []models.User{}, NotFound: []string{}}
+	for _, email := range emails {
+		if user, ok := existing[email]; ok && (!user.Deleted || opts.IncludeDeleted) && !expired(user) {
+			result.Users = append(result.Users, user)
+		} else {
+			result.NotFound = append(result.NotFound, email)
+		}
 	}
+	return result
+}
 
-	_, err = repo.client.PutItem(input)
+// uniqueEmails normalizes emails and drops duplicates, keeping the order of first occurrence.
+func uniqueEmails(emails []string) []string {
+	normalized := make([]string, 0, len(emails))
+	seen := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		email = validators.NormalizeEmail(email)
+		if !seen[email] {
+			seen[email] = true
+			normalized = append(normalized, email)
+		}
+	}
+	return normalized
+}
+
+// DeleteUsers deletes many users, reporting which emails were deleted, not found, or could not be processed.
+// Existence is checked up front with BatchGetItem so missing users do not fail the batch; the deletes
+// are then issued with BatchWriteItem in chunks of 25. With soft delete enabled each user is flagged
+// individually, since BatchWriteItem cannot update items; with KeySchemaID each user is deleted
+// individually too, since its email reservation has to go in the same transaction.
+func (repo *DynamoDBUserRepository) DeleteUsers(ctx context.Context, emails []string) (*BatchDeleteResult, error) {
+	result := &BatchDeleteResult{Deleted: []string{}, NotFound: []string{}, Failed: []string{}}
+
+	normalized := uniqueEmails(emails)
+
+	if repo.softDelete || repo.keyedByID() {
+		for _, email := range normalized {
+			_, err := repo.DeleteUser(ctx, email)
+			switch {
+			case err == nil:
+				result.Deleted = append(result.Deleted, email)
+			case errors.Is(err, ErrUserDoesNotExist):
+				result.NotFound = append(result.NotFound, email)
+			default:
+				result.Failed = append(result.Failed, email)
+			}
+		}
+		return result, nil
+	}
+
+	existing, err := repo.batchGet(ctx, normalized)
 	if err != nil {
-		log.Printf("DynamoDB PutItem error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorCouldNotDynamoPutItem, err)
+		return nil, err
 	}
-	return &user, nil
+	var toDelete []string
+	for _, email := range normalized {
+		if user, ok := existing[email]; ok && !user.Deleted && !expired(user) {
+			toDelete = append(toDelete, email)
+		} else {
+			result.NotFound = append(result.NotFound, email)
+		}
+	}
+
+	for start := 0; start < len(toDelete); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(toDelete))
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, email := range toDelete[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: userKey(email)}})
+		}
+
+		unprocessed, err := repo.batchWrite(ctx, repo.tableName, requests)
+		if err != nil {
+			return nil, err
+		}
+		failed := make(map[string]bool, len(unprocessed))
+		for _, request := range unprocessed {
+			email := aws.StringValue(request.DeleteRequest.Key["email"].S)
+			failed[email] = true
+			result.Failed = append(result.Failed, email)
+		}
+		for _, email := range toDelete[start:end] {
+			if !failed[email] {
+				result.Deleted = append(result.Deleted, email)
+			}
+		}
+	}
+	return result, nil
 }
 
 // UpdateUser updates an existing user in DynamoDB.
-func (repo *DynamoDBUserRepository) UpdateUser(user models.User) (*models.User, error) {
-	// Check if user exists
-	currentUser, err := repo.FetchUser(user.Email)
+// The write is conditional on the user existing (and not being soft-deleted),
+// so no separate read is needed to detect a missing user. Only mutable fields
+// are written, which leaves CreatedAt untouched and refreshes UpdatedAt.
+//
+// When user.Version is set it is treated as the version the client last read:
+// the update only succeeds if the stored version still matches, otherwise
+// ErrVersionConflict is returned. Every successful update increments Version.
+func (repo *DynamoDBUserRepository) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	user.Email = validators.NormalizeEmail(user.Email)
+
+	key, err := repo.keyOf(ctx, "UpdateUser", user.Email)
 	if err != nil {
 		return nil, err
 	}
-	if currentUser == nil {
-		return nil, errors.New(ErrorUserDoesNotExist)
+	if err := hashPassword(&user); err != nil {
+		return nil, err
+	}
+	update := buildUserUpdate(user, false, repo.keyedByID())
+	input := &dynamodb.UpdateItemInput{
+		Key:                                 key,
+		TableName:                           aws.String(repo.tableName),
+		UpdateExpression:                    aws.String(update.expression),
+		ConditionExpression:                 aws.String(update.condition),
+		ExpressionAttributeNames:            update.names,
+		ExpressionAttributeValues:           update.values,
+		ReturnValues:                        aws.String(dynamodb.ReturnValueAllNew),
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
 	}
 
-	av, err := dynamodbattribute.MarshalMap(user)
+	var result *dynamodb.UpdateItemOutput
+	err = repo.withRetry(ctx, "UpdateUser", func() (err error) {
+		result, err = repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
-		log.Printf("DynamoDB MarshalMap error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorCouldNotMarshalItem, err)
+		if isConditionalCheckFailed(err) {
+			return nil, updateConditionError(err, user.Email)
+		}
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "UpdateUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(repo.tableName),
-		// ConditionExpression: aws.String("attribute_exists(email)"), // Ensure user exists
+	updated := new(models.User)
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, updated)
+	if err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "UpdateUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	return updated, nil
+}
+
+// UpsertUser updates the user when present, with an UpdateItem conditional on the record existing,
+// and otherwise creates it with CreateUser. CreatedAt is kept for existing users, and a soft-deleted
+// user is revived. An expired user DynamoDB has yet to delete counts as absent, so the upserted user
+// starts afresh rather than keeping the old createdAt and expiresAt. Optimistic locking does not
+// apply, so user.Version is ignored. The returned flag reports whether the user was created, which
+// is known from the write that succeeded rather than from the stored version: a record written
+// before versioning also gets version 1 from its first update. A user created concurrently between
+// the two writes yields ErrVersionConflict. With KeySchemaID the user is addressed by its id, see
+// upsertUserByID.
+func (repo *DynamoDBUserRepository) UpsertUser(ctx context.Context, user models.User) (*models.User, bool, error) {
+	user.Email = validators.NormalizeEmail(user.Email)
+
+	if err := hashPassword(&user); err != nil {
+		return nil, false, err
+	}
+	if repo.keyedByID() {
+		return repo.upsertUserByID(ctx, user)
+	}
+	update := buildUserUpdate(user, true, false)
+	names := expressionNames(update.names)
+	expiresAt := names.name("expiresAt")
+	update.values[":now"] = nowValue()
+	input := &dynamodb.UpdateItemInput{
+		Key:              userKey(user.Email),
+		TableName:        aws.String(repo.tableName),
+		UpdateExpression: aws.String(update.expression),
+		ConditionExpression: aws.String("attribute_exists(" + names.name("email") + ") AND " +
+			"(attribute_not_exists(" + expiresAt + ") OR " + expiresAt + " > :now)"),
+		ExpressionAttributeNames:  update.names,
+		ExpressionAttributeValues: update.values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
 	}
 
-	_, err = repo.client.PutItem(input)
+	var result *dynamodb.UpdateItemOutput
+	err := repo.withRetry(ctx, "UpsertUser", func() (err error) {
+		result, err = repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
+	if isConditionalCheckFailed(err) {
+		created, err := repo.CreateUser(ctx, user)
+		if errors.Is(err, ErrUserAlreadyExists) {
+			return nil, false, ErrVersionConflict // Created by someone else in the meantime
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return created, true, nil
+	}
 	if err != nil {
-		log.Printf("DynamoDB PutItem error: %v", err)
-		return nil, fmt.Errorf("%s: %w", ErrorCouldNotDynamoPutItem, err)
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "UpsertUser"), slog.Any("error", err))
+		return nil, false, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
 	}
-	return &user, nil
+
+	upserted := new(models.User)
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, upserted)
+	if err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "UpsertUser"), slog.Any("error", err))
+		return nil, false, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	return upserted, false, nil
+}
+
+// userUpdate is the UpdateItem expression set used to update a user's mutable fields.
+type userUpdate struct {
+	expression string
+	condition  string
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+}
+
+// buildUserUpdate builds the update applied by UpdateUser: it writes the mutable fields,
+// refreshes updatedAt, increments version and requires the (live) user to exist.
+// A non-zero user.Version additionally requires the stored version to match.
+//
+// With upsert set the update is unconditional instead: a missing user is created (with
+// createdAt and the default role filled in) and a soft-deleted one is revived.
+//
+// With keyedByID the user is addressed by its id (KeySchemaID), which is never written, and the
+// update additionally requires the stored email to still be user.Email.
+func buildUserUpdate(user models.User, upsert, keyedByID bool) userUpdate {
+	now := timestamp()
+	values := map[string]*dynamodb.AttributeValue{
+		":firstName":       {S: aws.String(user.FirstName)},
+		":lastName":        {S: aws.String(user.LastName)},
+		":updatedAt":       {S: aws.String(now)},
+		":normalizedEmail": {S: aws.String(validators.NormalizeEmail(user.Email))},
+		":zero":            {N: aws.String("0")},
+		":one":             {N: aws.String("1")},
+	}
+	// Every attribute goes through a placeholder, so reserved words such as "role" are safe
+	names := expressionNames{"#deleted": aws.String("deleted")}
+	version := names.name("version")
+
+	sets := []string{
+		names.name("firstName") + " = :firstName",
+		names.name("lastName") + " = :lastName",
+		names.name("updatedAt") + " = :updatedAt",
+		names.name("normalizedEmail") + " = :normalizedEmail",
+		version + " = if_not_exists(" + version + ", :zero) + :one",
+	}
+	var removes []string
+	// Optional fields follow PUT semantics: an empty value removes the attribute.
+	setOptional := func(attr string, value *dynamodb.AttributeValue) {
+		if value == nil {
+			removes = append(removes, attr)
+			return
+		}
+		sets = append(sets, names.name(attr)+" = :"+attr)
+		values[":"+attr] = value
+	}
+	// The search tokens follow the names, and are removed when the names have no words
+	var searchTokens *dynamodb.AttributeValue
+	if tokens := SearchTokens(user.FirstName, user.LastName); len(tokens) > 0 {
+		searchTokens = &dynamodb.AttributeValue{L: make([]*dynamodb.AttributeValue, len(tokens))}
+		for i, token := range tokens {
+			searchTokens.L[i] = &dynamodb.AttributeValue{S: aws.String(token)}
+		}
+	}
+	setOptional("searchTokens", searchTokens)
+	setOptional("phone", stringValue(user.Phone))
+	setOptional("avatarUrl", stringValue(user.AvatarURL))
+	setOptional("locale", stringValue(user.Locale))
+	setOptional("timezone", stringValue(user.Timezone))
+	// Metadata is replaced as a whole, and removed when empty
+	var metadata *dynamodb.AttributeValue
+	if len(user.Metadata) > 0 {
+		metadata = &dynamodb.AttributeValue{M: make(map[string]*dynamodb.AttributeValue, len(user.Metadata))}
+		for key, value := range user.Metadata {
+			metadata.M[key] = &dynamodb.AttributeValue{S: aws.String(value)}
+		}
+	}
+	setOptional("metadata", metadata)
+	// The password only changes when a new one is given; hashPassword must have run first
+	if user.PasswordHash != "" {
+		sets = append(sets, names.name("passwordHash")+" = :passwordHash")
+		values[":passwordHash"] = &dynamodb.AttributeValue{S: aws.String(user.PasswordHash)}
+	}
+	// Role is never removed: an omitted role keeps the current one
+	if user.Role != "" {
+		sets = append(sets, names.name("role")+" = :role")
+		values[":role"] = &dynamodb.AttributeValue{S: aws.String(string(user.Role))}
+	}
+
+	var condition string
+	if upsert {
+		createdAt := names.name("createdAt")
+		sets = append(sets, createdAt+" = if_not_exists("+createdAt+", :updatedAt)")
+		if !keyedByID {
+			id := names.name("id")
+			sets = append(sets, id+" = if_not_exists("+id+", :id)")
+			values[":id"] = &dynamodb.AttributeValue{S: aws.String(newUserID())}
+		}
+		if user.Role == "" {
+			role := names.name("role")
+			sets = append(sets, role+" = if_not_exists("+role+", :defaultRole)")
+			values[":defaultRole"] = &dynamodb.AttributeValue{S: aws.String(string(models.RoleViewer))}
+		}
+		removes = append(removes, "deleted", "deletedAt")
+	} else {
+		condition = "attribute_exists(" + names.name("email") + ") AND (" + notDeletedFilter + ")" // Ensure user exists
+		values[":true"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+		// and has not expired
+		expiresAt := names.name("expiresAt")
+		condition += " AND (attribute_not_exists(" + expiresAt + ") OR " + expiresAt + " > :now)"
+		values[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))}
+		if user.Version > 0 {
+			condition += " AND " + version + " = :expectedVersion"
+			values[":expectedVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(user.Version))}
+		}
+	}
+	if keyedByID {
+		if condition != "" {
+			condition += " AND "
+		}
+		condition += names.name("email") + " = :email"
+		values[":email"] = &dynamodb.AttributeValue{S: aws.String(user.Email)}
+	}
+
+	expression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		expression += " REMOVE " + names.list(removes)
+	}
+
+	return userUpdate{
+		expression: expression,
+		condition:  condition,
+		names:      names,
+		values:     values,
+	}
+}
+
+// stringValue returns a string attribute value, or nil for the empty string.
+func stringValue(value string) *dynamodb.AttributeValue {
+	if value == "" {
+		return nil
+	}
+	return &dynamodb.AttributeValue{S: aws.String(value)}
+}
+
+// softDeleteUpdate builds the update that flags a user as deleted.
+func softDeleteUpdate() userUpdate {
+	return userUpdate{
+		expression: "SET #deleted = :true, #deletedAt = :deletedAt",
+		names: map[string]*string{
+			"#deleted":   aws.String("deleted"),
+			"#deletedAt": aws.String("deletedAt"),
+		},
+		values: map[string]*dynamodb.AttributeValue{
+			":true":      {BOOL: aws.Bool(true)},
+			":deletedAt": {S: aws.String(timestamp())},
+		},
+	}
+}
+
+// updateConditionError explains a failed update condition of the user with email using the item
+// DynamoDB returned with the failure. A live item that still has email means the expected version
+// did not match; anything else means the user does not exist.
+func updateConditionError(err error, email string) error {
+	var ccf *dynamodb.ConditionalCheckFailedException
+	if errors.As(err, &ccf) && ccf.Item != nil {
+		current := new(models.User)
+		if unmarshalErr := dynamodbattribute.UnmarshalMap(ccf.Item, current); unmarshalErr == nil && current.Email == email && !current.Deleted && !expired(*current) {
+			return ErrVersionConflict
+		}
+	}
+	return ErrUserDoesNotExist
 }
 
 // DeleteUser deletes a user by email from DynamoDB.
-func (repo *DynamoDBUserRepository) DeleteUser(email string) error {
-	// Check if user exists before attempting to delete
-	currentUser, err := repo.FetchUser(email)
+// With soft delete enabled the record is kept and flagged as deleted instead.
+// Unless DynamoDBOptions.SkipExistenceCheck is set, the user is read first to report missing users;
+// otherwise the write itself is conditioned on the user existing, saving the read.
+// It returns the user as it was before the delete, from the write's ReturnValues=ALL_OLD.
+// With KeySchemaID a hard delete also releases the user's email, see deleteUserByID, and a soft
+// delete keeps it reserved, like the key of a soft-deleted user with KeySchemaEmail.
+func (repo *DynamoDBUserRepository) DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	email = validators.NormalizeEmail(email)
+	if repo.keyedByID() && !repo.softDelete {
+		return repo.deleteUserByID(ctx, email)
+	}
+
+	// An expired user DynamoDB has yet to delete is already gone, as for FetchUser, even if it
+	// expires between the existence check and the write
+	condition := "(" + notExpiredFilter + ")"
+	names := map[string]*string{"#expiresAt": aws.String("expiresAt")}
+	values := map[string]*dynamodb.AttributeValue{":now": nowValue()}
+	if repo.skipExistenceCheck {
+		condition = "attribute_exists(email) AND (" + notDeletedFilter + ") AND " + condition
+		names["#deleted"] = aws.String("deleted")
+		values[":true"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	} else {
+		// Check if user exists before attempting to delete
+		exists, err := repo.UserExists(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrUserDoesNotExist
+		}
+	}
+
+	if repo.softDelete {
+		key, err := repo.keyOf(ctx, "DeleteUser", email)
+		if err != nil {
+			return nil, err
+		}
+		update := softDeleteUpdate()
+		maps.Copy(update.names, names)
+		maps.Copy(update.values, values)
+		if repo.keyedByID() {
+			// The key was read separately, so the user must still have the email
+			condition += " AND " + repo.emailCondition(email, update.names, update.values)
+		}
+		input := &dynamodb.UpdateItemInput{
+			Key:                       key,
+			TableName:                 aws.String(repo.tableName),
+			UpdateExpression:          aws.String(update.expression),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  update.names,
+			ExpressionAttributeValues: update.values,
+			ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+		}
+		var result *dynamodb.UpdateItemOutput
+		err = repo.withRetry(ctx, "DeleteUser", func() (err error) {
+			result, err = repo.client.UpdateItemWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			if isConditionalCheckFailed(err) {
+				return nil, ErrUserDoesNotExist
+			}
+			slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "DeleteUser"), slog.Any("error", err))
+			return nil, fmt.Errorf("%w: %w", ErrCouldNotDeleteItem, err)
+		}
+		return deletedUser(result.Attributes)
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		Key:                       userKey(email),
+		TableName:                 aws.String(repo.tableName),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+	}
+	var result *dynamodb.DeleteItemOutput
+	err := repo.withRetry(ctx, "DeleteUser", func() (err error) {
+		result, err = repo.client.DeleteItemWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil, ErrUserDoesNotExist
+		}
+		slog.Error("DynamoDB DeleteItem failed", slog.String("operation", "DeleteUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDeleteItem, err)
+	}
+	return deletedUser(result.Attributes)
+}
+
+// deletedUser unmarshals the item a delete returned with ReturnValues=ALL_OLD. No item means the
+// user vanished between the existence check and the delete.
+func deletedUser(item map[string]*dynamodb.AttributeValue) (*models.User, error) {
+	if len(item) == 0 {
+		return nil, ErrUserDoesNotExist
+	}
+	user := new(models.User)
+	if err := dynamodbattribute.UnmarshalMap(item, user); err != nil {
+		slog.Error("DynamoDB UnmarshalMap failed", slog.String("operation", "DeleteUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+	return user, nil
+}
+
+// Ping checks that the user table is reachable with a lightweight DescribeTable call.
+func (repo *DynamoDBUserRepository) Ping(ctx context.Context) error {
+	_, err := repo.client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(repo.tableName),
+	})
+	if err != nil {
+		slog.Error("DynamoDB DescribeTable failed", slog.String("operation", "Ping"), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrTableNotReachable, err)
+	}
+	return nil
+}
+
+// ValidateSchema checks that the table's primary key is exactly the string partition key "email"
+// the repository reads and writes, so a misconfigured DYNAMODB_TABLE_NAME fails fast at startup
+// instead of with cryptic ValidationExceptions on the first request. With KeySchemaID the partition
+// key must be "id" instead, the table must have the email index, partitioned on "email" and
+// projecting all attributes, and the email table must be keyed on "email".
+func (repo *DynamoDBUserRepository) ValidateSchema(ctx context.Context) error {
+	table, err := repo.describeTable(ctx, "ValidateSchema")
 	if err != nil {
 		return err
 	}
-	if currentUser == nil {
-		return errors.New(ErrorUserDoesNotExist)
+
+	keyAttr := repo.keyAttributes()[0]
+	if !hashKeyIs(table.KeySchema, keyAttr) {
+		return fmt.Errorf("%w: table %s must have only the partition key %q", ErrTableSchemaMismatch, repo.tableName, keyAttr)
+	}
+	for _, attr := range table.AttributeDefinitions {
+		if aws.StringValue(attr.AttributeName) == keyAttr && aws.StringValue(attr.AttributeType) != dynamodb.ScalarAttributeTypeS {
+			return fmt.Errorf("%w: partition key %q of table %s must be of type S, got %s",
+				ErrTableSchemaMismatch, keyAttr, repo.tableName, aws.StringValue(attr.AttributeType))
+		}
+	}
+	if !repo.keyedByID() {
+		return nil
 	}
 
-	input := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"email": {
-				S: aws.String(email),
-			},
+	index := slices.IndexFunc(table.GlobalSecondaryIndexes, func(index *dynamodb.GlobalSecondaryIndexDescription) bool {
+		return aws.StringValue(index.IndexName) == repo.emailIndex
+	})
+	if index < 0 {
+		return fmt.Errorf("%w: table %s has no index %s", ErrTableSchemaMismatch, repo.tableName, repo.emailIndex)
+	}
+	emailIndex := table.GlobalSecondaryIndexes[index]
+	if !hashKeyIs(emailIndex.KeySchema, "email") ||
+		emailIndex.Projection == nil || aws.StringValue(emailIndex.Projection.ProjectionType) != dynamodb.ProjectionTypeAll {
+		return fmt.Errorf("%w: index %s of table %s must have only the partition key \"email\" and project all attributes",
+			ErrTableSchemaMismatch, repo.emailIndex, repo.tableName)
+	}
+	return repo.validateEmailTable(ctx)
+}
+
+// DetectBillingMode reads the billing mode of the table and switches to its retry strategy, keeping
+// any overridden settings. It is meant to run once at startup, before the repository serves requests.
+func (repo *DynamoDBUserRepository) DetectBillingMode(ctx context.Context) (string, error) {
+	table, err := repo.describeTable(ctx, "DetectBillingMode")
+	if err != nil {
+		return "", err
+	}
+	// Tables created before on-demand capacity existed report no billing mode summary
+	billingMode := dynamodb.BillingModeProvisioned
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode != nil {
+		billingMode = aws.StringValue(table.BillingModeSummary.BillingMode)
+	}
+	repo.retryStrategy = RetryStrategyFor(billingMode, repo.retryOverrides)
+	return billingMode, nil
+}
+
+// describeTable returns the description of the table, calling DescribeTable only the first time.
+// It backs the startup checks; Ping always calls DynamoDB, since it must detect an unreachable table.
+func (repo *DynamoDBUserRepository) describeTable(ctx context.Context, operation string) (*dynamodb.TableDescription, error) {
+	if repo.table != nil {
+		return repo.table, nil
+	}
+	var result *dynamodb.DescribeTableOutput
+	err := repo.withRetry(ctx, operation, func() (err error) {
+		result, err = repo.client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(repo.tableName),
+		})
+		return err
+	})
+	if err != nil {
+		slog.Error("DynamoDB DescribeTable failed", slog.String("operation", operation), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrTableNotReachable, err)
+	}
+	repo.table = result.Table
+	return repo.table, nil
+}
+
+// RestoreUser clears the soft-delete flag on a user and returns the restored record.
+// Users that are missing or were never deleted yield ErrUserDoesNotExist.
+func (repo *DynamoDBUserRepository) RestoreUser(ctx context.Context, email string) (*models.User, error) {
+	email = validators.NormalizeEmail(email)
+
+	currentUser, err := repo.FetchUser(ctx, email, FetchOptions{IncludeDeleted: true})
+	if err != nil {
+		return nil, err
+	}
+	if currentUser == nil || !currentUser.Deleted {
+		return nil, ErrUserDoesNotExist
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		Key:              repo.userItemKey(*currentUser),
+		TableName:        aws.String(repo.tableName),
+		UpdateExpression: aws.String("REMOVE #deleted, #deletedAt"),
+		ExpressionAttributeNames: map[string]*string{
+			"#deleted":   aws.String("deleted"),
+			"#deletedAt": aws.String("deletedAt"),
 		},
-		TableName: aws.String(repo.tableName),
 	}
-	_, err = repo.client.DeleteItem(input)
+	if repo.keyedByID() {
+		// The user is addressed by id, and must not have changed its email since it was read
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+		input.ConditionExpression = aws.String(repo.emailCondition(email, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+	}
+	err = repo.withRetry(ctx, "RestoreUser", func() error {
+		_, err := repo.client.UpdateItemWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
-		log.Printf("DynamoDB DeleteItem error: %v", err)
-		return fmt.Errorf("%s: %w", ErrorCouldNotDeleteItem, err)
+		if isConditionalCheckFailed(err) {
+			return nil, ErrUserDoesNotExist
+		}
+		slog.Error("DynamoDB UpdateItem failed", slog.String("operation", "RestoreUser"), slog.Any("error", err))
+		return nil, fmt.Errorf("%w: %w", ErrCouldNotDynamoPutItem, err)
 	}
-	return nil
-}
\ No newline at end of file
+
+	currentUser.Deleted = false
+	currentUser.DeletedAt = ""
+	return currentUser, nil
+}
+
+// notDeletedFilter matches records that have not been soft-deleted.
+const notDeletedFilter = "attribute_not_exists(#deleted) OR #deleted <> :true"
+
+// addCreatedAtFilter ANDs the creation window of opts onto a Scan or Query filter expression.
+// createdAt is stored as a UTC RFC3339 string, so the bounds are formatted the same way and
+// compared lexically.
+func addCreatedAtFilter(opts FetchOptions, filter **string, names *map[string]*string, values *map[string]*dynamodb.AttributeValue) {
+	var conditions []string
+	bounds := map[string]*dynamodb.AttributeValue{}
+	if !opts.CreatedAfter.IsZero() {
+		conditions = append(conditions, "#createdAt > :createdAfter")
+		bounds[":createdAfter"] = &dynamodb.AttributeValue{S: aws.String(opts.CreatedAfter.UTC().Format(time.RFC3339))}
+	}
+	if !opts.CreatedBefore.IsZero() {
+		conditions = append(conditions, "#createdAt < :createdBefore")
+		bounds[":createdBefore"] = &dynamodb.AttributeValue{S: aws.String(opts.CreatedBefore.UTC().Format(time.RFC3339))}
+	}
+	if len(conditions) == 0 {
+		return
+	}
+
+	expression := strings.Join(conditions, " AND ")
+	if *filter != nil {
+		expression = "(" + aws.StringValue(*filter) + ") AND " + expression
+	}
+	*filter = aws.String(expression)
+	if *names == nil {
+		*names = map[string]*string{}
+	}
+	(*names)["#createdAt"] = aws.String("createdAt")
+	if *values == nil {
+		*values = map[string]*dynamodb.AttributeValue{}
+	}
+	maps.Copy(*values, bounds)
+}
+
+// decodeLastEvaluatedKey parses a pagination token produced by encodeLastEvaluatedKey.
+// An empty token yields a nil start key.
+func decodeLastEvaluatedKey(token string) (map[string]*dynamodb.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+	var startKey map[string]*dynamodb.AttributeValue
+	err := json.Unmarshal([]byte(token), &startKey)
+	if err != nil {
+		slog.Warn("Invalid lastEvaluatedKey JSON", slog.String("operation", "decodeLastEvaluatedKey"), slog.Any("error", err))
+		return nil, ErrInvalidLastEvaluatedKey
+	}
+	return startKey, nil
+}
+
+// encodeLastEvaluatedKey turns a DynamoDB LastEvaluatedKey into an opaque pagination token.
+// A nil key (no more pages) yields an empty token.
+func encodeLastEvaluatedKey(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if key == nil {
+		return "", nil
+	}
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		slog.Error("Could not marshal LastEvaluatedKey", slog.String("operation", "encodeLastEvaluatedKey"), slog.Any("error", err))
+		return "", fmt.Errorf("could not marshal LastEvaluatedKey: %w", err)
+	}
+	return string(keyBytes), nil
+}
+
+// unmarshalUserPage converts a page of DynamoDB items into users plus the token for the next page.
+func unmarshalUserPage(items []map[string]*dynamodb.AttributeValue, lastKey map[string]*dynamodb.AttributeValue) ([]models.User, string, error) {
+	users := new([]models.User)
+	err := dynamodbattribute.UnmarshalListOfMaps(items, users)
+	if err != nil {
+		slog.Error("DynamoDB UnmarshalListOfMaps failed", slog.String("operation", "unmarshalUserPage"), slog.Any("error", err))
+		return nil, "", fmt.Errorf("%w: %w", ErrFailedToUnmarshalRecord, err)
+	}
+
+	newLastEvaluatedKey, err := encodeLastEvaluatedKey(lastKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return *users, newLastEvaluatedKey, nil
+}
+
+// projection builds a ProjectionExpression for fields. Every attribute is referenced through a
+// placeholder added to names, so fields that are DynamoDB reserved words (e.g. "role") are safe.
+func projection(fields []string, names map[string]*string) *string {
+	return aws.String(expressionNames(names).list(fields))
+}
+
+// sleepContext waits for d, returning early with the context's error if it is cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// timestamp returns the current time in the RFC3339 format used for stored dates.
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// newUserID returns a random (version 4) UUID for User.ID.
+func newUserID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// stampNewUser sets the server-managed fields on a user that is about to be inserted.
+func stampNewUser(user *models.User) {
+	now := timestamp()
+	user.ID = newUserID()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+	user.NormalizedEmail = validators.NormalizeEmail(user.Email)
+	indexUser(user)
+	if user.Role == "" {
+		user.Role = models.RoleViewer
+	}
+	user.Deleted = false
+	user.DeletedAt = ""
+	user.ExpiresAt = 0
+	if user.TTLSeconds > 0 {
+		user.ExpiresAt = time.Now().Unix() + user.TTLSeconds
+	}
+	user.TTLSeconds = 0
+}
+
+// isConditionalCheckFailed reports whether err is DynamoDB rejecting a write because its condition did not hold.
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// userKey builds the DynamoDB primary key for a user.
+func userKey(email string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"email": {
+			S: aws.String(email),
+		},
+	}
+}
@@ -0,0 +1,1061 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCreateUsersSkipsExistingUsers(t *testing.T) {
+	expiredAt := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name         string
+		stored       []models.User
+		create       []string
+		wantCreated  []string
+		wantExisting []string
+	}{
+		{
+			name:        "no existing users",
+			create:      []string{"a@example.com", "b@example.com"},
+			wantCreated: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			name:         "one existing user",
+			stored:       []models.User{{Email: "a@example.com", FirstName: "Old"}},
+			create:       []string{"A@Example.com", "b@example.com"},
+			wantCreated:  []string{"b@example.com"},
+			wantExisting: []string{"a@example.com"},
+		},
+		{
+			name:         "soft-deleted user",
+			stored:       []models.User{{Email: "a@example.com", Deleted: true}},
+			create:       []string{"a@example.com"},
+			wantExisting: []string{"a@example.com"},
+		},
+		{
+			name:        "expired user",
+			stored:      []models.User{{Email: "a@example.com", ExpiresAt: expiredAt}},
+			create:      []string{"a@example.com"},
+			wantCreated: []string{"a@example.com"},
+		},
+		{
+			name:         "all existing",
+			stored:       []models.User{{Email: "a@example.com"}, {Email: "b@example.com"}},
+			create:       []string{"a@example.com", "b@example.com"},
+			wantExisting: []string{"a@example.com", "b@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written []string
+			client := &mockDynamoDB{
+				batchGetItem: batchGetFrom(t, tt.stored...),
+				batchWriteItem: func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+					for _, request := range input.RequestItems[testTable] {
+						written = append(written, aws.StringValue(request.PutRequest.Item["email"].S))
+					}
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			users := make([]models.User, len(tt.create))
+			for i, email := range tt.create {
+				users[i] = models.User{Email: email, FirstName: "New", LastName: "User"}
+			}
+			result, err := repo.CreateUsers(context.Background(), users)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var created []string
+			for _, user := range result.Created {
+				created = append(created, user.Email)
+			}
+			if !slices.Equal(created, tt.wantCreated) {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if !slices.Equal(written, tt.wantCreated) {
+				t.Errorf("written = %v, want %v", written, tt.wantCreated)
+			}
+			if !slices.Equal(result.Existing, tt.wantExisting) {
+				t.Errorf("existing = %v, want %v", result.Existing, tt.wantExisting)
+			}
+			if len(result.Failed) != 0 {
+				t.Errorf("failed = %v, want none", result.Failed)
+			}
+		})
+	}
+}
+
+func TestInMemoryCreateUsersSkipsExistingUsers(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	if _, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := repo.CreateUsers(context.Background(), []models.User{
+		{Email: "a@example.com", FirstName: "New"},
+		{Email: "b@example.com", FirstName: "New"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created) != 1 || result.Created[0].Email != "b@example.com" {
+		t.Errorf("created = %v, want b@example.com", result.Created)
+	}
+	if !slices.Equal(result.Existing, []string{"a@example.com"}) {
+		t.Errorf("existing = %v, want [a@example.com]", result.Existing)
+	}
+	stored, err := repo.FetchUser(context.Background(), "a@example.com", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FirstName != "Old" {
+		t.Errorf("a@example.com was overwritten with %q", stored.FirstName)
+	}
+}
+
+func TestCreateUserIsConditional(t *testing.T) {
+	tests := []struct {
+		name    string
+		putErr  error
+		wantErr error
+	}{
+		{name: "new user"},
+		{name: "existing user", putErr: errConditionFailed, wantErr: ErrUserAlreadyExists},
+		{name: "other errors", putErr: awserr.New("ValidationException", "invalid", nil), wantErr: ErrCouldNotDynamoPutItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); !strings.HasPrefix(got, "attribute_not_exists(email)") {
+						t.Errorf("condition = %q", got)
+					}
+					return &dynamodb.PutItemOutput{}, tt.putErr
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "Jane"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && created.Email != "a@example.com" {
+				t.Errorf("created = %+v", created)
+			}
+		})
+	}
+}
+
+func TestUpdateUserIsConditional(t *testing.T) {
+	// With ReturnValuesOnConditionCheckFailure, DynamoDB returns the stored item with the failure
+	conditionFailedWith := func(user models.User) error {
+		return &dynamodb.ConditionalCheckFailedException{Message_: aws.String("The conditional request failed"), Item: marshalUser(t, user)}
+	}
+	tests := []struct {
+		name      string
+		updateErr error
+		wantErr   error
+	}{
+		{name: "existing user"},
+		{name: "missing user", updateErr: errConditionFailed, wantErr: ErrUserDoesNotExist},
+		{name: "soft-deleted user", updateErr: conditionFailedWith(models.User{Email: "a@example.com", Deleted: true}), wantErr: ErrUserDoesNotExist},
+		{name: "stale version", updateErr: conditionFailedWith(models.User{Email: "a@example.com", Version: 3}), wantErr: ErrVersionConflict},
+		{name: "other errors", updateErr: awserr.New("ValidationException", "invalid", nil), wantErr: ErrCouldNotDynamoPutItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); !strings.HasPrefix(got, "attribute_exists(") {
+						t.Errorf("condition = %q", got)
+					}
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, models.User{Email: "a@example.com", FirstName: "Jane", Version: 2})}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			updated, err := repo.UpdateUser(context.Background(), models.User{Email: "A@example.com", FirstName: "Jane", Version: 1})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (updated.FirstName != "Jane" || updated.Version != 2) {
+				t.Errorf("updated = %+v", updated)
+			}
+		})
+	}
+}
+
+func TestUpdatesKeepCreatedAt(t *testing.T) {
+	const createdAt = "2020-01-01T00:00:00Z"
+	tests := []struct {
+		name   string
+		update func(repo *InMemoryUserRepository, user models.User) (*models.User, error)
+		upsert bool
+	}{
+		{
+			name: "UpdateUser",
+			update: func(repo *InMemoryUserRepository, user models.User) (*models.User, error) {
+				return repo.UpdateUser(context.Background(), user)
+			},
+		},
+		{
+			name: "UpsertUser",
+			update: func(repo *InMemoryUserRepository, user models.User) (*models.User, error) {
+				updated, _, err := repo.UpsertUser(context.Background(), user)
+				return updated, err
+			},
+			upsert: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// DynamoDB: createdAt is never overwritten, and only set by an upsert creating the user
+			update := buildUserUpdate(models.User{Email: "a@example.com", FirstName: "Jane"}, tt.upsert, false)
+			sets := 0
+			for name, attr := range update.names {
+				if aws.StringValue(attr) != "createdAt" {
+					continue
+				}
+				sets = strings.Count(update.expression, name+" =")
+				if tt.upsert && !strings.Contains(update.expression, name+" = if_not_exists("+name+", :updatedAt)") {
+					t.Errorf("update %q overwrites createdAt", update.expression)
+				}
+			}
+			want := 0
+			if tt.upsert {
+				want = 1
+			}
+			if sets != want {
+				t.Errorf("update %q sets createdAt %d times, want %d", update.expression, sets, want)
+			}
+
+			// In memory
+			repo := NewInMemoryUserRepository(DynamoDBOptions{})
+			created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", FirstName: "John"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			created.CreatedAt, created.UpdatedAt = createdAt, createdAt
+			repo.users["a@example.com"] = *created
+
+			updated, err := tt.update(repo, models.User{Email: "a@example.com", FirstName: "Jane", Version: created.Version})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.CreatedAt != createdAt {
+				t.Errorf("createdAt = %q, want %q", updated.CreatedAt, createdAt)
+			}
+			if updated.UpdatedAt == createdAt {
+				t.Error("updatedAt was not refreshed")
+			}
+		})
+	}
+}
+
+func TestMixedCaseEmailsHitTheSameRecord(t *testing.T) {
+	emails := []string{"user@example.com", "User@Example.com", "USER@EXAMPLE.COM", "  uSeR@eXaMpLe.CoM "}
+	stored := models.User{Email: "user@example.com", FirstName: "Jane", Version: 1}
+	for _, email := range emails {
+		t.Run(email, func(t *testing.T) {
+			var keys []string
+			keyOf := func(key map[string]*dynamodb.AttributeValue) {
+				keys = append(keys, aws.StringValue(key["email"].S))
+			}
+			client := &mockDynamoDB{
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					keyOf(input.Item)
+					return &dynamodb.PutItemOutput{}, nil
+				},
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					keyOf(input.Key)
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, stored)}, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					keyOf(input.Key)
+					return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, stored)}, nil
+				},
+				deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					keyOf(input.Key)
+					return &dynamodb.DeleteItemOutput{Attributes: marshalUser(t, stored)}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+			ctx := context.Background()
+
+			if _, err := repo.CreateUser(ctx, models.User{Email: email, FirstName: "Jane"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.FetchUser(ctx, email, FetchOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.UpdateUser(ctx, models.User{Email: email, FirstName: "Jane", Version: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.DeleteUser(ctx, email); err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) < 4 {
+				t.Fatalf("keys = %q, want one per operation", keys)
+			}
+			for _, key := range keys {
+				if key != "user@example.com" {
+					t.Errorf("keys = %q, want only user@example.com", keys)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryMixedCaseEmailsHitTheSameRecord(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	if _, err := repo.CreateUser(ctx, models.User{Email: "User@Example.com", FirstName: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateUser(ctx, models.User{Email: "user@example.com"}); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("err = %v, want %v", err, ErrUserAlreadyExists)
+	}
+
+	user, err := repo.FetchUser(ctx, " USER@EXAMPLE.COM ", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.Email != "user@example.com" || user.FirstName != "Jane" {
+		t.Fatalf("fetched = %+v", user)
+	}
+	if _, err := repo.UpdateUser(ctx, models.User{Email: "uSeR@example.COM", FirstName: "Janet", Version: user.Version}); err != nil {
+		t.Fatal(err)
+	}
+	user, err = repo.FetchUser(ctx, "user@example.com", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.FirstName != "Janet" {
+		t.Errorf("fetched = %+v, want the update", user)
+	}
+	if _, err := repo.DeleteUser(ctx, "User@EXAMPLE.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.users) != 0 {
+		t.Errorf("users = %v, want none", repo.users)
+	}
+}
+
+func TestInMemoryFetchUsersPaginates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository(DynamoDBOptions{SoftDelete: true})
+	for _, email := range []string{"d@example.com", "b@example.com", "e@example.com", "a@example.com", "c@example.com"} {
+		if _, err := repo.CreateUser(ctx, models.User{Email: email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.DeleteUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantPages [][]string
+	}{
+		{name: "pages of two", limit: 2, wantPages: [][]string{{"a@example.com", "b@example.com"}, {"d@example.com", "e@example.com"}}},
+		{name: "pages of three", limit: 3, wantPages: [][]string{{"a@example.com", "b@example.com", "d@example.com"}, {"e@example.com"}}},
+		{name: "one page", limit: 10, wantPages: [][]string{{"a@example.com", "b@example.com", "d@example.com", "e@example.com"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages [][]string
+			token := ""
+			for {
+				users, next, err := repo.FetchUsers(ctx, tt.limit, token, FetchOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var page []string
+				for _, user := range users {
+					page = append(page, user.Email)
+				}
+				pages = append(pages, page)
+				if next == "" {
+					break
+				}
+				if len(pages) > len(tt.wantPages) {
+					t.Fatalf("pages = %v, want %v", pages, tt.wantPages)
+				}
+				token = next
+			}
+			if !slices.EqualFunc(pages, tt.wantPages, slices.Equal) {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+
+	if _, _, err := repo.FetchUsers(ctx, 2, "not a token", FetchOptions{}); !errors.Is(err, ErrInvalidLastEvaluatedKey) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidLastEvaluatedKey)
+	}
+}
+
+func TestCreateUserDefaultsRole(t *testing.T) {
+	tests := []struct {
+		role models.Role
+		want models.Role
+	}{
+		{"", models.RoleViewer},
+		{models.RoleEditor, models.RoleEditor},
+		{models.RoleAdmin, models.RoleAdmin},
+	}
+	for _, tt := range tests {
+		var written models.Role
+		client := &mockDynamoDB{
+			putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				written = models.Role(aws.StringValue(input.Item["role"].S))
+				return &dynamodb.PutItemOutput{}, nil
+			},
+		}
+		repos := map[string]UserRepository{
+			"DynamoDB":  NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{}),
+			"in memory": NewInMemoryUserRepository(DynamoDBOptions{}),
+		}
+		for name, repo := range repos {
+			created, err := repo.CreateUser(context.Background(), models.User{Email: "a@example.com", Role: tt.role})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.Role != tt.want {
+				t.Errorf("%s: role %q created as %q, want %q", name, tt.role, created.Role, tt.want)
+			}
+		}
+		if written != tt.want {
+			t.Errorf("role %q written as %q, want %q", tt.role, written, tt.want)
+		}
+	}
+}
+
+func TestFetchUserFallsBackToNormalizedEmailIndex(t *testing.T) {
+	legacy := models.User{Email: "User@Example.com", NormalizedEmail: "user@example.com", FirstName: "Legacy"}
+	tests := []struct {
+		name      string
+		keyed     []models.User // users GetItem finds by their email
+		indexed   []models.User // users the index finds by their normalized email
+		index     string
+		wantFirst string
+		wantQuery bool
+	}{
+		{name: "record keyed on the normalized email", keyed: []models.User{{Email: "user@example.com", FirstName: "Current"}}, index: "normalized-email-index", wantFirst: "Current"},
+		{name: "legacy uppercase record", indexed: []models.User{legacy}, index: "normalized-email-index", wantFirst: "Legacy", wantQuery: true},
+		{name: "no record", index: "normalized-email-index", wantQuery: true},
+		{name: "index not configured", indexed: []models.User{legacy}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried := false
+			client := &mockDynamoDB{
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					for _, user := range tt.keyed {
+						if aws.StringValue(input.Key["email"].S) == user.Email {
+							return &dynamodb.GetItemOutput{Item: marshalUser(t, user)}, nil
+						}
+					}
+					return &dynamodb.GetItemOutput{}, nil
+				},
+				query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					queried = true
+					if aws.StringValue(input.IndexName) != tt.index || aws.StringValue(input.ExpressionAttributeValues[":normalizedEmail"].S) != "user@example.com" {
+						t.Errorf("query = %v", input)
+					}
+					var items []map[string]*dynamodb.AttributeValue
+					for _, user := range tt.indexed {
+						items = append(items, marshalUser(t, user))
+					}
+					return &dynamodb.QueryOutput{Items: items}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{NormalizedEmailIndex: tt.index})
+
+			user, err := repo.FetchUser(context.Background(), "user@example.com", FetchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotFirst string
+			if user != nil {
+				gotFirst = user.FirstName
+			}
+			if gotFirst != tt.wantFirst {
+				t.Errorf("first name = %q, want %q", gotFirst, tt.wantFirst)
+			}
+			if queried != tt.wantQuery {
+				t.Errorf("queried = %v, want %v", queried, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestWritesSetNormalizedEmail(t *testing.T) {
+	var written []string
+	client := &mockDynamoDB{
+		putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			written = append(written, aws.StringValue(input.Item["normalizedEmail"].S))
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if !strings.Contains(aws.StringValue(input.UpdateExpression), " = :normalizedEmail") {
+				t.Errorf("update %q does not set normalizedEmail", aws.StringValue(input.UpdateExpression))
+			}
+			written = append(written, aws.StringValue(input.ExpressionAttributeValues[":normalizedEmail"].S))
+			return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, models.User{Email: "user@example.com"})}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+	if _, err := repo.CreateUser(context.Background(), models.User{Email: "User@Example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateUser(context.Background(), models.User{Email: "USER@example.com", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"user@example.com", "user@example.com"}; !slices.Equal(written, want) {
+		t.Errorf("normalizedEmail written = %v, want %v", written, want)
+	}
+}
+
+func TestUpsertUser(t *testing.T) {
+	tests := []struct {
+		name          string
+		storedVersion int   // version after the write
+		updateErr     error // the UpdateItem fails its condition when there is no live record
+		putErr        error
+		wantPuts      int
+		wantCreated   bool
+		wantErr       error
+	}{
+		{name: "creates a missing user", updateErr: errConditionFailed, wantPuts: 1, wantCreated: true},
+		{name: "updates an existing user", storedVersion: 4},
+		{name: "updates a user written before versioning", storedVersion: 1},
+		{name: "replaces an expired user", updateErr: errConditionFailed, wantPuts: 1, wantCreated: true},
+		{name: "user created concurrently", updateErr: errConditionFailed, putErr: errConditionFailed, wantPuts: 1, wantErr: ErrVersionConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			puts := 0
+			client := &mockDynamoDB{
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if got := aws.StringValue(input.ConditionExpression); got != "attribute_exists(#email) AND (attribute_not_exists(#expiresAt) OR #expiresAt > :now)" {
+						t.Errorf("condition = %q, want only live records updated", got)
+					}
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, models.User{Email: "a@example.com", FirstName: "Jane", Version: tt.storedVersion})}, nil
+				},
+				putItem: func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					puts++
+					if _, ok := input.Item["expiresAt"]; ok {
+						t.Error("the replacement kept an expiry")
+					}
+					return &dynamodb.PutItemOutput{}, tt.putErr
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{MaxAttempts: 1})
+
+			upserted, created, err := repo.UpsertUser(context.Background(), models.User{Email: "A@Example.com", FirstName: "Jane"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if puts != tt.wantPuts {
+				t.Errorf("%d puts, want %d", puts, tt.wantPuts)
+			}
+			if err != nil {
+				return
+			}
+			if created != tt.wantCreated || upserted.FirstName != "Jane" {
+				t.Errorf("upserted = %+v, created = %v, want created %v", upserted, created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestFetchUserConsistentRead(t *testing.T) {
+	for _, consistent := range []bool{false, true} {
+		var got *bool
+		client := &mockDynamoDB{
+			getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+				got = input.ConsistentRead
+				return &dynamodb.GetItemOutput{}, nil
+			},
+		}
+		repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+		if _, err := repo.FetchUser(context.Background(), "a@example.com", FetchOptions{ConsistentRead: consistent}); err != nil {
+			t.Fatal(err)
+		}
+		if aws.BoolValue(got) != consistent {
+			t.Errorf("ConsistentRead %v: GetItem ConsistentRead = %v", consistent, aws.BoolValue(got))
+		}
+	}
+}
+
+// projectedAttributes resolves the placeholders of a projection expression to attribute names.
+func projectedAttributes(t *testing.T, expression *string, names map[string]*string) []string {
+	t.Helper()
+	var attrs []string
+	for _, placeholder := range strings.Split(aws.StringValue(expression), ",") {
+		placeholder = strings.TrimSpace(placeholder)
+		name, ok := names[placeholder]
+		if !ok {
+			t.Fatalf("projection %q uses %s without a name", aws.StringValue(expression), placeholder)
+		}
+		attrs = append(attrs, aws.StringValue(name))
+	}
+	slices.Sort(attrs)
+	return attrs
+}
+
+func TestFetchProjectsFields(t *testing.T) {
+	// A single user is read with its soft-delete flag and expiry, to hide it when deleted or
+	// expired; listings filter those server-side
+	want := [][]string{{"deleted", "email", "expiresAt", "firstName"}, {"email", "firstName"}}
+	stored := models.User{Email: "a@example.com", FirstName: "Ada"}
+	var projections [][]string
+	client := &mockDynamoDB{
+		getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			projections = append(projections, projectedAttributes(t, input.ProjectionExpression, input.ExpressionAttributeNames))
+			return &dynamodb.GetItemOutput{Item: marshalUser(t, stored)}, nil
+		},
+		scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			projections = append(projections, projectedAttributes(t, input.ProjectionExpression, input.ExpressionAttributeNames))
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{marshalUser(t, stored)}}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+	opts := FetchOptions{Fields: []string{"email", "firstName"}}
+
+	if _, err := repo.FetchUser(context.Background(), "a@example.com", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.FetchUsers(context.Background(), 10, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(projections, want, slices.Equal) {
+		t.Errorf("projections = %v, want %v", projections, want)
+	}
+}
+
+func TestFetchUsersByLastNameOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		descending bool
+		want       bool
+	}{
+		{name: "asc", want: true},
+		{name: "desc", descending: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forward *bool
+			client := &mockDynamoDB{
+				query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					forward = input.ScanIndexForward
+					return &dynamodb.QueryOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{LastNameIndex: "lastName-index"})
+
+			if _, _, err := repo.FetchUsersByLastName(context.Background(), "Lee", 10, "", FetchOptions{Descending: tt.descending}); err != nil {
+				t.Fatal(err)
+			}
+			if forward == nil || *forward != tt.want {
+				t.Errorf("ScanIndexForward = %v, want %v", aws.BoolValue(forward), tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryFetchUsersByLastNameOrder(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	for _, user := range []models.User{
+		{Email: "b@example.com", LastName: "Lee"},
+		{Email: "c@example.com", LastName: "Lee"},
+		{Email: "a@example.com", LastName: "Lee"},
+		{Email: "d@example.com", LastName: "Kim"},
+	} {
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name       string
+		descending bool
+		want       []string
+	}{
+		{name: "asc", want: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{name: "desc", descending: true, want: []string{"c@example.com", "b@example.com", "a@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := FetchOptions{Descending: tt.descending}
+			var got []string
+			token := ""
+			for {
+				users, next, err := repo.FetchUsersByLastName(ctx, "Lee", 2, token, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, user := range users {
+					got = append(got, user.Email)
+				}
+				if next == "" {
+					break
+				}
+				token = next
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("emails = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCreatedAtFilter(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name       string
+		opts       FetchOptions
+		filter     string // Filter already on the input
+		wantFilter string
+		wantValues map[string]string
+	}{
+		{name: "no bounds"},
+		{
+			name:       "after",
+			opts:       FetchOptions{CreatedAfter: after},
+			wantFilter: "#createdAt > :createdAfter",
+			wantValues: map[string]string{":createdAfter": "2024-01-01T00:00:00Z"},
+		},
+		{
+			name:       "before, in UTC",
+			opts:       FetchOptions{CreatedBefore: before},
+			wantFilter: "#createdAt < :createdBefore",
+			wantValues: map[string]string{":createdBefore": "2024-02-01T08:30:00Z"},
+		},
+		{
+			name:       "window",
+			opts:       FetchOptions{CreatedAfter: after, CreatedBefore: before},
+			wantFilter: "#createdAt > :createdAfter AND #createdAt < :createdBefore",
+			wantValues: map[string]string{":createdAfter": "2024-01-01T00:00:00Z", ":createdBefore": "2024-02-01T08:30:00Z"},
+		},
+		{
+			name:       "combined with an existing filter",
+			opts:       FetchOptions{CreatedAfter: after},
+			filter:     notDeletedFilter,
+			wantFilter: "(" + notDeletedFilter + ") AND #createdAt > :createdAfter",
+			wantValues: map[string]string{":createdAfter": "2024-01-01T00:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter *string
+			if tt.filter != "" {
+				filter = aws.String(tt.filter)
+			}
+			var names map[string]*string
+			var values map[string]*dynamodb.AttributeValue
+
+			addCreatedAtFilter(tt.opts, &filter, &names, &values)
+
+			if got := aws.StringValue(filter); got != tt.wantFilter {
+				t.Errorf("filter = %q, want %q", got, tt.wantFilter)
+			}
+			gotValues := map[string]string{}
+			for name, value := range values {
+				gotValues[name] = aws.StringValue(value.S)
+			}
+			if tt.wantValues == nil {
+				tt.wantValues = map[string]string{}
+			}
+			if !maps.Equal(gotValues, tt.wantValues) {
+				t.Errorf("values = %v, want %v", gotValues, tt.wantValues)
+			}
+			if tt.wantFilter != "" && aws.StringValue(names["#createdAt"]) != "createdAt" {
+				t.Errorf("names = %v, want #createdAt", names)
+			}
+		})
+	}
+}
+
+func TestFetchUsersFiltersByCreatedAt(t *testing.T) {
+	var input *dynamodb.ScanInput
+	client := &mockDynamoDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			input = in
+			return &dynamodb.ScanOutput{}, nil
+		},
+	}
+	repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{SoftDelete: true})
+
+	opts := FetchOptions{CreatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, _, err := repo.FetchUsers(context.Background(), 10, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(input.FilterExpression); !strings.HasSuffix(got, ") AND #createdAt > :createdAfter") {
+		t.Errorf("filter = %q, want the deleted filter and the creation bound", got)
+	}
+	if input.ExpressionAttributeValues[":true"] == nil || input.ExpressionAttributeValues[":createdAfter"] == nil {
+		t.Errorf("values = %v", input.ExpressionAttributeValues)
+	}
+}
+
+func TestInMemoryFetchUsersFiltersByCreatedAt(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts FetchOptions
+		want int
+	}{
+		{name: "created after an hour ago", opts: FetchOptions{CreatedAfter: time.Now().Add(-time.Hour)}, want: 1},
+		{name: "created after an hour from now", opts: FetchOptions{CreatedAfter: time.Now().Add(time.Hour)}, want: 0},
+		{name: "created before an hour ago", opts: FetchOptions{CreatedBefore: time.Now().Add(-time.Hour)}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, _, err := repo.FetchUsers(ctx, 10, "", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.want {
+				t.Errorf("%d users, want %d", len(users), tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchUsersByEmailPrefix(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		opts       FetchOptions
+		wantFilter string
+		wantPrefix string
+	}{
+		{
+			name:       "live users",
+			prefix:     "support@",
+			wantFilter: "((" + notDeletedFilter + ") AND (" + notExpiredFilter + ")) AND (begins_with(#email, :emailPrefix))",
+			wantPrefix: "support@",
+		},
+		{
+			name:       "including deleted users",
+			prefix:     "support@",
+			opts:       FetchOptions{IncludeDeleted: true},
+			wantFilter: "(" + notExpiredFilter + ") AND (begins_with(#email, :emailPrefix))",
+			wantPrefix: "support@",
+		},
+		{
+			name:       "prefix normalized like emails",
+			prefix:     " Support@Example ",
+			opts:       FetchOptions{IncludeDeleted: true},
+			wantFilter: "(" + notExpiredFilter + ") AND (begins_with(#email, :emailPrefix))",
+			wantPrefix: "support@example",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *dynamodb.ScanInput
+			client := &mockDynamoDB{
+				scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					input = in
+					return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+						marshalUser(t, models.User{Email: "support@example.com"}),
+					}}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, DynamoDBOptions{})
+
+			users, _, err := repo.FetchUsersByEmailPrefix(context.Background(), tt.prefix, 10, "", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != 1 {
+				t.Errorf("%d users, want 1", len(users))
+			}
+			if got := aws.StringValue(input.FilterExpression); got != tt.wantFilter {
+				t.Errorf("filter = %q, want %q", got, tt.wantFilter)
+			}
+			if got := aws.StringValue(input.ExpressionAttributeNames["#email"]); got != "email" {
+				t.Errorf("#email = %q, want email", got)
+			}
+			if got := aws.StringValue(input.ExpressionAttributeValues[":emailPrefix"].S); got != tt.wantPrefix {
+				t.Errorf(":emailPrefix = %q, want %q", got, tt.wantPrefix)
+			}
+			if aws.Int64Value(input.Limit) != 10 {
+				t.Errorf("limit = %d, want 10", aws.Int64Value(input.Limit))
+			}
+		})
+	}
+}
+
+func TestInMemoryFetchUsersByEmailPrefix(t *testing.T) {
+	repo := NewInMemoryUserRepository(DynamoDBOptions{})
+	ctx := context.Background()
+	for _, email := range []string{"support@example.com", "support@example.org", "sales@example.com"} {
+		if _, err := repo.CreateUser(ctx, models.User{Email: email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users, _, err := repo.FetchUsersByEmailPrefix(ctx, "Support@", 10, "", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, user := range users {
+		got = append(got, user.Email)
+	}
+	if want := []string{"support@example.com", "support@example.org"}; !slices.Equal(got, want) {
+		t.Errorf("emails = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteUserExistenceCheck(t *testing.T) {
+	stored := models.User{Email: "a@example.com", FirstName: "Ann"}
+	tests := []struct {
+		name          string
+		opts          DynamoDBOptions
+		exists        bool
+		wantReads     int
+		wantWrites    int
+		wantCondition string
+		wantErr       error
+	}{
+		{name: "legacy, existing user", exists: true, wantReads: 1, wantWrites: 1, wantCondition: "(" + notExpiredFilter + ")"},
+		{name: "legacy, missing user", wantReads: 1, wantErr: ErrUserDoesNotExist},
+		{
+			name: "skipped, existing user", opts: DynamoDBOptions{SkipExistenceCheck: true}, exists: true,
+			wantWrites: 1, wantCondition: "attribute_exists(email) AND (" + notDeletedFilter + ") AND (" + notExpiredFilter + ")",
+		},
+		{
+			name: "skipped, missing user", opts: DynamoDBOptions{SkipExistenceCheck: true},
+			wantWrites: 1, wantCondition: "attribute_exists(email) AND (" + notDeletedFilter + ") AND (" + notExpiredFilter + ")", wantErr: ErrUserDoesNotExist,
+		},
+		{
+			name: "skipped, soft delete of a missing user", opts: DynamoDBOptions{SkipExistenceCheck: true, SoftDelete: true},
+			wantWrites: 1, wantCondition: "attribute_exists(email) AND (" + notDeletedFilter + ") AND (" + notExpiredFilter + ")", wantErr: ErrUserDoesNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads, writes := 0, 0
+			var condition string
+			write := func(cond *string) error {
+				writes++
+				condition = aws.StringValue(cond)
+				if !tt.exists {
+					return errConditionFailed
+				}
+				return nil
+			}
+			client := &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					reads++
+					if !tt.exists {
+						return &dynamodb.GetItemOutput{}, nil
+					}
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, stored)}, nil
+				},
+				deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					if err := write(input.ConditionExpression); err != nil {
+						return nil, err
+					}
+					return &dynamodb.DeleteItemOutput{Attributes: marshalUser(t, stored)}, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if err := write(input.ConditionExpression); err != nil {
+						return nil, err
+					}
+					return &dynamodb.UpdateItemOutput{Attributes: marshalUser(t, stored)}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, tt.opts)
+
+			deleted, err := repo.DeleteUser(context.Background(), "a@example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && deleted.FirstName != "Ann" {
+				t.Errorf("deleted = %+v, want the stored user", deleted)
+			}
+			if reads != tt.wantReads || writes != tt.wantWrites {
+				t.Errorf("%d reads and %d writes, want %d and %d", reads, writes, tt.wantReads, tt.wantWrites)
+			}
+			if condition != tt.wantCondition {
+				t.Errorf("condition = %q, want %q", condition, tt.wantCondition)
+			}
+		})
+	}
+}
+
+func TestDeleteUserReturnsTheDeletedUser(t *testing.T) {
+	stored := models.User{Email: "a@example.com", FirstName: "Ann", LastName: "Lee", Version: 3, Metadata: map[string]string{"plan": "pro"}}
+	tests := []struct {
+		name     string
+		opts     DynamoDBOptions
+		returned map[string]*dynamodb.AttributeValue
+		wantErr  error
+	}{
+		{name: "hard delete", returned: marshalUser(t, stored)},
+		{name: "soft delete", opts: DynamoDBOptions{SoftDelete: true}, returned: marshalUser(t, stored)},
+		{name: "deleted concurrently", wantErr: ErrUserDoesNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var returnValues []string
+			client := &mockDynamoDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					return &dynamodb.GetItemOutput{Item: marshalUser(t, stored)}, nil
+				},
+				deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+					returnValues = append(returnValues, aws.StringValue(input.ReturnValues))
+					return &dynamodb.DeleteItemOutput{Attributes: tt.returned}, nil
+				},
+				updateItem: func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					returnValues = append(returnValues, aws.StringValue(input.ReturnValues))
+					return &dynamodb.UpdateItemOutput{Attributes: tt.returned}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(client, testTable, tt.opts)
+
+			deleted, err := repo.DeleteUser(context.Background(), "a@example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(returnValues, []string{dynamodb.ReturnValueAllOld}) {
+				t.Errorf("writes returned %v, want a single write returning %s", returnValues, dynamodb.ReturnValueAllOld)
+			}
+			if err == nil && !reflect.DeepEqual(*deleted, stored) {
+				t.Errorf("deleted = %+v, want %+v", *deleted, stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// TracedUserRepository decorates a UserRepository, wrapping every call in an X-Ray subsegment
+// named after the operation. The DynamoDB calls made inside appear as nested subsegments when
+// the client is instrumented with xray.AWS.
+type TracedUserRepository struct {
+	repository.UserRepository
+}
+
+// NewTracedUserRepository wraps repo so that each operation is traced.
+func NewTracedUserRepository(repo repository.UserRepository) *TracedUserRepository {
+	return &TracedUserRepository{
+		UserRepository: repo,
+	}
+}
+
+// FetchUser traces UserRepository.FetchUser.
+func (r *TracedUserRepository) FetchUser(ctx context.Context, email string, opts repository.FetchOptions) (user *models.User, err error) {
+	err = xray.Capture(ctx, "FetchUser", func(ctx context.Context) error {
+		user, err = r.UserRepository.FetchUser(ctx, email, opts)
+		return err
+	})
+	return user, err
+}
+
+// UserExists traces UserRepository.UserExists.
+func (r *TracedUserRepository) UserExists(ctx context.Context, email string) (exists bool, err error) {
+	err = xray.Capture(ctx, "UserExists", func(ctx context.Context) error {
+		exists, err = r.UserRepository.UserExists(ctx, email)
+		return err
+	})
+	return exists, err
+}
+
+// FetchUsers traces UserRepository.FetchUsers.
+func (r *TracedUserRepository) FetchUsers(ctx context.Context, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	err = xray.Capture(ctx, "FetchUsers", func(ctx context.Context) error {
+		users, next, err = r.UserRepository.FetchUsers(ctx, limit, lastEvaluatedKey, opts)
+		return err
+	})
+	return users, next, err
+}
+
+// FetchUsersByLastName traces UserRepository.FetchUsersByLastName.
+func (r *TracedUserRepository) FetchUsersByLastName(ctx context.Context, lastName string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	err = xray.Capture(ctx, "FetchUsersByLastName", func(ctx context.Context) error {
+		users, next, err = r.UserRepository.FetchUsersByLastName(ctx, lastName, limit, lastEvaluatedKey, opts)
+		return err
+	})
+	return users, next, err
+}
+
+// FetchUsersByEmailPrefix traces UserRepository.FetchUsersByEmailPrefix.
+func (r *TracedUserRepository) FetchUsersByEmailPrefix(ctx context.Context, prefix string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	err = xray.Capture(ctx, "FetchUsersByEmailPrefix", func(ctx context.Context) error {
+		users, next, err = r.UserRepository.FetchUsersByEmailPrefix(ctx, prefix, limit, lastEvaluatedKey, opts)
+		return err
+	})
+	return users, next, err
+}
+
+// SearchUsers traces UserRepository.SearchUsers.
+func (r *TracedUserRepository) SearchUsers(ctx context.Context, query string, limit int, lastEvaluatedKey string, opts repository.FetchOptions) (users []models.User, next string, err error) {
+	err = xray.Capture(ctx, "SearchUsers", func(ctx context.Context) error {
+		users, next, err = r.UserRepository.SearchUsers(ctx, query, limit, lastEvaluatedKey, opts)
+		return err
+	})
+	return users, next, err
+}
+
+// FetchUsersByEmails traces UserRepository.FetchUsersByEmails.
+func (r *TracedUserRepository) FetchUsersByEmails(ctx context.Context, emails []string, opts repository.FetchOptions) (result *repository.BatchFetchResult, err error) {
+	err = xray.Capture(ctx, "FetchUsersByEmails", func(ctx context.Context) error {
+		result, err = r.UserRepository.FetchUsersByEmails(ctx, emails, opts)
+		return err
+	})
+	return result, err
+}
+
+// ScanAll traces UserRepository.ScanAll. The subsegment spans the whole scan, including fn.
+func (r *TracedUserRepository) ScanAll(ctx context.Context, lastEvaluatedKey string, opts repository.FetchOptions, fn func(user models.User) error) error {
+	return xray.Capture(ctx, "ScanAll", func(ctx context.Context) error {
+		return r.UserRepository.ScanAll(ctx, lastEvaluatedKey, opts, fn)
+	})
+}
+
+// CountUsers traces UserRepository.CountUsers.
+func (r *TracedUserRepository) CountUsers(ctx context.Context, opts repository.FetchOptions) (total int64, err error) {
+	err = xray.Capture(ctx, "CountUsers", func(ctx context.Context) error {
+		total, err = r.UserRepository.CountUsers(ctx, opts)
+		return err
+	})
+	return total, err
+}
+
+// CreateUser traces UserRepository.CreateUser.
+func (r *TracedUserRepository) CreateUser(ctx context.Context, user models.User) (created *models.User, err error) {
+	err = xray.Capture(ctx, "CreateUser", func(ctx context.Context) error {
+		created, err = r.UserRepository.CreateUser(ctx, user)
+		return err
+	})
+	return created, err
+}
+
+// CreateUsers traces UserRepository.CreateUsers.
+func (r *TracedUserRepository) CreateUsers(ctx context.Context, users []models.User) (result *repository.BatchCreateResult, err error) {
+	err = xray.Capture(ctx, "CreateUsers", func(ctx context.Context) error {
+		result, err = r.UserRepository.CreateUsers(ctx, users)
+		return err
+	})
+	return result, err
+}
+
+// UpdateUser traces UserRepository.UpdateUser.
+func (r *TracedUserRepository) UpdateUser(ctx context.Context, user models.User) (updated *models.User, err error) {
+	err = xray.Capture(ctx, "UpdateUser", func(ctx context.Context) error {
+		updated, err = r.UserRepository.UpdateUser(ctx, user)
+		return err
+	})
+	return updated, err
+}
+
+// UpsertUser traces UserRepository.UpsertUser.
+func (r *TracedUserRepository) UpsertUser(ctx context.Context, user models.User) (upserted *models.User, created bool, err error) {
+	err = xray.Capture(ctx, "UpsertUser", func(ctx context.Context) error {
+		upserted, created, err = r.UserRepository.UpsertUser(ctx, user)
+		return err
+	})
+	return upserted, created, err
+}
+
+// DeleteUser traces UserRepository.DeleteUser.
+func (r *TracedUserRepository) DeleteUser(ctx context.Context, email string) (deleted *models.User, err error) {
+	err = xray.Capture(ctx, "DeleteUser", func(ctx context.Context) error {
+		deleted, err = r.UserRepository.DeleteUser(ctx, email)
+		return err
+	})
+	return deleted, err
+}
+
+// DeleteUsers traces UserRepository.DeleteUsers.
+func (r *TracedUserRepository) DeleteUsers(ctx context.Context, emails []string) (result *repository.BatchDeleteResult, err error) {
+	err = xray.Capture(ctx, "DeleteUsers", func(ctx context.Context) error {
+		result, err = r.UserRepository.DeleteUsers(ctx, emails)
+		return err
+	})
+	return result, err
+}
+
+// TransactWriteUsers traces UserRepository.TransactWriteUsers.
+func (r *TracedUserRepository) TransactWriteUsers(ctx context.Context, ops []repository.UserOperation) (err error) {
+	err = xray.Capture(ctx, "TransactWriteUsers", func(ctx context.Context) error {
+		err = r.UserRepository.TransactWriteUsers(ctx, ops)
+		return err
+	})
+	return err
+}
+
+// RestoreUser traces UserRepository.RestoreUser.
+func (r *TracedUserRepository) RestoreUser(ctx context.Context, email string) (restored *models.User, err error) {
+	err = xray.Capture(ctx, "RestoreUser", func(ctx context.Context) error {
+		restored, err = r.UserRepository.RestoreUser(ctx, email)
+		return err
+	})
+	return restored, err
+}
+
+// ChangeEmail traces UserRepository.ChangeEmail.
+func (r *TracedUserRepository) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (moved *models.User, err error) {
+	err = xray.Capture(ctx, "ChangeEmail", func(ctx context.Context) error {
+		moved, err = r.UserRepository.ChangeEmail(ctx, oldEmail, newEmail)
+		return err
+	})
+	return moved, err
+}
+
+// MergeUsers traces UserRepository.MergeUsers.
+func (r *TracedUserRepository) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string) (merged *models.User, err error) {
+	err = xray.Capture(ctx, "MergeUsers", func(ctx context.Context) error {
+		merged, err = r.UserRepository.MergeUsers(ctx, primaryEmail, duplicateEmail)
+		return err
+	})
+	return merged, err
+}
+
+// VerifyPassword traces UserRepository.VerifyPassword.
+func (r *TracedUserRepository) VerifyPassword(ctx context.Context, email, password string) error {
+	return xray.Capture(ctx, "VerifyPassword", func(ctx context.Context) error {
+		return r.UserRepository.VerifyPassword(ctx, email, password)
+	})
+}
+
+// DeleteAllUsers traces UserRepository.DeleteAllUsers.
+func (r *TracedUserRepository) DeleteAllUsers(ctx context.Context) (deleted int, err error) {
+	err = xray.Capture(ctx, "DeleteAllUsers", func(ctx context.Context) error {
+		deleted, err = r.UserRepository.DeleteAllUsers(ctx)
+		return err
+	})
+	return deleted, err
+}
+
+// MigrateUsers traces UserRepository.MigrateUsers.
+func (r *TracedUserRepository) MigrateUsers(ctx context.Context) (migrated int, err error) {
+	err = xray.Capture(ctx, "MigrateUsers", func(ctx context.Context) error {
+		migrated, err = r.UserRepository.MigrateUsers(ctx)
+		return err
+	})
+	return migrated, err
+}
+
+// UpdateUsersWhere traces UserRepository.UpdateUsersWhere.
+func (r *TracedUserRepository) UpdateUsersWhere(ctx context.Context, filter repository.UserFilter, patch repository.UserPatch, dryRun bool) (result repository.BulkUpdateResult, err error) {
+	err = xray.Capture(ctx, "UpdateUsersWhere", func(ctx context.Context) error {
+		result, err = r.UserRepository.UpdateUsersWhere(ctx, filter, patch, dryRun)
+		return err
+	})
+	return result, err
+}
+
+// PurgeDeletedUsers traces UserRepository.PurgeDeletedUsers.
+func (r *TracedUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (result repository.PurgeResult, err error) {
+	err = xray.Capture(ctx, "PurgeDeletedUsers", func(ctx context.Context) error {
+		result, err = r.UserRepository.PurgeDeletedUsers(ctx, deletedBefore)
+		return err
+	})
+	return result, err
+}
+
+// Ping traces the health check when the wrapped repository supports one.
+func (r *TracedUserRepository) Ping(ctx context.Context) error {
+	pinger, ok := r.UserRepository.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return xray.Capture(ctx, "Ping", pinger.Ping)
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+	"github.com/39sanskar/serverless-go/pkg/repository"
+	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+func TestTracedUserRepositoryPassesResultsThrough(t *testing.T) {
+	if err := xray.Configure(xray.Config{ContextMissingStrategy: ctxmissing.NewDefaultIgnoreErrorStrategy()}); err != nil {
+		t.Fatal(err)
+	}
+	base := repository.NewInMemoryUserRepository(repository.DynamoDBOptions{})
+	repo := NewTracedUserRepository(base)
+	ctx := context.Background() // No segment, as when tracing is disabled or running locally
+
+	created, err := repo.CreateUser(ctx, models.User{Email: "A@Example.com", FirstName: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "a@example.com" || created.Version != 1 {
+		t.Errorf("created = %+v", created)
+	}
+	if _, err := repo.CreateUser(ctx, models.User{Email: "a@example.com"}); !errors.Is(err, repository.ErrUserAlreadyExists) {
+		t.Errorf("err = %v, want %v", err, repository.ErrUserAlreadyExists)
+	}
+	fetched, err := repo.FetchUser(ctx, "a@example.com", repository.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched == nil || fetched.FirstName != "Ada" {
+		t.Errorf("fetched = %+v", fetched)
+	}
+	users, next, err := repo.FetchUsers(ctx, 10, "", repository.FetchOptions{})
+	if err != nil || len(users) != 1 || next != "" {
+		t.Errorf("FetchUsers = %v, %q, %v, want a@example.com", users, next, err)
+	}
+	if _, err := repo.DeleteUser(ctx, "nobody@example.com"); !errors.Is(err, repository.ErrUserDoesNotExist) {
+		t.Errorf("err = %v, want %v", err, repository.ErrUserDoesNotExist)
+	}
+}
@@ -0,0 +1,37 @@
+package validators
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// EmailValidation selects which addresses IsEmailValidFor accepts.
+type EmailValidation string
+
+const (
+	// EmailValidationPractical accepts the dot-atom addresses mailbox providers hand out, such as
+	// jane.doe+news@example.com, and rejects quoted local parts and domain literals. It is the default.
+	EmailValidationPractical EmailValidation = "practical"
+	// EmailValidationRFC accepts any RFC 5322 addr-spec, as parsed by net/mail: quoted local parts
+	// such as "jane doe"@example.com, domain literals such as jane@[192.0.2.1], and UTF-8 addresses.
+	// Display names, angle brackets and comments are still rejected, as they are not part of an email.
+	EmailValidationRFC EmailValidation = "rfc"
+)
+
+// IsEmailValidFor checks email under mode, falling back to EmailValidationPractical for an empty or
+// unknown mode. Either way the address must be 3 to 254 characters long.
+func IsEmailValidFor(email string, mode EmailValidation) bool {
+	if mode != EmailValidationRFC {
+		return IsEmailValid(email)
+	}
+	if len(email) < 3 || len(email) > 254 {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || strings.ContainsAny(email, "<>") {
+		return false
+	}
+	// ParseAddress skips trailing whitespace and comments, so the input must end with the parsed domain
+	domain := addr.Address[strings.LastIndexByte(addr.Address, '@'):]
+	return strings.HasSuffix(email, domain)
+}
@@ -0,0 +1,86 @@
+package validators
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsEmailValidFor(t *testing.T) {
+	tests := []struct {
+		email         string
+		wantPractical bool
+		wantRFC       bool
+	}{
+		// Everyday addresses pass either way
+		{email: "jane@example.com", wantPractical: true, wantRFC: true},
+		{email: "jane.doe@example.co.uk", wantPractical: true, wantRFC: true},
+		{email: "jane+news@example.com", wantPractical: true, wantRFC: true},
+		{email: "jane+@example.com", wantPractical: true, wantRFC: true},
+		{email: "o'brien@example.com", wantPractical: true, wantRFC: true},
+		{email: "x@y.z", wantPractical: true, wantRFC: true},
+		{email: "jane@localhost", wantPractical: true, wantRFC: true},
+		// Valid under RFC 5322, but not handed out by mailbox providers
+		{email: `"jane doe"@example.com`, wantRFC: true},
+		{email: `"jane@home"@example.com`, wantRFC: true},
+		{email: "jane@[192.0.2.1]", wantRFC: true},
+		{email: "jané@exämple.com", wantRFC: true},
+		{email: "jane@-example.com", wantRFC: true}, // A valid atom, though not a valid host name
+		// Dots the practical pattern lets through, though RFC 5322 forbids them unquoted
+		{email: ".jane@example.com", wantPractical: true},
+		{email: "jane.@example.com", wantPractical: true},
+		{email: "jane..doe@example.com", wantPractical: true},
+		// Invalid either way
+		{email: ""},
+		{email: "jane"},
+		{email: "jane@"},
+		{email: "@example.com"},
+		{email: "jane@@example.com"},
+		{email: "jane doe@example.com"},
+		{email: "jane@example..com"},
+		// Forms net/mail parses that are more than an address
+		{email: "Jane <jane@example.com>"},
+		{email: "<jane@example.com>"},
+		{email: "jane@example.com (Jane)"},
+		{email: "jane@example.com "},
+		// At most 254 characters
+		{email: "j@" + strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + "." + strings.Repeat("d", 60), wantPractical: true, wantRFC: true},
+		{email: "j@" + strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + "." + strings.Repeat("d", 61)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := IsEmailValidFor(tt.email, EmailValidationPractical); got != tt.wantPractical {
+				t.Errorf("practical = %v, want %v", got, tt.wantPractical)
+			}
+			if got := IsEmailValidFor(tt.email, EmailValidationRFC); got != tt.wantRFC {
+				t.Errorf("rfc = %v, want %v", got, tt.wantRFC)
+			}
+			// An empty or unknown mode falls back to the practical one
+			if got := IsEmailValidFor(tt.email, ""); got != tt.wantPractical {
+				t.Errorf("default = %v, want %v", got, tt.wantPractical)
+			}
+		})
+	}
+}
+
+func TestValidateUserEmailValidation(t *testing.T) {
+	tests := []struct {
+		mode       EmailValidation
+		wantFields []string
+	}{
+		{mode: "", wantFields: []string{"email"}},
+		{mode: EmailValidationPractical, wantFields: []string{"email"}},
+		{mode: EmailValidationRFC},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			user := validUser()
+			user.Email = `"jane doe"@example.com`
+
+			_, err := ValidateUser(user, ValidationOptions{EmailValidation: tt.mode})
+			if got := failedFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("failed fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package validators
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+)
+
+// NameSanitization selects how HTML markup in first and last names is handled.
+// This is defense-in-depth against stored XSS only: clients must still encode names for the
+// context they render them in.
+type NameSanitization string
+
+const (
+	// NameSanitizationReject fails validation for names containing markup. It is the default.
+	NameSanitizationReject NameSanitization = "reject"
+	// NameSanitizationStrip removes markup and control characters from names before validation.
+	NameSanitizationStrip NameSanitization = "strip"
+)
+
+// Regex for the start of an HTML tag, comment or processing instruction, e.g. <b, </b, <!-- or <?.
+// A bare "<" such as in "<3" is not markup.
+var rxMarkupStart = regexp.MustCompile(`<[a-zA-Z/!?]`)
+
+// Regex for a whole HTML tag, including one left unclosed at the end of the input.
+var rxMarkup = regexp.MustCompile(`<[a-zA-Z/!?][^>]*(>|$)`)
+
+// Regex for script and style elements, whose content is code rather than text.
+var rxScriptElement = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+
+// containsMarkup reports whether s contains something a browser would parse as HTML markup.
+func containsMarkup(s string) bool {
+	return rxMarkupStart.MatchString(s)
+}
+
+// StripMarkup removes HTML tags and control characters from s and trims the surrounding whitespace.
+// Text between tags is kept, so "<b>Ada</b>" becomes "Ada", but script and style elements are
+// dropped along with their content.
+func StripMarkup(s string) string {
+	s = rxScriptElement.ReplaceAllString(s, "")
+	s = rxMarkup.ReplaceAllString(s, "")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// SanitizeNames applies mode to the user's first and last names ahead of ValidateUser.
+// In strip mode the names are cleaned in place; in reject mode they are left for ValidateUser to reject.
+func SanitizeNames(user *models.User, mode NameSanitization) {
+	if mode != NameSanitizationStrip {
+		return
+	}
+	user.FirstName = StripMarkup(user.FirstName)
+	user.LastName = StripMarkup(user.LastName)
+}
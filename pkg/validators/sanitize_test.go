@@ -0,0 +1,64 @@
+package validators
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStripMarkup(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Ada", "Ada"},
+		{"<b>Ada</b>", "Ada"},
+		{`<img src=x onerror="alert(1)">Ada`, "Ada"},
+		{"<script>alert(1)</script>Ada", "Ada"},
+		{"<SCRIPT type=text/javascript>\nalert(1)\n</script >Ada", "Ada"},
+		{"<style>b{}</style>Ada", "Ada"},
+		{"Ada<!-- note -->", "Ada"},
+		{"Ada <b", "Ada"},
+		{"Ada\x00\x1b[31m", "Ada[31m"},
+		{" <i>Ada</i> ", "Ada"},
+		{"Ada <3", "Ada <3"},
+		{"a < b > c", "a < b > c"},
+	}
+	for _, tt := range tests {
+		if got := StripMarkup(tt.in); got != tt.want {
+			t.Errorf("StripMarkup(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          NameSanitization
+		firstName     string
+		wantFirstName string
+		wantFields    []string
+	}{
+		{name: "reject, plain name", mode: NameSanitizationReject, firstName: "Ada", wantFirstName: "Ada"},
+		{name: "reject, tags", mode: NameSanitizationReject, firstName: "<b>Ada</b>", wantFirstName: "<b>Ada</b>", wantFields: []string{"firstName"}},
+		{name: "reject, script", mode: NameSanitizationReject, firstName: "<script>alert(1)</script>", wantFirstName: "<script>alert(1)</script>", wantFields: []string{"firstName"}},
+		{name: "default mode rejects", firstName: "<i>Ada</i>", wantFirstName: "<i>Ada</i>", wantFields: []string{"firstName"}},
+		{name: "strip, tags", mode: NameSanitizationStrip, firstName: "<b>Ada</b>", wantFirstName: "Ada"},
+		{name: "strip, control characters", mode: NameSanitizationStrip, firstName: "A\x00da\n", wantFirstName: "Ada"},
+		{name: "strip, nothing left", mode: NameSanitizationStrip, firstName: "<script>alert(1)</script>", wantFields: []string{"firstName"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.FirstName = tt.firstName
+
+			SanitizeNames(&user, tt.mode)
+			if user.FirstName != tt.wantFirstName {
+				t.Errorf("first name = %q, want %q", user.FirstName, tt.wantFirstName)
+			}
+			_, err := ValidateUser(user, ValidationOptions{})
+			if got := failedFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("failed fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
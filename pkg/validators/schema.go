@@ -0,0 +1,50 @@
+package validators
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// userSchemaJSON is the JSON Schema of the user request body, shared with the API documentation.
+//
+//go:embed schemas/user.schema.json
+var userSchemaJSON string
+
+// userSchema is compiled once at startup; an invalid embedded schema is a programming error.
+var userSchema = jsonschema.MustCompileString("user.schema.json", userSchemaJSON)
+
+// ValidateUserJSON checks a raw user request body against the embedded user JSON Schema, before
+// it is decoded into a models.User. Every schema violation is reported as a FieldError whose field
+// is the JSON path of the offending value (empty for the body itself, e.g. missing properties).
+func ValidateUserJSON(body []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+
+	err := userSchema.Validate(doc)
+	var schemaErr *jsonschema.ValidationError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	var errs ValidationErrors
+	var collect func(*jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			errs = append(errs, FieldError{
+				Field:   strings.ReplaceAll(strings.TrimPrefix(e.InstanceLocation, "/"), "/", "."),
+				Message: e.Message,
+			})
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(schemaErr)
+	return errs
+}
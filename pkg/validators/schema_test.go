@@ -0,0 +1,55 @@
+package validators
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateUserJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantFields  []string
+		wantMessage string // Part of the message of the first error
+	}{
+		{name: "valid", body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee"}`},
+		{
+			name: "valid with optional and server-managed fields",
+			body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","phone":"+14155552671","role":"editor","metadata":{"team":"ops"},"version":3,"createdAt":"2024-01-01T00:00:00Z"}`,
+		},
+		{name: "required field missing", body: `{"email":"a@example.com","firstName":"Ann"}`, wantFields: []string{""}, wantMessage: "lastName"},
+		{name: "unknown field", body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","nickname":"Annie"}`, wantFields: []string{""}, wantMessage: "nickname"},
+		{name: "wrong type", body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","ttlSeconds":"60"}`, wantFields: []string{"ttlSeconds"}},
+		{name: "enum", body: `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","role":"owner"}`, wantFields: []string{"role"}},
+		{
+			name:       "nested value",
+			body:       `{"email":"a@example.com","firstName":"Ann","lastName":"Lee","metadata":{"team":"` + strings.Repeat("x", 257) + `"}}`,
+			wantFields: []string{"metadata.team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUserJSON([]byte(tt.body))
+			if got := failedFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Fatalf("fields = %q, want %q (err %v)", got, tt.wantFields, err)
+			}
+			if tt.wantMessage != "" {
+				var errs ValidationErrors
+				errors.As(err, &errs)
+				if !strings.Contains(errs[0].Message, tt.wantMessage) {
+					t.Errorf("message = %q, want one about %s", errs[0].Message, tt.wantMessage)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUserJSONRejectsMalformedJSON(t *testing.T) {
+	err := ValidateUserJSON([]byte(`{"email":`))
+	var errs ValidationErrors
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("err = %v, want a JSON syntax error", err)
+	}
+}
@@ -2,7 +2,16 @@ package validators
 
 import (
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Lambda runtimes may lack the system zoneinfo database that time.LoadLocation reads
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/39sanskar/serverless-go/pkg/models"
 )
@@ -10,7 +19,45 @@ import (
 // Regex for email validation (a commonly used robust pattern)
 var rxEmail = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
-// IsEmailValid checks if the provided email string is a valid email address.
+// Regex for E.164 phone numbers: a leading +, a non-zero country code digit, and at most 15 digits in total
+var rxPhone = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Regex for BCP 47 language tags: a language, then optional script, region and variant subtags (e.g. en-US, zh-Hant-TW)
+var rxLocale = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z]{4})?(-(?:[a-zA-Z]{2}|[0-9]{3}))?(-(?:[a-zA-Z0-9]{5,8}|[0-9][a-zA-Z0-9]{3}))*$`)
+
+// maxAvatarURLLength caps avatar URLs at a length browsers and CDNs reliably accept.
+const maxAvatarURLLength = 2048
+
+// minPasswordLength is the minimum number of characters in a password.
+const minPasswordLength = 8
+
+// maxPasswordBytes is the longest password bcrypt can hash; longer inputs are rejected by it.
+const maxPasswordBytes = 72
+
+// maxNameLength is the maximum number of characters (runes) allowed in a first or last name.
+const maxNameLength = 100
+
+// maxTTLSeconds is the longest lifetime of a temporary user, one year.
+const maxTTLSeconds = 365 * 24 * 60 * 60
+
+// Limits on user metadata, keeping items well below DynamoDB's 400 KB item size.
+const (
+	maxMetadataEntries     = 20
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 256
+)
+
+// NormalizeEmail trims surrounding whitespace and lowercases the whole address.
+// RFC 5321 technically allows a case-sensitive local part, but in practice mailbox
+// providers treat addresses case-insensitively and users expect "User@Example.com"
+// and "user@example.com" to be the same account. Emails are therefore normalized
+// deliberately before being used as the storage key.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsEmailValid checks if the provided email string is a valid email address, as EmailValidationPractical
+// defines it.
 func IsEmailValid(email string) bool {
 	if len(email) < 3 || len(email) > 254 || !rxEmail.MatchString(email) {
 		return false
@@ -18,20 +65,199 @@ func IsEmailValid(email string) bool {
 	return true
 }
 
+// IsPhoneValid checks if the provided phone number is in E.164 format (e.g. +14155552671).
+func IsPhoneValid(phone string) bool {
+	return rxPhone.MatchString(phone)
+}
+
+// IsLocaleValid checks if the provided locale is a well-formed BCP 47 language tag such as en-US.
+// Private-use and extension subtags are not accepted.
+func IsLocaleValid(locale string) bool {
+	return rxLocale.MatchString(locale)
+}
+
+// IsTimezoneValid checks if the provided timezone is an IANA time zone name such as America/New_York.
+// "Local" is rejected, since it names the server's zone rather than the user's.
+func IsTimezoneValid(timezone string) bool {
+	if timezone == "" || timezone == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(timezone)
+	return err == nil
+}
+
+// IsAvatarURLValid checks that avatarURL is an absolute http or https URL with a host.
+// Other schemes such as javascript: or data: are rejected, since the URL is rendered by clients.
+func IsAvatarURLValid(avatarURL string) bool {
+	if len(avatarURL) > maxAvatarURLLength {
+		return false
+	}
+	parsed, err := url.Parse(avatarURL)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return false
+	}
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+// validatePassword checks that a password is at least minPasswordLength characters long,
+// fits within bcrypt's 72-byte limit and mixes letters with digits or symbols.
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("password too short; minimum is %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password too long; maximum is %d bytes", maxPasswordBytes)
+	}
+	hasLetter := strings.IndexFunc(password, unicode.IsLetter) >= 0
+	hasOther := strings.IndexFunc(password, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0
+	if !hasLetter || !hasOther {
+		return errors.New("password must contain letters and at least one digit or symbol")
+	}
+	return nil
+}
+
+// validateName checks that a name is present, at most maxNameLength characters,
+// free of control characters and HTML markup, and not padded with whitespace.
+// Length is measured in runes so multibyte names are not penalized.
+func validateName(label, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%s is required", label)
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("%s is not valid UTF-8", label)
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("%s must not have leading or trailing whitespace", label)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%s too long; maximum is %d characters", label, maxNameLength)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%s must not contain control characters", label)
+	}
+	if containsMarkup(name) {
+		return fmt.Errorf("%s must not contain HTML markup", label)
+	}
+	return nil
+}
+
+// validateMetadata checks that metadata has at most maxMetadataEntries entries, with non-empty keys of
+// at most maxMetadataKeyLength characters and values of at most maxMetadataValueLength characters.
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataEntries {
+		return fmt.Errorf("metadata has too many entries; maximum is %d", maxMetadataEntries)
+	}
+	keys := slices.Sorted(maps.Keys(metadata)) // Report the same key on every call
+	for _, key := range keys {
+		if key == "" {
+			return errors.New("metadata keys must not be empty")
+		}
+		if utf8.RuneCountInString(key) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata key %q too long; maximum is %d characters", key, maxMetadataKeyLength)
+		}
+		if utf8.RuneCountInString(metadata[key]) > maxMetadataValueLength {
+			return fmt.Errorf("metadata value of %q too long; maximum is %d characters", key, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// IsRoleValid checks if the provided role is one of models.Roles.
+func IsRoleValid(role models.Role) bool {
+	return slices.Contains(models.Roles, role)
+}
+
+// FieldError describes why a single field of a request failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every field that failed validation, so a client can
+// report all problems at once instead of fixing them one request at a time.
+type ValidationErrors []FieldError
+
+// Error joins the failures into a single "field: message" list.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fieldErr := range v {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Prefixed returns a copy of v with prefix prepended to each field name,
+// e.g. "[2]." to locate the failures of the third element of an array body.
+func (v ValidationErrors) Prefixed(prefix string) ValidationErrors {
+	prefixed := make(ValidationErrors, len(v))
+	for i, fieldErr := range v {
+		prefixed[i] = FieldError{Field: prefix + fieldErr.Field, Message: fieldErr.Message}
+	}
+	return prefixed
+}
+
 // ValidateUser performs comprehensive validation for a User struct.
-func ValidateUser(user models.User) error {
+// Every field is checked; the returned error is a ValidationErrors listing all failures.
+// A valid user may still draw ValidationWarnings from the non-fatal checks configured by opts.
+// Field names match the JSON representation of the user.
+func ValidateUser(user models.User, opts ValidationOptions) (ValidationWarnings, error) {
+	var errs ValidationErrors
 	if user.Email == "" {
-		return errors.New("email is required")
+		errs = append(errs, FieldError{Field: "email", Message: "email is required"})
+	} else if !IsEmailValidFor(user.Email, opts.EmailValidation) {
+		errs = append(errs, FieldError{Field: "email", Message: "invalid email format"})
+	}
+	if err := validateName("first name", user.FirstName); err != nil {
+		errs = append(errs, FieldError{Field: "firstName", Message: err.Error()})
+	}
+	if err := validateName("last name", user.LastName); err != nil {
+		errs = append(errs, FieldError{Field: "lastName", Message: err.Error()})
+	}
+	// Phone is optional, so only validate it when provided
+	if user.Phone != "" && !IsPhoneValid(user.Phone) {
+		errs = append(errs, FieldError{Field: "phone", Message: "invalid phone format; expected E.164 such as +14155552671"})
+	}
+	// Avatar URL is optional, so only validate it when provided
+	if user.AvatarURL != "" && !IsAvatarURLValid(user.AvatarURL) {
+		errs = append(errs, FieldError{Field: "avatarUrl", Message: "invalid avatar URL; expected an absolute http or https URL"})
+	}
+	// Locale and timezone are optional, so only validate them when provided
+	if user.Locale != "" && !IsLocaleValid(user.Locale) {
+		errs = append(errs, FieldError{Field: "locale", Message: "invalid locale; expected a BCP 47 language tag such as en-US"})
 	}
-	if !IsEmailValid(user.Email) {
-		return errors.New("invalid email format")
+	if user.Timezone != "" && !IsTimezoneValid(user.Timezone) {
+		errs = append(errs, FieldError{Field: "timezone", Message: "invalid timezone; expected an IANA time zone such as America/New_York"})
 	}
-	if user.FirstName == "" {
-		return errors.New("first name is required")
+	// Metadata is optional, but bounded so it cannot bloat the item
+	if err := validateMetadata(user.Metadata); err != nil {
+		errs = append(errs, FieldError{Field: "metadata", Message: err.Error()})
 	}
-	if user.LastName == "" {
-		return errors.New("last name is required")
+	// Password is optional (it is only needed for login-capable users), but must be strong when set
+	if user.Password != "" {
+		if err := validatePassword(user.Password); err != nil {
+			errs = append(errs, FieldError{Field: "password", Message: err.Error()})
+		}
+	}
+	// TTL is optional (users are permanent by default), but bounded so temporary users do expire
+	if user.TTLSeconds < 0 || user.TTLSeconds > maxTTLSeconds {
+		errs = append(errs, FieldError{Field: "ttlSeconds", Message: fmt.Sprintf("ttlSeconds must be between 1 and %d", maxTTLSeconds)})
+	}
+	// Role is optional (new users default to viewer), but must be a known role when set
+	if user.Role != "" && !IsRoleValid(user.Role) {
+		errs = append(errs, FieldError{Field: "role", Message: fmt.Sprintf("invalid role %q; must be one of %s", user.Role, roleList())})
 	}
 	// Add more validation rules as needed (e.g., length, alphanumeric, etc.)
-	return nil
-}
\ No newline at end of file
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return userWarnings(user, opts), nil
+}
+
+// roleList formats the allowed roles for error messages.
+func roleList() string {
+	names := make([]string, len(models.Roles))
+	for i, role := range models.Roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
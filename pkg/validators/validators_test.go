@@ -0,0 +1,389 @@
+package validators
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+)
+
+// validUser returns a user that passes ValidateUser.
+func validUser() models.User {
+	return models.User{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}
+}
+
+// failedFields returns the fields ValidateUser reported in err, or fails the test if err is not a
+// ValidationErrors.
+func failedFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	fields := make([]string, len(errs))
+	for i, fieldErr := range errs {
+		fields[i] = fieldErr.Field
+	}
+	return fields
+}
+
+func TestIsPhoneValid(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  bool
+	}{
+		{"+14155552671", true},     // United States
+		{"+442071838750", true},    // United Kingdom
+		{"+919876543210", true},    // India
+		{"+8613800138000", true},   // China
+		{"+61", true},              // Shortest: a country code and one digit
+		{"+123456789012345", true}, // Longest: 15 digits
+		{"", false},
+		{"14155552671", false},       // No leading +
+		{"+04155552671", false},      // Country codes do not start with 0
+		{"+1234567890123456", false}, // 16 digits
+		{"+1", false},
+		{"+1 415 555 2671", false},
+		{"+1-415-555-2671", false},
+		{"+1(415)5552671", false},
+		{"++14155552671", false},
+		{"+1415555267a", false},
+		{" +14155552671", false},
+		{"+14155552671\n", false},
+	}
+	for _, tt := range tests {
+		if got := IsPhoneValid(tt.phone); got != tt.want {
+			t.Errorf("IsPhoneValid(%q) = %v, want %v", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUserPhone(t *testing.T) {
+	tests := []struct {
+		name    string
+		phone   string
+		wantErr bool
+	}{
+		{name: "no phone"},
+		{name: "valid phone", phone: "+442071838750"},
+		{name: "invalid phone", phone: "020 7183 8750", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Phone = tt.phone
+			_, err := ValidateUser(user, ValidationOptions{})
+			fields := failedFields(t, err)
+			if gotErr := len(fields) == 1 && fields[0] == "phone"; gotErr != tt.wantErr || (!tt.wantErr && err != nil) {
+				t.Errorf("err = %v, want a phone error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "ascii", value: "Jane"},
+		{name: "accents", value: "Zoë-Renée"},
+		{name: "cjk", value: "山田太郎"},
+		{name: "inner spaces", value: "Mary Ann"},
+		{name: "longest multibyte name", value: strings.Repeat("é", maxNameLength)},
+		{name: "empty", value: "", wantErr: "first name is required"},
+		{name: "whitespace only", value: "   ", wantErr: "first name is required"},
+		{name: "leading whitespace", value: " Jane", wantErr: "first name must not have leading or trailing whitespace"},
+		{name: "trailing whitespace", value: "Jane\t", wantErr: "first name must not have leading or trailing whitespace"},
+		{name: "too long", value: strings.Repeat("a", maxNameLength+1), wantErr: "first name too long; maximum is 100 characters"},
+		{name: "too long multibyte name", value: strings.Repeat("é", maxNameLength+1), wantErr: "first name too long; maximum is 100 characters"},
+		{name: "control character", value: "Ja\x00ne", wantErr: "first name must not contain control characters"},
+		{name: "invalid utf-8", value: "Ja\xffne", wantErr: "first name is not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateName("first name", tt.value)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("err = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUserNames(t *testing.T) {
+	user := validUser()
+	user.FirstName = strings.Repeat("x", maxNameLength+1)
+	user.LastName = " "
+	_, err := ValidateUser(user, ValidationOptions{})
+	if fields := failedFields(t, err); !slices.Equal(fields, []string{"firstName", "lastName"}) {
+		t.Errorf("failed fields = %v, want [firstName lastName]", fields)
+	}
+}
+
+func TestValidateUserRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    models.Role
+		wantErr string
+	}{
+		{name: "admin", role: models.RoleAdmin},
+		{name: "editor", role: models.RoleEditor},
+		{name: "viewer", role: models.RoleViewer},
+		{name: "empty", role: ""},
+		{name: "unknown", role: "owner", wantErr: `invalid role "owner"; must be one of admin, editor, viewer`},
+		{name: "wrong case", role: "Admin", wantErr: `invalid role "Admin"; must be one of admin, editor, viewer`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Role = tt.role
+			_, err := ValidateUser(user, ValidationOptions{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+				return
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != (FieldError{Field: "role", Message: tt.wantErr}) {
+				t.Errorf("err = %v, want role: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUserPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{name: "none", password: ""},
+		{name: "letters and digits", password: "secret123"},
+		{name: "letters and symbols", password: "correct-horse"},
+		{name: "multibyte", password: "pässwört1"},
+		{name: "too short", password: "abc123", wantErr: "password too short; minimum is 8 characters"},
+		{name: "too long", password: strings.Repeat("a1", 37), wantErr: "password too long; maximum is 72 bytes"},
+		{name: "letters only", password: "password", wantErr: "password must contain letters and at least one digit or symbol"},
+		{name: "digits only", password: "12345678", wantErr: "password must contain letters and at least one digit or symbol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Password = tt.password
+			_, err := ValidateUser(user, ValidationOptions{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+				return
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != (FieldError{Field: "password", Message: tt.wantErr}) {
+				t.Errorf("err = %v, want password: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUserReportsEveryField(t *testing.T) {
+	user := models.User{
+		Email:     "not-an-email",
+		FirstName: "",
+		LastName:  strings.Repeat("x", maxNameLength+1),
+		Phone:     "12345",
+		Role:      "owner",
+	}
+	_, err := ValidateUser(user, ValidationOptions{})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	want := ValidationErrors{
+		{Field: "email", Message: "invalid email format"},
+		{Field: "firstName", Message: "first name is required"},
+		{Field: "lastName", Message: "last name too long; maximum is 100 characters"},
+		{Field: "phone", Message: "invalid phone format; expected E.164 such as +14155552671"},
+		{Field: "role", Message: `invalid role "owner"; must be one of admin, editor, viewer`},
+	}
+	if !slices.Equal(errs, want) {
+		t.Errorf("errs = %v, want %v", errs, want)
+	}
+	if got := errs.Error(); !strings.HasPrefix(got, "email: invalid email format; firstName: first name is required; ") {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestIsAvatarURLValid(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://cdn.example.com/avatars/jane.png", true},
+		{"http://example.com/a.jpg?size=64", true},
+		{"HTTPS://EXAMPLE.COM/A.PNG", true},
+		{"/avatars/jane.png", false},       // Relative
+		{"avatars/jane.png", false},        // Relative
+		{"//cdn.example.com/a.png", false}, // Scheme-relative
+		{"https://", false},                // No host
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{"data:image/png;base64,iVBORw0KGgo=", false},
+		{"ftp://example.com/a.png", false},
+		{"file:///etc/passwd", false},
+		{"https://example.com/" + strings.Repeat("a", maxAvatarURLLength), false},
+	}
+	for _, tt := range tests {
+		if got := IsAvatarURLValid(tt.url); got != tt.want {
+			t.Errorf("IsAvatarURLValid(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUserAvatarURL(t *testing.T) {
+	tests := []struct {
+		avatarURL string
+		wantErr   bool
+	}{
+		{"", false},
+		{"https://cdn.example.com/jane.png", false},
+		{"javascript:alert(1)", true},
+	}
+	for _, tt := range tests {
+		user := validUser()
+		user.AvatarURL = tt.avatarURL
+		_, err := ValidateUser(user, ValidationOptions{})
+		if gotErr := slices.Equal(failedFields(t, err), []string{"avatarUrl"}); gotErr != tt.wantErr || (!tt.wantErr && err != nil) {
+			t.Errorf("AvatarURL %q: err = %v, want an avatarUrl error: %v", tt.avatarURL, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateUserMetadata(t *testing.T) {
+	// entries returns n metadata entries with short keys and values
+	entries := func(n int) map[string]string {
+		metadata := make(map[string]string, n)
+		for i := range n {
+			metadata["key"+strconv.Itoa(i)] = "value"
+		}
+		return metadata
+	}
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  string
+	}{
+		{name: "none"},
+		{name: "maximum entries", metadata: entries(maxMetadataEntries)},
+		{name: "longest key and value", metadata: map[string]string{strings.Repeat("k", maxMetadataKeyLength): strings.Repeat("v", maxMetadataValueLength)}},
+		{name: "multibyte characters count once", metadata: map[string]string{strings.Repeat("ü", maxMetadataKeyLength): strings.Repeat("é", maxMetadataValueLength)}},
+		{name: "empty value", metadata: map[string]string{"plan": ""}},
+		{name: "too many entries", metadata: entries(maxMetadataEntries + 1), wantErr: "metadata has too many entries; maximum is 20"},
+		{name: "empty key", metadata: map[string]string{"": "x"}, wantErr: "metadata keys must not be empty"},
+		{name: "key too long", metadata: map[string]string{strings.Repeat("k", maxMetadataKeyLength+1): "x"}, wantErr: `metadata key "` + strings.Repeat("k", maxMetadataKeyLength+1) + `" too long; maximum is 64 characters`},
+		{name: "value too long", metadata: map[string]string{"plan": strings.Repeat("v", maxMetadataValueLength+1)}, wantErr: `metadata value of "plan" too long; maximum is 256 characters`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Metadata = tt.metadata
+			_, err := ValidateUser(user, ValidationOptions{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+				return
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != (FieldError{Field: "metadata", Message: tt.wantErr}) {
+				t.Errorf("err = %v, want metadata: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsLocaleValid(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   bool
+	}{
+		{"en", true},
+		{"en-US", true},
+		{"es-419", true},         // Region as a UN M.49 code
+		{"zh-Hant-TW", true},     // With a script
+		{"sl-rozaj-biske", true}, // With variants
+		{"fil", true},
+		{"", false},
+		{"e", false},
+		{"english", false},
+		{"en_US", false},
+		{"en-", false},
+		{"en-USA", false},
+		{"x-private", false},
+		{"en-US-u-ca-gregory", false}, // Extensions are not accepted
+		{"en-US ", false},
+	}
+	for _, tt := range tests {
+		if got := IsLocaleValid(tt.locale); got != tt.want {
+			t.Errorf("IsLocaleValid(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestIsTimezoneValid(t *testing.T) {
+	tests := []struct {
+		timezone string
+		want     bool
+	}{
+		{"America/New_York", true},
+		{"Europe/London", true},
+		{"Asia/Kolkata", true},
+		{"UTC", true},
+		{"", false},
+		{"Local", false},
+		{"America/Atlantis", false},
+		{"EST5EDT4", false},
+		{"../etc/passwd", false},
+	}
+	for _, tt := range tests {
+		if got := IsTimezoneValid(tt.timezone); got != tt.want {
+			t.Errorf("IsTimezoneValid(%q) = %v, want %v", tt.timezone, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUserLocaleAndTimezone(t *testing.T) {
+	tests := []struct {
+		name       string
+		locale     string
+		timezone   string
+		wantFields []string
+	}{
+		{name: "neither"},
+		{name: "both valid", locale: "en-US", timezone: "America/New_York"},
+		{name: "invalid locale", locale: "en_US", timezone: "America/New_York", wantFields: []string{"locale"}},
+		{name: "invalid timezone", locale: "en-US", timezone: "Mars/Olympus_Mons", wantFields: []string{"timezone"}},
+		{name: "both invalid", locale: "english", timezone: "Local", wantFields: []string{"locale", "timezone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Locale, user.Timezone = tt.locale, tt.timezone
+			_, err := ValidateUser(user, ValidationOptions{})
+			if got := failedFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("failed fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
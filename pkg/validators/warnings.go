@@ -0,0 +1,52 @@
+package validators
+
+import (
+	"strings"
+
+	"github.com/39sanskar/serverless-go/pkg/models"
+)
+
+// ValidationOptions configures the configurable checks of ValidateUser.
+type ValidationOptions struct {
+	// EmailValidation selects the addresses accepted as emails. Empty means EmailValidationPractical.
+	EmailValidation EmailValidation
+	// DisposableEmailDomains are the domains of throwaway mailbox providers. Emails at one of them,
+	// or at a subdomain of one, are accepted with a warning. Empty disables the check.
+	DisposableEmailDomains []string
+}
+
+// ValidationWarnings lists the fields that passed validation but look doubtful, such as an email at
+// a disposable mailbox provider. Unlike ValidationErrors they never block a write; they are
+// returned alongside the stored user so the client may ask the user to double-check.
+type ValidationWarnings []FieldError
+
+// Prefixed returns a copy of w with prefix prepended to each field name, like ValidationErrors.Prefixed.
+func (w ValidationWarnings) Prefixed(prefix string) ValidationWarnings {
+	return ValidationWarnings(ValidationErrors(w).Prefixed(prefix))
+}
+
+// userWarnings runs the non-fatal checks of opts against a user that passed validation.
+func userWarnings(user models.User, opts ValidationOptions) ValidationWarnings {
+	var warnings ValidationWarnings
+	if domain, ok := disposableDomain(user.Email, opts.DisposableEmailDomains); ok {
+		warnings = append(warnings, FieldError{Field: "email", Message: "email domain " + domain + " is a disposable mailbox provider"})
+	}
+	return warnings
+}
+
+// disposableDomain returns the domain of email when it is one of domains or a subdomain of one.
+// Domains are compared case-insensitively.
+func disposableDomain(email string, domains []string) (string, bool) {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return "", false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, disposable := range domains {
+		disposable = strings.ToLower(strings.TrimPrefix(disposable, "@"))
+		if domain == disposable || strings.HasSuffix(domain, "."+disposable) {
+			return domain, true
+		}
+	}
+	return "", false
+}
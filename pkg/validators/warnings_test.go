@@ -0,0 +1,68 @@
+package validators
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateUserWarnings(t *testing.T) {
+	disposable := []string{"mailinator.com", "@Guerrillamail.com"}
+	tests := []struct {
+		name         string
+		email        string
+		domains      []string
+		wantWarnings []string
+	}{
+		{name: "no list", email: "jane@mailinator.com"},
+		{name: "regular domain", email: "jane@example.com", domains: disposable},
+		{name: "disposable domain", email: "jane@mailinator.com", domains: disposable, wantWarnings: []string{"email"}},
+		{name: "subdomain", email: "jane@eu.mailinator.com", domains: disposable, wantWarnings: []string{"email"}},
+		{name: "other case", email: "jane@MAILINATOR.com", domains: disposable, wantWarnings: []string{"email"}},
+		{name: "listed with an @", email: "jane@guerrillamail.com", domains: disposable, wantWarnings: []string{"email"}},
+		{name: "lookalike domain", email: "jane@notmailinator.com", domains: disposable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := validUser()
+			user.Email = tt.email
+
+			warnings, err := ValidateUser(user, ValidationOptions{DisposableEmailDomains: tt.domains})
+			if err != nil {
+				t.Fatalf("err = %v, want the user accepted", err)
+			}
+			var fields []string
+			for _, warning := range warnings {
+				fields = append(fields, warning.Field)
+			}
+			if !slices.Equal(fields, tt.wantWarnings) {
+				t.Errorf("warnings = %v, want fields %v", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestValidateUserWarnsOnlyValidUsers(t *testing.T) {
+	user := validUser()
+	user.Email = "jane@mailinator.com"
+	user.FirstName = ""
+
+	warnings, err := ValidateUser(user, ValidationOptions{DisposableEmailDomains: []string{"mailinator.com"}})
+	if got := failedFields(t, err); !slices.Equal(got, []string{"firstName"}) {
+		t.Errorf("failed fields = %v, want [firstName]", got)
+	}
+	if warnings != nil {
+		t.Errorf("warnings = %v, want none alongside errors", warnings)
+	}
+}
+
+func TestValidationWarningsPrefixed(t *testing.T) {
+	warnings := ValidationWarnings{{Field: "email", Message: "doubtful"}}
+
+	got := warnings.Prefixed("[1].")
+	if len(got) != 1 || got[0].Field != "[1].email" || got[0].Message != "doubtful" {
+		t.Errorf("prefixed = %v", got)
+	}
+	if warnings[0].Field != "email" {
+		t.Errorf("original changed to %v", warnings)
+	}
+}